@@ -15,10 +15,12 @@ package main
 
 import (
 	"flag"
+	"time"
 
 	"github.com/perses/common/app"
 	"github.com/perses/perses/internal/api/core"
 	"github.com/perses/perses/internal/api/shared/dependency"
+	"github.com/perses/perses/internal/api/shared/metrics"
 	"github.com/perses/perses/internal/config"
 	"github.com/sirupsen/logrus"
 )
@@ -38,6 +40,9 @@ The secure way to configure your monitoring.               <\
                                                            </
 `
 
+// resourceCountInterval is the frequency at which the resources stored are counted to be exposed on /metrics.
+const resourceCountInterval = 30 * time.Second
+
 func main() {
 	configFile := flag.String("config", "", "Path to the yaml configuration file for the api. Configuration can be overridden when using the environment variable")
 	flag.Parse()
@@ -46,15 +51,25 @@ func main() {
 	if err != nil {
 		logrus.WithError(err).Fatalf("error when reading configuration or from file '%s' or from environment", *configFile)
 	}
-	persistenceManager, err := dependency.NewPersistenceManager(*conf.Etcd)
+	persistenceManager, err := dependency.NewPersistenceManager(conf.Database)
 	if err != nil {
 		logrus.WithError(err).Fatal("unable to instantiate the persistent manager")
 	}
-	serviceManager := dependency.NewServiceManager(persistenceManager)
+	serviceManager := dependency.NewServiceManager(persistenceManager, conf)
 	persesAPI := core.NewPersesAPI(serviceManager)
 	runner := app.NewRunner().WithDefaultHTTPServer("perses").SetBanner(banner)
-	// register the API
-	runner.HTTPServerBuilder().APIRegistration(persesAPI)
+	// count periodically the resources stored
+	runner.WithCronTasks(resourceCountInterval, metrics.NewResourceCounter(persistenceManager.GetDatabase()))
+	// keep the index of the metrics used by the dashboards and the PrometheusRules up to date
+	runner.WithTasks(serviceManager.GetMetricDependency())
+	if conf.RuleFileSync != nil {
+		// write the PrometheusRules on disk
+		runner.WithTasks(serviceManager.GetRuleFileSync())
+	}
+	// register the API and the metrics about the requests it receives
+	runner.HTTPServerBuilder().
+		Middleware(metrics.HTTPMiddleware).
+		APIRegistration(persesAPI)
 	// start the application
 	runner.Start()
 }
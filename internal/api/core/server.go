@@ -17,11 +17,23 @@ import (
 	"github.com/labstack/echo/v4"
 	echoUtils "github.com/perses/common/echo"
 	"github.com/perses/perses/internal/api/front"
+	"github.com/perses/perses/internal/api/impl/v1/alertmanager"
+	"github.com/perses/perses/internal/api/impl/v1/alertmanagerconfig"
 	"github.com/perses/perses/internal/api/impl/v1/dashboard"
 	"github.com/perses/perses/internal/api/impl/v1/dashboard_feed"
 	"github.com/perses/perses/internal/api/impl/v1/datasource"
+	"github.com/perses/perses/internal/api/impl/v1/datasource_check"
+	"github.com/perses/perses/internal/api/impl/v1/datasource_discovery"
+	"github.com/perses/perses/internal/api/impl/v1/datasource_proxy"
+	"github.com/perses/perses/internal/api/impl/v1/metric_dependency"
 	"github.com/perses/perses/internal/api/impl/v1/project"
 	"github.com/perses/perses/internal/api/impl/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/impl/v1/rulefile"
+	"github.com/perses/perses/internal/api/impl/v1/rulefile_sync"
+	"github.com/perses/perses/internal/api/impl/v1/ruleimport"
+	"github.com/perses/perses/internal/api/impl/v1/rulepreview"
+	"github.com/perses/perses/internal/api/impl/v1/ruletest"
+	"github.com/perses/perses/internal/api/impl/v1/slo"
 	"github.com/perses/perses/internal/api/impl/v1/user"
 	"github.com/perses/perses/internal/api/shared/dependency"
 )
@@ -38,12 +50,24 @@ type api struct {
 
 func NewPersesAPI(serviceManager dependency.ServiceManager) echoUtils.Register {
 	endpoints := []endpoint{
-		dashboard.NewEndpoint(serviceManager.GetDashboard()),
+		alertmanager.NewEndpoint(serviceManager.GetAlertmanager()),
+		alertmanagerconfig.NewEndpoint(serviceManager.GetAlertmanagerConfig(), serviceManager.GetRevision(), serviceManager.GetWatch()),
+		dashboard.NewEndpoint(serviceManager.GetDashboard(), serviceManager.GetRevision(), serviceManager.GetWatch()),
 		dashboard_feed.NewEndpoint(serviceManager.GetDashboardFeed()),
-		datasource.NewEndpoint(serviceManager.GetDatasource()),
-		project.NewEndpoint(serviceManager.GetProject()),
-		prometheusrule.NewEndpoint(serviceManager.GetPrometheusRule()),
-		user.NewEndpoint(serviceManager.GetUser()),
+		datasource.NewEndpoint(serviceManager.GetDatasource(), serviceManager.GetRevision(), serviceManager.GetWatch()),
+		datasource_check.NewEndpoint(serviceManager.GetDatasourceCheck()),
+		datasource_discovery.NewEndpoint(serviceManager.GetDatasourceDiscovery()),
+		datasource_proxy.NewEndpoint(serviceManager.GetDatasourceProxy()),
+		metric_dependency.NewEndpoint(serviceManager.GetMetricDependency()),
+		project.NewEndpoint(serviceManager.GetProject(), serviceManager.GetRevision(), serviceManager.GetWatch()),
+		prometheusrule.NewEndpoint(serviceManager.GetPrometheusRule(), serviceManager.GetRevision(), serviceManager.GetWatch()),
+		rulefile.NewEndpoint(serviceManager.GetRuleFile()),
+		rulefile_sync.NewEndpoint(serviceManager.GetRuleFileSync()),
+		ruleimport.NewEndpoint(serviceManager.GetRuleImport()),
+		rulepreview.NewEndpoint(serviceManager.GetRulePreview()),
+		ruletest.NewEndpoint(serviceManager.GetRuleTest()),
+		slo.NewEndpoint(serviceManager.GetSLO(), serviceManager.GetRevision(), serviceManager.GetWatch()),
+		user.NewEndpoint(serviceManager.GetUser(), serviceManager.GetRevision(), serviceManager.GetWatch()),
 	}
 	return &api{
 		endpoints:     endpoints,
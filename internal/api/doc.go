@@ -15,7 +15,9 @@ package api
 
 // this file is just there to run the command generate
 //go:generate go run generate.go -package=user -plural=users -kind=User
-//go:generate go run generate.go -package=datasource -plural=datasources -kind=Datasource
+//go:generate go run generate.go -package=datasource -plural=datasources -kind=Datasource -isProjectResource=true -isGlobalResource=true
 //go:generate go run generate.go -package=project -plural=projects -kind=Project
 //go:generate go run generate.go -package=dashboard -plural=dashboards -kind=Dashboard -isProjectResource=true
 //go:generate go run generate.go -package=prometheusrule -plural=prometheusrules -kind=PrometheusRule -isProjectResource=true
+//go:generate go run generate.go -package=alertmanagerconfig -plural=alertmanagerconfigs -kind=AlertmanagerConfig -isProjectResource=true
+//go:generate go run generate.go -package=slo -plural=slos -kind=SLO -isProjectResource=true
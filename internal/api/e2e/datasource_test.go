@@ -0,0 +1,82 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/perses/perses/utils"
+)
+
+func TestCreateDatasourceInProjectFromPath(t *testing.T) {
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+	datasourcePath := fmt.Sprintf("%s/%s/perses/%s", shared.APIV1Prefix, shared.PathProject, shared.PathDatasource)
+
+	e.POST(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathProject)).
+		WithJSON(&v1.Project{Kind: v1.KindProject, Metadata: v1.Metadata{Name: "perses"}}).
+		Expect().
+		Status(http.StatusOK)
+
+	// the datasource doesn't give any project, the one of the path is used
+	e.POST(datasourcePath).
+		WithJSON(newProjectDatasource(t, "")).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Path("$.metadata.project").Equal("perses")
+	e.GET(fmt.Sprintf("%s/PrometheusDemo", datasourcePath)).
+		Expect().
+		Status(http.StatusOK)
+	e.GET(fmt.Sprintf("%s/%s/PrometheusDemo", shared.APIV1Prefix, shared.PathDatasource)).
+		Expect().
+		Status(http.StatusNotFound)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
+
+func TestCreateDatasourceInAnotherProject(t *testing.T) {
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	for _, name := range []string{"perses", "other"} {
+		e.POST(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathProject)).
+			WithJSON(&v1.Project{Kind: v1.KindProject, Metadata: v1.Metadata{Name: name}}).
+			Expect().
+			Status(http.StatusOK)
+	}
+
+	// the project of the datasource doesn't match the one of the path
+	e.POST(fmt.Sprintf("%s/%s/perses/%s", shared.APIV1Prefix, shared.PathProject, shared.PathDatasource)).
+		WithJSON(newProjectDatasource(t, "other")).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.GET(fmt.Sprintf("%s/%s/other/%s/PrometheusDemo", shared.APIV1Prefix, shared.PathProject, shared.PathDatasource)).
+		Expect().
+		Status(http.StatusNotFound)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
@@ -11,8 +11,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// +build integration
-
 package e2e
 
 // this file is only present to trigger the e2e test. The e2e test of the api are in a dedicated package to avoid
@@ -11,8 +11,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// +build integration
-
 package e2e
 
 import (
@@ -51,7 +49,7 @@ func TestCreateProject(t *testing.T) {
 	// check the document exists in the db
 	_, err := persistenceManager.GetProject().Get(project.Metadata.Name)
 	assert.NoError(t, err)
-	utils.ClearAllKeys(t, persistenceManager.GetETCDClient())
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
 }
 
 func TestCreateProjectWithConflict(t *testing.T) {
@@ -81,7 +79,7 @@ func TestCreateProjectWithConflict(t *testing.T) {
 		Expect().
 		Status(http.StatusConflict)
 
-	utils.ClearAllKeys(t, persistenceManager.GetETCDClient())
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
 }
 
 func TestCreateProjectBadRequest(t *testing.T) {
@@ -147,7 +145,7 @@ func TestUpdateProject(t *testing.T) {
 	_, err = persistenceManager.GetProject().Get(project.Metadata.Name)
 	assert.NoError(t, err)
 
-	utils.ClearAllKeys(t, persistenceManager.GetETCDClient())
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
 }
 
 func TestUpdateProjectNotFound(t *testing.T) {
@@ -169,7 +167,7 @@ func TestUpdateProjectNotFound(t *testing.T) {
 		Expect().
 		Status(http.StatusNotFound)
 
-	utils.ClearAllKeys(t, persistenceManager.GetETCDClient())
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
 }
 
 func TestUpdateProjectBadRequest(t *testing.T) {
@@ -192,7 +190,66 @@ func TestUpdateProjectBadRequest(t *testing.T) {
 		Expect().
 		Status(http.StatusBadRequest)
 
-	utils.ClearAllKeys(t, persistenceManager.GetETCDClient())
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
+
+func TestUpdateProjectWithStaleVersion(t *testing.T) {
+	project := &v1.Project{
+		Kind: v1.KindProject,
+		Metadata: v1.Metadata{
+			Name: "perses",
+		},
+	}
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	e.POST(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathProject)).
+		WithJSON(project).
+		Expect().
+		Status(http.StatusOK)
+
+	// two clients read the same version of the project
+	response := e.GET(fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)).
+		Expect().
+		Status(http.StatusOK)
+	etag := response.Header("ETag").Raw()
+	version := uint64(response.JSON().Path("$.metadata.version").Number().Raw())
+	assert.Equal(t, fmt.Sprintf("%q", fmt.Sprint(version)), etag)
+
+	// the first update with the version in the body succeeds
+	project.Metadata.Version = version
+	e.PUT(fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)).
+		WithJSON(project).
+		Expect().
+		Status(http.StatusOK)
+
+	// the second one is rejected, with the version in the body or in the header If-Match
+	e.PUT(fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)).
+		WithJSON(project).
+		Expect().
+		Status(http.StatusConflict)
+	project.Metadata.Version = 0
+	e.PUT(fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)).
+		WithHeader("If-Match", etag).
+		WithJSON(project).
+		Expect().
+		Status(http.StatusConflict)
+	e.DELETE(fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)).
+		WithHeader("If-Match", etag).
+		Expect().
+		Status(http.StatusConflict)
+
+	// without any version, the update is always done
+	e.PUT(fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)).
+		WithJSON(project).
+		Expect().
+		Status(http.StatusOK)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
 }
 
 func TestGetProject(t *testing.T) {
@@ -218,7 +275,7 @@ func TestGetProject(t *testing.T) {
 		Expect().
 		Status(http.StatusOK)
 
-	utils.ClearAllKeys(t, persistenceManager.GetETCDClient())
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
 }
 
 func TestGetProjectNotFound(t *testing.T) {
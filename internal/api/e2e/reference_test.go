@@ -0,0 +1,199 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/perses/perses/utils"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func newProjectDatasource(t *testing.T, project string) *v1.Datasource {
+	u, err := url.Parse("http://localhost:9090")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &v1.Datasource{
+		Kind: v1.KindDatasource,
+		Metadata: v1.OptionalProjectMetadata{
+			Metadata: v1.Metadata{Name: "PrometheusDemo"},
+			Project:  project,
+		},
+		Spec: v1.DatasourceSpec{Kind: v1.KindPrometheusDatasource, URL: u},
+	}
+}
+
+func TestCreateResourceInUnknownProject(t *testing.T) {
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+
+	// the project perses doesn't exist, the datasource cannot be created in it
+	e.POST(fmt.Sprintf("%s/%s/perses/%s", shared.APIV1Prefix, shared.PathProject, shared.PathDatasource)).
+		WithJSON(newProjectDatasource(t, "perses")).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
+
+func TestCreateDashboardWithUnknownDatasource(t *testing.T) {
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+	projectPath := fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)
+	dashboardPath := fmt.Sprintf("%s/%s", projectPath, shared.PathDashboard)
+	e.POST(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathProject)).
+		WithJSON(&v1.Project{Kind: v1.KindProject, Metadata: v1.Metadata{Name: "perses"}}).
+		Expect().
+		Status(http.StatusOK)
+	e.POST(fmt.Sprintf("%s/%s", projectPath, shared.PathDatasource)).
+		WithJSON(newProjectDatasource(t, "perses")).
+		Expect().
+		Status(http.StatusOK)
+
+	newDashboard := func(name string, panel v1.Panel) *v1.Dashboard {
+		return &v1.Dashboard{
+			Kind: v1.KindDashboard,
+			Metadata: v1.ProjectMetadata{
+				Metadata: v1.Metadata{Name: name},
+				Project:  "perses",
+			},
+			Spec: v1.DashboardSpec{
+				Datasource: "PrometheusDemo",
+				Duration:   model.Duration(time.Hour),
+				Sections:   []v1.DashboardSection{{Name: "main", Panels: []v1.Panel{panel}}},
+			},
+		}
+	}
+	// the datasource of a LogsChart is the one of the panel
+	logs := v1.Panel{Name: "logs", Datasource: "Loki", Chart: &v1.LogsChart{Kind: v1.KindLogsChart, Query: `{job="api"}`}}
+	e.POST(dashboardPath).
+		WithJSON(newDashboard("logs", logs)).
+		Expect().
+		Status(http.StatusBadRequest)
+	lines := v1.Panel{Name: "lines", Chart: &v1.LineChart{Kind: v1.KindLineChart, Lines: []v1.Line{
+		{Expr: "up"},
+		{Datasource: "Thanos", Expr: "up"},
+	}}}
+	e.POST(dashboardPath).
+		WithJSON(newDashboard("lines", lines)).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// once the datasources exist, the dashboards can be created
+	for _, name := range []string{"Loki", "Thanos"} {
+		dts := newProjectDatasource(t, "perses")
+		dts.Metadata.Name = name
+		e.POST(fmt.Sprintf("%s/%s", projectPath, shared.PathDatasource)).
+			WithJSON(dts).
+			Expect().
+			Status(http.StatusOK)
+	}
+	e.POST(dashboardPath).
+		WithJSON(newDashboard("logs", logs)).
+		Expect().
+		Status(http.StatusOK)
+	e.POST(dashboardPath).
+		WithJSON(newDashboard("lines", lines)).
+		Expect().
+		Status(http.StatusOK)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
+
+func TestDeleteProjectWithResources(t *testing.T) {
+	project := &v1.Project{
+		Kind: v1.KindProject,
+		Metadata: v1.Metadata{
+			Name: "perses",
+		},
+	}
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+	projectPath := fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)
+	datasourcePath := fmt.Sprintf("%s/%s", projectPath, shared.PathDatasource)
+
+	e.POST(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathProject)).
+		WithJSON(project).
+		Expect().
+		Status(http.StatusOK)
+	e.POST(datasourcePath).
+		WithJSON(newProjectDatasource(t, "perses")).
+		Expect().
+		Status(http.StatusOK)
+	// a global datasource having the name of the project
+	globalDatasource := newProjectDatasource(t, "")
+	globalDatasource.Metadata.Name = "perses"
+	e.POST(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathDatasource)).
+		WithJSON(globalDatasource).
+		Expect().
+		Status(http.StatusOK)
+
+	// the project still contains a datasource, the deletion is blocked
+	e.DELETE(projectPath).
+		Expect().
+		Status(http.StatusConflict)
+	e.DELETE(projectPath).
+		WithQuery(shared.ParamCascade, "maybe").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	// with the cascade, the project is deleted together with its datasource and the history of it
+	e.DELETE(projectPath).
+		WithQuery(shared.ParamCascade, true).
+		Expect().
+		Status(http.StatusNoContent)
+	e.GET(projectPath).
+		Expect().
+		Status(http.StatusNotFound)
+	e.GET(fmt.Sprintf("%s/PrometheusDemo", datasourcePath)).
+		Expect().
+		Status(http.StatusNotFound)
+	e.GET(fmt.Sprintf("%s/PrometheusDemo/%s", datasourcePath, shared.PathRevision)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Array().Empty()
+
+	// the history of the project itself is kept
+	revisions, err := persistenceManager.GetRevision().List(v1.GenerateProjectID("perses"))
+	assert.NoError(t, err)
+	assert.Len(t, revisions, 2)
+	// the global datasource and its history are not part of the project
+	e.GET(fmt.Sprintf("%s/%s/perses/%s", shared.APIV1Prefix, shared.PathDatasource, shared.PathRevision)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Array().Length().Equal(1)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
@@ -0,0 +1,104 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/perses/perses/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProjectRevisions(t *testing.T) {
+	project := &v1.Project{
+		Kind: v1.KindProject,
+		Metadata: v1.Metadata{
+			Name: "perses",
+		},
+	}
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+	projectPath := fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)
+	revisionPath := fmt.Sprintf("%s/%s", projectPath, shared.PathRevision)
+
+	e.POST(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathProject)).
+		WithJSON(project).
+		Expect().
+		Status(http.StatusOK)
+	e.PUT(projectPath).
+		WithJSON(project).
+		Expect().
+		Status(http.StatusOK)
+	e.DELETE(projectPath).
+		WithHeader("X-Forwarded-User", "admin").
+		Expect().
+		Status(http.StatusNoContent)
+
+	revisions := e.GET(revisionPath).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Array()
+	revisions.Length().Equal(3)
+	revisions.Element(0).Object().ValueEqual("operation", v1.RevisionCreated).NotContainsKey("resource")
+	revisions.Element(1).Object().ValueEqual("operation", v1.RevisionUpdated)
+	revisions.Element(2).Object().ValueEqual("operation", v1.RevisionDeleted).ValueEqual("author", "admin")
+
+	e.GET(fmt.Sprintf("%s/1", revisionPath)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().Value("resource").Object().Value("metadata").Object().ValueEqual("name", "perses")
+	e.GET(fmt.Sprintf("%s/42", revisionPath)).
+		Expect().
+		Status(http.StatusNotFound)
+
+	// only the dates changed between the creation and the update, they are not part of the diff
+	e.GET(fmt.Sprintf("%s/%s", revisionPath, shared.PathDiff)).
+		WithQuery("from", 1).
+		WithQuery("to", 2).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().ValueEqual("diff", "")
+	// the latest revision is the deletion, so everything has been removed
+	e.GET(fmt.Sprintf("%s/%s", revisionPath, shared.PathDiff)).
+		WithQuery("from", 2).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().ValueEqual("to", 3).Value("diff").String().Contains("-kind: Project")
+
+	// a deletion cannot be restored, but the resource before it can
+	e.POST(fmt.Sprintf("%s/3/%s", revisionPath, shared.PathRestore)).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.POST(fmt.Sprintf("%s/2/%s", revisionPath, shared.PathRestore)).
+		Expect().
+		Status(http.StatusOK)
+
+	_, err := persistenceManager.GetProject().Get(project.Metadata.Name)
+	assert.NoError(t, err)
+	e.GET(fmt.Sprintf("%s/4", revisionPath)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object().ValueEqual("operation", v1.RevisionCreated).ValueEqual("restored_from", 2)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/perses/perses/utils"
+)
+
+func TestManagedResourcesOfSLO(t *testing.T) {
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+	projectPath := fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)
+	rulePath := fmt.Sprintf("%s/%s/slo-availability", projectPath, shared.PathPrometheusRule)
+
+	e.POST(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathProject)).
+		WithJSON(&v1.Project{Kind: v1.KindProject, Metadata: v1.Metadata{Name: "perses"}}).
+		Expect().
+		Status(http.StatusOK)
+	e.POST(fmt.Sprintf("%s/%s", projectPath, shared.PathSLO)).
+		WithJSON(map[string]interface{}{
+			"kind":     v1.KindSLO,
+			"metadata": map[string]string{"name": "availability", "project": "perses"},
+			"spec": map[string]interface{}{
+				"objective": 0.999,
+				"good":      `sum(rate(http_requests_total{code!~"5.."}[$window]))`,
+				"total":     "sum(rate(http_requests_total[$window]))",
+			},
+		}).
+		Expect().
+		Status(http.StatusOK)
+
+	// the generated prometheusRule is managed by the SLO and has its own history
+	rule := e.GET(rulePath).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Object()
+	rule.Path("$.metadata.managed_by").Equal("SLO/availability")
+	e.GET(fmt.Sprintf("%s/%s", rulePath, shared.PathRevision)).
+		Expect().
+		Status(http.StatusOK).
+		JSON().Array().Length().Equal(1)
+
+	// it can only be modified through the SLO
+	e.PUT(rulePath).
+		WithJSON(rule.Raw()).
+		Expect().
+		Status(http.StatusBadRequest)
+	e.DELETE(rulePath).
+		Expect().
+		Status(http.StatusBadRequest)
+
+	e.DELETE(fmt.Sprintf("%s/%s/availability", projectPath, shared.PathSLO)).
+		Expect().
+		Status(http.StatusNoContent)
+	e.GET(rulePath).
+		Expect().
+		Status(http.StatusNotFound)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
@@ -0,0 +1,169 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package e2e
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+
+	"github.com/gavv/httpexpect/v2"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/perses/perses/utils"
+	"github.com/stretchr/testify/assert"
+)
+
+type serverSentEvent struct {
+	id    string
+	event string
+	data  *v1.WatchEvent
+}
+
+// watch starts to watch the resources at the given url and returns the function reading the next event.
+func watch(t *testing.T, ctx context.Context, url string) func() serverSentEvent {
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	response, err := http.DefaultClient.Do(request)
+	if err != nil {
+		t.Fatal(err)
+	}
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	reader := bufio.NewReader(response.Body)
+	return func() serverSentEvent {
+		result := serverSentEvent{}
+		for {
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				t.Fatal(err)
+			}
+			line = strings.TrimSuffix(line, "\n")
+			switch {
+			case len(line) == 0:
+				return result
+			case strings.HasPrefix(line, "id: "):
+				result.id = strings.TrimPrefix(line, "id: ")
+			case strings.HasPrefix(line, "event: "):
+				result.event = strings.TrimPrefix(line, "event: ")
+			case strings.HasPrefix(line, "data: "):
+				result.data = &v1.WatchEvent{}
+				if err := json.Unmarshal([]byte(strings.TrimPrefix(line, "data: ")), result.data); err != nil {
+					t.Fatal(err)
+				}
+			}
+		}
+	}
+}
+
+func TestWatchProjects(t *testing.T) {
+	project := &v1.Project{
+		Kind: v1.KindProject,
+		Metadata: v1.Metadata{
+			Name: "perses",
+		},
+	}
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchURL := fmt.Sprintf("%s%s/%s?watch=true", server.URL, shared.APIV1Prefix, shared.PathProject)
+	next := watch(t, ctx, watchURL)
+
+	e.POST(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathProject)).
+		WithJSON(project).
+		Expect().
+		Status(http.StatusOK)
+	event := next()
+	assert.Equal(t, string(v1.WatchEventAdded), event.event)
+	assert.Equal(t, fmt.Sprintf("%d", event.data.Version), event.id)
+	created := &v1.Project{}
+	assert.NoError(t, json.Unmarshal(event.data.Resource, created))
+	assert.Equal(t, "perses", created.Metadata.Name)
+	assert.Equal(t, event.data.Version, created.Metadata.Version)
+
+	e.DELETE(fmt.Sprintf("%s/%s/perses", shared.APIV1Prefix, shared.PathProject)).
+		Expect().
+		Status(http.StatusNoContent)
+	assert.Equal(t, string(v1.WatchEventDeleted), next().event)
+
+	// a new watch resumed from the creation receives the deletion
+	next = watch(t, ctx, fmt.Sprintf("%s&version=%d", watchURL, created.Metadata.Version))
+	event = next()
+	assert.Equal(t, string(v1.WatchEventDeleted), event.event)
+	assert.NoError(t, json.Unmarshal(event.data.Resource, created))
+	assert.Equal(t, "perses", created.Metadata.Name)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
+
+func TestWatchUsersWithoutPassword(t *testing.T) {
+	user := &v1.User{
+		Kind: v1.KindUser,
+		Metadata: v1.Metadata{
+			Name: "jdoe",
+		},
+		Spec: v1.UserSpec{
+			Password: []byte("password"),
+		},
+	}
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	next := watch(t, ctx, fmt.Sprintf("%s%s/%s?watch=true", server.URL, shared.APIV1Prefix, shared.PathUser))
+
+	e.POST(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathUser)).
+		WithJSON(user).
+		Expect().
+		Status(http.StatusOK)
+	event := next()
+	assert.Equal(t, string(v1.WatchEventAdded), event.event)
+	created := &v1.User{}
+	assert.NoError(t, json.Unmarshal(event.data.Resource, created))
+	assert.Equal(t, "jdoe", created.Metadata.Name)
+	assert.Empty(t, created.Spec.Password)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
+
+func TestWatchWithInvalidVersion(t *testing.T) {
+	server, persistenceManager := utils.CreateServer(t)
+	defer server.Close()
+	e := httpexpect.WithConfig(httpexpect.Config{
+		BaseURL:  server.URL,
+		Reporter: httpexpect.NewAssertReporter(t),
+	})
+	e.GET(fmt.Sprintf("%s/%s", shared.APIV1Prefix, shared.PathProject)).
+		WithQuery("watch", true).
+		WithQuery("version", "abc").
+		Expect().
+		Status(http.StatusBadRequest)
+
+	utils.ClearAllKeys(t, persistenceManager.GetDatabase())
+}
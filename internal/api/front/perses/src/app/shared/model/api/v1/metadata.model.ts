@@ -15,8 +15,10 @@ export interface Metadata {
   name: string;
   created_at: string;
   updated_at: string;
+  version?: number;
 }
 
 export interface ProjectMetadata extends Metadata {
   project: string;
+  managed_by?: string;
 }
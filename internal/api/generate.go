@@ -61,9 +61,9 @@ type Endpoint struct {
 	toolbox shared.Toolbox
 }
 
-func NewEndpoint(service {{ $package }}.Service) *Endpoint {
+func NewEndpoint(service {{ $package }}.Service, history shared.History, watcher shared.Watcher) *Endpoint {
 	return &Endpoint{
-		toolbox: shared.NewToolBox(service),
+		toolbox: shared.NewToolBox(service, history, watcher),
 	}
 }
 
@@ -71,7 +71,16 @@ func (e *Endpoint) RegisterRoutes(g *echo.Group) {
 	group := g.Group(fmt.Sprintf("/%s", shared.Path{{ $kind }}))
 	group.POST("", e.Create)
 	group.GET("", e.List)
-{{ if $endpoint.IsProjectResource -}}
+{{- if or (not $endpoint.IsProjectResource) $endpoint.IsGlobalResource }}
+	group.PUT(fmt.Sprintf("/:%s", shared.ParamName), e.Update)
+	group.DELETE(fmt.Sprintf("/:%s", shared.ParamName), e.Delete)
+	group.GET(fmt.Sprintf("/:%s", shared.ParamName), e.Get)
+	group.GET(fmt.Sprintf("/:%s/%s", shared.ParamName, shared.PathRevision), e.ListRevisions)
+	group.GET(fmt.Sprintf("/:%s/%s/%s", shared.ParamName, shared.PathRevision, shared.PathDiff), e.DiffRevisions)
+	group.GET(fmt.Sprintf("/:%s/%s/:%s", shared.ParamName, shared.PathRevision, shared.ParamRevision), e.GetRevision)
+	group.POST(fmt.Sprintf("/:%s/%s/:%s/%s", shared.ParamName, shared.PathRevision, shared.ParamRevision, shared.PathRestore), e.RestoreRevision)
+{{- end }}
+{{- if $endpoint.IsProjectResource }}
 
 	subGroup := g.Group(fmt.Sprintf("/%s/:%s/%s", shared.PathProject, shared.ParamProject, shared.Path{{ $kind }}))
 	subGroup.POST("", e.Create)
@@ -79,10 +88,10 @@ func (e *Endpoint) RegisterRoutes(g *echo.Group) {
 	subGroup.PUT(fmt.Sprintf("/:%s", shared.ParamName), e.Update)
 	subGroup.DELETE(fmt.Sprintf("/:%s", shared.ParamName), e.Delete)
 	subGroup.GET(fmt.Sprintf("/:%s", shared.ParamName), e.Get)
-{{- else -}}
-	group.PUT(fmt.Sprintf("/:%s", shared.ParamName), e.Update)
-	group.DELETE(fmt.Sprintf("/:%s", shared.ParamName), e.Delete)
-	group.GET(fmt.Sprintf("/:%s", shared.ParamName), e.Get)
+	subGroup.GET(fmt.Sprintf("/:%s/%s", shared.ParamName, shared.PathRevision), e.ListRevisions)
+	subGroup.GET(fmt.Sprintf("/:%s/%s/%s", shared.ParamName, shared.PathRevision, shared.PathDiff), e.DiffRevisions)
+	subGroup.GET(fmt.Sprintf("/:%s/%s/:%s", shared.ParamName, shared.PathRevision, shared.ParamRevision), e.GetRevision)
+	subGroup.POST(fmt.Sprintf("/:%s/%s/:%s/%s", shared.ParamName, shared.PathRevision, shared.ParamRevision, shared.PathRestore), e.RestoreRevision)
 {{- end }}
 }
 
@@ -106,8 +115,32 @@ func (e *Endpoint) Get(ctx echo.Context) error {
 
 func (e *Endpoint) List(ctx echo.Context) error {
 	q := &{{ $package }}.Query{}
+	if shared.IsWatchRequested(ctx) {
+		entity := &v1.{{ $kind }}{}
+		return e.toolbox.Watch(ctx, q, entity)
+	}
 	return e.toolbox.List(ctx, q)
 }
+
+func (e *Endpoint) ListRevisions(ctx echo.Context) error {
+	entity := &v1.{{ $kind }}{}
+	return e.toolbox.ListRevisions(ctx, entity)
+}
+
+func (e *Endpoint) GetRevision(ctx echo.Context) error {
+	entity := &v1.{{ $kind }}{}
+	return e.toolbox.GetRevision(ctx, entity)
+}
+
+func (e *Endpoint) DiffRevisions(ctx echo.Context) error {
+	entity := &v1.{{ $kind }}{}
+	return e.toolbox.DiffRevisions(ctx, entity)
+}
+
+func (e *Endpoint) RestoreRevision(ctx echo.Context) error {
+	entity := &v1.{{ $kind }}{}
+	return e.toolbox.RestoreRevision(ctx, entity)
+}
 `))
 	tplFunc = map[string]interface{}{
 		"tag":     printTag,
@@ -146,6 +179,9 @@ type Query struct {
 {{ if $endpoint.IsProjectResource -}}
 	// Project is the exact name of the project. 
 	// The value can come or from the path of the URL or from the query parameter
+{{- if $endpoint.IsGlobalResource }}
+	// When it is empty, only the global {{ $kind }} are considered.
+{{- end }}
 	Project string {{ tag "path:\"project\" query:\"project\"" }}
 {{ end }}
 }
@@ -158,10 +194,10 @@ type DAO interface {
 	Create(entity *v1.{{ $kind }}) error
 	Update(entity *v1.{{ $kind }}) error
 {{ if $endpoint.IsProjectResource -}}
-	Delete(project string, name string) error
+	Delete(project string, name string, version uint64) error
 	Get(project string, name string) (*v1.{{ $kind }}, error)
 {{- else -}}
-	Delete(name string) error
+	Delete(name string, version uint64) error
 	Get(name string) (*v1.{{ $kind }}, error)
 {{- end }}
 	List(q etcd.Query) ([]*v1.{{ $kind }}, error)
@@ -192,21 +228,20 @@ type Service interface {
 package {{ $package }}
 
 import (
-	"time"
-
 	"github.com/perses/common/etcd"
 	"github.com/perses/perses/internal/api/interface/v1/{{ $package }}"
+	"github.com/perses/perses/internal/api/shared/database"
+	"github.com/perses/perses/internal/api/shared/metrics"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 type dao struct {
 	{{ $package }}.DAO
-	client etcd.DAO
+	client database.DAO
 }
 
-func NewDAO(etcdClient *clientv3.Client, timeout time.Duration) {{ $package }}.DAO {
-	client := etcd.NewDAO(etcdClient, timeout)
+func NewDAO(persistence database.DAO) {{ $package }}.DAO {
+	client := metrics.NewInstrumentedDAO(persistence, string(v1.Kind{{ $kind }}))
 	return &dao{
 		client: client,
 	}
@@ -219,12 +254,12 @@ func (d *dao) Create(entity *v1.{{ $kind }}) error {
 
 func (d *dao) Update(entity *v1.{{ $kind }}) error {
 	key := entity.GenerateID()
-	return d.client.Upsert(key, entity)
+	return d.client.Upsert(key, entity, entity.Metadata.Version)
 }
 
-func (d *dao) Delete({{- if $endpoint.IsProjectResource -}}project string,{{- end -}} name string) error {
+func (d *dao) Delete({{- if $endpoint.IsProjectResource -}}project string,{{- end -}} name string, version uint64) error {
 	key := v1.Generate{{ $kind }}ID({{- if $endpoint.IsProjectResource -}}project,{{- end -}} name)
-	return d.client.Delete(key)
+	return d.client.Delete(key, version)
 }
 
 func (d *dao) Get({{- if $endpoint.IsProjectResource -}}project string,{{- end -}} name string) (*v1.{{ $kind }}, error) {
@@ -267,6 +302,10 @@ import (
 
 const {{ unTitle $kind }}Resource = "{{ $plural }}"
 
+{{ if $endpoint.IsGlobalResource -}}
+// {{ $kind }}Interface is used to manage the {{ $kind }} of a project.
+// When the project is empty, the global {{ $kind }} are managed instead.
+{{ end -}}
 type {{ $kind }}Interface interface {
 	Create(entity *v1.{{ $kind }}) (*v1.{{ $kind }}, error)
 	Update(entity *v1.{{ $kind }}) (*v1.{{ $kind }}, error)
@@ -382,6 +421,8 @@ type endpoint struct {
 	Kind              string
 	Plural            string
 	IsProjectResource bool
+	// IsGlobalResource is used when the resource is part of a project and can also be defined outside of any project.
+	IsGlobalResource bool
 }
 
 func generateEndpoint(ept endpoint) {
@@ -440,6 +481,7 @@ func main() {
 	pkg := flag.String("package", "", "the name of the package that needs to be generated. It should match the name of the resource you would like to expose through http")
 	kind := flag.String("kind", "", "the name of the resource with the appropriate cases")
 	isProjectResource := flag.Bool("isProjectResource", false, "if the resource is part of a project.")
+	isGlobalResource := flag.Bool("isGlobalResource", false, "if the resource is part of a project, it can also be defined globally, outside of any project.")
 	plural := flag.String("plural", "", "")
 	flag.Parse()
 
@@ -450,6 +492,7 @@ func main() {
 		PackageName:       *pkg,
 		Kind:              *kind,
 		IsProjectResource: *isProjectResource,
+		IsGlobalResource:  *isGlobalResource,
 		Plural:            *plural,
 	}
 	generateEndpoint(ept)
@@ -0,0 +1,49 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanager
+
+import (
+	alertmanagerconfigImpl "github.com/perses/perses/internal/api/impl/v1/alertmanagerconfig"
+	"github.com/perses/perses/internal/api/interface/v1/alertmanager"
+	"github.com/perses/perses/internal/api/interface/v1/alertmanagerconfig"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+type service struct {
+	alertmanager.Service
+	dao alertmanagerconfig.DAO
+}
+
+func NewService(dao alertmanagerconfig.DAO) alertmanager.Service {
+	return &service{
+		dao: dao,
+	}
+}
+
+func (s *service) GetConfig() (*v1.AlertmanagerConfigFile, error) {
+	configs, err := s.dao.List(&alertmanagerconfig.Query{})
+	if err != nil {
+		logrus.WithError(err).Error("unable to get the alertmanagerConfigs, something wrong with etcd")
+		return nil, shared.InternalError
+	}
+	result := alertmanagerconfigImpl.Merge(configs)
+	// each config has been checked when it was created or updated, so the merge is supposed to be valid.
+	if err := alertmanagerconfigImpl.Check(result); err != nil {
+		logrus.WithError(err).Error("the merged alertmanager configuration is not valid")
+		return nil, shared.InternalError
+	}
+	return result, nil
+}
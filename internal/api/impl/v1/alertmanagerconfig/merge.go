@@ -0,0 +1,121 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanagerconfig
+
+import (
+	"fmt"
+	"sort"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	amConfig "github.com/prometheus/alertmanager/config"
+	"gopkg.in/yaml.v2"
+)
+
+// DefaultReceiver is the receiver of the root route of the merged configuration.
+// It doesn't have any integration, so the alerts that don't belong to any project are dropped.
+const DefaultReceiver = "blackhole"
+
+// Merge puts the AlertmanagerConfig of every project in a single Alertmanager configuration.
+// The route of each AlertmanagerConfig becomes a child of the root route that only matches the alerts having the label
+// AlertmanagerProjectLabel set with the project, and the same applies to its inhibition rules.
+// The receivers are renamed <project>/<name>/<receiver> to avoid any collision between the projects.
+// The configs are sorted before being merged, so the result is always the same.
+func Merge(configs []*v1.AlertmanagerConfig) *v1.AlertmanagerConfigFile {
+	sort.Slice(configs, func(i, j int) bool {
+		if configs[i].Metadata.Project != configs[j].Metadata.Project {
+			return configs[i].Metadata.Project < configs[j].Metadata.Project
+		}
+		return configs[i].Metadata.Name < configs[j].Metadata.Name
+	})
+	result := &v1.AlertmanagerConfigFile{
+		Route:     &v1.AlertmanagerRoute{Receiver: DefaultReceiver},
+		Receivers: []v1.AlertmanagerReceiver{{"name": DefaultReceiver}},
+	}
+	for _, config := range configs {
+		project := config.Metadata.Project
+		prefix := fmt.Sprintf("%s/%s/", project, config.Metadata.Name)
+		route := renameReceivers(&config.Spec.Route, prefix)
+		route.Match = withProject(route.Match, project)
+		route.MatchRE = withoutProject(route.MatchRE)
+		// every config of the project must receive the alerts, not only the first one
+		route.Continue = true
+		result.Route.Routes = append(result.Route.Routes, route)
+		for _, receiver := range config.Spec.Receivers {
+			renamed := make(v1.AlertmanagerReceiver, len(receiver))
+			for key, value := range receiver {
+				renamed[key] = value
+			}
+			renamed["name"] = prefix + receiver.Name()
+			result.Receivers = append(result.Receivers, renamed)
+		}
+		for _, rule := range config.Spec.InhibitRules {
+			rule.SourceMatch = withProject(rule.SourceMatch, project)
+			rule.SourceMatchRE = withoutProject(rule.SourceMatchRE)
+			rule.TargetMatch = withProject(rule.TargetMatch, project)
+			rule.TargetMatchRE = withoutProject(rule.TargetMatchRE)
+			result.InhibitRules = append(result.InhibitRules, rule)
+		}
+	}
+	return result
+}
+
+// renameReceivers returns a copy of the route where the receiver of the route and of all its children are prefixed.
+func renameReceivers(route *v1.AlertmanagerRoute, prefix string) *v1.AlertmanagerRoute {
+	result := *route
+	if len(result.Receiver) > 0 {
+		result.Receiver = prefix + result.Receiver
+	}
+	result.Routes = make([]*v1.AlertmanagerRoute, 0, len(route.Routes))
+	for _, child := range route.Routes {
+		result.Routes = append(result.Routes, renameReceivers(child, prefix))
+	}
+	if len(result.Routes) == 0 {
+		result.Routes = nil
+	}
+	return &result
+}
+
+// withProject returns a copy of the matchers with the project label set.
+func withProject(match map[string]string, project string) map[string]string {
+	result := make(map[string]string, len(match)+1)
+	for name, value := range match {
+		result[name] = value
+	}
+	result[v1.AlertmanagerProjectLabel] = project
+	return result
+}
+
+// withoutProject returns a copy of the regexp matchers without the project label, as it is replaced by an exact match.
+func withoutProject(matchRE map[string]string) map[string]string {
+	if len(matchRE) == 0 {
+		return matchRE
+	}
+	result := make(map[string]string, len(matchRE))
+	for name, value := range matchRE {
+		if name != v1.AlertmanagerProjectLabel {
+			result[name] = value
+		}
+	}
+	return result
+}
+
+// Check validates the configuration with the parser of Alertmanager.
+func Check(file *v1.AlertmanagerConfigFile) error {
+	data, err := yaml.Marshal(file)
+	if err != nil {
+		return err
+	}
+	_, err = amConfig.Load(string(data))
+	return err
+}
@@ -0,0 +1,186 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanagerconfig
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func unmarshalConfig(t *testing.T, data string) *v1.AlertmanagerConfig {
+	config := &v1.AlertmanagerConfig{}
+	if err := yaml.Unmarshal([]byte(data), config); err != nil {
+		t.Fatal(err)
+	}
+	return config
+}
+
+const persesConfig = `
+kind: AlertmanagerConfig
+metadata:
+  name: oncall
+  project: perses
+spec:
+  route:
+    receiver: team
+    group_by: [alertname]
+    match_re:
+      project: .*
+    routes:
+      - receiver: pager
+        match:
+          severity: page
+  receivers:
+    - name: team
+      webhook_configs:
+        - url: http://team.example.com/hook
+    - name: pager
+      webhook_configs:
+        - url: http://pager.example.com/hook
+          send_resolved: true
+  inhibit_rules:
+    - source_match:
+        severity: page
+      target_match:
+        severity: ticket
+      equal: [alertname]
+`
+
+const demoConfig = `
+kind: AlertmanagerConfig
+metadata:
+  name: oncall
+  project: demo
+spec:
+  route:
+    receiver: team
+  receivers:
+    - name: team
+      webhook_configs:
+        - url: http://demo.example.com/hook
+`
+
+func TestMerge(t *testing.T) {
+	perses := unmarshalConfig(t, persesConfig)
+	demo := unmarshalConfig(t, demoConfig)
+	result := Merge([]*v1.AlertmanagerConfig{perses, demo})
+	assert.NoError(t, Check(result))
+
+	assert.Equal(t, DefaultReceiver, result.Route.Receiver)
+	receivers := make([]string, 0, len(result.Receivers))
+	for _, receiver := range result.Receivers {
+		receivers = append(receivers, receiver.Name())
+	}
+	assert.Equal(t, []string{DefaultReceiver, "demo/oncall/team", "perses/oncall/team", "perses/oncall/pager"}, receivers)
+
+	if assert.Len(t, result.Route.Routes, 2) {
+		demoRoute := result.Route.Routes[0]
+		assert.Equal(t, "demo/oncall/team", demoRoute.Receiver)
+		assert.Equal(t, map[string]string{"project": "demo"}, demoRoute.Match)
+		assert.True(t, demoRoute.Continue)
+
+		persesRoute := result.Route.Routes[1]
+		assert.Equal(t, "perses/oncall/team", persesRoute.Receiver)
+		assert.Equal(t, map[string]string{"project": "perses"}, persesRoute.Match)
+		assert.Empty(t, persesRoute.MatchRE)
+		if assert.Len(t, persesRoute.Routes, 1) {
+			assert.Equal(t, "perses/oncall/pager", persesRoute.Routes[0].Receiver)
+		}
+	}
+	assert.Equal(t, []v1.AlertmanagerInhibitRule{{
+		SourceMatch: map[string]string{"project": "perses", "severity": "page"},
+		TargetMatch: map[string]string{"project": "perses", "severity": "ticket"},
+		Equal:       []string{"alertname"},
+	}}, result.InhibitRules)
+
+	// the configs given are not modified by the merge
+	assert.Equal(t, "team", perses.Spec.Route.Receiver)
+	assert.Equal(t, map[string]string{"project": ".*"}, perses.Spec.Route.MatchRE)
+	assert.Equal(t, "pager", perses.Spec.Receivers[1].Name())
+}
+
+func TestMergeCanBeStoredInJSON(t *testing.T) {
+	// a config coming from YAML must be stored in etcd as JSON without losing the integrations of the receivers.
+	perses := unmarshalConfig(t, persesConfig)
+	data, err := json.Marshal(perses)
+	assert.NoError(t, err)
+	result := &v1.AlertmanagerConfig{}
+	assert.NoError(t, json.Unmarshal(data, result))
+	assert.NoError(t, Check(Merge([]*v1.AlertmanagerConfig{result})))
+}
+
+func TestCheckConfig(t *testing.T) {
+	testSuite := []struct {
+		title  string
+		config string
+	}{
+		{
+			title: "undefined receiver",
+			config: `
+kind: AlertmanagerConfig
+metadata:
+  name: oncall
+  project: perses
+spec:
+  route:
+    receiver: unknown
+  receivers:
+    - name: team
+`,
+		},
+		{
+			title: "invalid integration",
+			config: `
+kind: AlertmanagerConfig
+metadata:
+  name: oncall
+  project: perses
+spec:
+  route:
+    receiver: team
+  receivers:
+    - name: team
+      webhook_configs:
+        - send_resolved: true
+`,
+		},
+		{
+			title: "receiver defined twice",
+			config: `
+kind: AlertmanagerConfig
+metadata:
+  name: oncall
+  project: perses
+spec:
+  route:
+    receiver: team
+  receivers:
+    - name: team
+    - name: team
+`,
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			err := checkConfig(unmarshalConfig(t, test.config))
+			assert.True(t, errors.Is(err, shared.BadRequestError))
+		})
+	}
+}
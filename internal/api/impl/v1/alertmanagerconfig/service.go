@@ -0,0 +1,299 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanagerconfig
+
+import (
+	"fmt"
+	"reflect"
+	"time"
+
+	"github.com/perses/common/etcd"
+	projectImpl "github.com/perses/perses/internal/api/impl/v1/project"
+	"github.com/perses/perses/internal/api/interface/v1/alertmanagerconfig"
+	"github.com/perses/perses/internal/api/interface/v1/project"
+	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/pkg/model/api"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// checkConfig validates the config as it will be once merged with the configs of the other projects.
+func checkConfig(entity *v1.AlertmanagerConfig) error {
+	if err := Check(Merge([]*v1.AlertmanagerConfig{entity})); err != nil {
+		return fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
+	return nil
+}
+
+// secretFields are the fields of the receiver integrations that contain a secret, at any level of their configuration.
+var secretFields = map[string]bool{
+	"auth_password": true, // email
+	"auth_secret":   true, // email
+	"service_key":   true, // pagerduty
+	"routing_key":   true, // pagerduty
+	"api_secret":    true, // wechat
+	"api_key":       true, // opsgenie, victorops
+	"user_key":      true, // pushover
+	"token":         true, // pushover
+	"password":      true, // http_config.basic_auth
+	"bearer_token":  true, // http_config
+	"credentials":   true, // http_config.authorization
+}
+
+// integrationSecretFields are the fields that contain a secret only for some integrations.
+var integrationSecretFields = map[string]map[string]bool{
+	// the URL of a Slack webhook contains its token
+	"slack_configs": {"api_url": true},
+}
+
+// boundFields are the fields the secrets are given for: where the notifications are sent and who is authenticated.
+var boundFields = []string{"url", "api_url", "smarthost", "auth_username", "username"}
+
+func isSecret(integration string, field string) bool {
+	return secretFields[field] || integrationSecretFields[integration][field]
+}
+
+// integrationConfigs returns the configurations of an integration of a receiver. The position of each configuration is
+// kept, so an element is nil when it's not a configuration.
+func integrationConfigs(value interface{}) []map[string]interface{} {
+	list, _ := value.([]interface{})
+	result := make([]map[string]interface{}, 0, len(list))
+	for _, item := range list {
+		config, _ := item.(map[string]interface{})
+		result = append(result, config)
+	}
+	return result
+}
+
+// removeSecrets removes every secret contained in the receivers so it won't be leaked.
+func removeSecrets(entity *v1.AlertmanagerConfig) {
+	for _, receiver := range entity.Spec.Receivers {
+		for integration, value := range receiver {
+			for _, config := range integrationConfigs(value) {
+				removeConfigSecrets(integration, config)
+			}
+		}
+	}
+}
+
+func removeConfigSecrets(integration string, config map[string]interface{}) {
+	for field, value := range config {
+		if isSecret(integration, field) {
+			delete(config, field)
+		} else if nested, ok := value.(map[string]interface{}); ok {
+			removeConfigSecrets(integration, nested)
+		}
+	}
+}
+
+// keepSecrets is filling the secrets that are not provided in the new version of the receivers with the one of the old version.
+// It is required since the secrets are never returned by the API and so a client cannot send them back when performing an update.
+// A secret is kept only for the configuration at the same position in the receiver of the same name, and only if the
+// fields it is bound to didn't change, otherwise it would be sent to another server than the one it was given for.
+// A secret can be removed by setting it explicitly with an empty value.
+func keepSecrets(newSpec *v1.AlertmanagerConfigSpec, oldSpec v1.AlertmanagerConfigSpec) {
+	oldReceivers := make(map[string]v1.AlertmanagerReceiver, len(oldSpec.Receivers))
+	for _, receiver := range oldSpec.Receivers {
+		oldReceivers[receiver.Name()] = receiver
+	}
+	for _, receiver := range newSpec.Receivers {
+		oldReceiver, ok := oldReceivers[receiver.Name()]
+		if !ok {
+			continue
+		}
+		for integration, value := range receiver {
+			oldConfigs := integrationConfigs(oldReceiver[integration])
+			for i, config := range integrationConfigs(value) {
+				if config != nil && i < len(oldConfigs) && oldConfigs[i] != nil {
+					keepConfigSecrets(integration, config, oldConfigs[i])
+				}
+			}
+		}
+	}
+}
+
+func keepConfigSecrets(integration string, config map[string]interface{}, oldConfig map[string]interface{}) {
+	for _, field := range boundFields {
+		if !isSecret(integration, field) && !reflect.DeepEqual(config[field], oldConfig[field]) {
+			return
+		}
+	}
+	for field, oldValue := range oldConfig {
+		value, exists := config[field]
+		if isSecret(integration, field) {
+			if !exists {
+				config[field] = oldValue
+			}
+			continue
+		}
+		nested, ok := value.(map[string]interface{})
+		oldNested, oldOk := oldValue.(map[string]interface{})
+		if ok && oldOk {
+			keepConfigSecrets(integration, nested, oldNested)
+		}
+	}
+}
+
+type service struct {
+	alertmanagerconfig.Service
+	dao        alertmanagerconfig.DAO
+	projectDAO project.DAO
+}
+
+func NewService(dao alertmanagerconfig.DAO, projectDAO project.DAO) alertmanagerconfig.Service {
+	return &service{
+		dao:        dao,
+		projectDAO: projectDAO,
+	}
+}
+
+func (s *service) Create(entity api.Entity) (interface{}, error) {
+	if configObject, ok := entity.(*v1.AlertmanagerConfig); ok {
+		return s.create(configObject)
+	}
+	return nil, fmt.Errorf("%w: wrong entity format, attempting alertmanagerConfig format, received '%T'", shared.BadRequestError, entity)
+}
+
+func (s *service) create(entity *v1.AlertmanagerConfig) (*v1.AlertmanagerConfig, error) {
+	if err := projectImpl.CheckExists(s.projectDAO, entity.Metadata.Project); err != nil {
+		return nil, err
+	}
+	if err := checkConfig(entity); err != nil {
+		return nil, err
+	}
+	// Update the time contains in the entity
+	entity.Metadata.CreateNow()
+	if err := s.dao.Create(entity); err != nil {
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to create the alertmanagerConfig '%s'. It already exits", entity.Metadata.Name)
+			return nil, shared.ConflictError
+		}
+		logrus.WithError(err).Errorf("unable to perform the creation of the alertmanagerConfig '%s', something wrong with etcd", entity.Metadata.Name)
+		return nil, shared.InternalError
+	}
+	// once the alertmanagerConfig is stored, remove the secrets so they won't be leaked
+	removeSecrets(entity)
+	return entity, nil
+}
+
+func (s *service) Update(entity api.Entity, parameters shared.Parameters) (interface{}, error) {
+	if configObject, ok := entity.(*v1.AlertmanagerConfig); ok {
+		return s.update(configObject, parameters)
+	}
+	return nil, fmt.Errorf("%w: wrong entity format, attempting alertmanagerConfig format, received '%T'", shared.BadRequestError, entity)
+}
+
+func (s *service) update(entity *v1.AlertmanagerConfig, parameters shared.Parameters) (*v1.AlertmanagerConfig, error) {
+	if entity.Metadata.Name != parameters.Name {
+		logrus.Debugf("name in alertmanagerConfig '%s' and coming from the http request: '%s' doesn't match", entity.Metadata.Name, parameters.Name)
+		return nil, fmt.Errorf("%w: metadata.name and the name in the http path request doesn't match", shared.BadRequestError)
+	}
+	if len(entity.Metadata.Project) == 0 {
+		entity.Metadata.Project = parameters.Project
+	} else if entity.Metadata.Project != parameters.Project {
+		logrus.Debugf("project in alertmanagerConfig '%s' and coming from the http request: '%s' doesn't match", entity.Metadata.Project, parameters.Project)
+		return nil, fmt.Errorf("%w: metadata.project and the project name in the http path request doesn't match", shared.BadRequestError)
+	}
+	if err := projectImpl.CheckExists(s.projectDAO, entity.Metadata.Project); err != nil {
+		return nil, err
+	}
+	// find the previous version of the alertmanagerConfig.
+	// The DAO is used directly since the secrets of the previous version are needed.
+	oldObject, err := s.dao.Get(parameters.Project, parameters.Name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the alertmanagerConfig '%s'", parameters.Name)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to find the previous version of the alertmanagerConfig '%s', something wrong with etcd", parameters.Name)
+		return nil, shared.InternalError
+	}
+	// in case the secrets are not provided, the old ones should be kept.
+	// It's done before the check since the config isn't valid without its secrets.
+	keepSecrets(&entity.Spec, oldObject.Spec)
+	if err := checkConfig(entity); err != nil {
+		return nil, err
+	}
+	// update the immutable field of the newEntity with the old one
+	entity.Metadata.CreatedAt = oldObject.Metadata.CreatedAt
+	// update the field UpdatedAt with the new time
+	entity.Metadata.UpdatedAt = time.Now().UTC()
+	if err := s.dao.Update(entity); err != nil {
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to update the alertmanagerConfig '%s', it has been modified since the version given", entity.Metadata.Name)
+			return nil, shared.VersionConflictError
+		}
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to update the alertmanagerConfig '%s', it has been deleted in the meantime", entity.Metadata.Name)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to perform the update of the alertmanagerConfig '%s', something wrong with etcd", entity.Metadata.Name)
+		return nil, shared.InternalError
+	}
+	// once the alertmanagerConfig is stored, remove the secrets so they won't be leaked
+	removeSecrets(entity)
+	return entity, nil
+}
+
+func (s *service) Delete(parameters shared.Parameters) error {
+	if err := s.dao.Delete(parameters.Project, parameters.Name, parameters.Version); err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the alertmanagerConfig '%s'", parameters.Name)
+			return shared.NotFoundError
+		}
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to delete the alertmanagerConfig '%s', it has been modified since the version given", parameters.Name)
+			return shared.VersionConflictError
+		}
+		logrus.WithError(err).Errorf("unable to delete the alertmanagerConfig '%s', something wrong with etcd", parameters.Name)
+		return shared.InternalError
+	}
+	return nil
+}
+
+func (s *service) Get(parameters shared.Parameters) (interface{}, error) {
+	entity, err := s.dao.Get(parameters.Project, parameters.Name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the alertmanagerConfig '%s'", parameters.Name)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to find the previous version of the alertmanagerConfig '%s', something wrong with etcd", parameters.Name)
+		return nil, shared.InternalError
+	}
+	// remove the secrets so they won't be leaked
+	removeSecrets(entity)
+	return entity, nil
+}
+
+func (s *service) List(q etcd.Query, _ shared.Parameters) (interface{}, error) {
+	results, err := s.dao.List(q)
+	if err != nil {
+		return nil, err
+	}
+	// on each alertmanagerConfig found, let's remove the secrets so they won't be leaked
+	for _, result := range results {
+		removeSecrets(result)
+	}
+	return results, nil
+}
+
+// FilterWatched removes the secrets of the watched alertmanagerConfigs, like List does.
+func (s *service) FilterWatched(entity api.Entity, _ etcd.Query, _ shared.Parameters) bool {
+	configObject, ok := entity.(*v1.AlertmanagerConfig)
+	if ok {
+		removeSecrets(configObject)
+	}
+	return ok
+}
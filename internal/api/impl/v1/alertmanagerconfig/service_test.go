@@ -0,0 +1,154 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package alertmanagerconfig
+
+import (
+	"encoding/json"
+	"testing"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+const secretConfig = `
+kind: AlertmanagerConfig
+metadata:
+  name: oncall
+  project: perses
+spec:
+  route:
+    receiver: team
+  receivers:
+    - name: team
+      slack_configs:
+        - api_url: https://hooks.slack.com/services/secret
+          channel: '#perses'
+      webhook_configs:
+        - url: http://team.example.com/hook
+          http_config:
+            basic_auth:
+              username: perses
+              password: secret
+    - name: pager
+      pagerduty_configs:
+        - routing_key: secret
+          url: https://events.pagerduty.com/v2/enqueue
+`
+
+func marshalReceivers(t *testing.T, config *v1.AlertmanagerConfig) string {
+	data, err := json.Marshal(config.Spec.Receivers)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func integrationConfig(receiver v1.AlertmanagerReceiver, integration string) map[string]interface{} {
+	return integrationConfigs(receiver[integration])[0]
+}
+
+func basicAuth(receiver v1.AlertmanagerReceiver) map[string]interface{} {
+	httpConfig := integrationConfig(receiver, "webhook_configs")["http_config"].(map[string]interface{})
+	return httpConfig["basic_auth"].(map[string]interface{})
+}
+
+func TestRemoveSecrets(t *testing.T) {
+	config := unmarshalConfig(t, secretConfig)
+	removeSecrets(config)
+	assert.JSONEq(t, `[
+  {
+    "name": "team",
+    "slack_configs": [{"channel": "#perses"}],
+    "webhook_configs": [{"url": "http://team.example.com/hook", "http_config": {"basic_auth": {"username": "perses"}}}]
+  },
+  {
+    "name": "pager",
+    "pagerduty_configs": [{"url": "https://events.pagerduty.com/v2/enqueue"}]
+  }
+]`, marshalReceivers(t, config))
+}
+
+func TestKeepSecrets(t *testing.T) {
+	testSuite := []struct {
+		title    string
+		update   func(receivers []v1.AlertmanagerReceiver)
+		expected func(receivers []v1.AlertmanagerReceiver)
+	}{
+		{
+			title:    "secrets not provided are kept",
+			update:   func(_ []v1.AlertmanagerReceiver) {},
+			expected: func(_ []v1.AlertmanagerReceiver) {},
+		},
+		{
+			title: "a secret provided replaces the old one",
+			update: func(receivers []v1.AlertmanagerReceiver) {
+				integrationConfig(receivers[1], "pagerduty_configs")["routing_key"] = "new"
+			},
+			expected: func(receivers []v1.AlertmanagerReceiver) {
+				integrationConfig(receivers[1], "pagerduty_configs")["routing_key"] = "new"
+			},
+		},
+		{
+			title: "a secret set with an empty value is removed",
+			update: func(receivers []v1.AlertmanagerReceiver) {
+				basicAuth(receivers[0])["password"] = ""
+			},
+			expected: func(receivers []v1.AlertmanagerReceiver) {
+				basicAuth(receivers[0])["password"] = ""
+			},
+		},
+		{
+			title: "the secrets are not kept when the URL changes",
+			update: func(receivers []v1.AlertmanagerReceiver) {
+				integrationConfig(receivers[0], "webhook_configs")["url"] = "http://attacker.example.com/hook"
+			},
+			expected: func(receivers []v1.AlertmanagerReceiver) {
+				integrationConfig(receivers[0], "webhook_configs")["url"] = "http://attacker.example.com/hook"
+				delete(basicAuth(receivers[0]), "password")
+			},
+		},
+		{
+			title: "the password is not kept when the username changes",
+			update: func(receivers []v1.AlertmanagerReceiver) {
+				basicAuth(receivers[0])["username"] = "admin"
+			},
+			expected: func(receivers []v1.AlertmanagerReceiver) {
+				basicAuth(receivers[0])["username"] = "admin"
+				delete(basicAuth(receivers[0]), "password")
+			},
+		},
+		{
+			title: "the secrets are not kept when the receiver is renamed",
+			update: func(receivers []v1.AlertmanagerReceiver) {
+				receivers[1]["name"] = "oncall"
+			},
+			expected: func(receivers []v1.AlertmanagerReceiver) {
+				receivers[1]["name"] = "oncall"
+				delete(integrationConfig(receivers[1], "pagerduty_configs"), "routing_key")
+			},
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			oldConfig := unmarshalConfig(t, secretConfig)
+			newConfig := unmarshalConfig(t, secretConfig)
+			removeSecrets(newConfig)
+			test.update(newConfig.Spec.Receivers)
+			keepSecrets(&newConfig.Spec, oldConfig.Spec)
+			expected := unmarshalConfig(t, secretConfig)
+			test.expected(expected.Spec.Receivers)
+			assert.JSONEq(t, marshalReceivers(t, expected), marshalReceivers(t, newConfig))
+		})
+	}
+}
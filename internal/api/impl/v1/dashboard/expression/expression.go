@@ -0,0 +1,273 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package expression is providing the necessary function to verify the PromQL expressions used in a dashboard.
+//
+// An expression can contain variables (like `$instance`) that are only known when the dashboard is displayed.
+// So before parsing the expression, each variable is replaced by a placeholder that depends on where the variable is used:
+//
+// * in a range selector, in the step of a subquery or after the offset modifier, the placeholder is a duration.
+// * anywhere else, the placeholder is an identifier, or a number if the expression cannot be parsed with an identifier.
+//
+// The position of a syntax error is always given relatively to the original expression and not to the one with the placeholders.
+package expression
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql/parser"
+)
+
+const (
+	durationPlaceholder   = "5m"
+	identifierPlaceholder = "perses_variable"
+	numberPlaceholder     = "1"
+)
+
+var variableRegexp = regexp.MustCompile(`\$([a-zA-Z0-9_-]+)`)
+
+// Error is the error returned when an expression is not a valid PromQL expression.
+type Error struct {
+	// Path is the path of the field in the dashboard that contains the expression.
+	Path string
+	// Line and Column are the position of the error in the expression. They both start at 1.
+	Line   int
+	Column int
+	Err    error
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %d:%d: parse error: %s", e.Path, e.Line, e.Column, e.Err)
+}
+
+func (e *Error) Unwrap() error {
+	return e.Err
+}
+
+// Expression is a PromQL expression used in a dashboard.
+type Expression struct {
+	// Path is the path of the field in the dashboard that contains the expression.
+	Path string
+	// Datasource is the name of the datasource the expression is sent to.
+	Datasource string
+	Expr       string
+}
+
+// Expressions returns every PromQL expression used in the dashboard (the one of each line and the one of each query variable).
+// The variables come first, sorted by name, so the order is always the same.
+// The datasource of a line is the first one set, starting from the line, then the panel and finally the dashboard.
+func Expressions(spec v1.DashboardSpec) []Expression {
+	var result []Expression
+	names := make([]string, 0, len(spec.Variables))
+	for name := range spec.Variables {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	for _, name := range names {
+		if parameter, ok := spec.Variables[name].Parameter.(*v1.QueryVariableParameter); ok {
+			result = append(result, Expression{Path: fmt.Sprintf("spec.variables.%s.parameter.expr", name), Datasource: spec.Datasource, Expr: parameter.Expr})
+		}
+	}
+	for i, section := range spec.Sections {
+		for j, panel := range section.Panels {
+			panelDatasource := spec.Datasource
+			if len(panel.Datasource) > 0 {
+				panelDatasource = panel.Datasource
+			}
+			switch chart := panel.Chart.(type) {
+			case *v1.LineChart:
+				for k, line := range chart.Lines {
+					lineDatasource := panelDatasource
+					if len(line.Datasource) > 0 {
+						lineDatasource = line.Datasource
+					}
+					result = append(result, Expression{
+						Path:       fmt.Sprintf("spec.sections[%d].panels[%d].chart.lines[%d].expr", i, j, k),
+						Datasource: lineDatasource,
+						Expr:       line.Expr,
+					})
+				}
+			}
+		}
+	}
+	return result
+}
+
+// Check parses every PromQL expression used in the dashboard.
+// kinds gives the kind of the datasources used by the dashboard. An expression sent to a datasource that is not
+// a Prometheus one (like a LogQL expression sent to Loki) is not checked. When the kind of the datasource is unknown,
+// the expression is checked as a PromQL one.
+// It returns an *Error for the first expression that is not valid.
+func Check(spec v1.DashboardSpec, kinds map[string]v1.DatasourceKind) error {
+	for _, e := range Expressions(spec) {
+		if kind, ok := kinds[e.Datasource]; ok && kind != v1.KindPrometheusDatasource {
+			continue
+		}
+		if err := parse(e.Expr, e.Path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Metrics returns the names of the metrics selected by the expression, sorted and without duplicates.
+// A metric selected through a variable (like `$metric{job="api"}`) is ignored since its name is only known when the dashboard is displayed.
+func Metrics(expr string) ([]string, error) {
+	var firstErr error
+	for _, placeholder := range []string{identifierPlaceholder, numberPlaceholder} {
+		replacedExpr, _ := replaceVariables(expr, placeholder)
+		node, err := parser.ParseExpr(replacedExpr)
+		if err != nil {
+			if firstErr == nil {
+				firstErr = err
+			}
+			continue
+		}
+		return metricNames(node), nil
+	}
+	return nil, firstErr
+}
+
+func metricNames(node parser.Node) []string {
+	names := make(map[string]bool)
+	parser.Inspect(node, func(node parser.Node, _ []parser.Node) error {
+		selector, ok := node.(*parser.VectorSelector)
+		if !ok {
+			return nil
+		}
+		name := selector.Name
+		if len(name) == 0 {
+			for _, matcher := range selector.LabelMatchers {
+				if matcher.Name == labels.MetricName && matcher.Type == labels.MatchEqual {
+					name = matcher.Value
+				}
+			}
+		}
+		if len(name) > 0 && name != identifierPlaceholder {
+			names[name] = true
+		}
+		return nil
+	})
+	result := make([]string, 0, len(names))
+	for name := range names {
+		result = append(result, name)
+	}
+	sort.Strings(result)
+	return result
+}
+
+func parse(expr string, path string) error {
+	var firstErr error
+	for _, placeholder := range []string{identifierPlaceholder, numberPlaceholder} {
+		replacedExpr, shifts := replaceVariables(expr, placeholder)
+		_, err := parser.ParseExpr(replacedExpr)
+		if err == nil {
+			return nil
+		}
+		if firstErr == nil {
+			firstErr = newError(expr, path, err, shifts)
+		}
+	}
+	return firstErr
+}
+
+func newError(expr string, path string, err error, shifts []shift) error {
+	parseErrors, ok := err.(parser.ParseErrors)
+	if !ok || len(parseErrors) == 0 {
+		return &Error{Path: path, Line: 1, Column: 1, Err: err}
+	}
+	parseErr := parseErrors[0]
+	line, column := position(expr, originalPosition(int(parseErr.PositionRange.Start), shifts))
+	return &Error{Path: path, Line: line, Column: column, Err: parseErr.Err}
+}
+
+// shift records that the variable starting at the position start in the original expression
+// has been replaced by a placeholder of a different length.
+type shift struct {
+	start          int
+	originalLength int
+	newLength      int
+}
+
+func replaceVariables(expr string, placeholder string) (string, []shift) {
+	var builder strings.Builder
+	var shifts []shift
+	last := 0
+	for _, match := range variableRegexp.FindAllStringIndex(expr, -1) {
+		start, end := match[0], match[1]
+		value := placeholder
+		if isDuration(expr[:start], expr[end:]) {
+			value = durationPlaceholder
+		}
+		builder.WriteString(expr[last:start])
+		builder.WriteString(value)
+		shifts = append(shifts, shift{start: start, originalLength: end - start, newLength: len(value)})
+		last = end
+	}
+	builder.WriteString(expr[last:])
+	return builder.String(), shifts
+}
+
+// isDuration returns true if the variable between the string before and after is used where PromQL is expecting a duration.
+func isDuration(before string, after string) bool {
+	before = strings.TrimRightFunc(before, isSpace)
+	after = strings.TrimLeftFunc(after, isSpace)
+	if strings.HasSuffix(before, "[") || strings.HasSuffix(before, "offset") {
+		return true
+	}
+	return strings.HasSuffix(before, ":") && strings.HasPrefix(after, "]")
+}
+
+func isSpace(r rune) bool {
+	return r == ' ' || r == '\t' || r == '\n' || r == '\r'
+}
+
+// originalPosition translates a position in the expression containing the placeholders to the position in the original expression.
+func originalPosition(pos int, shifts []shift) int {
+	delta := 0
+	for _, s := range shifts {
+		newStart := s.start + delta
+		if pos < newStart {
+			break
+		}
+		if pos < newStart+s.newLength {
+			// the error is located inside the placeholder, so it's pointing to the variable itself.
+			return s.start
+		}
+		delta += s.newLength - s.originalLength
+	}
+	return pos - delta
+}
+
+// position returns the line and the column of the character at the position pos in the expression.
+func position(expr string, pos int) (int, int) {
+	if pos < 0 {
+		pos = 0
+	} else if pos > len(expr) {
+		pos = len(expr)
+	}
+	line := 1
+	lastLineBreak := -1
+	for i, c := range expr[:pos] {
+		if c == '\n' {
+			lastLineBreak = i
+			line++
+		}
+	}
+	return line, pos - lastLineBreak
+}
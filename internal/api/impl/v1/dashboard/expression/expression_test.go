@@ -0,0 +1,217 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package expression
+
+import (
+	"errors"
+	"testing"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func newSpec(variableExpr string, lineExpr string) v1.DashboardSpec {
+	return v1.DashboardSpec{
+		Datasource: "PrometheusDemo",
+		Variables: map[string]v1.DashboardVariable{
+			"instance": {
+				Kind: v1.KindQueryVariable,
+				Parameter: &v1.QueryVariableParameter{
+					Expr: variableExpr,
+				},
+			},
+			"interval": {
+				Kind: v1.KindConstantVariable,
+				Parameter: &v1.ConstantVariableParameter{
+					Values: []string{"5m", "1h"},
+				},
+			},
+		},
+		Sections: []v1.DashboardSection{
+			{
+				Panels: []v1.Panel{
+					{
+						Name: "myPanel",
+						Chart: &v1.LineChart{
+							Kind: v1.KindLineChart,
+							Lines: []v1.Line{
+								{Expr: "up"},
+								{Expr: lineExpr},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestCheck(t *testing.T) {
+	testSuite := []struct {
+		title        string
+		variableExpr string
+		lineExpr     string
+	}{
+		{
+			title:        "no variable used",
+			variableExpr: "group by (instance) (up)",
+			lineExpr:     "sum(rate(http_requests_total[5m]))",
+		},
+		{
+			title:        "variable used as a label value",
+			variableExpr: "up",
+			lineExpr:     "rate(http_requests_total{instance='$instance'}[5m])",
+		},
+		{
+			title:        "variable used as a metric name and a label name",
+			variableExpr: "up",
+			lineExpr:     "sum by ($instance) ($instance)",
+		},
+		{
+			title:        "variable used as a duration",
+			variableExpr: "up",
+			lineExpr:     "rate(http_requests_total[$interval] offset $interval)",
+		},
+		{
+			title:        "variable used as the step of a subquery",
+			variableExpr: "up",
+			lineExpr:     "max_over_time(rate(http_requests_total[5m])[1h:$interval])",
+		},
+		{
+			title:        "variable used as a scalar",
+			variableExpr: "up",
+			lineExpr:     "topk($instance, up)",
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			assert.NoError(t, Check(newSpec(test.variableExpr, test.lineExpr), nil))
+		})
+	}
+}
+
+func TestCheckError(t *testing.T) {
+	testSuite := []struct {
+		title        string
+		variableExpr string
+		lineExpr     string
+		path         string
+		line         int
+		column       int
+	}{
+		{
+			title:        "invalid variable expression",
+			variableExpr: "sum(up",
+			lineExpr:     "up",
+			path:         "spec.variables.instance.parameter.expr",
+			line:         1,
+			column:       7,
+		},
+		{
+			title:        "invalid line expression",
+			variableExpr: "up",
+			lineExpr:     "rate(up[5m]",
+			path:         "spec.sections[0].panels[0].chart.lines[1].expr",
+			line:         1,
+			column:       12,
+		},
+		{
+			title:        "position is not shifted by the variable replaced",
+			variableExpr: "up",
+			lineExpr:     "rate(http_requests_total{instance='$instance'}[5m]) +",
+			path:         "spec.sections[0].panels[0].chart.lines[1].expr",
+			line:         1,
+			column:       54,
+		},
+		{
+			title:        "multiline expression",
+			variableExpr: "up",
+			lineExpr:     "sum(\n  rate(up[5m]))\n)",
+			path:         "spec.sections[0].panels[0].chart.lines[1].expr",
+			line:         3,
+			column:       2,
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			err := Check(newSpec(test.variableExpr, test.lineExpr), nil)
+			var exprErr *Error
+			if assert.True(t, errors.As(err, &exprErr)) {
+				assert.Equal(t, test.path, exprErr.Path)
+				assert.Equal(t, test.line, exprErr.Line)
+				assert.Equal(t, test.column, exprErr.Column)
+			}
+		})
+	}
+}
+
+func TestCheckLogQLLine(t *testing.T) {
+	spec := newSpec("up", "up")
+	panel := &spec.Sections[0].Panels[0]
+	panel.Chart.(*v1.LineChart).Lines[1] = v1.Line{Datasource: "LokiDemo", Expr: `sum(rate({job="api"} |= "error" [5m]))`}
+	kinds := map[string]v1.DatasourceKind{
+		"PrometheusDemo": v1.KindPrometheusDatasource,
+		"LokiDemo":       v1.KindLokiDatasource,
+	}
+	assert.NoError(t, Check(spec, kinds))
+	// the datasource of the panel applies to its lines
+	panel.Chart.(*v1.LineChart).Lines[1].Datasource = ""
+	panel.Datasource = "LokiDemo"
+	assert.NoError(t, Check(spec, kinds))
+	// the LogQL expression is rejected once sent to Prometheus
+	panel.Datasource = ""
+	var exprErr *Error
+	assert.True(t, errors.As(Check(spec, kinds), &exprErr))
+}
+
+func TestMetrics(t *testing.T) {
+	testSuite := []struct {
+		title   string
+		expr    string
+		metrics []string
+	}{
+		{
+			title:   "single metric",
+			expr:    "up",
+			metrics: []string{"up"},
+		},
+		{
+			title:   "several metrics sorted and without duplicates",
+			expr:    "sum(rate(http_requests_total[5m])) / sum(rate(http_requests_total[5m] offset 1d)) > on() job:errors:rate5m",
+			metrics: []string{"http_requests_total", "job:errors:rate5m"},
+		},
+		{
+			title:   "metric selected with the label __name__",
+			expr:    "{__name__='up', job='api'}",
+			metrics: []string{"up"},
+		},
+		{
+			title:   "variables",
+			expr:    "rate(http_requests_total{instance='$instance'}[$interval]) + $metric",
+			metrics: []string{"http_requests_total"},
+		},
+		{
+			title:   "no metric",
+			expr:    "vector(1)",
+			metrics: []string{},
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			metrics, err := Metrics(test.expr)
+			assert.NoError(t, err)
+			assert.Equal(t, test.metrics, metrics)
+		})
+	}
+}
@@ -18,8 +18,13 @@ import (
 	"time"
 
 	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/impl/v1/dashboard/expression"
 	"github.com/perses/perses/internal/api/impl/v1/dashboard/variable"
+	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
+	projectImpl "github.com/perses/perses/internal/api/impl/v1/project"
 	"github.com/perses/perses/internal/api/interface/v1/dashboard"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/project"
 	"github.com/perses/perses/internal/api/shared"
 	"github.com/perses/perses/pkg/model/api"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
@@ -28,12 +33,16 @@ import (
 
 type service struct {
 	dashboard.Service
-	dao dashboard.DAO
+	dao           dashboard.DAO
+	projectDAO    project.DAO
+	datasourceDAO datasource.DAO
 }
 
-func NewService(dao dashboard.DAO) dashboard.Service {
+func NewService(dao dashboard.DAO, projectDAO project.DAO, datasourceDAO datasource.DAO) dashboard.Service {
 	return &service{
-		dao: dao,
+		dao:           dao,
+		projectDAO:    projectDAO,
+		datasourceDAO: datasourceDAO,
 	}
 }
 
@@ -45,13 +54,19 @@ func (s *service) Create(entity api.Entity) (interface{}, error) {
 }
 
 func (s *service) create(entity *v1.Dashboard) (*v1.Dashboard, error) {
-	// Note: you don't need to check that the project exists since once the permission middleware will be in place,
-	// it won't be possible to create a resources into a not known project
-
+	if err := s.checkReferences(entity); err != nil {
+		return nil, err
+	}
 	// verify it's possible to calculate the build order for the variable.
 	if err := variable.Check(entity.Spec.Variables); err != nil {
 		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
 	}
+	// verify every PromQL expression is syntactically correct
+	if err := s.checkExpressions(entity); err != nil {
+		return nil, err
+	}
+	// only the server can generate a managed dashboard
+	entity.Metadata.ManagedBy = ""
 	// Update the time contains in the entity
 	entity.Metadata.CreateNow()
 	if err := s.dao.Create(entity); err != nil {
@@ -65,6 +80,67 @@ func (s *service) create(entity *v1.Dashboard) (*v1.Dashboard, error) {
 	return entity, nil
 }
 
+// checkReferences verifies the project of the dashboard and the datasources it uses exist.
+func (s *service) checkReferences(entity *v1.Dashboard) error {
+	if err := projectImpl.CheckExists(s.projectDAO, entity.Metadata.Project); err != nil {
+		return err
+	}
+	for _, name := range datasources(entity.Spec) {
+		if err := datasourceImpl.CheckExists(s.datasourceDAO, entity.Metadata.Project, name); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// datasources returns the names of the datasources used by the dashboard, the panels and the lines, without duplicates.
+// A panel that doesn't have lines, like a LogsChart, sends its query to the datasource of the panel.
+func datasources(spec v1.DashboardSpec) []string {
+	result := []string{spec.Datasource}
+	found := map[string]bool{spec.Datasource: true}
+	add := func(name string) {
+		if len(name) > 0 && !found[name] {
+			found[name] = true
+			result = append(result, name)
+		}
+	}
+	for _, section := range spec.Sections {
+		for _, panel := range section.Panels {
+			add(panel.Datasource)
+			if chart, ok := panel.Chart.(*v1.LineChart); ok {
+				for _, line := range chart.Lines {
+					add(line.Datasource)
+				}
+			}
+		}
+	}
+	return result
+}
+
+// checkExpressions verifies the syntax of the expressions sent to the Prometheus datasources of the dashboard.
+func (s *service) checkExpressions(entity *v1.Dashboard) error {
+	kinds := make(map[string]v1.DatasourceKind)
+	for _, e := range expression.Expressions(entity.Spec) {
+		if _, ok := kinds[e.Datasource]; ok {
+			continue
+		}
+		dts, err := datasourceImpl.Find(s.datasourceDAO, entity.Metadata.Project, e.Datasource)
+		if err != nil {
+			if etcd.IsKeyNotFound(err) {
+				// the expression is checked as a PromQL one
+				continue
+			}
+			logrus.WithError(err).Errorf("unable to find the datasource '%s', something wrong with etcd", e.Datasource)
+			return shared.InternalError
+		}
+		kinds[e.Datasource] = dts.Spec.Kind
+	}
+	if err := expression.Check(entity.Spec, kinds); err != nil {
+		return fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
+	return nil
+}
+
 func (s *service) Update(entity api.Entity, parameters shared.Parameters) (interface{}, error) {
 	if dashboardObject, ok := entity.(*v1.Dashboard); ok {
 		return s.update(dashboardObject, parameters)
@@ -83,21 +159,40 @@ func (s *service) update(entity *v1.Dashboard, parameters shared.Parameters) (*v
 		logrus.Debugf("project in dashboard '%s' and coming from the http request: '%s' doesn't match", entity.Metadata.Project, parameters.Project)
 		return nil, fmt.Errorf("%w: metadata.project and the project name in the http path request doesn't match", shared.BadRequestError)
 	}
+	if err := s.checkReferences(entity); err != nil {
+		return nil, err
+	}
 	// verify it's possible to calculate the build order for the variable.
 	if err := variable.Check(entity.Spec.Variables); err != nil {
 		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
 	}
+	// verify every PromQL expression is syntactically correct
+	if err := s.checkExpressions(entity); err != nil {
+		return nil, err
+	}
 	// find the previous version of the dashboard
 	oldEntity, err := s.Get(parameters)
 	if err != nil {
 		return nil, err
 	}
 	oldObject := oldEntity.(*v1.Dashboard)
+	if err := shared.CheckNotManaged(oldObject.Metadata); err != nil {
+		return nil, err
+	}
+	entity.Metadata.ManagedBy = ""
 	// update the immutable field of the newEntity with the old one
 	entity.Metadata.CreatedAt = oldObject.Metadata.CreatedAt
 	// update the field UpdatedAt with the new time
 	entity.Metadata.UpdatedAt = time.Now().UTC()
 	if err := s.dao.Update(entity); err != nil {
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to update the dashboard '%s', it has been modified since the version given", entity.Metadata.Name)
+			return nil, shared.VersionConflictError
+		}
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to update the dashboard '%s', it has been deleted in the meantime", entity.Metadata.Name)
+			return nil, shared.NotFoundError
+		}
 		logrus.WithError(err).Errorf("unable to perform the update of the dashboard '%s', something wrong with etcd", entity.Metadata.Name)
 		return nil, shared.InternalError
 	}
@@ -105,11 +200,28 @@ func (s *service) update(entity *v1.Dashboard, parameters shared.Parameters) (*v
 }
 
 func (s *service) Delete(parameters shared.Parameters) error {
-	if err := s.dao.Delete(parameters.Project, parameters.Name); err != nil {
+	oldEntity, err := s.Get(parameters)
+	if err != nil {
+		return err
+	}
+	oldObject := oldEntity.(*v1.Dashboard)
+	if err := shared.CheckNotManaged(oldObject.Metadata); err != nil {
+		return err
+	}
+	version := parameters.Version
+	if version == 0 {
+		// delete the version checked, so it cannot have become managed in the meantime
+		version = oldObject.Metadata.Version
+	}
+	if err := s.dao.Delete(parameters.Project, parameters.Name, version); err != nil {
 		if etcd.IsKeyNotFound(err) {
 			logrus.Debugf("unable to find the project '%s'", parameters.Name)
 			return shared.NotFoundError
 		}
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to delete the dashboard '%s', it has been modified since the version given", parameters.Name)
+			return shared.VersionConflictError
+		}
 		logrus.WithError(err).Errorf("unable to delete the project '%s', something wrong with etcd", parameters.Name)
 		return shared.InternalError
 	}
@@ -0,0 +1,271 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+)
+
+const (
+	lokiResultTypeStreams = "streams"
+	// maxLokiErrorSize is the maximum number of bytes of the error message returned by Loki that is kept.
+	maxLokiErrorSize = 1024
+)
+
+type lokiResponse struct {
+	Status string          `json:"status"`
+	Data   json.RawMessage `json:"data"`
+}
+
+type lokiQueryData struct {
+	ResultType string          `json:"resultType"`
+	Result     json.RawMessage `json:"result"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	// Values is the list of the log lines of the stream. Each value is a pair timestamp in nanosecond / log line.
+	Values [][2]string `json:"values"`
+}
+
+// loki is the Plugin used to contact a Loki datasource.
+// On top of the log lines, Loki is able to return metrics computed from the logs with a LogQL metric query.
+type loki struct {
+	url          *url.URL
+	client       *http.Client
+	roundTripper *datasourceImpl.RoundTripper
+}
+
+func newLoki(spec v1.DatasourceSpec) (Plugin, error) {
+	roundTripper, err := datasourceImpl.NewRoundTripper(spec)
+	if err != nil {
+		return nil, err
+	}
+	return &loki{
+		url:          spec.URL,
+		client:       &http.Client{Transport: roundTripper},
+		roundTripper: roundTripper,
+	}, nil
+}
+
+func formatLokiTime(t time.Time) string {
+	return strconv.FormatInt(t.UnixNano(), 10)
+}
+
+func (l *loki) QueryRange(ctx context.Context, query string, r Range) (model.Value, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatLokiTime(r.Start))
+	params.Set("end", formatLokiTime(r.End))
+	params.Set("step", strconv.FormatFloat(r.Step.Seconds(), 'f', -1, 64))
+	data := &lokiQueryData{}
+	if err := l.get(ctx, "/loki/api/v1/query_range", params, data); err != nil {
+		return nil, err
+	}
+	return decodeLokiValue(query, data)
+}
+
+func (l *loki) Query(ctx context.Context, query string, ts time.Time) (model.Value, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("time", formatLokiTime(ts))
+	data := &lokiQueryData{}
+	if err := l.get(ctx, "/loki/api/v1/query", params, data); err != nil {
+		return nil, err
+	}
+	return decodeLokiValue(query, data)
+}
+
+func (l *loki) QueryLogs(ctx context.Context, query string, start time.Time, end time.Time, limit uint64) ([]v1.LogLine, error) {
+	params := url.Values{}
+	params.Set("query", query)
+	params.Set("start", formatLokiTime(start))
+	params.Set("end", formatLokiTime(end))
+	params.Set("direction", "backward")
+	if limit > 0 {
+		params.Set("limit", strconv.FormatUint(limit, 10))
+	}
+	data := &lokiQueryData{}
+	if err := l.get(ctx, "/loki/api/v1/query_range", params, data); err != nil {
+		return nil, err
+	}
+	if data.ResultType != lokiResultTypeStreams {
+		return nil, fmt.Errorf("the query '%s' is not returning log lines but a result of type '%s'", query, data.ResultType)
+	}
+	var streams []lokiStream
+	if err := json.Unmarshal(data.Result, &streams); err != nil {
+		return nil, err
+	}
+	var lines []v1.LogLine
+	for _, stream := range streams {
+		for _, value := range stream.Values {
+			nanoseconds, err := strconv.ParseInt(value[0], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid timestamp '%s' returned by Loki: %s", value[0], err)
+			}
+			lines = append(lines, v1.LogLine{
+				Timestamp: time.Unix(0, nanoseconds).UTC(),
+				Labels:    stream.Stream,
+				Line:      value[1],
+			})
+		}
+	}
+	// Loki is only sorting the log lines inside each stream.
+	sort.SliceStable(lines, func(i, j int) bool {
+		return lines[i].Timestamp.After(lines[j].Timestamp)
+	})
+	return lines, nil
+}
+
+func (l *loki) LabelNames(ctx context.Context, matchers []string, start time.Time, end time.Time) ([]string, error) {
+	if len(matchers) == 0 {
+		var names []string
+		err := l.get(ctx, "/loki/api/v1/labels", timeRangeParams(start, end), &names)
+		return names, err
+	}
+	series, err := l.series(ctx, matchers, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return collect(series, func(labels map[string]string, add func(string)) {
+		for name := range labels {
+			add(name)
+		}
+	}), nil
+}
+
+func (l *loki) LabelValues(ctx context.Context, label string, matchers []string, start time.Time, end time.Time) ([]string, error) {
+	if len(matchers) == 0 {
+		var values []string
+		err := l.get(ctx, fmt.Sprintf("/loki/api/v1/label/%s/values", url.PathEscape(label)), timeRangeParams(start, end), &values)
+		return values, err
+	}
+	series, err := l.series(ctx, matchers, start, end)
+	if err != nil {
+		return nil, err
+	}
+	return collect(series, func(labels map[string]string, add func(string)) {
+		if value, ok := labels[label]; ok {
+			add(value)
+		}
+	}), nil
+}
+
+func (l *loki) Close() {
+	l.roundTripper.CloseIdleConnections()
+}
+
+// series returns the label set of each stream matching at least one of the matchers.
+// It is used to discover the labels since the labels endpoints of Loki don't accept any matcher.
+func (l *loki) series(ctx context.Context, matchers []string, start time.Time, end time.Time) ([]map[string]string, error) {
+	params := timeRangeParams(start, end)
+	for _, matcher := range matchers {
+		params.Add("match[]", matcher)
+	}
+	var series []map[string]string
+	err := l.get(ctx, "/loki/api/v1/series", params, &series)
+	return series, err
+}
+
+func (l *loki) get(ctx context.Context, path string, params url.Values, data interface{}) error {
+	u := *l.url
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	u.RawQuery = params.Encode()
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return err
+	}
+	response, err := l.client.Do(request)
+	if err != nil {
+		return err
+	}
+	defer response.Body.Close()
+	if response.StatusCode != http.StatusOK {
+		// Loki is returning the error as plain text
+		body, _ := ioutil.ReadAll(&io.LimitedReader{R: response.Body, N: maxLokiErrorSize})
+		return fmt.Errorf("loki returned the status code %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+	result := &lokiResponse{}
+	if err := json.NewDecoder(response.Body).Decode(result); err != nil {
+		return err
+	}
+	if result.Status != "success" {
+		return fmt.Errorf("loki returned the status '%s'", result.Status)
+	}
+	return json.Unmarshal(result.Data, data)
+}
+
+// timeRangeParams returns the parameters for the given time range. A zero time is not sent so Loki uses its default value.
+func timeRangeParams(start time.Time, end time.Time) url.Values {
+	params := url.Values{}
+	if !start.IsZero() {
+		params.Set("start", formatLokiTime(start))
+	}
+	if !end.IsZero() {
+		params.Set("end", formatLokiTime(end))
+	}
+	return params
+}
+
+// collect returns the sorted list of the distinct strings added by the function extract for each label set.
+func collect(series []map[string]string, extract func(labels map[string]string, add func(string))) []string {
+	alreadyAdded := make(map[string]bool)
+	result := make([]string, 0)
+	for _, labels := range series {
+		extract(labels, func(s string) {
+			if !alreadyAdded[s] {
+				alreadyAdded[s] = true
+				result = append(result, s)
+			}
+		})
+	}
+	sort.Strings(result)
+	return result
+}
+
+// decodeLokiValue decodes the result of a LogQL metric query. Loki is using the same format as Prometheus for the metrics.
+func decodeLokiValue(query string, data *lokiQueryData) (model.Value, error) {
+	switch data.ResultType {
+	case model.ValMatrix.String():
+		var matrix model.Matrix
+		err := json.Unmarshal(data.Result, &matrix)
+		return matrix, err
+	case model.ValVector.String():
+		var vector model.Vector
+		err := json.Unmarshal(data.Result, &vector)
+		return vector, err
+	case model.ValScalar.String():
+		scalar := &model.Scalar{}
+		err := json.Unmarshal(data.Result, scalar)
+		return scalar, err
+	case lokiResultTypeStreams:
+		return nil, fmt.Errorf("the query '%s' is returning log lines and not metrics", query)
+	default:
+		return nil, fmt.Errorf("unknown result type '%s' returned by Loki", data.ResultType)
+	}
+}
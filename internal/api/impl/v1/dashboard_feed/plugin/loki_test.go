@@ -0,0 +1,142 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func newLokiServer(t *testing.T) (*httptest.Server, v1.DatasourceSpec) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+			return
+		}
+		switch r.URL.Path {
+		case "/loki/api/v1/query_range":
+			switch r.FormValue("query") {
+			case `{job="api"}`:
+				if len(r.FormValue("step")) == 0 {
+					// the log lines are requested
+					assert.Equal(t, "backward", r.FormValue("direction"))
+					assert.Equal(t, "10", r.FormValue("limit"))
+				}
+				_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[` +
+					`{"stream":{"job":"api","level":"info"},"values":[["1600000003000000000","request served"],["1600000001000000000","starting"]]},` +
+					`{"stream":{"job":"api","level":"error"},"values":[["1600000002000000000","connection refused"]]}]}}`))
+			case `count_over_time({job="api"}[5m])`:
+				_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"job":"api"},"values":[[1600000000,"3"]]}]}}`))
+			default:
+				w.WriteHeader(http.StatusBadRequest)
+				_, _ = w.Write([]byte("parse error : syntax error: unexpected IDENTIFIER\n"))
+			}
+		case "/loki/api/v1/labels":
+			_, _ = w.Write([]byte(`{"status":"success","data":["job","level"]}`))
+		case "/loki/api/v1/series":
+			assert.Equal(t, []string{`{level="error"}`}, r.Form["match[]"])
+			_, _ = w.Write([]byte(`{"status":"success","data":[{"job":"api","level":"error"},{"job":"db","level":"error"}]}`))
+		default:
+			t.Errorf("unexpected path '%s'", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, v1.DatasourceSpec{Kind: v1.KindLokiDatasource, URL: u}
+}
+
+func TestLokiQueryLogs(t *testing.T) {
+	server, spec := newLokiServer(t)
+	defer server.Close()
+	p, err := New(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	end := time.Now()
+	lines, err := p.(LogsPlugin).QueryLogs(context.Background(), `{job="api"}`, end.Add(-time.Hour), end, 10)
+	assert.NoError(t, err)
+	assert.Equal(t, []v1.LogLine{
+		{
+			Timestamp: time.Unix(1600000003, 0).UTC(),
+			Labels:    map[string]string{"job": "api", "level": "info"},
+			Line:      "request served",
+		},
+		{
+			Timestamp: time.Unix(1600000002, 0).UTC(),
+			Labels:    map[string]string{"job": "api", "level": "error"},
+			Line:      "connection refused",
+		},
+		{
+			Timestamp: time.Unix(1600000001, 0).UTC(),
+			Labels:    map[string]string{"job": "api", "level": "info"},
+			Line:      "starting",
+		},
+	}, lines)
+}
+
+func TestLokiQueryLogsError(t *testing.T) {
+	server, spec := newLokiServer(t)
+	defer server.Close()
+	p, err := New(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	end := time.Now()
+	_, err = p.(LogsPlugin).QueryLogs(context.Background(), `{job="api"} |=`, end.Add(-time.Hour), end, 0)
+	assert.EqualError(t, err, "loki returned the status code 400: parse error : syntax error: unexpected IDENTIFIER")
+	_, err = p.(LogsPlugin).QueryLogs(context.Background(), `count_over_time({job="api"}[5m])`, end.Add(-time.Hour), end, 0)
+	assert.EqualError(t, err, `the query 'count_over_time({job="api"}[5m])' is not returning log lines but a result of type 'matrix'`)
+}
+
+func TestLokiMetrics(t *testing.T) {
+	server, spec := newLokiServer(t)
+	defer server.Close()
+	p, err := New(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	ctx := context.Background()
+	end := time.Now()
+	start := end.Add(-time.Hour)
+
+	result, err := p.QueryRange(ctx, `count_over_time({job="api"}[5m])`, Range{Start: start, End: end, Step: time.Minute})
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.ValMatrix, result.Type())
+	}
+	_, err = p.QueryRange(ctx, `{job="api"}`, Range{Start: start, End: end, Step: time.Minute})
+	assert.Error(t, err)
+
+	names, err := p.LabelNames(ctx, nil, start, end)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"job", "level"}, names)
+	}
+	values, err := p.LabelValues(ctx, "job", []string{`{level="error"}`}, start, end)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"api", "db"}, values)
+	}
+}
@@ -0,0 +1,82 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package plugin is defining the interface a datasource must implement to be used by the feed service.
+// Supporting a new kind of datasource only requires to implement the interface Plugin and to add its Constructor to the map constructors.
+package plugin
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+)
+
+// Range is the time range and the resolution used to evaluate a range query.
+type Range struct {
+	Start time.Time
+	End   time.Time
+	Step  time.Duration
+}
+
+// Plugin is the interface a datasource must implement to be queried by the feed service.
+type Plugin interface {
+	// QueryRange evaluates the query over the time range given.
+	QueryRange(ctx context.Context, query string, r Range) (model.Value, error)
+	// Query evaluates the query at the given time.
+	Query(ctx context.Context, query string, ts time.Time) (model.Value, error)
+	// LabelNames returns the label names of the series matching at least one of the matchers on the given time range.
+	// When no matcher is given, every series is considered.
+	LabelNames(ctx context.Context, matchers []string, start time.Time, end time.Time) ([]string, error)
+	// LabelValues returns the values of the label for the series matching at least one of the matchers on the given time range.
+	// When no matcher is given, every series is considered.
+	LabelValues(ctx context.Context, label string, matchers []string, start time.Time, end time.Time) ([]string, error)
+	// Close releases the resources (like the idle connections) used by the plugin.
+	// It must be called once the plugin is not used anymore.
+	Close()
+}
+
+// LogsPlugin is the interface implemented by the datasources that are able to return log lines.
+type LogsPlugin interface {
+	Plugin
+	// QueryLogs returns the log lines selected by the query on the time range given, the most recent first.
+	// When limit is 0, the default limit of the datasource is used.
+	QueryLogs(ctx context.Context, query string, start time.Time, end time.Time, limit uint64) ([]v1.LogLine, error)
+}
+
+// MetadataPlugin is the interface implemented by the datasources that are able to describe their metrics.
+type MetadataPlugin interface {
+	Plugin
+	// Metadata returns the metadata of the metrics grouped by metric name.
+	// When metric is empty, the metadata of every metric is returned.
+	Metadata(ctx context.Context, metric string) (map[string][]v1.MetricMetadata, error)
+}
+
+// Constructor creates the Plugin that will contact the datasource described by the spec.
+type Constructor func(spec v1.DatasourceSpec) (Plugin, error)
+
+var constructors = map[v1.DatasourceKind]Constructor{
+	v1.KindPrometheusDatasource: newPrometheus,
+	v1.KindLokiDatasource:       newLoki,
+}
+
+// New returns the Plugin matching the kind of the datasource.
+func New(spec v1.DatasourceSpec) (Plugin, error) {
+	constructor, ok := constructors[spec.Kind]
+	if !ok {
+		return nil, fmt.Errorf("datasource kind '%s' is not supported", spec.Kind)
+	}
+	return constructor(spec)
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+func newPrometheusServer(t *testing.T) (*httptest.Server, v1.DatasourceSpec) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/api/v1/query_range":
+			assert.Equal(t, "up", r.FormValue("query"))
+			assert.Equal(t, "60", r.FormValue("step"))
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"matrix","result":[{"metric":{"__name__":"up"},"values":[[1600000000,"1"]]}]}}`))
+		case "/api/v1/query":
+			assert.Equal(t, "up", r.FormValue("query"))
+			_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"vector","result":[{"metric":{"__name__":"up"},"value":[1600000000,"1"]}]}}`))
+		case "/api/v1/labels":
+			assert.Equal(t, []string{"up"}, r.Form["match[]"])
+			_, _ = w.Write([]byte(`{"status":"success","data":["__name__","instance"]}`))
+		case "/api/v1/label/instance/values":
+			_, _ = w.Write([]byte(`{"status":"success","data":["localhost:9090"]}`))
+		default:
+			t.Errorf("unexpected path '%s'", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return server, v1.DatasourceSpec{Kind: v1.KindPrometheusDatasource, URL: u}
+}
+
+func TestPrometheus(t *testing.T) {
+	server, spec := newPrometheusServer(t)
+	defer server.Close()
+	p, err := New(spec)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer p.Close()
+	ctx := context.Background()
+	end := time.Now()
+	start := end.Add(-time.Hour)
+
+	rangeResult, err := p.QueryRange(ctx, "up", Range{Start: start, End: end, Step: time.Minute})
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.ValMatrix, rangeResult.Type())
+	}
+	instantResult, err := p.Query(ctx, "up", end)
+	if assert.NoError(t, err) {
+		assert.Equal(t, model.ValVector, instantResult.Type())
+	}
+	names, err := p.LabelNames(ctx, []string{"up"}, start, end)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"__name__", "instance"}, names)
+	}
+	values, err := p.LabelValues(ctx, "instance", nil, start, end)
+	if assert.NoError(t, err) {
+		assert.Equal(t, []string{"localhost:9090"}, values)
+	}
+}
+
+func TestNewUnknownKind(t *testing.T) {
+	_, err := New(v1.DatasourceSpec{Kind: "unknown"})
+	assert.Error(t, err)
+}
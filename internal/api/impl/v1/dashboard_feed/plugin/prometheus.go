@@ -0,0 +1,104 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package plugin
+
+import (
+	"context"
+	"time"
+
+	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	prometheusAPI "github.com/prometheus/client_golang/api"
+	prometheusAPIV1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+)
+
+// prometheus is the Plugin used to contact a Prometheus datasource.
+// It can also be used for any datasource exposing the Prometheus HTTP API.
+type prometheus struct {
+	client       prometheusAPIV1.API
+	roundTripper *datasourceImpl.RoundTripper
+}
+
+func newPrometheus(spec v1.DatasourceSpec) (Plugin, error) {
+	roundTripper, err := datasourceImpl.NewRoundTripper(spec)
+	if err != nil {
+		return nil, err
+	}
+	client, err := prometheusAPI.NewClient(prometheusAPI.Config{
+		Address:      spec.URL.String(),
+		RoundTripper: roundTripper,
+	})
+	if err != nil {
+		roundTripper.CloseIdleConnections()
+		return nil, err
+	}
+	return &prometheus{
+		client:       prometheusAPIV1.NewAPI(client),
+		roundTripper: roundTripper,
+	}, nil
+}
+
+func (p *prometheus) QueryRange(ctx context.Context, query string, r Range) (model.Value, error) {
+	result, _, err := p.client.QueryRange(ctx, query, prometheusAPIV1.Range{
+		Start: r.Start,
+		End:   r.End,
+		Step:  r.Step,
+	})
+	return result, err
+}
+
+func (p *prometheus) Query(ctx context.Context, query string, ts time.Time) (model.Value, error) {
+	result, _, err := p.client.Query(ctx, query, ts)
+	return result, err
+}
+
+func (p *prometheus) LabelNames(ctx context.Context, matchers []string, start time.Time, end time.Time) ([]string, error) {
+	result, _, err := p.client.LabelNames(ctx, matchers, start, end)
+	return result, err
+}
+
+func (p *prometheus) LabelValues(ctx context.Context, label string, matchers []string, start time.Time, end time.Time) ([]string, error) {
+	values, _, err := p.client.LabelValues(ctx, label, matchers, start, end)
+	if err != nil {
+		return nil, err
+	}
+	result := make([]string, 0, len(values))
+	for _, value := range values {
+		result = append(result, string(value))
+	}
+	return result, nil
+}
+
+func (p *prometheus) Metadata(ctx context.Context, metric string) (map[string][]v1.MetricMetadata, error) {
+	metadata, err := p.client.Metadata(ctx, metric, "")
+	if err != nil {
+		return nil, err
+	}
+	result := make(map[string][]v1.MetricMetadata, len(metadata))
+	for name, list := range metadata {
+		for _, m := range list {
+			result[name] = append(result[name], v1.MetricMetadata{
+				Type: string(m.Type),
+				Help: m.Help,
+				Unit: m.Unit,
+			})
+		}
+	}
+	return result, nil
+}
+
+func (p *prometheus) Close() {
+	p.roundTripper.CloseIdleConnections()
+}
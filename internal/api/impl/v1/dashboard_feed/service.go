@@ -2,37 +2,43 @@ package dashboard_feed
 
 import (
 	"context"
-	"errors"
 	"fmt"
-	"net/url"
 	"strings"
 	"time"
 
 	"github.com/perses/common/async"
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/impl/v1/dashboard_feed/plugin"
+	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
 	"github.com/perses/perses/internal/api/interface/v1/dashboard_feed"
 	"github.com/perses/perses/internal/api/interface/v1/datasource"
 	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/internal/api/shared/metrics"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
-	prometheusAPI "github.com/prometheus/client_golang/api"
-	prometheusAPIV1 "github.com/prometheus/client_golang/api/prometheus/v1"
 	"github.com/prometheus/common/model"
 	"github.com/sirupsen/logrus"
 )
 
-func prometheusQuery(variables map[string]string, query string, duration model.Duration, promClient prometheusAPIV1.API) func() interface{} {
+// replaceVariables replaces every variable used in the expression by its value.
+func replaceVariables(variables map[string]string, expr string) string {
+	for k, v := range variables {
+		expr = strings.Replace(expr, fmt.Sprintf("$%s", k), v, -1)
+	}
+	return expr
+}
+
+func query(variables map[string]string, expr string, duration model.Duration, datasourceName string, datasourcePlugin plugin.Plugin) func() interface{} {
 	return func() interface{} {
-		q := query
-		for k, v := range variables {
-			q = strings.Replace(q, fmt.Sprintf("$%s", k), v, -1)
-		}
+		q := replaceVariables(variables, expr)
 		end := time.Now()
 		start := end.Add(-time.Duration(duration))
 		logrus.Debugf("performing the http request with the query '%s'", q)
-		result, _, err := promClient.QueryRange(context.Background(), q, prometheusAPIV1.Range{
+		result, err := datasourcePlugin.QueryRange(context.Background(), q, plugin.Range{
 			Start: start,
 			End:   end,
 			Step:  time.Minute,
 		})
+		metrics.ObserveFeedQuery(datasourceName, end, err)
 		return &v1.PromQueryResult{
 			Err:    err,
 			Result: result,
@@ -40,38 +46,22 @@ func prometheusQuery(variables map[string]string, query string, duration model.D
 	}
 }
 
-func newPrometheusClient(url *url.URL) (prometheusAPIV1.API, error) {
-	promClient, err := prometheusAPI.NewClient(prometheusAPI.Config{
-		Address: url.String(),
-	})
-	if err != nil {
-		return nil, err
-	}
-	return prometheusAPIV1.NewAPI(promClient), nil
-}
-
-func NewService(datasourceService datasource.Service) dashboard_feed.Service {
-	return &service{datasourceService: datasourceService}
+func NewService(datasourceDAO datasource.DAO) dashboard_feed.Service {
+	return &service{datasourceDAO: datasourceDAO}
 }
 
 type service struct {
 	dashboard_feed.Service
-	datasourceService datasource.Service
+	// datasourceDAO is used instead of the datasource service since the secrets of the datasource are required to contact it.
+	datasourceDAO datasource.DAO
 }
 
 func (s *service) FeedSection(sectionRequest *v1.SectionFeedRequest) ([]v1.SectionFeedResponse, error) {
-	dtsObject, err := s.datasourceService.Get(shared.Parameters{Name: sectionRequest.Datasource})
-	if err != nil {
-		if errors.Is(err, shared.NotFoundError) {
-			return nil, fmt.Errorf("%w: datasource '%s' doesn't exist", shared.BadRequestError, sectionRequest.Datasource)
-		}
-	}
-	dts := dtsObject.(*v1.Datasource)
-	promClient, err := newPrometheusClient(dts.Spec.URL)
+	plugins, closePlugins, err := s.buildPlugins(sectionRequest)
 	if err != nil {
-		logrus.WithError(err).Errorf("unable to create the prometheus client with the url '%s'", dts.Spec.URL)
-		return nil, shared.InternalError
+		return nil, err
 	}
+	defer closePlugins()
 
 	var sectionResponses []v1.SectionFeedResponse
 	for _, section := range sectionRequest.Sections {
@@ -84,9 +74,11 @@ func (s *service) FeedSection(sectionRequest *v1.SectionFeedRequest) ([]v1.Secti
 			panelAsynchronousRequests = append(panelAsynchronousRequests,
 				async.Async(func(currentPanel v1.Panel) func() interface{} {
 					return func() interface{} {
-						switch chart := panel.Chart.(type) {
+						switch chart := currentPanel.Chart.(type) {
 						case *v1.LineChart:
-							return s.feedLineChart(sectionRequest, panel, chart, promClient)
+							return s.feedLineChart(sectionRequest, currentPanel, chart, plugins)
+						case *v1.LogsChart:
+							return s.feedLogsChart(sectionRequest, currentPanel, chart, plugins)
 						default:
 							return fmt.Errorf("this chart '%T' is not supported", chart)
 						}
@@ -106,15 +98,87 @@ func (s *service) FeedSection(sectionRequest *v1.SectionFeedRequest) ([]v1.Secti
 	return sectionResponses, nil
 }
 
-func (s *service) feedLineChart(sectionRequest *v1.SectionFeedRequest, currentPanel v1.Panel, chart *v1.LineChart, promClient prometheusAPIV1.API) *v1.PanelFeedResponse {
+// buildPlugins creates one plugin per datasource used in the request.
+// The datasource can be set at the level of the request, of a panel or of a line.
+// It returns a BadRequestError if one of them doesn't exist.
+// The function returned must be called once the plugins are not used anymore.
+func (s *service) buildPlugins(sectionRequest *v1.SectionFeedRequest) (map[string]plugin.Plugin, func(), error) {
+	plugins := make(map[string]plugin.Plugin)
+	closePlugins := func() {
+		for _, datasourcePlugin := range plugins {
+			datasourcePlugin.Close()
+		}
+	}
+	for _, name := range usedDatasources(sectionRequest) {
+		dts, err := datasourceImpl.Find(s.datasourceDAO, sectionRequest.Project, name)
+		if err != nil {
+			closePlugins()
+			if etcd.IsKeyNotFound(err) {
+				return nil, nil, fmt.Errorf("%w: datasource '%s' doesn't exist", shared.BadRequestError, name)
+			}
+			logrus.WithError(err).Errorf("unable to find the datasource '%s', something wrong with etcd", name)
+			return nil, nil, shared.InternalError
+		}
+		datasourcePlugin, err := plugin.New(dts.Spec)
+		if err != nil {
+			closePlugins()
+			logrus.WithError(err).Errorf("unable to create the plugin for the datasource '%s'", name)
+			return nil, nil, shared.InternalError
+		}
+		plugins[name] = datasourcePlugin
+	}
+	return plugins, closePlugins, nil
+}
+
+// usedDatasources returns the name of every datasource used in the request, without duplicate.
+func usedDatasources(sectionRequest *v1.SectionFeedRequest) []string {
+	names := []string{sectionRequest.Datasource}
+	alreadyUsed := map[string]bool{sectionRequest.Datasource: true}
+	add := func(name string) {
+		if len(name) > 0 && !alreadyUsed[name] {
+			alreadyUsed[name] = true
+			names = append(names, name)
+		}
+	}
+	for _, section := range sectionRequest.Sections {
+		for _, panel := range section.Panels {
+			add(panel.Datasource)
+			if chart, ok := panel.Chart.(*v1.LineChart); ok {
+				for _, line := range chart.Lines {
+					add(line.Datasource)
+				}
+			}
+		}
+	}
+	return names
+}
+
+// datasourceName returns the first datasource set, starting from the line, then the panel and finally the request.
+func datasourceName(sectionRequest *v1.SectionFeedRequest, panel v1.Panel, line v1.Line) string {
+	if len(line.Datasource) > 0 {
+		return line.Datasource
+	}
+	return panelDatasourceName(sectionRequest, panel)
+}
+
+// panelDatasourceName returns the datasource of the panel if it is set, the one of the request otherwise.
+func panelDatasourceName(sectionRequest *v1.SectionFeedRequest, panel v1.Panel) string {
+	if len(panel.Datasource) > 0 {
+		return panel.Datasource
+	}
+	return sectionRequest.Datasource
+}
+
+func (s *service) feedLineChart(sectionRequest *v1.SectionFeedRequest, currentPanel v1.Panel, chart *v1.LineChart, plugins map[string]plugin.Plugin) *v1.PanelFeedResponse {
 	panelAnswer := &v1.PanelFeedResponse{
 		Name:  currentPanel.Name,
 		Order: currentPanel.Order,
 	}
 	asynchronousRequests := make([]async.Future, 0, len(chart.Lines))
 	for _, line := range chart.Lines {
+		name := datasourceName(sectionRequest, currentPanel, line)
 		asynchronousRequests = append(asynchronousRequests,
-			async.Async(prometheusQuery(sectionRequest.Variables, line.Expr, sectionRequest.Duration, promClient)),
+			async.Async(query(sectionRequest.Variables, line.Expr, sectionRequest.Duration, name, plugins[name])),
 		)
 	}
 
@@ -122,9 +186,36 @@ func (s *service) feedLineChart(sectionRequest *v1.SectionFeedRequest, currentPa
 		object := request.Await()
 		queryResult := object.(*v1.PromQueryResult)
 		if queryResult.Err != nil {
-			logrus.WithError(queryResult.Err).Error("Error occurred when contacting the prometheus server")
+			logrus.WithError(queryResult.Err).Error("Error occurred when contacting the datasource")
 		}
 		panelAnswer.Results = append(panelAnswer.Results, *queryResult)
 	}
 	return panelAnswer
 }
+
+func (s *service) feedLogsChart(sectionRequest *v1.SectionFeedRequest, currentPanel v1.Panel, chart *v1.LogsChart, plugins map[string]plugin.Plugin) *v1.PanelFeedResponse {
+	panelAnswer := &v1.PanelFeedResponse{
+		Name:  currentPanel.Name,
+		Order: currentPanel.Order,
+		Logs:  &v1.LogsQueryResult{},
+	}
+	name := panelDatasourceName(sectionRequest, currentPanel)
+	logsPlugin, ok := plugins[name].(plugin.LogsPlugin)
+	if !ok {
+		panelAnswer.Logs.Err = fmt.Errorf("datasource '%s' is not able to return log lines", name)
+		return panelAnswer
+	}
+	end := time.Now()
+	start := end.Add(-time.Duration(sectionRequest.Duration))
+	q := replaceVariables(sectionRequest.Variables, chart.Query)
+	logrus.Debugf("performing the http request with the log query '%s'", q)
+	lines, err := logsPlugin.QueryLogs(context.Background(), q, start, end, chart.Limit)
+	metrics.ObserveFeedQuery(name, end, err)
+	if err != nil {
+		logrus.WithError(err).Error("Error occurred when contacting the datasource")
+		panelAnswer.Logs.Err = err
+		return panelAnswer
+	}
+	panelAnswer.Logs.Lines = lines
+	return panelAnswer
+}
@@ -0,0 +1,148 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package dashboard_feed
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDatasourceDAO struct {
+	datasource.DAO
+	datasources map[string]*v1.Datasource
+}
+
+func (f *fakeDatasourceDAO) Get(project string, name string) (*v1.Datasource, error) {
+	key := v1.GenerateDatasourceID(project, name)
+	if dts, ok := f.datasources[key]; ok {
+		return dts, nil
+	}
+	return nil, &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+}
+
+func newSectionFeedRequest() *v1.SectionFeedRequest {
+	return &v1.SectionFeedRequest{
+		Datasource: "global",
+		Sections: []v1.DashboardSection{
+			{
+				Panels: []v1.Panel{
+					{
+						Name: "defaultDatasource",
+						Chart: &v1.LineChart{
+							Lines: []v1.Line{
+								{Expr: "up"},
+							},
+						},
+					},
+					{
+						Name:       "panelDatasource",
+						Datasource: "europe",
+						Chart: &v1.LineChart{
+							Lines: []v1.Line{
+								{Expr: "up"},
+								{Expr: "up", Datasource: "asia"},
+								{Expr: "up", Datasource: "global"},
+							},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func TestUsedDatasources(t *testing.T) {
+	assert.Equal(t, []string{"global", "europe", "asia"}, usedDatasources(newSectionFeedRequest()))
+}
+
+func TestDatasourceName(t *testing.T) {
+	request := newSectionFeedRequest()
+	defaultPanel := request.Sections[0].Panels[0]
+	overriddenPanel := request.Sections[0].Panels[1]
+	overriddenLines := overriddenPanel.Chart.(*v1.LineChart).Lines
+
+	assert.Equal(t, "global", datasourceName(request, defaultPanel, defaultPanel.Chart.(*v1.LineChart).Lines[0]))
+	assert.Equal(t, "europe", datasourceName(request, overriddenPanel, overriddenLines[0]))
+	assert.Equal(t, "asia", datasourceName(request, overriddenPanel, overriddenLines[1]))
+	assert.Equal(t, "global", datasourceName(request, overriddenPanel, overriddenLines[2]))
+}
+
+func TestFeedSectionLogs(t *testing.T) {
+	lokiServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "/loki/api/v1/query_range", r.URL.Path)
+		assert.Equal(t, `{job="api"} |= "error"`, r.FormValue("query"))
+		start, _ := strconv.ParseInt(r.FormValue("start"), 10, 64)
+		end, _ := strconv.ParseInt(r.FormValue("end"), 10, 64)
+		assert.Equal(t, int64(time.Hour), end-start)
+		_, _ = w.Write([]byte(`{"status":"success","data":{"resultType":"streams","result":[` +
+			`{"stream":{"job":"api"},"values":[["1600000000000000000","error: connection refused"]]}]}}`))
+	}))
+	defer lokiServer.Close()
+	u, err := url.Parse(lokiServer.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(&fakeDatasourceDAO{
+		datasources: map[string]*v1.Datasource{
+			"/datasources/prometheus": {Spec: v1.DatasourceSpec{Kind: v1.KindPrometheusDatasource, URL: u}},
+			"/datasources/loki":       {Spec: v1.DatasourceSpec{Kind: v1.KindLokiDatasource, URL: u}},
+		},
+	})
+	request := &v1.SectionFeedRequest{
+		Datasource: "prometheus",
+		Duration:   model.Duration(time.Hour),
+		Variables:  map[string]string{"job": "api"},
+		Sections: []v1.DashboardSection{
+			{
+				Panels: []v1.Panel{
+					{
+						Name:       "logs",
+						Datasource: "loki",
+						Chart:      &v1.LogsChart{Kind: v1.KindLogsChart, Query: `{job="$job"} |= "error"`},
+					},
+					{
+						Name:  "notSupported",
+						Order: 1,
+						Chart: &v1.LogsChart{Kind: v1.KindLogsChart, Query: `{job="$job"}`},
+					},
+				},
+			},
+		},
+	}
+	response, err := s.FeedSection(request)
+	if !assert.NoError(t, err) {
+		return
+	}
+	panels := response[0].Panels
+	assert.Equal(t, &v1.LogsQueryResult{
+		Lines: []v1.LogLine{
+			{
+				Timestamp: time.Unix(1600000000, 0).UTC(),
+				Labels:    map[string]string{"job": "api"},
+				Line:      "error: connection refused",
+			},
+		},
+	}, panels[0].Logs)
+	assert.EqualError(t, panels[1].Logs.Err, "datasource 'prometheus' is not able to return log lines")
+}
@@ -0,0 +1,99 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+const connectionTimeout = 30 * time.Second
+
+// NewTLSConfig builds the TLS configuration used to contact a datasource.
+// It returns an error if one of the certificates or the key is not valid.
+func NewTLSConfig(config *v1.TLSConfig) (*tls.Config, error) {
+	tlsConfig := &tls.Config{}
+	if config == nil {
+		return tlsConfig, nil
+	}
+	tlsConfig.InsecureSkipVerify = config.InsecureSkipVerify // nolint: gosec
+	if len(config.CA) > 0 {
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM([]byte(config.CA)) {
+			return nil, fmt.Errorf("unable to parse the certificate authority contained in tls_config.ca")
+		}
+		tlsConfig.RootCAs = pool
+	}
+	if len(config.Cert) > 0 || len(config.Key) > 0 {
+		certificate, err := tls.X509KeyPair([]byte(config.Cert), []byte(config.Key))
+		if err != nil {
+			return nil, fmt.Errorf("unable to load the client certificate from tls_config.cert and tls_config.key: %s", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{certificate}
+	}
+	return tlsConfig, nil
+}
+
+// NewRoundTripper returns the http.RoundTripper to use to contact the datasource.
+// On top of the TLS configuration, it is adding the custom headers and the credentials to every request.
+func NewRoundTripper(spec v1.DatasourceSpec) (*RoundTripper, error) {
+	tlsConfig, err := NewTLSConfig(spec.TLSConfig)
+	if err != nil {
+		return nil, err
+	}
+	return &RoundTripper{
+		spec: spec,
+		transport: &http.Transport{
+			Proxy: http.ProxyFromEnvironment,
+			DialContext: (&net.Dialer{
+				Timeout:   connectionTimeout,
+				KeepAlive: 30 * time.Second,
+			}).DialContext,
+			TLSHandshakeTimeout:   10 * time.Second,
+			ResponseHeaderTimeout: connectionTimeout,
+			TLSClientConfig:       tlsConfig,
+		},
+	}, nil
+}
+
+type RoundTripper struct {
+	spec      v1.DatasourceSpec
+	transport *http.Transport
+}
+
+func (r *RoundTripper) RoundTrip(request *http.Request) (*http.Response, error) {
+	// a RoundTripper must not modify the request, so let's work on a copy.
+	request = request.Clone(request.Context())
+	for key, value := range r.spec.Headers {
+		request.Header.Set(key, value)
+	}
+	if r.spec.BasicAuth != nil {
+		request.SetBasicAuth(r.spec.BasicAuth.Username, r.spec.BasicAuth.Password)
+	} else if len(r.spec.BearerToken) > 0 {
+		request.Header.Set("Authorization", fmt.Sprintf("Bearer %s", r.spec.BearerToken))
+	}
+	return r.transport.RoundTrip(request)
+}
+
+// CloseIdleConnections closes the connections kept alive by the transport.
+// It should be called once the RoundTripper is not used anymore.
+func (r *RoundTripper) CloseIdleConnections() {
+	r.transport.CloseIdleConnections()
+}
@@ -14,21 +14,20 @@
 package datasource
 
 import (
-	"time"
-
 	"github.com/perses/common/etcd"
 	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/shared/database"
+	"github.com/perses/perses/internal/api/shared/metrics"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 type dao struct {
 	datasource.DAO
-	client etcd.DAO
+	client database.DAO
 }
 
-func NewDAO(etcdClient *clientv3.Client, timeout time.Duration) datasource.DAO {
-	client := etcd.NewDAO(etcdClient, timeout)
+func NewDAO(persistence database.DAO) datasource.DAO {
+	client := metrics.NewInstrumentedDAO(persistence, string(v1.KindDatasource))
 	return &dao{
 		client: client,
 	}
@@ -41,16 +40,16 @@ func (d *dao) Create(entity *v1.Datasource) error {
 
 func (d *dao) Update(entity *v1.Datasource) error {
 	key := entity.GenerateID()
-	return d.client.Upsert(key, entity)
+	return d.client.Upsert(key, entity, entity.Metadata.Version)
 }
 
-func (d *dao) Delete(name string) error {
-	key := v1.GenerateDatasourceID(name)
-	return d.client.Delete(key)
+func (d *dao) Delete(project string, name string, version uint64) error {
+	key := v1.GenerateDatasourceID(project, name)
+	return d.client.Delete(key, version)
 }
 
-func (d *dao) Get(name string) (*v1.Datasource, error) {
-	key := v1.GenerateDatasourceID(name)
+func (d *dao) Get(project string, name string) (*v1.Datasource, error) {
+	key := v1.GenerateDatasourceID(project, name)
 	entity := &v1.Datasource{}
 	return entity, d.client.Get(key, entity)
 }
@@ -18,21 +18,68 @@ import (
 	"time"
 
 	"github.com/perses/common/etcd"
+	projectImpl "github.com/perses/perses/internal/api/impl/v1/project"
 	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/project"
 	"github.com/perses/perses/internal/api/shared"
 	"github.com/perses/perses/pkg/model/api"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
 	"github.com/sirupsen/logrus"
 )
 
+// removeSecrets removes every secret contained in the datasource so it won't be leaked.
+func removeSecrets(entity *v1.Datasource) {
+	if entity.Spec.BasicAuth != nil {
+		entity.Spec.BasicAuth.Password = ""
+	}
+	entity.Spec.BearerToken = ""
+	// the headers can carry credentials, only their names are returned
+	for name := range entity.Spec.Headers {
+		entity.Spec.Headers[name] = ""
+	}
+	if entity.Spec.TLSConfig != nil {
+		entity.Spec.TLSConfig.Key = ""
+	}
+}
+
+// keepSecrets is filling the secrets that are not provided in the new version of the datasource with the one of the old version.
+// It is required since the secrets are never returned by the API and so a client cannot send them back when performing an update.
+// A secret is kept only if the information it is associated to didn't change.
+// None of them are kept when the URL changes, otherwise they would be sent to another server than the one they were given for.
+func keepSecrets(newSpec *v1.DatasourceSpec, oldSpec v1.DatasourceSpec) {
+	if newSpec.URL == nil || oldSpec.URL == nil || newSpec.URL.String() != oldSpec.URL.String() {
+		return
+	}
+	if newSpec.BasicAuth != nil && len(newSpec.BasicAuth.Password) == 0 &&
+		oldSpec.BasicAuth != nil && oldSpec.BasicAuth.Username == newSpec.BasicAuth.Username {
+		newSpec.BasicAuth.Password = oldSpec.BasicAuth.Password
+	}
+	// the token can be removed by setting explicitly an empty bearer_token
+	if newSpec.BasicAuth == nil && oldSpec.BasicAuth == nil &&
+		len(newSpec.BearerToken) == 0 && !newSpec.IsBearerTokenCleared() {
+		newSpec.BearerToken = oldSpec.BearerToken
+	}
+	for name, value := range newSpec.Headers {
+		if len(value) == 0 {
+			newSpec.Headers[name] = oldSpec.Headers[name]
+		}
+	}
+	if newSpec.TLSConfig != nil && len(newSpec.TLSConfig.Key) == 0 &&
+		oldSpec.TLSConfig != nil && oldSpec.TLSConfig.Cert == newSpec.TLSConfig.Cert {
+		newSpec.TLSConfig.Key = oldSpec.TLSConfig.Key
+	}
+}
+
 type service struct {
 	datasource.Service
-	dao datasource.DAO
+	dao        datasource.DAO
+	projectDAO project.DAO
 }
 
-func NewService(dao datasource.DAO) datasource.Service {
+func NewService(dao datasource.DAO, projectDAO project.DAO) datasource.Service {
 	return &service{
-		dao: dao,
+		dao:        dao,
+		projectDAO: projectDAO,
 	}
 }
 
@@ -44,6 +91,13 @@ func (s *service) Create(entity api.Entity) (interface{}, error) {
 }
 
 func (s *service) create(entity *v1.Datasource) (*v1.Datasource, error) {
+	if err := s.checkProject(entity); err != nil {
+		return nil, err
+	}
+	// check the TLS configuration can be loaded
+	if _, err := NewTLSConfig(entity.Spec.TLSConfig); err != nil {
+		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
 	// Update the time contains in the entity
 	entity.Metadata.CreateNow()
 	if err := s.dao.Create(entity); err != nil {
@@ -54,9 +108,19 @@ func (s *service) create(entity *v1.Datasource) (*v1.Datasource, error) {
 		logrus.WithError(err).Errorf("unable to perform the creation of the Datasource '%s', something wrong with etcd", entity.Metadata.Name)
 		return nil, shared.InternalError
 	}
+	// once the datasource is stored, remove the secrets so they won't be leaked
+	removeSecrets(entity)
 	return entity, nil
 }
 
+// checkProject verifies the project of the datasource exists, unless it's a global datasource.
+func (s *service) checkProject(entity *v1.Datasource) error {
+	if len(entity.Metadata.Project) == 0 {
+		return nil
+	}
+	return projectImpl.CheckExists(s.projectDAO, entity.Metadata.Project)
+}
+
 func (s *service) Update(entity api.Entity, parameters shared.Parameters) (interface{}, error) {
 	if DatasourceObject, ok := entity.(*v1.Datasource); ok {
 		return s.update(DatasourceObject, parameters)
@@ -69,29 +133,63 @@ func (s *service) update(entity *v1.Datasource, parameters shared.Parameters) (*
 		logrus.Debugf("name in Datasource '%s' and coming from the http request: '%s' doesn't match", entity.Metadata.Name, parameters.Name)
 		return nil, fmt.Errorf("%w: metadata.name and the name in the http path request doesn't match", shared.BadRequestError)
 	}
-	// find the previous version of the Datasource
-	oldEntity, err := s.Get(parameters)
-	if err != nil {
+	if len(entity.Metadata.Project) == 0 {
+		entity.Metadata.Project = parameters.Project
+	} else if entity.Metadata.Project != parameters.Project {
+		logrus.Debugf("project in Datasource '%s' and coming from the http request: '%s' doesn't match", entity.Metadata.Project, parameters.Project)
+		return nil, fmt.Errorf("%w: metadata.project and the project name in the http path request doesn't match", shared.BadRequestError)
+	}
+	if err := s.checkProject(entity); err != nil {
 		return nil, err
 	}
-	oldObject := oldEntity.(*v1.Datasource)
+	// find the previous version of the Datasource.
+	// The DAO is used directly since the secrets of the previous version are needed.
+	oldObject, err := s.dao.Get(parameters.Project, parameters.Name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the Datasource '%s'", parameters.Name)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to find the previous version of the Datasource '%s', something wrong with etcd", parameters.Name)
+		return nil, shared.InternalError
+	}
+	// in case the secrets are not provided, the old ones should be kept
+	keepSecrets(&entity.Spec, oldObject.Spec)
+	// check the TLS configuration can be loaded
+	if _, err := NewTLSConfig(entity.Spec.TLSConfig); err != nil {
+		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
 	// update the immutable field of the newEntity with the old one
 	entity.Metadata.CreatedAt = oldObject.Metadata.CreatedAt
 	// update the field UpdatedAt with the new time
 	entity.Metadata.UpdatedAt = time.Now().UTC()
 	if err := s.dao.Update(entity); err != nil {
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to update the Datasource '%s', it has been modified since the version given", entity.Metadata.Name)
+			return nil, shared.VersionConflictError
+		}
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to update the Datasource '%s', it has been deleted in the meantime", entity.Metadata.Name)
+			return nil, shared.NotFoundError
+		}
 		logrus.WithError(err).Errorf("unable to perform the update of the Datasource '%s', something wrong with etcd", entity.Metadata.Name)
 		return nil, shared.InternalError
 	}
+	// once the datasource is stored, remove the secrets so they won't be leaked
+	removeSecrets(entity)
 	return entity, nil
 }
 
 func (s *service) Delete(parameters shared.Parameters) error {
-	if err := s.dao.Delete(parameters.Name); err != nil {
+	if err := s.dao.Delete(parameters.Project, parameters.Name, parameters.Version); err != nil {
 		if etcd.IsKeyNotFound(err) {
 			logrus.Debugf("unable to find the Datasource '%s'", parameters.Name)
 			return shared.NotFoundError
 		}
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to delete the Datasource '%s', it has been modified since the version given", parameters.Name)
+			return shared.VersionConflictError
+		}
 		logrus.WithError(err).Errorf("unable to delete the Datasource '%s', something wrong with etcd", parameters.Name)
 		return shared.InternalError
 	}
@@ -99,7 +197,7 @@ func (s *service) Delete(parameters shared.Parameters) error {
 }
 
 func (s *service) Get(parameters shared.Parameters) (interface{}, error) {
-	entity, err := s.dao.Get(parameters.Name)
+	entity, err := s.dao.Get(parameters.Project, parameters.Name)
 	if err != nil {
 		if etcd.IsKeyNotFound(err) {
 			logrus.Debugf("unable to find the Datasource '%s'", parameters.Name)
@@ -108,9 +206,78 @@ func (s *service) Get(parameters shared.Parameters) (interface{}, error) {
 		logrus.WithError(err).Errorf("unable to find the previous version of the Datasource '%s', something wrong with etcd", parameters.Name)
 		return nil, shared.InternalError
 	}
+	// remove the secrets so they won't be leaked
+	removeSecrets(entity)
 	return entity, nil
 }
 
-func (s *service) List(q etcd.Query, _ shared.Parameters) (interface{}, error) {
-	return s.dao.List(q)
+func (s *service) List(q etcd.Query, parameters shared.Parameters) (interface{}, error) {
+	project := parameters.Project
+	if query, ok := q.(*datasource.Query); ok {
+		if len(query.Project) == 0 {
+			query.Project = project
+		}
+		project = query.Project
+	}
+	results, err := s.dao.List(q)
+	if err != nil {
+		return nil, err
+	}
+	// The global datasources are stored using the same prefix as the datasources of the projects.
+	// So we have to keep only the datasources that belong to the project requested (or the global ones when there is no project).
+	// Then on each datasource kept, let's remove the secrets so they won't be leaked.
+	filteredResults := make([]*v1.Datasource, 0, len(results))
+	for _, result := range results {
+		if result.Metadata.Project != project {
+			continue
+		}
+		removeSecrets(result)
+		filteredResults = append(filteredResults, result)
+	}
+	return filteredResults, nil
+}
+
+// FilterWatched applies to the watched datasources the same filter as List, and removes their secrets.
+func (s *service) FilterWatched(entity api.Entity, q etcd.Query, parameters shared.Parameters) bool {
+	result, ok := entity.(*v1.Datasource)
+	if !ok {
+		return false
+	}
+	project := parameters.Project
+	if query, ok := q.(*datasource.Query); ok && len(query.Project) > 0 {
+		project = query.Project
+	}
+	if result.Metadata.Project != project {
+		return false
+	}
+	removeSecrets(result)
+	return true
+}
+
+// Find returns the datasource to use when the name is referenced from the given project.
+// The datasource defined in the project is used in priority, then the global one.
+// The project can be empty to look only for a global datasource.
+// It returns the error of the DAO if the datasource doesn't exist.
+func Find(dao datasource.DAO, project string, name string) (*v1.Datasource, error) {
+	if len(project) > 0 {
+		dts, err := dao.Get(project, name)
+		if err == nil || !etcd.IsKeyNotFound(err) {
+			return dts, err
+		}
+	}
+	return dao.Get("", name)
+}
+
+// CheckExists returns a bad request error when the datasource referenced from the project cannot be found,
+// neither in the project nor in the global datasources.
+func CheckExists(dao datasource.DAO, project string, name string) error {
+	if _, err := Find(dao, project, name); err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the datasource '%s' from the project '%s'", name, project)
+			return fmt.Errorf("%w: datasource '%s' doesn't exist in the project '%s' nor globally", shared.BadRequestError, name, project)
+		}
+		logrus.WithError(err).Errorf("unable to find the datasource '%s', something wrong with etcd", name)
+		return shared.InternalError
+	}
+	return nil
 }
@@ -0,0 +1,239 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDAO struct {
+	datasource.DAO
+	datasources map[string]*v1.Datasource
+}
+
+func newFakeDAO(datasources ...*v1.Datasource) *fakeDAO {
+	f := &fakeDAO{datasources: make(map[string]*v1.Datasource)}
+	for _, dts := range datasources {
+		f.datasources[dts.GenerateID()] = dts
+	}
+	return f
+}
+
+func (f *fakeDAO) Get(project string, name string) (*v1.Datasource, error) {
+	key := v1.GenerateDatasourceID(project, name)
+	if dts, ok := f.datasources[key]; ok {
+		return dts, nil
+	}
+	return nil, &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+}
+
+func (f *fakeDAO) List(q etcd.Query) ([]*v1.Datasource, error) {
+	prefix, err := q.Build()
+	if err != nil {
+		return nil, err
+	}
+	var result []*v1.Datasource
+	for key, dts := range f.datasources {
+		if strings.HasPrefix(key, prefix) {
+			result = append(result, dts)
+		}
+	}
+	return result, nil
+}
+
+func newDatasource(project string, name string) *v1.Datasource {
+	return &v1.Datasource{
+		Kind: v1.KindDatasource,
+		Metadata: v1.OptionalProjectMetadata{
+			Metadata: v1.Metadata{Name: name},
+			Project:  project,
+		},
+		Spec: v1.DatasourceSpec{
+			Kind:      v1.KindPrometheusDatasource,
+			BasicAuth: &v1.BasicAuth{Username: "admin", Password: "secret"},
+			Headers:   map[string]string{"X-Api-Key": "key"},
+		},
+	}
+}
+
+func TestFind(t *testing.T) {
+	dao := newFakeDAO(
+		newDatasource("", "global"),
+		newDatasource("", "overridden"),
+		newDatasource("perses", "overridden"),
+		newDatasource("perses", "local"),
+	)
+	testSuite := []struct {
+		title           string
+		project         string
+		name            string
+		expectedProject string
+	}{
+		{
+			title:           "global datasource",
+			project:         "",
+			name:            "global",
+			expectedProject: "",
+		},
+		{
+			title:           "global datasource used from a project",
+			project:         "perses",
+			name:            "global",
+			expectedProject: "",
+		},
+		{
+			title:           "project datasource overriding the global one",
+			project:         "perses",
+			name:            "overridden",
+			expectedProject: "perses",
+		},
+		{
+			title:           "global datasource used from another project",
+			project:         "other",
+			name:            "overridden",
+			expectedProject: "",
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			dts, err := Find(dao, test.project, test.name)
+			if assert.NoError(t, err) {
+				assert.Equal(t, test.name, dts.Metadata.Name)
+				assert.Equal(t, test.expectedProject, dts.Metadata.Project)
+			}
+		})
+	}
+	// a datasource of a project is never visible from the global datasources
+	_, err := Find(dao, "", "local")
+	assert.True(t, etcd.IsKeyNotFound(err))
+}
+
+func TestList(t *testing.T) {
+	s := NewService(newFakeDAO(
+		newDatasource("", "global"),
+		newDatasource("perses", "local"),
+	), nil)
+	result, err := s.List(&datasource.Query{}, shared.Parameters{})
+	if assert.NoError(t, err) {
+		datasources := result.([]*v1.Datasource)
+		if assert.Len(t, datasources, 1) {
+			assert.Equal(t, "global", datasources[0].Metadata.Name)
+			// the secrets are never returned
+			assert.Empty(t, datasources[0].Spec.BasicAuth.Password)
+			assert.Equal(t, map[string]string{"X-Api-Key": ""}, datasources[0].Spec.Headers)
+		}
+	}
+	result, err = s.List(&datasource.Query{}, shared.Parameters{Project: "perses"})
+	if assert.NoError(t, err) {
+		datasources := result.([]*v1.Datasource)
+		if assert.Len(t, datasources, 1) {
+			assert.Equal(t, "local", datasources[0].Metadata.Name)
+		}
+	}
+}
+
+func newSpec(t *testing.T, rawURL string, data string) v1.DatasourceSpec {
+	spec := v1.DatasourceSpec{}
+	if err := json.Unmarshal([]byte(fmt.Sprintf(`{"url":%q,%s}`, rawURL, data)), &spec); err != nil {
+		t.Fatal(err)
+	}
+	return spec
+}
+
+func TestKeepSecrets(t *testing.T) {
+	const oldURL = "https://prometheus.demo.do.prometheus.io"
+	testSuite := []struct {
+		title    string
+		old      string
+		new      string
+		url      string
+		expected string
+	}{
+		{
+			title:    "password kept",
+			old:      `"basic_auth":{"username":"admin","password":"secret"}`,
+			new:      `"basic_auth":{"username":"admin"}`,
+			url:      oldURL,
+			expected: `"basic_auth":{"username":"admin","password":"secret"}`,
+		},
+		{
+			title:    "password dropped when the username changes",
+			old:      `"basic_auth":{"username":"admin","password":"secret"}`,
+			new:      `"basic_auth":{"username":"user"}`,
+			url:      oldURL,
+			expected: `"basic_auth":{"username":"user"}`,
+		},
+		{
+			title:    "bearer token kept",
+			old:      `"bearer_token":"token"`,
+			new:      `"kind":"Prometheus"`,
+			url:      oldURL,
+			expected: `"bearer_token":"token"`,
+		},
+		{
+			title:    "bearer token cleared explicitly",
+			old:      `"bearer_token":"token"`,
+			new:      `"bearer_token":""`,
+			url:      oldURL,
+			expected: `"kind":"Prometheus"`,
+		},
+		{
+			title:    "bearer token not restored when switching from the basic auth",
+			old:      `"basic_auth":{"username":"admin","password":"secret"}`,
+			new:      `"kind":"Prometheus"`,
+			url:      oldURL,
+			expected: `"kind":"Prometheus"`,
+		},
+		{
+			title:    "headers kept",
+			old:      `"headers":{"X-Api-Key":"key","X-Other":"other"}`,
+			new:      `"headers":{"X-Api-Key":""}`,
+			url:      oldURL,
+			expected: `"headers":{"X-Api-Key":"key"}`,
+		},
+		{
+			title:    "secrets dropped when the url changes",
+			old:      `"basic_auth":{"username":"admin","password":"secret"},"headers":{"X-Api-Key":"key"}`,
+			new:      `"basic_auth":{"username":"admin"},"headers":{"X-Api-Key":""}`,
+			url:      "https://attacker.example.com",
+			expected: `"basic_auth":{"username":"admin"},"headers":{"X-Api-Key":""}`,
+		},
+		{
+			title:    "bearer token dropped when the url changes",
+			old:      `"bearer_token":"token"`,
+			new:      `"kind":"Prometheus"`,
+			url:      oldURL + "/other",
+			expected: `"kind":"Prometheus"`,
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			spec := newSpec(t, test.url, test.new)
+			keepSecrets(&spec, newSpec(t, oldURL, test.old))
+			expected := newSpec(t, test.url, test.expected)
+			assert.Equal(t, expected.BasicAuth, spec.BasicAuth)
+			assert.Equal(t, expected.BearerToken, spec.BearerToken)
+			assert.Equal(t, expected.Headers, spec.Headers)
+		})
+	}
+}
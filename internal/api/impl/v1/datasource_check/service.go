@@ -0,0 +1,224 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_check
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/perses/common/etcd"
+	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/datasource_check"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// checkTimeout is the maximum amount of time to wait for each request sent to the datasource.
+	checkTimeout = 10 * time.Second
+	// maxCheckResponseSize is the maximum number of bytes read from each response of the datasource.
+	// The build information is way smaller, a larger response is truncated and then fails to be decoded.
+	maxCheckResponseSize = 64 * 1024
+)
+
+type buildInfoResponse struct {
+	Status string `json:"status"`
+	Data   struct {
+		Version string `json:"version"`
+	} `json:"data"`
+}
+
+type lokiBuildInfoResponse struct {
+	Version string `json:"version"`
+}
+
+// endpoints are the paths used to check a kind of datasource.
+type endpoints struct {
+	ready     string
+	buildInfo string
+	// version extracts the version of the datasource from the body returned by the buildInfo endpoint.
+	version func(body []byte) (string, error)
+}
+
+var kindEndpoints = map[v1.DatasourceKind]endpoints{
+	v1.KindPrometheusDatasource: {
+		ready:     "/-/ready",
+		buildInfo: "/api/v1/status/buildinfo",
+		version: func(body []byte) (string, error) {
+			info := &buildInfoResponse{}
+			if err := json.Unmarshal(body, info); err != nil {
+				return "", err
+			}
+			if info.Status != "success" {
+				return "", fmt.Errorf("unexpected status '%s'", info.Status)
+			}
+			return info.Data.Version, nil
+		},
+	},
+	v1.KindLokiDatasource: {
+		ready:     "/ready",
+		buildInfo: "/loki/api/v1/status/buildinfo",
+		version: func(body []byte) (string, error) {
+			info := &lokiBuildInfoResponse{}
+			if err := json.Unmarshal(body, info); err != nil {
+				return "", err
+			}
+			return info.Version, nil
+		},
+	},
+}
+
+// errorType is returning the kind of failure that occurred when contacting the datasource.
+func errorType(err error) v1.DatasourceCheckErrorType {
+	var netErr net.Error
+	if errors.As(err, &netErr) && netErr.Timeout() {
+		return v1.CheckErrorTimeout
+	}
+	var unknownAuthorityErr x509.UnknownAuthorityError
+	var hostnameErr x509.HostnameError
+	var certificateInvalidErr x509.CertificateInvalidError
+	var recordHeaderErr tls.RecordHeaderError
+	if errors.As(err, &unknownAuthorityErr) || errors.As(err, &hostnameErr) ||
+		errors.As(err, &certificateInvalidErr) || errors.As(err, &recordHeaderErr) {
+		return v1.CheckErrorTLS
+	}
+	return v1.CheckErrorConnection
+}
+
+type service struct {
+	datasource_check.Service
+	// datasourceDAO is used instead of the datasource service since the secrets of the datasource are required to contact it.
+	datasourceDAO datasource.DAO
+}
+
+func NewService(datasourceDAO datasource.DAO) datasource_check.Service {
+	return &service{
+		datasourceDAO: datasourceDAO,
+	}
+}
+
+func (s *service) Check(project string, name string) (*v1.DatasourceCheckResponse, error) {
+	dts, err := datasourceImpl.Find(s.datasourceDAO, project, name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the Datasource '%s'", name)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to find the Datasource '%s', something wrong with etcd", name)
+		return nil, shared.InternalError
+	}
+	return s.check(dts.Spec)
+}
+
+func (s *service) CheckDatasource(entity *v1.Datasource) (*v1.DatasourceCheckResponse, error) {
+	if entity.Spec.URL == nil || len(entity.Spec.URL.Host) == 0 {
+		return nil, fmt.Errorf("%w: spec.url must be an absolute URL", shared.BadRequestError)
+	}
+	return s.check(entity.Spec)
+}
+
+func (s *service) check(spec v1.DatasourceSpec) (*v1.DatasourceCheckResponse, error) {
+	kindEndpoint, ok := kindEndpoints[spec.Kind]
+	if !ok {
+		return nil, fmt.Errorf("%w: datasource kind '%s' cannot be checked", shared.BadRequestError, spec.Kind)
+	}
+	roundTripper, err := datasourceImpl.NewRoundTripper(spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
+	defer roundTripper.CloseIdleConnections()
+	client := &http.Client{
+		Transport: roundTripper,
+		Timeout:   checkTimeout,
+	}
+	result := &v1.DatasourceCheckResponse{}
+	start := time.Now()
+	response, err := get(client, spec.URL, kindEndpoint.ready)
+	result.Latency = model.Duration(time.Since(start))
+	if err != nil {
+		result.ErrorType = errorType(err)
+		result.Error = err.Error()
+		return result, nil
+	}
+	result.Reachable = true
+	if response.statusCode == http.StatusUnauthorized || response.statusCode == http.StatusForbidden {
+		result.ErrorType = v1.CheckErrorAuth
+		result.Error = fmt.Sprintf("datasource rejected the credentials with the status code %d", response.statusCode)
+		return result, nil
+	}
+	if response.statusCode != http.StatusOK {
+		result.ErrorType = v1.CheckErrorUnexpectedResponse
+		result.Error = fmt.Sprintf("datasource is not ready, it returned the status code %d", response.statusCode)
+		return result, nil
+	}
+	result.Ready = true
+
+	response, err = get(client, spec.URL, kindEndpoint.buildInfo)
+	if err != nil {
+		result.ErrorType = errorType(err)
+		result.Error = err.Error()
+		return result, nil
+	}
+	var version string
+	if response.statusCode == http.StatusOK {
+		version, err = kindEndpoint.version(response.body)
+	}
+	if response.statusCode != http.StatusOK || err != nil {
+		result.ErrorType = v1.CheckErrorUnexpectedResponse
+		result.Error = fmt.Sprintf("unable to get the build information of the datasource, it returned the status code %d", response.statusCode)
+		return result, nil
+	}
+	result.Version = version
+	return result, nil
+}
+
+type checkResponse struct {
+	statusCode int
+	body       []byte
+}
+
+func get(client *http.Client, target *url.URL, path string) (*checkResponse, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), checkTimeout)
+	defer cancel()
+	u := *target
+	u.Path = strings.TrimSuffix(u.Path, "/") + path
+	request, err := http.NewRequestWithContext(ctx, http.MethodGet, u.String(), nil)
+	if err != nil {
+		return nil, err
+	}
+	response, err := client.Do(request)
+	if err != nil {
+		return nil, err
+	}
+	defer response.Body.Close() // nolint: errcheck
+	body, err := ioutil.ReadAll(io.LimitReader(response.Body, maxCheckResponseSize))
+	if err != nil {
+		return nil, err
+	}
+	return &checkResponse{statusCode: response.StatusCode, body: body}, nil
+}
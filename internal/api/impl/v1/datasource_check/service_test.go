@@ -0,0 +1,168 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_check
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func newDatasource(t *testing.T, kind v1.DatasourceKind, rawURL string) *v1.Datasource {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return &v1.Datasource{
+		Kind:     v1.KindDatasource,
+		Metadata: v1.OptionalProjectMetadata{Metadata: v1.Metadata{Name: "datasource"}},
+		Spec:     v1.DatasourceSpec{Kind: kind, URL: u},
+	}
+}
+
+func fakePrometheus(readyStatus int) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/-/ready", func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(readyStatus)
+	})
+	mux.HandleFunc("/api/v1/status/buildinfo", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"status":"success","data":{"version":"2.26.0"}}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func fakeLoki() *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/ready", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("ready"))
+	})
+	mux.HandleFunc("/loki/api/v1/status/buildinfo", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(`{"version":"2.4.1","revision":"f61a4d261"}`))
+	})
+	return httptest.NewServer(mux)
+}
+
+func TestCheckDatasource(t *testing.T) {
+	s := NewService(nil)
+
+	prometheusServer := fakePrometheus(http.StatusOK)
+	defer prometheusServer.Close()
+	lokiServer := fakeLoki()
+	defer lokiServer.Close()
+
+	testSuite := []struct {
+		title   string
+		kind    v1.DatasourceKind
+		url     string
+		version string
+	}{
+		{
+			title:   "prometheus",
+			kind:    v1.KindPrometheusDatasource,
+			url:     prometheusServer.URL,
+			version: "2.26.0",
+		},
+		{
+			title:   "loki",
+			kind:    v1.KindLokiDatasource,
+			url:     lokiServer.URL,
+			version: "2.4.1",
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			result, err := s.CheckDatasource(newDatasource(t, test.kind, test.url))
+			assert.NoError(t, err)
+			assert.True(t, result.Reachable)
+			assert.True(t, result.Ready)
+			assert.Equal(t, test.version, result.Version)
+			assert.Empty(t, result.ErrorType)
+		})
+	}
+}
+
+func TestCheckDatasourceError(t *testing.T) {
+	s := NewService(nil)
+
+	unauthorizedServer := fakePrometheus(http.StatusUnauthorized)
+	defer unauthorizedServer.Close()
+	notReadyServer := fakePrometheus(http.StatusServiceUnavailable)
+	defer notReadyServer.Close()
+	tlsServer := httptest.NewTLSServer(http.NotFoundHandler())
+	defer tlsServer.Close()
+	closedServer := httptest.NewServer(http.NotFoundHandler())
+	closedServer.Close()
+	// the build information comes after more bytes than what is read from the response
+	largeServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/api/v1/status/buildinfo" {
+			_, _ = w.Write([]byte(strings.Repeat(" ", 2*maxCheckResponseSize)))
+			_, _ = w.Write([]byte(`{"status":"success","data":{"version":"2.26.0"}}`))
+		}
+	}))
+	defer largeServer.Close()
+
+	testSuite := []struct {
+		title     string
+		url       string
+		reachable bool
+		ready     bool
+		errorType v1.DatasourceCheckErrorType
+	}{
+		{
+			title:     "credentials rejected",
+			url:       unauthorizedServer.URL,
+			reachable: true,
+			errorType: v1.CheckErrorAuth,
+		},
+		{
+			title:     "datasource not ready",
+			url:       notReadyServer.URL,
+			reachable: true,
+			errorType: v1.CheckErrorUnexpectedResponse,
+		},
+		{
+			title:     "build information too large",
+			url:       largeServer.URL,
+			reachable: true,
+			ready:     true,
+			errorType: v1.CheckErrorUnexpectedResponse,
+		},
+		{
+			title:     "unknown certificate authority",
+			url:       tlsServer.URL,
+			reachable: false,
+			errorType: v1.CheckErrorTLS,
+		},
+		{
+			title:     "connection refused",
+			url:       closedServer.URL,
+			reachable: false,
+			errorType: v1.CheckErrorConnection,
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			result, err := s.CheckDatasource(newDatasource(t, v1.KindPrometheusDatasource, test.url))
+			assert.NoError(t, err)
+			assert.Equal(t, test.reachable, result.Reachable)
+			assert.Equal(t, test.ready, result.Ready)
+			assert.Equal(t, test.errorType, result.ErrorType)
+		})
+	}
+}
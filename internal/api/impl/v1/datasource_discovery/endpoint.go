@@ -0,0 +1,103 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_discovery
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/perses/internal/api/interface/v1/datasource_discovery"
+	"github.com/perses/perses/internal/api/shared"
+)
+
+type Endpoint struct {
+	service datasource_discovery.Service
+}
+
+func NewEndpoint(service datasource_discovery.Service) *Endpoint {
+	return &Endpoint{
+		service: service,
+	}
+}
+
+func (e *Endpoint) RegisterRoutes(g *echo.Group) {
+	group := g.Group(fmt.Sprintf("/%s/:%s", shared.PathDatasource, shared.ParamName))
+	e.registerRoutes(group)
+	subGroup := g.Group(fmt.Sprintf("/%s/:%s/%s/:%s", shared.PathProject, shared.ParamProject, shared.PathDatasource, shared.ParamName))
+	e.registerRoutes(subGroup)
+}
+
+func (e *Endpoint) registerRoutes(g *echo.Group) {
+	g.GET(fmt.Sprintf("/%s", shared.PathMetric), e.MetricNames)
+	g.GET(fmt.Sprintf("/%s", shared.PathMetadata), e.MetricMetadata)
+	g.GET(fmt.Sprintf("/%s", shared.PathLabel), e.LabelNames)
+	g.GET(fmt.Sprintf("/%s/:%s/%s", shared.PathLabel, shared.ParamLabel, shared.PathValue), e.LabelValues)
+}
+
+func (e *Endpoint) MetricNames(ctx echo.Context) error {
+	query, err := bindQuery(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := e.service.MetricNames(ctx.Param(shared.ParamProject), ctx.Param(shared.ParamName), query)
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, result)
+}
+
+func (e *Endpoint) MetricMetadata(ctx echo.Context) error {
+	query, err := bindQuery(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := e.service.MetricMetadata(ctx.Param(shared.ParamProject), ctx.Param(shared.ParamName), query)
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, result)
+}
+
+func (e *Endpoint) LabelNames(ctx echo.Context) error {
+	query, err := bindQuery(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := e.service.LabelNames(ctx.Param(shared.ParamProject), ctx.Param(shared.ParamName), query)
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, result)
+}
+
+func (e *Endpoint) LabelValues(ctx echo.Context) error {
+	query, err := bindQuery(ctx)
+	if err != nil {
+		return err
+	}
+	result, err := e.service.LabelValues(ctx.Param(shared.ParamProject), ctx.Param(shared.ParamName), ctx.Param(shared.ParamLabel), query)
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, result)
+}
+
+func bindQuery(ctx echo.Context) (*datasource_discovery.Query, error) {
+	query := &datasource_discovery.Query{}
+	if err := ctx.Bind(query); err != nil {
+		return nil, shared.HandleError(fmt.Errorf("%w: %s", shared.BadRequestError, err))
+	}
+	return query, nil
+}
@@ -0,0 +1,175 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_discovery
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/impl/v1/dashboard_feed/plugin"
+	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/datasource_discovery"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	prometheusAPIV1 "github.com/prometheus/client_golang/api/prometheus/v1"
+	"github.com/prometheus/common/model"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// cacheTTL is the amount of time a result of the discovery is kept in the cache.
+	cacheTTL = 30 * time.Second
+	// discoveryTimeout is the maximum amount of time to wait for the datasource to answer.
+	discoveryTimeout = 30 * time.Second
+)
+
+// discoverFunc is requesting the datasource through the plugin. The time range can contain zero time when not set.
+type discoverFunc func(ctx context.Context, datasourcePlugin plugin.Plugin, start time.Time, end time.Time) (interface{}, error)
+
+type service struct {
+	datasource_discovery.Service
+	// datasourceDAO is used instead of the datasource service since the secrets of the datasource are required to contact it.
+	datasourceDAO datasource.DAO
+	cache         *cache
+}
+
+func NewService(datasourceDAO datasource.DAO) datasource_discovery.Service {
+	return &service{
+		datasourceDAO: datasourceDAO,
+		cache:         newCache(cacheTTL),
+	}
+}
+
+func (s *service) MetricNames(project string, name string, query *datasource_discovery.Query) ([]string, error) {
+	return s.LabelValues(project, name, model.MetricNameLabel, query)
+}
+
+func (s *service) MetricMetadata(project string, name string, query *datasource_discovery.Query) (map[string][]v1.MetricMetadata, error) {
+	result, err := s.discover(project, name, shared.PathMetadata, query, func(ctx context.Context, datasourcePlugin plugin.Plugin, start time.Time, end time.Time) (interface{}, error) {
+		metadataPlugin, ok := datasourcePlugin.(plugin.MetadataPlugin)
+		if !ok {
+			return nil, fmt.Errorf("%w: datasource '%s' doesn't provide the metadata of its metrics", shared.BadRequestError, name)
+		}
+		metadata, err := metadataPlugin.Metadata(ctx, "")
+		if err != nil {
+			return nil, err
+		}
+		if len(query.Matchers) == 0 && start.IsZero() && end.IsZero() {
+			return metadata, nil
+		}
+		// the metadata cannot be filtered by the datasource, so only the metrics matching the query are kept.
+		names, err := datasourcePlugin.LabelValues(ctx, model.MetricNameLabel, query.Matchers, start, end)
+		if err != nil {
+			return nil, err
+		}
+		filteredMetadata := make(map[string][]v1.MetricMetadata, len(names))
+		for _, metricName := range names {
+			if m, ok := metadata[metricName]; ok {
+				filteredMetadata[metricName] = m
+			}
+		}
+		return filteredMetadata, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return result.(map[string][]v1.MetricMetadata), nil
+}
+
+func (s *service) LabelNames(project string, name string, query *datasource_discovery.Query) ([]string, error) {
+	result, err := s.discover(project, name, shared.PathLabel, query, func(ctx context.Context, datasourcePlugin plugin.Plugin, start time.Time, end time.Time) (interface{}, error) {
+		return datasourcePlugin.LabelNames(ctx, query.Matchers, start, end)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nonNil(result.([]string)), nil
+}
+
+func (s *service) LabelValues(project string, name string, label string, query *datasource_discovery.Query) ([]string, error) {
+	endpoint := fmt.Sprintf("%s/%s/%s", shared.PathLabel, label, shared.PathValue)
+	result, err := s.discover(project, name, endpoint, query, func(ctx context.Context, datasourcePlugin plugin.Plugin, start time.Time, end time.Time) (interface{}, error) {
+		return datasourcePlugin.LabelValues(ctx, label, query.Matchers, start, end)
+	})
+	if err != nil {
+		return nil, err
+	}
+	return nonNil(result.([]string)), nil
+}
+
+// discover returns the result of the function f from the cache if possible. Otherwise it calls f and stores its result in the cache.
+func (s *service) discover(project string, name string, endpoint string, query *datasource_discovery.Query, f discoverFunc) (interface{}, error) {
+	start, end, err := query.TimeRange()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
+	key := cacheKey(project, name, endpoint, query)
+	if result, ok := s.cache.get(key); ok {
+		return result, nil
+	}
+	dts, err := datasourceImpl.Find(s.datasourceDAO, project, name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the Datasource '%s'", name)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to find the Datasource '%s', something wrong with etcd", name)
+		return nil, shared.InternalError
+	}
+	datasourcePlugin, err := plugin.New(dts.Spec)
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to create the plugin for the datasource '%s'", name)
+		return nil, shared.InternalError
+	}
+	defer datasourcePlugin.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), discoveryTimeout)
+	defer cancel()
+	result, err := f(ctx, datasourcePlugin, start, end)
+	if err != nil {
+		return nil, datasourceError(name, err)
+	}
+	s.cache.set(key, result)
+	return result, nil
+}
+
+// datasourceError is translating the error returned when contacting the datasource.
+func datasourceError(name string, err error) error {
+	var persesErr *shared.PersesError
+	if errors.As(err, &persesErr) {
+		return err
+	}
+	var promErr *prometheusAPIV1.Error
+	if errors.As(err, &promErr) && promErr.Type == prometheusAPIV1.ErrBadData {
+		// the matchers or the time range are not accepted by the datasource
+		return fmt.Errorf("%w: %s", shared.BadRequestError, promErr.Msg)
+	}
+	logrus.WithError(err).Errorf("unable to get the information from the datasource '%s'", name)
+	return fmt.Errorf("%w: unable to get the information from the datasource '%s': %s", shared.BadGatewayError, name, err)
+}
+
+func cacheKey(project string, name string, endpoint string, query *datasource_discovery.Query) string {
+	return strings.Join([]string{project, name, endpoint, strings.Join(query.Matchers, ","), query.Start, query.End}, "|")
+}
+
+func nonNil(values []string) []string {
+	if values == nil {
+		return []string{}
+	}
+	return values
+}
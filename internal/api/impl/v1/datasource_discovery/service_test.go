@@ -0,0 +1,169 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_discovery
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync/atomic"
+	"testing"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/datasource_discovery"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDatasourceDAO struct {
+	datasource.DAO
+	datasources map[string]*v1.Datasource
+}
+
+func (f *fakeDatasourceDAO) Get(project string, name string) (*v1.Datasource, error) {
+	key := v1.GenerateDatasourceID(project, name)
+	if dts, ok := f.datasources[key]; ok {
+		return dts, nil
+	}
+	return nil, &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+}
+
+// newService returns a service using a fake Prometheus and the number of requests received by the fake Prometheus.
+func newService(t *testing.T) (*service, *int32, func()) {
+	var requests int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&requests, 1)
+		if err := r.ParseForm(); err != nil {
+			t.Error(err)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if matchers := r.Form["match[]"]; len(matchers) > 0 && matchers[0] == "{" {
+			w.WriteHeader(http.StatusBadRequest)
+			_, _ = w.Write([]byte(`{"status":"error","errorType":"bad_data","error":"parse error: unexpected end of input"}`))
+			return
+		}
+		switch r.URL.Path {
+		case "/api/v1/label/__name__/values":
+			if len(r.Form["match[]"]) > 0 {
+				_, _ = w.Write([]byte(`{"status":"success","data":["up"]}`))
+				return
+			}
+			_, _ = w.Write([]byte(`{"status":"success","data":["http_requests_total","up"]}`))
+		case "/api/v1/metadata":
+			_, _ = w.Write([]byte(`{"status":"success","data":{` +
+				`"http_requests_total":[{"type":"counter","help":"Total number of HTTP requests.","unit":""}],` +
+				`"up":[{"type":"gauge","help":"Health of the target.","unit":""}]}}`))
+		case "/api/v1/labels":
+			_, _ = w.Write([]byte(`{"status":"success","data":["__name__","instance","job"]}`))
+		default:
+			t.Errorf("unexpected path '%s'", r.URL.Path)
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(&fakeDatasourceDAO{
+		datasources: map[string]*v1.Datasource{
+			"/datasources/prometheus": {Spec: v1.DatasourceSpec{Kind: v1.KindPrometheusDatasource, URL: u}},
+		},
+	}).(*service)
+	return s, &requests, server.Close
+}
+
+func TestDiscovery(t *testing.T) {
+	s, _, closeServer := newService(t)
+	defer closeServer()
+
+	names, err := s.MetricNames("", "prometheus", &datasource_discovery.Query{})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"http_requests_total", "up"}, names)
+
+	names, err = s.MetricNames("perses", "prometheus", &datasource_discovery.Query{Matchers: []string{`{job="prometheus"}`}})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"up"}, names)
+
+	metadata, err := s.MetricMetadata("", "prometheus", &datasource_discovery.Query{Matchers: []string{`{job="prometheus"}`}})
+	assert.NoError(t, err)
+	assert.Equal(t, map[string][]v1.MetricMetadata{
+		"up": {{Type: "gauge", Help: "Health of the target."}},
+	}, metadata)
+
+	labels, err := s.LabelNames("", "prometheus", &datasource_discovery.Query{Start: "2021-01-01T00:00:00Z", End: "1609462800"})
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"__name__", "instance", "job"}, labels)
+}
+
+func TestDiscoveryCache(t *testing.T) {
+	s, requests, closeServer := newService(t)
+	defer closeServer()
+
+	for i := 0; i < 3; i++ {
+		_, err := s.LabelNames("", "prometheus", &datasource_discovery.Query{})
+		assert.NoError(t, err)
+	}
+	assert.Equal(t, int32(1), atomic.LoadInt32(requests))
+	// another set of parameters is not using the same entry of the cache
+	_, err := s.LabelNames("", "prometheus", &datasource_discovery.Query{Matchers: []string{"up"}})
+	assert.NoError(t, err)
+	assert.Equal(t, int32(2), atomic.LoadInt32(requests))
+}
+
+func TestDiscoveryError(t *testing.T) {
+	s, _, closeServer := newService(t)
+	defer closeServer()
+
+	testSuite := []struct {
+		title      string
+		datasource string
+		query      *datasource_discovery.Query
+		err        error
+	}{
+		{
+			title:      "unknown datasource",
+			datasource: "unknown",
+			query:      &datasource_discovery.Query{},
+			err:        shared.NotFoundError,
+		},
+		{
+			title:      "invalid time range",
+			datasource: "prometheus",
+			query:      &datasource_discovery.Query{Start: "yesterday"},
+			err:        shared.BadRequestError,
+		},
+		{
+			title:      "end before start",
+			datasource: "prometheus",
+			query:      &datasource_discovery.Query{Start: "1609462800", End: "1609459200"},
+			err:        shared.BadRequestError,
+		},
+		{
+			title:      "invalid matcher",
+			datasource: "prometheus",
+			query:      &datasource_discovery.Query{Matchers: []string{"{"}},
+			err:        shared.BadRequestError,
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := s.LabelNames("", test.datasource, test.query)
+			assert.True(t, errors.Is(err, test.err))
+		})
+	}
+}
@@ -0,0 +1,50 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_proxy
+
+import (
+	"fmt"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/perses/internal/api/interface/v1/datasource_proxy"
+	"github.com/perses/perses/internal/api/shared"
+)
+
+type Endpoint struct {
+	service datasource_proxy.Service
+}
+
+func NewEndpoint(service datasource_proxy.Service) *Endpoint {
+	return &Endpoint{
+		service: service,
+	}
+}
+
+func (e *Endpoint) RegisterRoutes(g *echo.Group) {
+	group := g.Group(fmt.Sprintf("/%s/:%s/%s", shared.PathDatasource, shared.ParamName, shared.PathProxy))
+	group.GET("/*", e.Proxy)
+	group.POST("/*", e.Proxy)
+
+	subGroup := g.Group(fmt.Sprintf("/%s/:%s/%s/:%s/%s", shared.PathProject, shared.ParamProject, shared.PathDatasource, shared.ParamName, shared.PathProxy))
+	subGroup.GET("/*", e.Proxy)
+	subGroup.POST("/*", e.Proxy)
+}
+
+func (e *Endpoint) Proxy(ctx echo.Context) error {
+	path := fmt.Sprintf("/%s", ctx.Param("*"))
+	if err := e.service.Proxy(ctx.Param(shared.ParamProject), ctx.Param(shared.ParamName), path, ctx.Response(), ctx.Request()); err != nil {
+		return shared.HandleError(err)
+	}
+	return nil
+}
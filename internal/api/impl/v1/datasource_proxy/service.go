@@ -0,0 +1,175 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_proxy
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	pathpkg "path"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/perses/common/etcd"
+	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/datasource_proxy"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+const (
+	// proxyTimeout is the maximum amount of time to wait for the datasource to answer completely.
+	proxyTimeout = 30 * time.Second
+	// maxResponseSize is the maximum size in bytes of the response returned by the datasource.
+	maxResponseSize = 10 * 1024 * 1024
+)
+
+// allowedPaths is, for each kind of datasource, the list of the read-only path of its API that can be reached through the proxy.
+var allowedPaths = map[v1.DatasourceKind][]*regexp.Regexp{
+	v1.KindPrometheusDatasource: {
+		regexp.MustCompile(`^/api/v1/query$`),
+		regexp.MustCompile(`^/api/v1/query_range$`),
+		regexp.MustCompile(`^/api/v1/query_exemplars$`),
+		regexp.MustCompile(`^/api/v1/series$`),
+		regexp.MustCompile(`^/api/v1/labels$`),
+		regexp.MustCompile(`^/api/v1/label/[^/]+/values$`),
+		regexp.MustCompile(`^/api/v1/metadata$`),
+		regexp.MustCompile(`^/api/v1/rules$`),
+		regexp.MustCompile(`^/api/v1/alerts$`),
+		regexp.MustCompile(`^/api/v1/status/buildinfo$`),
+	},
+	v1.KindLokiDatasource: {
+		regexp.MustCompile(`^/loki/api/v1/query$`),
+		regexp.MustCompile(`^/loki/api/v1/query_range$`),
+		regexp.MustCompile(`^/loki/api/v1/series$`),
+		regexp.MustCompile(`^/loki/api/v1/labels$`),
+		regexp.MustCompile(`^/loki/api/v1/label/[^/]+/values$`),
+		regexp.MustCompile(`^/loki/api/v1/status/buildinfo$`),
+	},
+}
+
+var errResponseTooLarge = fmt.Errorf("response of the datasource is exceeding the limit of %d bytes", maxResponseSize)
+
+func isAllowedPath(kind v1.DatasourceKind, path string) bool {
+	if pathpkg.Clean(path) != path {
+		// avoid any attempt to reach another path using relative path like '..'
+		return false
+	}
+	for _, allowedPath := range allowedPaths[kind] {
+		if allowedPath.MatchString(path) {
+			return true
+		}
+	}
+	return false
+}
+
+// limitedReader is returning an error once more than remaining bytes have been read.
+type limitedReader struct {
+	io.ReadCloser
+	remaining int64
+}
+
+func (l *limitedReader) Read(p []byte) (int, error) {
+	if l.remaining < 0 {
+		return 0, errResponseTooLarge
+	}
+	if int64(len(p)) > l.remaining+1 {
+		p = p[:l.remaining+1]
+	}
+	n, err := l.ReadCloser.Read(p)
+	l.remaining -= int64(n)
+	if l.remaining < 0 {
+		// the byte beyond the limit is only read to detect the overflow, it must not be returned
+		return n + int(l.remaining), errResponseTooLarge
+	}
+	return n, err
+}
+
+func limitResponseSize(response *http.Response) error {
+	if response.ContentLength > maxResponseSize {
+		return errResponseTooLarge
+	}
+	response.Body = &limitedReader{ReadCloser: response.Body, remaining: maxResponseSize}
+	return nil
+}
+
+type service struct {
+	datasource_proxy.Service
+	// datasourceDAO is used instead of the datasource service since the secrets of the datasource are required to contact it.
+	datasourceDAO datasource.DAO
+}
+
+func NewService(datasourceDAO datasource.DAO) datasource_proxy.Service {
+	return &service{
+		datasourceDAO: datasourceDAO,
+	}
+}
+
+func (s *service) Proxy(project string, name string, path string, w http.ResponseWriter, r *http.Request) error {
+	dts, err := datasourceImpl.Find(s.datasourceDAO, project, name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the Datasource '%s'", name)
+			return shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to find the Datasource '%s', something wrong with etcd", name)
+		return shared.InternalError
+	}
+	if !isAllowedPath(dts.Spec.Kind, path) {
+		return fmt.Errorf("%w: path '%s' cannot be reached through the proxy", shared.ForbiddenError, path)
+	}
+	roundTripper, err := datasourceImpl.NewRoundTripper(dts.Spec)
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to create the round tripper for the datasource '%s'", name)
+		return shared.InternalError
+	}
+	defer roundTripper.CloseIdleConnections()
+	ctx, cancel := context.WithTimeout(r.Context(), proxyTimeout)
+	defer cancel()
+	newReverseProxy(dts.Spec.URL, path, roundTripper).ServeHTTP(w, r.WithContext(ctx))
+	return nil
+}
+
+func newReverseProxy(target *url.URL, path string, transport http.RoundTripper) *httputil.ReverseProxy {
+	return &httputil.ReverseProxy{
+		Director: func(r *http.Request) {
+			r.URL.Scheme = target.Scheme
+			r.URL.Host = target.Host
+			r.URL.Path = strings.TrimSuffix(target.Path, "/") + path
+			r.URL.RawPath = ""
+			r.Host = target.Host
+			// the credentials used to reach Perses must not be sent to the datasource.
+			// The one of the datasource are injected by the transport.
+			r.Header.Del("Authorization")
+			r.Header.Del("Cookie")
+		},
+		Transport:      transport,
+		ModifyResponse: limitResponseSize,
+		ErrorHandler: func(w http.ResponseWriter, r *http.Request, err error) {
+			logrus.WithError(err).Errorf("unable to proxy the request to the datasource '%s'", target.Host)
+			status := http.StatusBadGateway
+			if errors.Is(err, context.DeadlineExceeded) {
+				status = http.StatusGatewayTimeout
+			}
+			w.WriteHeader(status)
+		},
+	}
+}
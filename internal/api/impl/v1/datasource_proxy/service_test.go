@@ -0,0 +1,158 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_proxy
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDatasourceDAO struct {
+	datasource.DAO
+	datasources map[string]*v1.Datasource
+}
+
+func (f *fakeDatasourceDAO) Get(project string, name string) (*v1.Datasource, error) {
+	key := v1.GenerateDatasourceID(project, name)
+	if dts, ok := f.datasources[key]; ok {
+		return dts, nil
+	}
+	return nil, &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+}
+
+func newService(t *testing.T, handler http.HandlerFunc) (*service, func()) {
+	server := httptest.NewServer(handler)
+	u, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewService(&fakeDatasourceDAO{
+		datasources: map[string]*v1.Datasource{
+			"/datasources/prometheus": {
+				Kind:     v1.KindDatasource,
+				Metadata: v1.OptionalProjectMetadata{Metadata: v1.Metadata{Name: "prometheus"}},
+				Spec: v1.DatasourceSpec{
+					Kind:      v1.KindPrometheusDatasource,
+					URL:       u,
+					BasicAuth: &v1.BasicAuth{Username: "admin", Password: "secret"},
+				},
+			},
+		},
+	}).(*service)
+	return s, server.Close
+}
+
+func TestProxy(t *testing.T) {
+	s, closeServer := newService(t, func(w http.ResponseWriter, r *http.Request) {
+		user, password, _ := r.BasicAuth()
+		assert.Equal(t, "admin", user)
+		assert.Equal(t, "secret", password)
+		assert.Empty(t, r.Header.Get("Cookie"))
+		assert.Equal(t, "/api/v1/query", r.URL.Path)
+		assert.Equal(t, "up", r.URL.Query().Get("query"))
+		_, _ = w.Write([]byte(`{"status":"success"}`))
+	})
+	defer closeServer()
+
+	request := httptest.NewRequest(http.MethodGet, "/api/v1/datasources/prometheus/proxy/api/v1/query?query=up", nil)
+	request.Header.Set("Authorization", "Bearer perses-token")
+	request.Header.Set("Cookie", "session=perses")
+	recorder := httptest.NewRecorder()
+	assert.NoError(t, s.Proxy("", "prometheus", "/api/v1/query", recorder, request))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+	assert.Equal(t, `{"status":"success"}`, recorder.Body.String())
+}
+
+func TestProxyError(t *testing.T) {
+	s, closeServer := newService(t, func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("the datasource shouldn't be reached")
+	})
+	defer closeServer()
+
+	testSuite := []struct {
+		title      string
+		datasource string
+		path       string
+		err        error
+	}{
+		{
+			title:      "path not allowed",
+			datasource: "prometheus",
+			path:       "/api/v1/admin/tsdb/delete_series",
+			err:        shared.ForbiddenError,
+		},
+		{
+			title:      "relative path",
+			datasource: "prometheus",
+			path:       "/api/v1/label/../../admin/values",
+			err:        shared.ForbiddenError,
+		},
+		{
+			title:      "unknown datasource",
+			datasource: "unknown",
+			path:       "/api/v1/query",
+			err:        shared.NotFoundError,
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			request := httptest.NewRequest(http.MethodPost, "/", nil)
+			err := s.Proxy("", test.datasource, test.path, httptest.NewRecorder(), request)
+			assert.True(t, errors.Is(err, test.err))
+		})
+	}
+}
+
+func TestProxyResponseTooLarge(t *testing.T) {
+	s, closeServer := newService(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "20971520")
+		_, _ = w.Write([]byte(strings.Repeat("a", 1024)))
+	})
+	defer closeServer()
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder := httptest.NewRecorder()
+	assert.NoError(t, s.Proxy("", "prometheus", "/api/v1/series", recorder, request))
+	assert.Equal(t, http.StatusBadGateway, recorder.Code)
+}
+
+func TestProxyStreamedResponseTooLarge(t *testing.T) {
+	s, closeServer := newService(t, func(w http.ResponseWriter, r *http.Request) {
+		// no Content-Length is set, the response is streamed until it exceeds the limit
+		chunk := []byte(strings.Repeat("a", 1024*1024))
+		for i := 0; i <= maxResponseSize/len(chunk); i++ {
+			if _, err := w.Write(chunk); err != nil {
+				return
+			}
+			w.(http.Flusher).Flush()
+		}
+	})
+	defer closeServer()
+
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	recorder := httptest.NewRecorder()
+	assert.NoError(t, s.Proxy("", "prometheus", "/api/v1/series", recorder, request))
+	assert.Empty(t, recorder.Header().Get("Content-Length"))
+	assert.LessOrEqual(t, recorder.Body.Len(), maxResponseSize)
+}
@@ -0,0 +1,58 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_dependency
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/perses/internal/api/interface/v1/metric_dependency"
+	"github.com/perses/perses/internal/api/shared"
+)
+
+type Endpoint struct {
+	service metric_dependency.Service
+}
+
+func NewEndpoint(service metric_dependency.Service) *Endpoint {
+	return &Endpoint{
+		service: service,
+	}
+}
+
+func (e *Endpoint) RegisterRoutes(g *echo.Group) {
+	g.GET(fmt.Sprintf("/%s/:%s/%s", shared.PathMetric, shared.ParamName, shared.PathUsage), e.GetMetricUsage)
+
+	subGroup := g.Group(fmt.Sprintf("/%s/:%s/%s", shared.PathProject, shared.ParamProject, shared.PathDashboard))
+	subGroup.GET(fmt.Sprintf("/:%s/%s", shared.ParamName, shared.PathDependency), e.GetDashboardDependencies)
+}
+
+// GetMetricUsage returns the recording rules producing the metric and the dashboards and the rules using it.
+func (e *Endpoint) GetMetricUsage(ctx echo.Context) error {
+	response, err := e.service.GetMetricUsage(ctx.Param(shared.ParamName))
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// GetDashboardDependencies returns the metrics used by the dashboard and the recording rules producing them.
+func (e *Endpoint) GetDashboardDependencies(ctx echo.Context) error {
+	response, err := e.service.GetDashboardDependencies(ctx.Param(shared.ParamProject), ctx.Param(shared.ParamName))
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, response)
+}
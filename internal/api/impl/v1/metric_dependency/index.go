@@ -0,0 +1,153 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_dependency
+
+import (
+	"fmt"
+
+	"github.com/perses/perses/internal/api/impl/v1/dashboard/expression"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// index is the dependency index between the metrics, the recording rules producing them and the expressions using them.
+// It's not safe for concurrent use.
+type index struct {
+	recordedBy map[string][]v1.MetricReference
+	usedBy     map[string][]v1.MetricReference
+	// metricsOf contains, for each resource indexed, the metrics it records or uses, so it can be removed from the index.
+	metricsOf map[string][]string
+}
+
+func newIndex() *index {
+	return &index{
+		recordedBy: make(map[string][]v1.MetricReference),
+		usedBy:     make(map[string][]v1.MetricReference),
+		metricsOf:  make(map[string][]string),
+	}
+}
+
+func resourceID(kind v1.Kind, project string, name string) string {
+	return fmt.Sprintf("%s/%s/%s", kind, project, name)
+}
+
+func (i *index) addRecord(metric string, ref v1.MetricReference) {
+	i.recordedBy[metric] = append(i.recordedBy[metric], ref)
+	id := resourceID(ref.Kind, ref.Project, ref.Name)
+	i.metricsOf[id] = append(i.metricsOf[id], metric)
+}
+
+// addExpression indexes the metrics used by the expression. An expression that cannot be parsed is ignored.
+func (i *index) addExpression(expr string, ref v1.MetricReference) {
+	metrics, err := expression.Metrics(expr)
+	if err != nil {
+		logrus.WithError(err).Debugf("unable to parse the expression '%s' of the %s '%s' in the project '%s', it is ignored", ref.Path, ref.Kind, ref.Name, ref.Project)
+		return
+	}
+	id := resourceID(ref.Kind, ref.Project, ref.Name)
+	for _, metric := range metrics {
+		i.usedBy[metric] = append(i.usedBy[metric], ref)
+		i.metricsOf[id] = append(i.metricsOf[id], metric)
+	}
+}
+
+// remove removes from the index every reference to the resource.
+func (i *index) remove(kind v1.Kind, project string, name string) {
+	id := resourceID(kind, project, name)
+	for _, metric := range i.metricsOf[id] {
+		removeReferences(i.recordedBy, metric, kind, project, name)
+		removeReferences(i.usedBy, metric, kind, project, name)
+	}
+	delete(i.metricsOf, id)
+}
+
+// removeReferences removes the references to the resource from the ones of the metric.
+// A new slice is allocated, so the slices previously returned by the index are not modified.
+func removeReferences(refsByMetric map[string][]v1.MetricReference, metric string, kind v1.Kind, project string, name string) {
+	refs, ok := refsByMetric[metric]
+	if !ok {
+		return
+	}
+	kept := make([]v1.MetricReference, 0, len(refs))
+	for _, ref := range refs {
+		if !isFrom(ref, kind, project, name) {
+			kept = append(kept, ref)
+		}
+	}
+	if len(kept) == 0 {
+		delete(refsByMetric, metric)
+		return
+	}
+	refsByMetric[metric] = kept
+}
+
+// setDashboard replaces the references of the dashboard by the ones of its new version.
+func (i *index) setDashboard(dashboard *v1.Dashboard) {
+	i.remove(v1.KindDashboard, dashboard.Metadata.Project, dashboard.Metadata.Name)
+	i.addDashboard(dashboard)
+}
+
+// setPrometheusRule replaces the references of the PrometheusRule by the ones of its new version.
+func (i *index) setPrometheusRule(rule *v1.PrometheusRule) {
+	i.remove(v1.KindPrometheusRule, rule.Metadata.Project, rule.Metadata.Name)
+	i.addPrometheusRule(rule)
+}
+
+func (i *index) addDashboard(dashboard *v1.Dashboard) {
+	for _, e := range expression.Expressions(dashboard.Spec) {
+		i.addExpression(e.Expr, v1.MetricReference{
+			Kind:    v1.KindDashboard,
+			Project: dashboard.Metadata.Project,
+			Name:    dashboard.Metadata.Name,
+			Path:    e.Path,
+		})
+	}
+}
+
+func (i *index) addPrometheusRule(rule *v1.PrometheusRule) {
+	for j, group := range rule.Spec.Groups {
+		for k, r := range group.Rules {
+			ref := v1.MetricReference{
+				Kind:    v1.KindPrometheusRule,
+				Project: rule.Metadata.Project,
+				Name:    rule.Metadata.Name,
+			}
+			if len(r.Record) > 0 {
+				ref.Path = fmt.Sprintf("spec.groups[%d].rules[%d].record", j, k)
+				i.addRecord(r.Record, ref)
+			}
+			ref.Path = fmt.Sprintf("spec.groups[%d].rules[%d].expr", j, k)
+			i.addExpression(r.Expr, ref)
+		}
+	}
+}
+
+func (i *index) usage(metric string) v1.MetricUsage {
+	return v1.MetricUsage{
+		Metric:     metric,
+		RecordedBy: copyReferences(i.recordedBy[metric]),
+		UsedBy:     copyReferences(i.usedBy[metric]),
+	}
+}
+
+// copyReferences returns a copy of the references, so they can be used once the index has been modified.
+// The copy is never nil to avoid a null in the JSON response.
+func copyReferences(refs []v1.MetricReference) []v1.MetricReference {
+	return append(make([]v1.MetricReference, 0, len(refs)), refs...)
+}
+
+// isFrom returns true if the reference points to the resource with the given kind, project and name.
+func isFrom(ref v1.MetricReference, kind v1.Kind, project string, name string) bool {
+	return ref.Kind == kind && ref.Project == project && ref.Name == name
+}
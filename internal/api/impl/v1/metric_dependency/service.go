@@ -0,0 +1,328 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_dependency
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/dashboard"
+	"github.com/perses/perses/internal/api/interface/v1/metric_dependency"
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/internal/api/shared/database"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// watchRetryInterval is the time to wait before watching the resources again when the watch cannot be started.
+const watchRetryInterval = 10 * time.Second
+
+type service struct {
+	metric_dependency.Service
+	dashboardDAO dashboard.DAO
+	ruleDAO      prometheusrule.DAO
+	mutex        sync.RWMutex
+	// idx is kept up to date by the task with the changes of the dashboards and the PrometheusRules.
+	// It's nil when the task isn't running, or while it's (re)building the index.
+	idx *index
+}
+
+func NewService(dashboardDAO dashboard.DAO, ruleDAO prometheusrule.DAO) metric_dependency.Service {
+	return &service{
+		dashboardDAO: dashboardDAO,
+		ruleDAO:      ruleDAO,
+	}
+}
+
+func (s *service) String() string {
+	return "metric dependency indexer"
+}
+
+func (s *service) Initialize() error {
+	return nil
+}
+
+func (s *service) Finalize() error {
+	return nil
+}
+
+// Execute builds the index and then keeps it up to date with the changes of the dashboards and the PrometheusRules.
+func (s *service) Execute(ctx context.Context, _ context.CancelFunc) error {
+	for {
+		if err := s.watch(ctx); err != nil {
+			logrus.WithError(err).Error("unable to index the metrics used by the dashboards and the prometheusRules")
+		}
+		s.setIndex(nil)
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-time.After(watchRetryInterval):
+		}
+	}
+}
+
+// watch builds the index and applies the changes to it, until the context is done or the watch stops.
+func (s *service) watch(ctx context.Context) error {
+	// the watches are started before the index is built, so no change can be missed between the two.
+	watchCtx, cancelWatch := context.WithCancel(ctx)
+	defer cancelWatch()
+	dashboardChan, err := s.dashboardDAO.Watch(watchCtx)
+	if err != nil {
+		return fmt.Errorf("unable to watch the dashboards: %w", err)
+	}
+	ruleChan, err := s.ruleDAO.Watch(watchCtx)
+	if err != nil {
+		return fmt.Errorf("unable to watch the prometheusRules: %w", err)
+	}
+	idx, err := s.buildIndex()
+	if err != nil {
+		return err
+	}
+	s.setIndex(idx)
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case response, ok := <-dashboardChan:
+			if !ok {
+				return fmt.Errorf("the watch of the dashboards has been closed")
+			}
+			if response.Err != nil {
+				return fmt.Errorf("error received when watching the dashboards: %w", response.Err)
+			}
+			s.applyDashboardEvents(response.Events)
+		case response, ok := <-ruleChan:
+			if !ok {
+				return fmt.Errorf("the watch of the prometheusRules has been closed")
+			}
+			if response.Err != nil {
+				return fmt.Errorf("error received when watching the prometheusRules: %w", response.Err)
+			}
+			s.applyPrometheusRuleEvents(response.Events)
+		}
+	}
+}
+
+func (s *service) applyDashboardEvents(events []database.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, event := range events {
+		entity := &v1.Dashboard{}
+		if err := json.Unmarshal(event.Value, entity); err != nil {
+			logrus.WithError(err).Errorf("unable to decode the dashboard '%s', it is not indexed", event.Key)
+			continue
+		}
+		if event.Type == database.EventDelete {
+			s.idx.remove(v1.KindDashboard, entity.Metadata.Project, entity.Metadata.Name)
+		} else {
+			s.idx.setDashboard(entity)
+		}
+	}
+}
+
+func (s *service) applyPrometheusRuleEvents(events []database.Event) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, event := range events {
+		entity := &v1.PrometheusRule{}
+		if err := json.Unmarshal(event.Value, entity); err != nil {
+			logrus.WithError(err).Errorf("unable to decode the prometheusRule '%s', it is not indexed", event.Key)
+			continue
+		}
+		if event.Type == database.EventDelete {
+			s.idx.remove(v1.KindPrometheusRule, entity.Metadata.Project, entity.Metadata.Name)
+		} else {
+			s.idx.setPrometheusRule(entity)
+		}
+	}
+}
+
+func (s *service) setIndex(idx *index) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.idx = idx
+}
+
+// withIndex calls f with the index kept up to date by the task. When the task isn't running, or isn't ready yet,
+// an index is built for the request, so the result is always up to date.
+func (s *service) withIndex(f func(idx *index)) error {
+	s.mutex.RLock()
+	if s.idx != nil {
+		defer s.mutex.RUnlock()
+		f(s.idx)
+		return nil
+	}
+	s.mutex.RUnlock()
+	idx, err := s.buildIndex()
+	if err != nil {
+		return err
+	}
+	f(idx)
+	return nil
+}
+
+// buildIndex indexes every dashboard and every PrometheusRule of every project.
+func (s *service) buildIndex() (*index, error) {
+	dashboards, err := s.dashboardDAO.List(&dashboard.Query{})
+	if err != nil {
+		logrus.WithError(err).Error("unable to get the dashboards, something wrong with etcd")
+		return nil, shared.InternalError
+	}
+	rules, err := s.ruleDAO.List(&prometheusrule.Query{})
+	if err != nil {
+		logrus.WithError(err).Error("unable to get the prometheusRules, something wrong with etcd")
+		return nil, shared.InternalError
+	}
+	result := newIndex()
+	for _, d := range dashboards {
+		result.addDashboard(d)
+	}
+	for _, r := range rules {
+		result.addPrometheusRule(r)
+	}
+	return result, nil
+}
+
+func (s *service) GetMetricUsage(metric string) (*v1.MetricUsage, error) {
+	var usage v1.MetricUsage
+	if err := s.withIndex(func(idx *index) {
+		usage = idx.usage(metric)
+	}); err != nil {
+		return nil, err
+	}
+	return &usage, nil
+}
+
+func (s *service) GetDashboardDependencies(project string, name string) ([]v1.MetricUsage, error) {
+	entity, err := s.dashboardDAO.Get(project, name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the dashboard '%s' in the project '%s'", name, project)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to find the dashboard '%s' in the project '%s', something wrong with etcd", name, project)
+		return nil, shared.InternalError
+	}
+	var result []v1.MetricUsage
+	if err := s.withIndex(func(idx *index) {
+		result = dashboardDependencies(idx, entity)
+	}); err != nil {
+		return nil, err
+	}
+	return result, nil
+}
+
+// dashboardDependencies returns the metrics used by the dashboard, with only the references to the dashboard.
+func dashboardDependencies(idx *index, entity *v1.Dashboard) []v1.MetricUsage {
+	var metrics []string
+	for metric, refs := range idx.usedBy {
+		for _, ref := range refs {
+			if isFrom(ref, v1.KindDashboard, entity.Metadata.Project, entity.Metadata.Name) {
+				metrics = append(metrics, metric)
+				break
+			}
+		}
+	}
+	sort.Strings(metrics)
+	result := make([]v1.MetricUsage, 0, len(metrics))
+	for _, metric := range metrics {
+		usage := idx.usage(metric)
+		usedBy := make([]v1.MetricReference, 0, len(usage.UsedBy))
+		for _, ref := range usage.UsedBy {
+			if isFrom(ref, v1.KindDashboard, entity.Metadata.Project, entity.Metadata.Name) {
+				usedBy = append(usedBy, ref)
+			}
+		}
+		usage.UsedBy = usedBy
+		result = append(result, usage)
+	}
+	return result
+}
+
+func (s *service) GetPrometheusRuleDeletionWarnings(project string, name string) ([]string, error) {
+	entity, err := s.ruleDAO.Get(project, name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			// nothing to warn about, the deletion itself will report that the prometheusRule doesn't exist.
+			return nil, nil
+		}
+		logrus.WithError(err).Errorf("unable to find the prometheusRule '%s' in the project '%s', something wrong with etcd", name, project)
+		return nil, shared.InternalError
+	}
+	var warnings []string
+	if err := s.withIndex(func(idx *index) {
+		warnings = deletionWarnings(idx, entity)
+	}); err != nil {
+		return nil, err
+	}
+	return warnings, nil
+}
+
+// deletionWarnings returns the warnings about the metrics recorded by the PrometheusRule and still used elsewhere.
+func deletionWarnings(idx *index, entity *v1.PrometheusRule) []string {
+	project := entity.Metadata.Project
+	name := entity.Metadata.Name
+	var warnings []string
+	warned := make(map[string]bool)
+	for _, group := range entity.Spec.Groups {
+		for _, r := range group.Rules {
+			if len(r.Record) == 0 || warned[r.Record] {
+				continue
+			}
+			warned[r.Record] = true
+			if recordedElsewhere(idx.recordedBy[r.Record], project, name) {
+				continue
+			}
+			users := usersElsewhere(idx.usedBy[r.Record], project, name)
+			if len(users) > 0 {
+				warnings = append(warnings, fmt.Sprintf("the metric '%s' recorded by the prometheusRule is still used by: %s", r.Record, strings.Join(users, ", ")))
+			}
+		}
+	}
+	return warnings
+}
+
+// recordedElsewhere returns true if a rule of another PrometheusRule is recording the metric too.
+func recordedElsewhere(recordedBy []v1.MetricReference, project string, name string) bool {
+	for _, ref := range recordedBy {
+		if !isFrom(ref, v1.KindPrometheusRule, project, name) {
+			return true
+		}
+	}
+	return false
+}
+
+// usersElsewhere returns the resources other than the given PrometheusRule using the metric, formatted as kind project/name.
+func usersElsewhere(usedBy []v1.MetricReference, project string, name string) []string {
+	var result []string
+	seen := make(map[string]bool)
+	for _, ref := range usedBy {
+		if isFrom(ref, v1.KindPrometheusRule, project, name) {
+			continue
+		}
+		user := fmt.Sprintf("%s %s/%s", ref.Kind, ref.Project, ref.Name)
+		if !seen[user] {
+			seen[user] = true
+			result = append(result, user)
+		}
+	}
+	return result
+}
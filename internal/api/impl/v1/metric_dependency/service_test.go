@@ -0,0 +1,267 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_dependency
+
+import (
+	"context"
+	"encoding/json"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/dashboard"
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/shared/database"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDashboardDAO struct {
+	dashboard.DAO
+	dashboards []*v1.Dashboard
+	lists      int32
+	events     chan database.WatchResponse
+}
+
+func (d *fakeDashboardDAO) List(_ etcd.Query) ([]*v1.Dashboard, error) {
+	atomic.AddInt32(&d.lists, 1)
+	return d.dashboards, nil
+}
+
+func (d *fakeDashboardDAO) Watch(_ context.Context) (database.WatchChan, error) {
+	return d.events, nil
+}
+
+func (d *fakeDashboardDAO) Get(project string, name string) (*v1.Dashboard, error) {
+	for _, entity := range d.dashboards {
+		if entity.Metadata.Project == project && entity.Metadata.Name == name {
+			return entity, nil
+		}
+	}
+	return nil, &etcd.Error{Key: v1.GenerateDashboardID(project, name), Code: etcd.ErrorCodeKeyNotFound}
+}
+
+type fakeRuleDAO struct {
+	prometheusrule.DAO
+	rules  []*v1.PrometheusRule
+	lists  int32
+	events chan database.WatchResponse
+}
+
+func (d *fakeRuleDAO) List(_ etcd.Query) ([]*v1.PrometheusRule, error) {
+	atomic.AddInt32(&d.lists, 1)
+	return d.rules, nil
+}
+
+func (d *fakeRuleDAO) Watch(_ context.Context) (database.WatchChan, error) {
+	return d.events, nil
+}
+
+func (d *fakeRuleDAO) Get(project string, name string) (*v1.PrometheusRule, error) {
+	for _, entity := range d.rules {
+		if entity.Metadata.Project == project && entity.Metadata.Name == name {
+			return entity, nil
+		}
+	}
+	return nil, &etcd.Error{Key: v1.GeneratePrometheusRuleID(project, name), Code: etcd.ErrorCodeKeyNotFound}
+}
+
+func newDashboard(project string, name string, exprs ...string) *v1.Dashboard {
+	lines := make([]v1.Line, 0, len(exprs))
+	for _, expr := range exprs {
+		lines = append(lines, v1.Line{Expr: expr})
+	}
+	return &v1.Dashboard{
+		Kind: v1.KindDashboard,
+		Metadata: v1.ProjectMetadata{
+			Metadata: v1.Metadata{Name: name},
+			Project:  project,
+		},
+		Spec: v1.DashboardSpec{
+			Datasource: "PrometheusDemo",
+			Sections: []v1.DashboardSection{
+				{
+					Panels: []v1.Panel{
+						{
+							Name:  "myPanel",
+							Chart: &v1.LineChart{Kind: v1.KindLineChart, Lines: lines},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func newPrometheusRule(project string, name string, rules ...v1.Rule) *v1.PrometheusRule {
+	return &v1.PrometheusRule{
+		Kind: v1.KindPrometheusRule,
+		Metadata: v1.ProjectMetadata{
+			Metadata: v1.Metadata{Name: name},
+			Project:  project,
+		},
+		Spec: v1.PrometheusRuleSpec{Groups: []v1.RuleGroup{{Name: name, Rules: rules}}},
+	}
+}
+
+func newTestService() *service {
+	return &service{
+		dashboardDAO: &fakeDashboardDAO{dashboards: []*v1.Dashboard{
+			newDashboard("perses", "api", "job:http_requests:rate5m", "sum(job:http_errors:rate5m) / sum(job:http_requests:rate5m)"),
+			newDashboard("perses", "node", "up"),
+		}},
+		ruleDAO: &fakeRuleDAO{rules: []*v1.PrometheusRule{
+			newPrometheusRule("perses", "http",
+				v1.Rule{Record: "job:http_requests:rate5m", Expr: "sum by (job) (rate(http_requests_total[5m]))"},
+				v1.Rule{Record: "job:http_errors:rate5m", Expr: "sum by (job) (rate(http_requests_total{code=~'5..'}[5m]))"},
+				v1.Rule{Alert: "HighErrorRate", Expr: "job:http_errors:rate5m / job:http_requests:rate5m > 0.05"},
+			),
+			newPrometheusRule("perses", "http-copy",
+				v1.Rule{Record: "job:http_errors:rate5m", Expr: "sum by (job) (rate(http_requests_total{code=~'5..'}[5m]))"},
+			),
+			newPrometheusRule("perses", "unused",
+				v1.Rule{Record: "job:up:sum", Expr: "sum by (job) (up)"},
+			),
+		}},
+	}
+}
+
+func TestGetMetricUsage(t *testing.T) {
+	usage, err := newTestService().GetMetricUsage("job:http_requests:rate5m")
+	assert.NoError(t, err)
+	assert.Equal(t, &v1.MetricUsage{
+		Metric: "job:http_requests:rate5m",
+		RecordedBy: []v1.MetricReference{
+			{Kind: v1.KindPrometheusRule, Project: "perses", Name: "http", Path: "spec.groups[0].rules[0].record"},
+		},
+		UsedBy: []v1.MetricReference{
+			{Kind: v1.KindDashboard, Project: "perses", Name: "api", Path: "spec.sections[0].panels[0].chart.lines[0].expr"},
+			{Kind: v1.KindDashboard, Project: "perses", Name: "api", Path: "spec.sections[0].panels[0].chart.lines[1].expr"},
+			{Kind: v1.KindPrometheusRule, Project: "perses", Name: "http", Path: "spec.groups[0].rules[2].expr"},
+		},
+	}, usage)
+}
+
+func TestGetDashboardDependencies(t *testing.T) {
+	result, err := newTestService().GetDashboardDependencies("perses", "api")
+	assert.NoError(t, err)
+	assert.Equal(t, []v1.MetricUsage{
+		{
+			Metric: "job:http_errors:rate5m",
+			RecordedBy: []v1.MetricReference{
+				{Kind: v1.KindPrometheusRule, Project: "perses", Name: "http", Path: "spec.groups[0].rules[1].record"},
+				{Kind: v1.KindPrometheusRule, Project: "perses", Name: "http-copy", Path: "spec.groups[0].rules[0].record"},
+			},
+			UsedBy: []v1.MetricReference{
+				{Kind: v1.KindDashboard, Project: "perses", Name: "api", Path: "spec.sections[0].panels[0].chart.lines[1].expr"},
+			},
+		},
+		{
+			Metric: "job:http_requests:rate5m",
+			RecordedBy: []v1.MetricReference{
+				{Kind: v1.KindPrometheusRule, Project: "perses", Name: "http", Path: "spec.groups[0].rules[0].record"},
+			},
+			UsedBy: []v1.MetricReference{
+				{Kind: v1.KindDashboard, Project: "perses", Name: "api", Path: "spec.sections[0].panels[0].chart.lines[0].expr"},
+				{Kind: v1.KindDashboard, Project: "perses", Name: "api", Path: "spec.sections[0].panels[0].chart.lines[1].expr"},
+			},
+		},
+	}, result)
+}
+
+func TestGetPrometheusRuleDeletionWarnings(t *testing.T) {
+	testSuite := []struct {
+		title    string
+		name     string
+		warnings []string
+	}{
+		{
+			title: "metric still used and recorded only by this rule",
+			name:  "http",
+			// job:http_errors:rate5m is also recorded by http-copy, so there is no warning for it
+			warnings: []string{"the metric 'job:http_requests:rate5m' recorded by the prometheusRule is still used by: Dashboard perses/api"},
+		},
+		{
+			title: "metric recorded by another rule",
+			name:  "http-copy",
+		},
+		{
+			title: "metric not used",
+			name:  "unused",
+		},
+		{
+			title: "unknown prometheusRule",
+			name:  "unknown",
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			warnings, err := newTestService().GetPrometheusRuleDeletionWarnings("perses", test.name)
+			assert.NoError(t, err)
+			assert.Equal(t, test.warnings, warnings)
+		})
+	}
+}
+
+func newEvent(t *testing.T, eventType database.EventType, entity interface{}) database.WatchResponse {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return database.WatchResponse{Events: []database.Event{{Type: eventType, Value: data}}}
+}
+
+func TestIndexKeptUpToDate(t *testing.T) {
+	s := newTestService()
+	dashboardDAO := s.dashboardDAO.(*fakeDashboardDAO)
+	ruleDAO := s.ruleDAO.(*fakeRuleDAO)
+	dashboardDAO.events = make(chan database.WatchResponse)
+	ruleDAO.events = make(chan database.WatchResponse)
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan struct{})
+	go func() {
+		assert.NoError(t, s.Execute(ctx, cancel))
+		close(done)
+	}()
+	defer func() {
+		cancel()
+		<-done
+	}()
+	// the index is built once, then only the changes are applied to it
+	assert.Eventually(t, func() bool {
+		s.mutex.RLock()
+		defer s.mutex.RUnlock()
+		return s.idx != nil
+	}, time.Second, 10*time.Millisecond)
+
+	ruleDAO.events <- newEvent(t, database.EventDelete, ruleDAO.rules[0])
+	dashboardDAO.events <- newEvent(t, database.EventCreate, newDashboard("perses", "requests", "job:http_requests:rate5m"))
+	dashboardDAO.events <- newEvent(t, database.EventUpdate, newDashboard("perses", "api", "up"))
+
+	expected := &v1.MetricUsage{
+		Metric:     "job:http_requests:rate5m",
+		RecordedBy: []v1.MetricReference{},
+		UsedBy: []v1.MetricReference{
+			{Kind: v1.KindDashboard, Project: "perses", Name: "requests", Path: "spec.sections[0].panels[0].chart.lines[0].expr"},
+		},
+	}
+	// the last change received may not be applied yet
+	assert.Eventually(t, func() bool {
+		usage, err := s.GetMetricUsage("job:http_requests:rate5m")
+		return err == nil && assert.ObjectsAreEqual(expected, usage)
+	}, time.Second, 10*time.Millisecond)
+	assert.Equal(t, int32(1), atomic.LoadInt32(&dashboardDAO.lists))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&ruleDAO.lists))
+}
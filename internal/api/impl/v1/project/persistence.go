@@ -14,21 +14,37 @@
 package project
 
 import (
+	"context"
 	"time"
 
 	"github.com/perses/common/etcd"
 	"github.com/perses/perses/internal/api/interface/v1/project"
+	"github.com/perses/perses/internal/api/shared/database"
+	"github.com/perses/perses/internal/api/shared/metrics"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
+// resourceKeysTimeout is the maximum amount of time to list the keys of the resources belonging to a project.
+const resourceKeysTimeout = 30 * time.Second
+
+// resourcePrefixes returns the prefixes of the keys of the resources belonging to the project.
+func resourcePrefixes(name string) []string {
+	return []string{
+		v1.GenerateAlertmanagerConfigID(name, ""),
+		v1.GenerateDashboardID(name, ""),
+		v1.GenerateDatasourceID(name, ""),
+		v1.GeneratePrometheusRuleID(name, ""),
+		v1.GenerateSLOID(name, ""),
+	}
+}
+
 type dao struct {
 	project.DAO
-	client etcd.DAO
+	client database.DAO
 }
 
-func NewDAO(etcdClient *clientv3.Client, timeout time.Duration) project.DAO {
-	client := etcd.NewDAO(etcdClient, timeout)
+func NewDAO(persistence database.DAO) project.DAO {
+	client := metrics.NewInstrumentedDAO(persistence, string(v1.KindProject))
 	return &dao{
 		client: client,
 	}
@@ -41,7 +57,7 @@ func (d *dao) Create(entity *v1.Project) error {
 
 func (d *dao) Update(entity *v1.Project) error {
 	key := entity.GenerateID()
-	return d.client.Upsert(key, entity)
+	return d.client.Upsert(key, entity, entity.Metadata.Version)
 }
 
 func (d *dao) Get(name string) (*v1.Project, error) {
@@ -50,9 +66,9 @@ func (d *dao) Get(name string) (*v1.Project, error) {
 	return entity, d.client.Get(key, entity)
 }
 
-func (d *dao) Delete(name string) error {
+func (d *dao) Delete(name string, version uint64) error {
 	key := v1.GenerateProjectID(name)
-	return d.client.Delete(key)
+	return d.client.Delete(key, version)
 }
 
 func (d *dao) List(q etcd.Query) ([]*v1.Project, error) {
@@ -60,3 +76,37 @@ func (d *dao) List(q etcd.Query) ([]*v1.Project, error) {
 	err := d.client.Query(q, &result)
 	return result, err
 }
+
+func (d *dao) ResourceKeys(name string) ([]string, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), resourceKeysTimeout)
+	defer cancel()
+	var result []string
+	for _, prefix := range resourcePrefixes(name) {
+		keys, err := d.client.Keys(ctx, prefix)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, keys...)
+	}
+	return result, nil
+}
+
+func (d *dao) DeleteIfEmpty(name string, version uint64) error {
+	if len(name) == 0 {
+		// the prefixes would match the resources of every project
+		return &etcd.Error{Key: v1.GenerateProjectID(name), Code: etcd.ErrorCodeKeyNotFound}
+	}
+	return d.client.DeleteIfPrefixesEmpty(v1.GenerateProjectID(name), version, resourcePrefixes(name))
+}
+
+func (d *dao) DeleteWithResources(name string, version uint64) error {
+	if len(name) == 0 {
+		// the prefixes would match the resources of every project
+		return &etcd.Error{Key: v1.GenerateProjectID(name), Code: etcd.ErrorCodeKeyNotFound}
+	}
+	var prefixes []string
+	for _, prefix := range resourcePrefixes(name) {
+		prefixes = append(prefixes, prefix, v1.GenerateProjectRevisionPrefix(prefix))
+	}
+	return d.client.DeleteWithPrefixes(v1.GenerateProjectID(name), version, prefixes)
+}
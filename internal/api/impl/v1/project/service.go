@@ -14,12 +14,15 @@
 package project
 
 import (
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/perses/common/etcd"
 	"github.com/perses/perses/internal/api/interface/v1/project"
 	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/internal/api/shared/database"
 	"github.com/perses/perses/pkg/model/api"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
 	"github.com/sirupsen/logrus"
@@ -80,24 +83,80 @@ func (s *service) update(entity *v1.Project, parameters shared.Parameters) (*v1.
 	// update the field UpdatedAt with the new time
 	entity.Metadata.UpdatedAt = time.Now().UTC()
 	if err := s.dao.Update(entity); err != nil {
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to update the project '%s', it has been modified since the version given", entity.Metadata.Name)
+			return nil, shared.VersionConflictError
+		}
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to update the project '%s', it has been deleted in the meantime", entity.Metadata.Name)
+			return nil, shared.NotFoundError
+		}
 		logrus.WithError(err).Errorf("unable to perform the update of the project '%s', something wrong with etcd", entity.Metadata.Name)
 		return nil, shared.InternalError
 	}
 	return entity, nil
 }
 
+// Delete deletes the project only when it's empty, unless the deletion is cascaded to the resources it contains.
 func (s *service) Delete(parameters shared.Parameters) error {
-	if err := s.dao.Delete(parameters.Name); err != nil {
+	var err error
+	if parameters.Cascade {
+		err = s.dao.DeleteWithResources(parameters.Name, parameters.Version)
+	} else {
+		// the emptiness is checked in the same transaction as the deletion, so a resource created in the meantime isn't left orphan
+		err = s.dao.DeleteIfEmpty(parameters.Name, parameters.Version)
+	}
+	if err != nil {
+		if errors.Is(err, database.ErrPrefixNotEmpty) {
+			if err := s.checkEmpty(parameters.Name); err != nil {
+				return err
+			}
+			// the resources have been deleted since
+			return fmt.Errorf("%w: the project '%s' contained resources, retry its deletion", shared.InUseError, parameters.Name)
+		}
 		if etcd.IsKeyNotFound(err) {
 			logrus.Debugf("unable to find the project '%s'", parameters.Name)
 			return shared.NotFoundError
 		}
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to delete the project '%s', it has been modified since the version given", parameters.Name)
+			return shared.VersionConflictError
+		}
 		logrus.WithError(err).Errorf("unable to delete the project '%s', something wrong with etcd", parameters.Name)
 		return shared.InternalError
 	}
 	return nil
 }
 
+// checkEmpty returns an error listing the resources still contained in the project, if any.
+func (s *service) checkEmpty(name string) error {
+	keys, err := s.dao.ResourceKeys(name)
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to find the resources of the project '%s', something wrong with etcd", name)
+		return shared.InternalError
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+	// the keys are like /dashboards/<project>/<name>, the resources are counted per kind
+	var kinds []string
+	counts := make(map[string]int)
+	for _, key := range keys {
+		kind := strings.Split(key, "/")[1]
+		if counts[kind] == 0 {
+			kinds = append(kinds, kind)
+		}
+		counts[kind]++
+	}
+	messages := make([]string, 0, len(kinds))
+	for _, kind := range kinds {
+		messages = append(messages, fmt.Sprintf("%d %s", counts[kind], kind))
+	}
+	logrus.Debugf("unable to delete the project '%s', it still contains resources", name)
+	return fmt.Errorf("%w: project '%s' still contains %s, delete them first or use the query parameter %s=true",
+		shared.InUseError, name, strings.Join(messages, ", "), shared.ParamCascade)
+}
+
 func (s *service) Get(parameters shared.Parameters) (interface{}, error) {
 	entity, err := s.dao.Get(parameters.Name)
 	if err != nil {
@@ -114,3 +173,17 @@ func (s *service) Get(parameters shared.Parameters) (interface{}, error) {
 func (s *service) List(q etcd.Query, _ shared.Parameters) (interface{}, error) {
 	return s.dao.List(q)
 }
+
+// CheckExists returns a bad request error when the project doesn't exist,
+// so a resource cannot be created or moved into an unknown project.
+func CheckExists(dao project.DAO, name string) error {
+	if _, err := dao.Get(name); err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the project '%s'", name)
+			return fmt.Errorf("%w: project '%s' doesn't exist", shared.BadRequestError, name)
+		}
+		logrus.WithError(err).Errorf("unable to find the project '%s', something wrong with etcd", name)
+		return shared.InternalError
+	}
+	return nil
+}
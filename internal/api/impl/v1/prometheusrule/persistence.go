@@ -14,21 +14,22 @@
 package prometheusrule
 
 import (
-	"time"
+	"context"
 
 	"github.com/perses/common/etcd"
 	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/shared/database"
+	"github.com/perses/perses/internal/api/shared/metrics"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 type dao struct {
 	prometheusrule.DAO
-	client etcd.DAO
+	client database.DAO
 }
 
-func NewDAO(etcdClient *clientv3.Client, timeout time.Duration) prometheusrule.DAO {
-	client := etcd.NewDAO(etcdClient, timeout)
+func NewDAO(persistence database.DAO) prometheusrule.DAO {
+	client := metrics.NewInstrumentedDAO(persistence, string(v1.KindPrometheusRule))
 	return &dao{
 		client: client,
 	}
@@ -41,12 +42,12 @@ func (d *dao) Create(entity *v1.PrometheusRule) error {
 
 func (d *dao) Update(entity *v1.PrometheusRule) error {
 	key := entity.GenerateID()
-	return d.client.Upsert(key, entity)
+	return d.client.Upsert(key, entity, entity.Metadata.Version)
 }
 
-func (d *dao) Delete(project string, name string) error {
+func (d *dao) Delete(project string, name string, version uint64) error {
 	key := v1.GeneratePrometheusRuleID(project, name)
-	return d.client.Delete(key)
+	return d.client.Delete(key, version)
 }
 
 func (d *dao) Get(project string, name string) (*v1.PrometheusRule, error) {
@@ -60,3 +61,7 @@ func (d *dao) List(q etcd.Query) ([]*v1.PrometheusRule, error) {
 	err := d.client.Query(q, &result)
 	return result, err
 }
+
+func (d *dao) Watch(ctx context.Context) (database.WatchChan, error) {
+	return d.client.Watch(ctx, &prometheusrule.Query{}, 0)
+}
@@ -0,0 +1,114 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheusrule
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+)
+
+// lint checks every rule against every policy and returns all the violations found.
+// The policies are supposed to be valid, as they are validated when the project is unmarshalled.
+func lint(spec v1.PrometheusRuleSpec, policies []v1.RulePolicy) []v1.RuleViolation {
+	var violations []v1.RuleViolation
+	for _, policy := range policies {
+		var pattern *regexp.Regexp
+		if policy.Kind == v1.RulePolicyKindRecordName {
+			pattern = regexp.MustCompile(policy.Pattern)
+		}
+		for _, group := range spec.Groups {
+			for _, rule := range group.Rules {
+				message := checkPolicy(policy, pattern, rule)
+				if len(message) == 0 {
+					continue
+				}
+				name := rule.Alert
+				if len(rule.Record) > 0 {
+					name = rule.Record
+				}
+				violations = append(violations, v1.RuleViolation{
+					Group:    group.Name,
+					Rule:     name,
+					Policy:   policy.Kind,
+					Severity: policy.Severity,
+					Message:  message,
+				})
+			}
+		}
+	}
+	return violations
+}
+
+// checkPolicy returns a message describing the violation of the policy by the rule, or an empty string if the rule respects it.
+func checkPolicy(policy v1.RulePolicy, pattern *regexp.Regexp, rule v1.Rule) string {
+	if policy.Kind == v1.RulePolicyKindRecordName {
+		if len(rule.Record) == 0 || pattern.MatchString(rule.Record) {
+			return ""
+		}
+		return fmt.Sprintf("recording rule name doesn't match the pattern '%s'", policy.Pattern)
+	}
+	// the other policies only concern the alerting rules selected by the policy
+	if len(rule.Alert) == 0 || !matchSelector(policy.Selector, rule.Labels) {
+		return ""
+	}
+	switch policy.Kind {
+	case v1.RulePolicyKindLabel:
+		value, ok := rule.Labels[policy.Name]
+		if !ok {
+			return fmt.Sprintf("label '%s' is missing", policy.Name)
+		}
+		if len(policy.Values) > 0 && !contains(policy.Values, value) {
+			return fmt.Sprintf("label '%s' has the value '%s', allowed values are: %s", policy.Name, value, strings.Join(policy.Values, ", "))
+		}
+	case v1.RulePolicyKindAnnotation:
+		if len(rule.Annotations[policy.Name]) == 0 {
+			return fmt.Sprintf("annotation '%s' is missing", policy.Name)
+		}
+	case v1.RulePolicyKindMinimumFor:
+		var forDuration model.Duration
+		if len(rule.For) > 0 {
+			var err error
+			if forDuration, err = model.ParseDuration(rule.For); err != nil {
+				// an invalid duration is already reported by the syntax check of the rules
+				return ""
+			}
+		}
+		if forDuration < policy.Duration {
+			return fmt.Sprintf("'for' is %s, it must be at least %s", forDuration, policy.Duration)
+		}
+	}
+	return ""
+}
+
+func matchSelector(selector map[string]string, labels map[string]string) bool {
+	for name, value := range selector {
+		if labels[name] != value {
+			return false
+		}
+	}
+	return true
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,227 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package prometheusrule
+
+import (
+	"encoding/json"
+	"errors"
+	"testing"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/project"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func unmarshalPolicies(t *testing.T, data string) []v1.RulePolicy {
+	var policies []v1.RulePolicy
+	if err := json.Unmarshal([]byte(data), &policies); err != nil {
+		t.Fatal(err)
+	}
+	return policies
+}
+
+const sreGuidelines = `[
+  {"kind": "Label", "name": "severity", "values": ["page", "ticket"]},
+  {"kind": "Annotation", "name": "summary"},
+  {"kind": "Annotation", "name": "runbook_url", "severity": "warning"},
+  {"kind": "RecordName"},
+  {"kind": "MinimumFor", "duration": "5m", "selector": {"severity": "page"}}
+]`
+
+func TestLint(t *testing.T) {
+	testSuite := []struct {
+		title      string
+		rules      []v1.Rule
+		violations []v1.RuleViolation
+	}{
+		{
+			title: "rules respecting the policies",
+			rules: []v1.Rule{
+				{
+					Alert:       "InstanceDown",
+					Expr:        "up == 0",
+					For:         "10m",
+					Labels:      map[string]string{"severity": "page"},
+					Annotations: map[string]string{"summary": "instance down", "runbook_url": "https://runbook/instance-down"},
+				},
+				{
+					Alert:       "HighLatency",
+					Expr:        "job:request_latency_seconds:mean5m > 1",
+					Labels:      map[string]string{"severity": "ticket"},
+					Annotations: map[string]string{"summary": "high latency", "runbook_url": "https://runbook/high-latency"},
+				},
+				{
+					Record: "job:request_latency_seconds:mean5m",
+					Expr:   "avg by (job) (rate(request_latency_seconds_sum[5m]))",
+				},
+			},
+		},
+		{
+			title: "every violation is returned",
+			rules: []v1.Rule{
+				{
+					Alert:  "InstanceDown",
+					Expr:   "up == 0",
+					For:    "1m",
+					Labels: map[string]string{"severity": "page"},
+				},
+				{
+					Alert:       "HighLatency",
+					Expr:        "job:request_latency_seconds:mean5m > 1",
+					Labels:      map[string]string{"severity": "critical"},
+					Annotations: map[string]string{"summary": "high latency", "runbook_url": "https://runbook/high-latency"},
+				},
+				{
+					Record: "request_latency",
+					Expr:   "avg by (job) (rate(request_latency_seconds_sum[5m]))",
+				},
+			},
+			violations: []v1.RuleViolation{
+				{Group: "test", Rule: "HighLatency", Policy: v1.RulePolicyKindLabel, Severity: v1.PolicySeverityError, Message: "label 'severity' has the value 'critical', allowed values are: page, ticket"},
+				{Group: "test", Rule: "InstanceDown", Policy: v1.RulePolicyKindAnnotation, Severity: v1.PolicySeverityError, Message: "annotation 'summary' is missing"},
+				{Group: "test", Rule: "InstanceDown", Policy: v1.RulePolicyKindAnnotation, Severity: v1.PolicySeverityWarning, Message: "annotation 'runbook_url' is missing"},
+				{Group: "test", Rule: "request_latency", Policy: v1.RulePolicyKindRecordName, Severity: v1.PolicySeverityError, Message: "recording rule name doesn't match the pattern '" + v1.DefaultRecordNamePattern + "'"},
+				{Group: "test", Rule: "InstanceDown", Policy: v1.RulePolicyKindMinimumFor, Severity: v1.PolicySeverityError, Message: "'for' is 1m, it must be at least 5m"},
+			},
+		},
+		{
+			title: "missing for on a paging alert",
+			rules: []v1.Rule{
+				{
+					Alert:       "InstanceDown",
+					Expr:        "up == 0",
+					Labels:      map[string]string{"severity": "page"},
+					Annotations: map[string]string{"summary": "instance down", "runbook_url": "https://runbook/instance-down"},
+				},
+			},
+			violations: []v1.RuleViolation{
+				{Group: "test", Rule: "InstanceDown", Policy: v1.RulePolicyKindMinimumFor, Severity: v1.PolicySeverityError, Message: "'for' is 0s, it must be at least 5m"},
+			},
+		},
+	}
+	policies := unmarshalPolicies(t, sreGuidelines)
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			spec := v1.PrometheusRuleSpec{Groups: []v1.RuleGroup{{Name: "test", Rules: test.rules}}}
+			assert.Equal(t, test.violations, lint(spec, policies))
+		})
+	}
+}
+
+func TestRulePolicyValidation(t *testing.T) {
+	testSuite := []struct {
+		title  string
+		policy string
+	}{
+		{
+			title:  "unknown kind",
+			policy: `[{"kind": "Unknown"}]`,
+		},
+		{
+			title:  "unknown severity",
+			policy: `[{"kind": "Annotation", "name": "summary", "severity": "info"}]`,
+		},
+		{
+			title:  "label without name",
+			policy: `[{"kind": "Label"}]`,
+		},
+		{
+			title:  "invalid pattern",
+			policy: `[{"kind": "RecordName", "pattern": "("}]`,
+		},
+		{
+			title:  "minimum for without duration",
+			policy: `[{"kind": "MinimumFor"}]`,
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			var policies []v1.RulePolicy
+			assert.Error(t, json.Unmarshal([]byte(test.policy), &policies))
+		})
+	}
+}
+
+type fakeProjectDAO struct {
+	project.DAO
+	project *v1.Project
+}
+
+func (d *fakeProjectDAO) Get(name string) (*v1.Project, error) {
+	if d.project == nil {
+		return nil, &etcd.Error{Key: v1.GenerateProjectID(name), Code: etcd.ErrorCodeKeyNotFound}
+	}
+	return d.project, nil
+}
+
+func TestApplyPolicies(t *testing.T) {
+	newRule := func(severity string) *v1.PrometheusRule {
+		return &v1.PrometheusRule{
+			Kind: v1.KindPrometheusRule,
+			Metadata: v1.ProjectMetadata{
+				Metadata: v1.Metadata{Name: "node"},
+				Project:  "perses",
+			},
+			Spec: v1.PrometheusRuleSpec{Groups: []v1.RuleGroup{{
+				Name: "node",
+				Rules: []v1.Rule{{
+					Alert:       "InstanceDown",
+					Expr:        "up == 0",
+					For:         "5m",
+					Labels:      map[string]string{"severity": severity},
+					Annotations: map[string]string{"summary": "instance down"},
+				}},
+			}}},
+			Status: &v1.PrometheusRuleStatus{Warnings: []v1.RuleViolation{{Message: "sent by the client"}}},
+		}
+	}
+	s := &service{projectDAO: &fakeProjectDAO{project: &v1.Project{
+		Kind:     v1.KindProject,
+		Metadata: v1.Metadata{Name: "perses"},
+		Spec:     &v1.ProjectSpec{RulePolicies: unmarshalPolicies(t, sreGuidelines)},
+	}}}
+
+	// only a warning: the rules are accepted and the warning is set in the status
+	entity := newRule("page")
+	assert.NoError(t, s.applyPolicies(entity))
+	if assert.NotNil(t, entity.Status) {
+		assert.Equal(t, []v1.RuleViolation{{
+			Group:    "node",
+			Rule:     "InstanceDown",
+			Policy:   v1.RulePolicyKindAnnotation,
+			Severity: v1.PolicySeverityWarning,
+			Message:  "annotation 'runbook_url' is missing",
+		}}, entity.Status.Warnings)
+	}
+
+	// an error: the rules are rejected and the message contains every violation
+	err := s.applyPolicies(newRule("critical"))
+	assert.True(t, errors.Is(err, shared.BadRequestError))
+	assert.Contains(t, err.Error(), "[error] node/InstanceDown: label 'severity' has the value 'critical'")
+	assert.Contains(t, err.Error(), "[warning] node/InstanceDown: annotation 'runbook_url' is missing")
+
+	// no policy: the status sent by the client is dropped
+	s.projectDAO = &fakeProjectDAO{project: &v1.Project{Kind: v1.KindProject, Metadata: v1.Metadata{Name: "perses"}}}
+	entity = newRule("critical")
+	assert.NoError(t, s.applyPolicies(entity))
+	assert.Nil(t, entity.Status)
+
+	// unknown project: the rules are rejected
+	s.projectDAO = &fakeProjectDAO{}
+	err = s.applyPolicies(newRule("page"))
+	assert.True(t, errors.Is(err, shared.BadRequestError))
+	assert.Contains(t, err.Error(), "project 'perses' doesn't exist")
+}
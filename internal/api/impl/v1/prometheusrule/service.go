@@ -15,9 +15,12 @@ package prometheusrule
 
 import (
 	"fmt"
+	"strings"
 	"time"
 
 	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/metric_dependency"
+	"github.com/perses/perses/internal/api/interface/v1/project"
 	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
 	"github.com/perses/perses/internal/api/shared"
 	"github.com/perses/perses/pkg/model/api"
@@ -27,8 +30,9 @@ import (
 	"gopkg.in/yaml.v2"
 )
 
-func checkRule(ruleSpec v1.PrometheusRuleSpec) error {
-	data, err := yaml.Marshal(ruleSpec)
+// CheckRule verifies that the rules can be loaded by Prometheus.
+func CheckRule(ruleSpec v1.PrometheusRuleSpec) error {
+	data, err := yaml.Marshal(ruleSpec.RuleFile())
 	if err != nil {
 		logrus.WithError(err).Error("unable to marshal the ruleSpec")
 		return shared.InternalError
@@ -42,15 +46,55 @@ func checkRule(ruleSpec v1.PrometheusRuleSpec) error {
 
 type service struct {
 	prometheusrule.Service
-	dao prometheusrule.DAO
+	dao               prometheusrule.DAO
+	projectDAO        project.DAO
+	dependencyService metric_dependency.Service
 }
 
-func NewService(dao prometheusrule.DAO) prometheusrule.Service {
+func NewService(dao prometheusrule.DAO, projectDAO project.DAO, dependencyService metric_dependency.Service) prometheusrule.Service {
 	return &service{
-		dao: dao,
+		dao:               dao,
+		projectDAO:        projectDAO,
+		dependencyService: dependencyService,
 	}
 }
 
+// applyPolicies lints the rules with the policies of the project, which must exist.
+// The rules are rejected if at least one policy with the severity error is violated, otherwise the violations are set as warnings in the status.
+func (s *service) applyPolicies(entity *v1.PrometheusRule) error {
+	// the status is computed by the server, whatever is sent by the client
+	entity.Status = nil
+	projectObject, err := s.projectDAO.Get(entity.Metadata.Project)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the project '%s'", entity.Metadata.Project)
+			return fmt.Errorf("%w: project '%s' doesn't exist", shared.BadRequestError, entity.Metadata.Project)
+		}
+		logrus.WithError(err).Errorf("unable to find the project '%s', something wrong with etcd", entity.Metadata.Project)
+		return shared.InternalError
+	}
+	if projectObject.Spec == nil {
+		return nil
+	}
+	violations := lint(entity.Spec, projectObject.Spec.RulePolicies)
+	if len(violations) == 0 {
+		return nil
+	}
+	rejected := false
+	messages := make([]string, 0, len(violations))
+	for _, violation := range violations {
+		if violation.Severity == v1.PolicySeverityError {
+			rejected = true
+		}
+		messages = append(messages, fmt.Sprintf("[%s] %s", violation.Severity, violation))
+	}
+	if rejected {
+		return fmt.Errorf("%w: rules don't respect the policies of the project: %s", shared.BadRequestError, strings.Join(messages, "; "))
+	}
+	entity.Status = &v1.PrometheusRuleStatus{Warnings: violations}
+	return nil
+}
+
 func (s *service) Create(entity api.Entity) (interface{}, error) {
 	if ruleObject, ok := entity.(*v1.PrometheusRule); ok {
 		return s.create(ruleObject)
@@ -58,14 +102,20 @@ func (s *service) Create(entity api.Entity) (interface{}, error) {
 	return nil, fmt.Errorf("%w: wrong entity format, attempting prometheusRule format, received '%T'", shared.BadRequestError, entity)
 }
 
-func (s *service) create(entity *v1.PrometheusRule) (*v1.PrometheusRule, error) {
-	// Note: you don't need to check that the project exists since once the permission middleware will be in place,
-	// it won't be possible to create a resources into a not known project
+// Validate checks the rules are correct and then applies the policies of the project, it also checks the project exists.
+func (s *service) Validate(entity *v1.PrometheusRule) error {
+	if err := CheckRule(entity.Spec); err != nil {
+		return err
+	}
+	return s.applyPolicies(entity)
+}
 
-	// check that the rules are correct
-	if err := checkRule(entity.Spec); err != nil {
+func (s *service) create(entity *v1.PrometheusRule) (*v1.PrometheusRule, error) {
+	if err := s.Validate(entity); err != nil {
 		return nil, err
 	}
+	// only the server can generate a managed prometheusRule
+	entity.Metadata.ManagedBy = ""
 	// Update the time contains in the entity
 	entity.Metadata.CreateNow()
 	if err := s.dao.Create(entity); err != nil {
@@ -97,8 +147,7 @@ func (s *service) update(entity *v1.PrometheusRule, parameters shared.Parameters
 		logrus.Debugf("project in prometheusRule '%s' and coming from the http request: '%s' doesn't match", entity.Metadata.Project, parameters.Project)
 		return nil, fmt.Errorf("%w: metadata.project and the project name in the http path request doesn't match", shared.BadRequestError)
 	}
-	// check that the rules are correct
-	if err := checkRule(entity.Spec); err != nil {
+	if err := s.Validate(entity); err != nil {
 		return nil, err
 	}
 	// find the previous version of the prometheusRule
@@ -107,23 +156,57 @@ func (s *service) update(entity *v1.PrometheusRule, parameters shared.Parameters
 		return nil, err
 	}
 	oldObject := oldEntity.(*v1.PrometheusRule)
+	if err := shared.CheckNotManaged(oldObject.Metadata); err != nil {
+		return nil, err
+	}
+	entity.Metadata.ManagedBy = ""
 	// update the immutable field of the newEntity with the old one
 	entity.Metadata.CreatedAt = oldObject.Metadata.CreatedAt
 	// update the field UpdatedAt with the new time
 	entity.Metadata.UpdatedAt = time.Now().UTC()
 	if err := s.dao.Update(entity); err != nil {
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to update the prometheusRule '%s', it has been modified since the version given", entity.Metadata.Name)
+			return nil, shared.VersionConflictError
+		}
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to update the prometheusRule '%s', it has been deleted in the meantime", entity.Metadata.Name)
+			return nil, shared.NotFoundError
+		}
 		logrus.WithError(err).Errorf("unable to perform the update of the prometheusRule '%s', something wrong with etcd", entity.Metadata.Name)
 		return nil, shared.InternalError
 	}
 	return entity, nil
 }
 
+// DeletionWarnings warns when the prometheusRule records metrics that are still used by dashboards or by other rules.
+func (s *service) DeletionWarnings(parameters shared.Parameters) ([]string, error) {
+	return s.dependencyService.GetPrometheusRuleDeletionWarnings(parameters.Project, parameters.Name)
+}
+
 func (s *service) Delete(parameters shared.Parameters) error {
-	if err := s.dao.Delete(parameters.Project, parameters.Name); err != nil {
+	oldEntity, err := s.Get(parameters)
+	if err != nil {
+		return err
+	}
+	oldObject := oldEntity.(*v1.PrometheusRule)
+	if err := shared.CheckNotManaged(oldObject.Metadata); err != nil {
+		return err
+	}
+	version := parameters.Version
+	if version == 0 {
+		// delete the version checked, so it cannot have become managed in the meantime
+		version = oldObject.Metadata.Version
+	}
+	if err := s.dao.Delete(parameters.Project, parameters.Name, version); err != nil {
 		if etcd.IsKeyNotFound(err) {
 			logrus.Debugf("unable to find the project '%s'", parameters.Name)
 			return shared.NotFoundError
 		}
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to delete the prometheusRule '%s', it has been modified since the version given", parameters.Name)
+			return shared.VersionConflictError
+		}
 		logrus.WithError(err).Errorf("unable to delete the project '%s', something wrong with etcd", parameters.Name)
 		return shared.InternalError
 	}
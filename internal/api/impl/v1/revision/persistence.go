@@ -0,0 +1,59 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revision
+
+import (
+	"sort"
+
+	"github.com/perses/perses/internal/api/interface/v1/revision"
+	"github.com/perses/perses/internal/api/shared/database"
+	"github.com/perses/perses/internal/api/shared/metrics"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+type dao struct {
+	revision.DAO
+	client database.DAO
+}
+
+func NewDAO(persistence database.DAO) revision.DAO {
+	client := metrics.NewInstrumentedDAO(persistence, "Revision")
+	return &dao{
+		client: client,
+	}
+}
+
+func (d *dao) Create(key string, entity *v1.Revision) error {
+	return d.client.Create(v1.GenerateRevisionID(key, entity.Number), entity)
+}
+
+func (d *dao) Delete(key string, number uint64) error {
+	return d.client.Delete(v1.GenerateRevisionID(key, number), 0)
+}
+
+func (d *dao) Get(key string, number uint64) (*v1.Revision, error) {
+	entity := &v1.Revision{}
+	return entity, d.client.Get(v1.GenerateRevisionID(key, number), entity)
+}
+
+func (d *dao) List(key string) ([]*v1.Revision, error) {
+	var result []*v1.Revision
+	if err := d.client.Query(&revision.Query{Key: key}, &result); err != nil {
+		return nil, err
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].Number < result[j].Number
+	})
+	return result, nil
+}
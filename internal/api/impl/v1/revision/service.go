@@ -0,0 +1,210 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revision
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/revision"
+	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/internal/config"
+	"github.com/perses/perses/pkg/model/api"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/pmezard/go-difflib/difflib"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+// maxCreateAttempts is the number of times a revision is created before giving up, when its number is always taken
+// by a concurrent modification of the resource.
+const maxCreateAttempts = 5
+
+type service struct {
+	revision.Service
+	dao  revision.DAO
+	conf config.HistoryConfig
+}
+
+func NewService(dao revision.DAO, conf config.HistoryConfig) revision.Service {
+	return &service{
+		dao:  dao,
+		conf: conf,
+	}
+}
+
+func (s *service) Record(entity api.Entity, operation v1.RevisionOperation, author string, restoredFrom uint64) {
+	if err := s.record(entity, operation, author, restoredFrom); err != nil {
+		logrus.WithError(err).Errorf("unable to record the revision of the resource '%s'", entity.GenerateID())
+	}
+}
+
+func (s *service) record(entity api.Entity, operation v1.RevisionOperation, author string, restoredFrom uint64) error {
+	data, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	var kind struct {
+		Kind v1.Kind `json:"kind"`
+	}
+	if err := json.Unmarshal(data, &kind); err != nil {
+		return err
+	}
+	retention := s.conf.GetRetention(kind.Kind)
+	if retention == 0 {
+		return nil
+	}
+	key := entity.GenerateID()
+	newRevision := &v1.Revision{
+		Operation:    operation,
+		Author:       author,
+		Date:         time.Now().UTC(),
+		RestoredFrom: restoredFrom,
+	}
+	if operation != v1.RevisionDeleted {
+		newRevision.Resource = data
+	}
+	revisions, err := s.create(key, newRevision)
+	if err != nil {
+		return err
+	}
+	// remove the oldest revisions beyond the retention
+	revisions = append(revisions, newRevision)
+	for i := 0; uint64(len(revisions)-i) > retention; i++ {
+		if err := s.dao.Delete(key, revisions[i].Number); err != nil && !etcd.IsKeyNotFound(err) {
+			return err
+		}
+	}
+	return nil
+}
+
+// create stores the revision with the number following the one of the latest revision, and returns the revisions
+// that were already there. When the resource is modified concurrently, the number can be taken in the meantime,
+// so the creation is retried with the next one.
+func (s *service) create(key string, newRevision *v1.Revision) ([]*v1.Revision, error) {
+	for attempt := 1; ; attempt++ {
+		revisions, err := s.dao.List(key)
+		if err != nil {
+			return nil, err
+		}
+		newRevision.Number = 1
+		if len(revisions) > 0 {
+			newRevision.Number = revisions[len(revisions)-1].Number + 1
+		}
+		err = s.dao.Create(key, newRevision)
+		if err == nil {
+			return revisions, nil
+		}
+		if !etcd.IsKeyConflict(err) || attempt == maxCreateAttempts {
+			return nil, err
+		}
+	}
+}
+
+func (s *service) ListRevisions(key string) ([]*v1.Revision, error) {
+	revisions, err := s.dao.List(key)
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to get the revisions of the resource '%s'", key)
+		return nil, shared.InternalError
+	}
+	for _, rev := range revisions {
+		rev.Resource = nil
+	}
+	return revisions, nil
+}
+
+func (s *service) GetRevision(key string, number uint64) (*v1.Revision, error) {
+	entity, err := s.dao.Get(key, number)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the revision %d of the resource '%s'", number, key)
+			return nil, fmt.Errorf("%w: revision %d doesn't exist", shared.NotFoundError, number)
+		}
+		logrus.WithError(err).Errorf("unable to get the revision %d of the resource '%s'", number, key)
+		return nil, shared.InternalError
+	}
+	return entity, nil
+}
+
+func (s *service) DiffRevisions(key string, from uint64, to uint64) (*v1.RevisionDiff, error) {
+	fromRevision, err := s.GetRevision(key, from)
+	if err != nil {
+		return nil, err
+	}
+	toRevision, err := s.getRevisionOrLatest(key, to)
+	if err != nil {
+		return nil, err
+	}
+	fromYAML, err := resourceToYAML(fromRevision.Resource)
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to decode the revision %d of the resource '%s'", from, key)
+		return nil, shared.InternalError
+	}
+	toYAML, err := resourceToYAML(toRevision.Resource)
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to decode the revision %d of the resource '%s'", toRevision.Number, key)
+		return nil, shared.InternalError
+	}
+	diff, err := difflib.GetUnifiedDiffString(difflib.UnifiedDiff{
+		A:        difflib.SplitLines(fromYAML),
+		B:        difflib.SplitLines(toYAML),
+		FromFile: fmt.Sprintf("revision %d", from),
+		ToFile:   fmt.Sprintf("revision %d", toRevision.Number),
+		Context:  3,
+	})
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to compare the revisions of the resource '%s'", key)
+		return nil, shared.InternalError
+	}
+	return &v1.RevisionDiff{
+		From: from,
+		To:   toRevision.Number,
+		Diff: diff,
+	}, nil
+}
+
+func (s *service) getRevisionOrLatest(key string, number uint64) (*v1.Revision, error) {
+	if number != 0 {
+		return s.GetRevision(key, number)
+	}
+	revisions, err := s.dao.List(key)
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to get the revisions of the resource '%s'", key)
+		return nil, shared.InternalError
+	}
+	if len(revisions) == 0 {
+		return nil, fmt.Errorf("%w: the resource doesn't have any revision", shared.NotFoundError)
+	}
+	return revisions[len(revisions)-1], nil
+}
+
+// resourceToYAML returns the resource of a revision written in YAML, without the fields changing at every
+// modification, so they don't appear in the diff. A deleted resource is an empty document.
+func resourceToYAML(resource json.RawMessage) (string, error) {
+	if len(resource) == 0 {
+		return "", nil
+	}
+	var document map[string]interface{}
+	if err := json.Unmarshal(resource, &document); err != nil {
+		return "", err
+	}
+	if metadata, ok := document["metadata"].(map[string]interface{}); ok {
+		delete(metadata, "version")
+		delete(metadata, "updated_at")
+	}
+	data, err := yaml.Marshal(document)
+	return string(data), err
+}
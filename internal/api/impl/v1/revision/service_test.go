@@ -0,0 +1,158 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revision
+
+import (
+	"errors"
+	"net/url"
+	"testing"
+
+	"github.com/perses/perses/internal/api/interface/v1/revision"
+	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/internal/api/shared/database"
+	"github.com/perses/perses/internal/config"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func newProject(name string) *v1.Project {
+	return &v1.Project{
+		Kind:     v1.KindProject,
+		Metadata: v1.Metadata{Name: name},
+	}
+}
+
+func newDatasource(project string, name string) *v1.Datasource {
+	return &v1.Datasource{
+		Kind:     v1.KindDatasource,
+		Metadata: v1.OptionalProjectMetadata{Metadata: v1.Metadata{Name: name}, Project: project},
+		Spec:     v1.DatasourceSpec{Kind: v1.KindPrometheusDatasource, URL: &url.URL{Scheme: "http", Host: "localhost:9090"}},
+	}
+}
+
+func revisionNumbers(t *testing.T, s *service, key string) []uint64 {
+	revisions, err := s.ListRevisions(key)
+	assert.NoError(t, err)
+	numbers := []uint64{}
+	for _, revision := range revisions {
+		assert.Empty(t, revision.Resource)
+		numbers = append(numbers, revision.Number)
+	}
+	return numbers
+}
+
+func TestRecordRetention(t *testing.T) {
+	testSuite := []struct {
+		title    string
+		conf     config.HistoryConfig
+		records  int
+		expected []uint64
+	}{
+		{
+			title:    "default retention",
+			records:  12,
+			expected: []uint64{3, 4, 5, 6, 7, 8, 9, 10, 11, 12},
+		},
+		{
+			title:    "retention of the kind",
+			conf:     config.HistoryConfig{DefaultRetention: 1, Retention: map[v1.Kind]uint64{v1.KindProject: 3}},
+			records:  5,
+			expected: []uint64{3, 4, 5},
+		},
+		{
+			title:    "history disabled for the kind",
+			conf:     config.HistoryConfig{Retention: map[v1.Kind]uint64{v1.KindProject: 0}},
+			records:  2,
+			expected: []uint64{},
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			s := NewService(NewDAO(database.NewMemoryDAO()), test.conf).(*service)
+			project := newProject("perses")
+			for i := 0; i < test.records; i++ {
+				s.Record(project, v1.RevisionUpdated, "", 0)
+			}
+			assert.Equal(t, test.expected, revisionNumbers(t, s, project.GenerateID()))
+			// the history of another resource is not affected
+			assert.Equal(t, []uint64{}, revisionNumbers(t, s, newProject("perses-dev").GenerateID()))
+		})
+	}
+}
+
+func TestRecordGlobalAndProjectResources(t *testing.T) {
+	s := NewService(NewDAO(database.NewMemoryDAO()), config.HistoryConfig{DefaultRetention: 2}).(*service)
+	global := newDatasource("", "perses")
+	inProject := newDatasource("perses", "node")
+	s.Record(global, v1.RevisionCreated, "", 0)
+	for i := 0; i < 3; i++ {
+		s.Record(inProject, v1.RevisionUpdated, "", 0)
+	}
+	// the global datasource perses doesn't share its history with the datasources of the project perses
+	assert.Equal(t, []uint64{1}, revisionNumbers(t, s, global.GenerateID()))
+	assert.Equal(t, []uint64{2, 3}, revisionNumbers(t, s, inProject.GenerateID()))
+}
+
+// concurrentDAO creates a revision with the same number just before the first creation, like a concurrent modification would do.
+type concurrentDAO struct {
+	revision.DAO
+	created bool
+}
+
+func (d *concurrentDAO) Create(key string, entity *v1.Revision) error {
+	if !d.created {
+		d.created = true
+		if err := d.DAO.Create(key, &v1.Revision{Number: entity.Number, Operation: v1.RevisionUpdated}); err != nil {
+			return err
+		}
+	}
+	return d.DAO.Create(key, entity)
+}
+
+func TestRecordConcurrentModification(t *testing.T) {
+	s := NewService(&concurrentDAO{DAO: NewDAO(database.NewMemoryDAO())}, config.HistoryConfig{}).(*service)
+	project := newProject("perses")
+	s.Record(project, v1.RevisionCreated, "", 0)
+	// the number taken by the concurrent modification is not lost, the revision gets the next one
+	assert.Equal(t, []uint64{1, 2}, revisionNumbers(t, s, project.GenerateID()))
+	revision, err := s.GetRevision(project.GenerateID(), 2)
+	assert.NoError(t, err)
+	assert.Equal(t, v1.RevisionCreated, revision.Operation)
+}
+
+func TestDiffRevisions(t *testing.T) {
+	s := NewService(NewDAO(database.NewMemoryDAO()), config.HistoryConfig{})
+	project := newProject("perses")
+	key := project.GenerateID()
+	project.Metadata.CreateNow()
+	s.Record(project, v1.RevisionCreated, "", 0)
+	project.Metadata.Version = 42
+	s.Record(project, v1.RevisionUpdated, "", 0)
+	s.Record(project, v1.RevisionDeleted, "", 0)
+
+	diff, err := s.DiffRevisions(key, 1, 2)
+	assert.NoError(t, err)
+	assert.Equal(t, &v1.RevisionDiff{From: 1, To: 2}, diff)
+
+	diff, err = s.DiffRevisions(key, 1, 0)
+	assert.NoError(t, err)
+	assert.Equal(t, uint64(3), diff.To)
+	assert.Contains(t, diff.Diff, "--- revision 1\n+++ revision 3\n")
+	assert.Contains(t, diff.Diff, "-kind: Project\n")
+
+	_, err = s.DiffRevisions(key, 4, 0)
+	assert.True(t, errors.Is(err, shared.NotFoundError))
+	_, err = s.DiffRevisions(newProject("perses-dev").GenerateID(), 1, 0)
+	assert.True(t, errors.Is(err, shared.NotFoundError))
+}
@@ -0,0 +1,55 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulefile
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/perses/internal/api/interface/v1/rulefile"
+	"github.com/perses/perses/internal/api/shared"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+const contentTypeYAML = "application/yaml"
+
+type Endpoint struct {
+	service rulefile.Service
+}
+
+func NewEndpoint(service rulefile.Service) *Endpoint {
+	return &Endpoint{
+		service: service,
+	}
+}
+
+func (e *Endpoint) RegisterRoutes(g *echo.Group) {
+	g.GET(fmt.Sprintf("/%s", shared.PathRuleFile), e.Get)
+	g.GET(fmt.Sprintf("/%s/:%s/%s", shared.PathProject, shared.ParamProject, shared.PathRuleFile), e.Get)
+}
+
+func (e *Endpoint) Get(ctx echo.Context) error {
+	ruleFile, err := e.service.Get(ctx.Param(shared.ParamProject))
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	data, err := yaml.Marshal(ruleFile)
+	if err != nil {
+		logrus.WithError(err).Error("unable to marshal the rule file")
+		return shared.HandleError(shared.InternalError)
+	}
+	return ctx.Blob(http.StatusOK, contentTypeYAML, data)
+}
@@ -0,0 +1,77 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulefile
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/rulefile"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// merge puts the rule groups of every PrometheusRule in a single rule file.
+// A rule file cannot contain two groups with the same name. When it happens, the group is renamed using the project and
+// the name of the PrometheusRule it comes from. The alerting rules get the label of their project, so the alerts are routed
+// to the AlertmanagerConfig of the project. The rules are sorted before being merged, so the result is always the same.
+func merge(rules []*v1.PrometheusRule) *v1.PrometheusRuleSpec {
+	sort.Slice(rules, func(i, j int) bool {
+		if rules[i].Metadata.Project != rules[j].Metadata.Project {
+			return rules[i].Metadata.Project < rules[j].Metadata.Project
+		}
+		return rules[i].Metadata.Name < rules[j].Metadata.Name
+	})
+	result := &v1.PrometheusRuleSpec{Groups: []v1.RuleGroup{}}
+	groupNames := make(map[string]bool)
+	for _, rule := range rules {
+		for _, group := range rule.Spec.WithProjectLabel(rule.Metadata.Project).Groups {
+			name := group.Name
+			if groupNames[name] {
+				name = fmt.Sprintf("%s/%s/%s", rule.Metadata.Project, rule.Metadata.Name, group.Name)
+				// the same PrometheusRule can contain twice the same group name if it has not been validated by Prometheus
+				for i := 1; groupNames[name]; i++ {
+					name = fmt.Sprintf("%s/%s/%s_%d", rule.Metadata.Project, rule.Metadata.Name, group.Name, i)
+				}
+				logrus.Debugf("the group '%s' of the prometheusRule '%s' in the project '%s' is renamed '%s' since the name is already used", group.Name, rule.Metadata.Name, rule.Metadata.Project, name)
+			}
+			groupNames[name] = true
+			group.Name = name
+			result.Groups = append(result.Groups, group)
+		}
+	}
+	return result
+}
+
+type service struct {
+	rulefile.Service
+	dao prometheusrule.DAO
+}
+
+func NewService(dao prometheusrule.DAO) rulefile.Service {
+	return &service{
+		dao: dao,
+	}
+}
+
+func (s *service) Get(project string) (*v1.PrometheusRuleSpec, error) {
+	rules, err := s.dao.List(&prometheusrule.Query{Project: project})
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to get the prometheusRules of the project '%s', something wrong with etcd", project)
+		return nil, shared.InternalError
+	}
+	return merge(rules), nil
+}
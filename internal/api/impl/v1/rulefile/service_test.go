@@ -0,0 +1,112 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulefile
+
+import (
+	"testing"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/prometheus/pkg/rulefmt"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+func newPrometheusRule(project string, name string, groups ...string) *v1.PrometheusRule {
+	rule := &v1.PrometheusRule{
+		Kind: v1.KindPrometheusRule,
+		Metadata: v1.ProjectMetadata{
+			Metadata: v1.Metadata{Name: name},
+			Project:  project,
+		},
+	}
+	for _, group := range groups {
+		rule.Spec.Groups = append(rule.Spec.Groups, v1.RuleGroup{
+			Name: group,
+			Rules: []v1.Rule{
+				{
+					Alert:       "InstanceDown",
+					Expr:        "up == 0",
+					For:         "5m",
+					Labels:      map[string]string{"severity": "critical"},
+					Annotations: map[string]string{"summary": "the instance {{ $labels.instance }} is down"},
+				},
+			},
+		})
+	}
+	return rule
+}
+
+func groupNames(spec *v1.PrometheusRuleSpec) []string {
+	result := make([]string, 0, len(spec.Groups))
+	for _, group := range spec.Groups {
+		result = append(result, group.Name)
+	}
+	return result
+}
+
+func TestMerge(t *testing.T) {
+	testSuite := []struct {
+		title  string
+		rules  []*v1.PrometheusRule
+		groups []string
+	}{
+		{
+			title:  "no rule",
+			groups: []string{},
+		},
+		{
+			title: "no collision",
+			rules: []*v1.PrometheusRule{
+				newPrometheusRule("perses", "node", "node"),
+				newPrometheusRule("perses", "etcd", "etcd", "etcd-disk"),
+			},
+			groups: []string{"etcd", "etcd-disk", "node"},
+		},
+		{
+			title: "collision between projects",
+			rules: []*v1.PrometheusRule{
+				newPrometheusRule("perses", "node", "node"),
+				newPrometheusRule("demo", "node", "node"),
+			},
+			groups: []string{"node", "perses/node/node"},
+		},
+		{
+			title: "collision in the same resource",
+			rules: []*v1.PrometheusRule{
+				newPrometheusRule("perses", "node", "node", "node", "node"),
+			},
+			groups: []string{"node", "perses/node/node", "perses/node/node_1"},
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			result := merge(test.rules)
+			assert.Equal(t, test.groups, groupNames(result))
+			data, err := yaml.Marshal(result)
+			assert.NoError(t, err)
+			_, errs := rulefmt.Parse(data)
+			assert.Empty(t, errs)
+		})
+	}
+}
+
+func TestMergeProjectLabel(t *testing.T) {
+	result := merge([]*v1.PrometheusRule{
+		newPrometheusRule("perses", "node", "node"),
+		newPrometheusRule("demo", "etcd", "etcd"),
+	})
+	assert.Equal(t, []string{"etcd", "node"}, groupNames(result))
+	assert.Equal(t, map[string]string{"severity": "critical", v1.AlertmanagerProjectLabel: "demo"}, result.Groups[0].Rules[0].Labels)
+	assert.Equal(t, map[string]string{"severity": "critical", v1.AlertmanagerProjectLabel: "perses"}, result.Groups[1].Rules[0].Labels)
+}
@@ -0,0 +1,41 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulefile_sync
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/perses/internal/api/interface/v1/rulefile_sync"
+	"github.com/perses/perses/internal/api/shared"
+)
+
+type Endpoint struct {
+	service rulefile_sync.Service
+}
+
+func NewEndpoint(service rulefile_sync.Service) *Endpoint {
+	return &Endpoint{
+		service: service,
+	}
+}
+
+func (e *Endpoint) RegisterRoutes(g *echo.Group) {
+	g.GET(fmt.Sprintf("/%s/%s", shared.PathRuleFile, shared.PathSync), e.GetStatus)
+}
+
+func (e *Endpoint) GetStatus(ctx echo.Context) error {
+	return ctx.JSON(http.StatusOK, e.service.GetStatus())
+}
@@ -0,0 +1,259 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulefile_sync
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/rulefile_sync"
+	"github.com/perses/perses/internal/config"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	ruleFileExtension = ".yaml"
+	reloadTimeout     = 30 * time.Second
+)
+
+// writeFile writes the data in the file only if the content changed. It returns true if the file has been written.
+// The data is written in a temporary file that is then renamed, so Prometheus never reads a partially written file.
+func writeFile(path string, data []byte) (bool, error) {
+	if previousData, err := ioutil.ReadFile(path); err == nil && bytes.Equal(previousData, data) {
+		return false, nil
+	}
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return false, err
+	}
+	// the temporary file doesn't have the extension of the rule files, so it's not loaded by Prometheus nor removed as a stale file.
+	tmpFile, err := ioutil.TempFile(dir, fmt.Sprintf(".%s.*.tmp", filepath.Base(path)))
+	if err != nil {
+		return false, err
+	}
+	defer os.Remove(tmpFile.Name()) // nolint: errcheck
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close() // nolint: errcheck
+		return false, err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return false, err
+	}
+	if err := os.Chmod(tmpFile.Name(), 0644); err != nil {
+		return false, err
+	}
+	return true, os.Rename(tmpFile.Name(), path)
+}
+
+// removeStaleFiles removes the rule files that are not expected anymore. It returns true if at least one file has been removed.
+func removeStaleFiles(folder string, expectedFiles map[string]bool) (bool, error) {
+	files, err := filepath.Glob(filepath.Join(folder, "*", "*"+ruleFileExtension))
+	if err != nil {
+		return false, err
+	}
+	removed := false
+	for _, file := range files {
+		if expectedFiles[file] {
+			continue
+		}
+		if err := os.Remove(file); err != nil {
+			return removed, err
+		}
+		removed = true
+		// the folder of the project is removed when it's empty. Otherwise it fails, and that's fine.
+		_ = os.Remove(filepath.Dir(file))
+	}
+	return removed, nil
+}
+
+type service struct {
+	rulefile_sync.Service
+	dao            prometheusrule.DAO
+	enabled        bool
+	folder         string
+	reloadURL      string
+	resyncInterval time.Duration
+	httpClient     *http.Client
+	// pendingReload is true when the rule files changed since the last successful reload of Prometheus.
+	// It's only used by sync, that is never called concurrently.
+	pendingReload bool
+	mutex         sync.RWMutex
+	status        v1.RuleFileSyncStatus
+}
+
+// NewService returns the service synchronizing the PrometheusRules on disk.
+// conf can be nil, in this case the synchronization is disabled and the task does nothing.
+func NewService(conf *config.RuleFileSyncConfig, dao prometheusrule.DAO) rulefile_sync.Service {
+	s := &service{
+		dao:        dao,
+		httpClient: &http.Client{Timeout: reloadTimeout},
+	}
+	if conf != nil {
+		s.enabled = true
+		s.folder = conf.Folder
+		s.reloadURL = conf.ReloadURL
+		resyncIntervalSeconds := conf.ResyncIntervalSeconds
+		if resyncIntervalSeconds == 0 {
+			// the config isn't necessarily verified, and a ticker cannot be created with a zero interval
+			resyncIntervalSeconds = config.DefaultResyncIntervalSeconds
+		}
+		s.resyncInterval = time.Duration(resyncIntervalSeconds) * time.Second
+	}
+	s.status = v1.RuleFileSyncStatus{
+		Enabled: s.enabled,
+		Folder:  s.folder,
+	}
+	return s
+}
+
+func (s *service) String() string {
+	return "rule file synchronizer"
+}
+
+func (s *service) Initialize() error {
+	if !s.enabled {
+		return nil
+	}
+	return os.MkdirAll(s.folder, 0755)
+}
+
+func (s *service) Finalize() error {
+	return nil
+}
+
+func (s *service) Execute(ctx context.Context, _ context.CancelFunc) error {
+	if !s.enabled {
+		return nil
+	}
+	ticker := time.NewTicker(s.resyncInterval)
+	defer ticker.Stop()
+	for {
+		// the watch is started before the synchronization, so no change can be missed between the two.
+		watchCtx, cancelWatch := context.WithCancel(ctx)
+		watchChan, err := s.dao.Watch(watchCtx)
+		if err != nil {
+			cancelWatch()
+			return fmt.Errorf("unable to watch the prometheusRules: %w", err)
+		}
+		s.sync()
+		for watchChan != nil {
+			select {
+			case <-ctx.Done():
+				cancelWatch()
+				return nil
+			case <-ticker.C:
+				s.sync()
+			case response, ok := <-watchChan:
+				if !ok {
+					// the watch has been closed by the database, it's restarted with a complete synchronization.
+					watchChan = nil
+					continue
+				}
+				if err := response.Err; err != nil {
+					logrus.WithError(err).Error("error received when watching the prometheusRules")
+					continue
+				}
+				s.sync()
+			}
+		}
+		cancelWatch()
+	}
+}
+
+func (s *service) GetStatus() *v1.RuleFileSyncStatus {
+	s.mutex.RLock()
+	defer s.mutex.RUnlock()
+	status := s.status
+	return &status
+}
+
+// sync writes every PrometheusRule on disk and then asks Prometheus to reload its configuration if something changed.
+// A failed reload is retried at each synchronization until it succeeds, even if the files didn't change since.
+func (s *service) sync() {
+	files, changed, err := s.writeRuleFiles()
+	if changed && len(s.reloadURL) > 0 {
+		s.pendingReload = true
+	}
+	if err == nil && s.pendingReload {
+		if err = s.reload(); err == nil {
+			s.pendingReload = false
+		}
+	}
+	if err != nil {
+		logrus.WithError(err).Error("unable to synchronize the rule files")
+	}
+	now := time.Now().UTC()
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.status.LastSync = &now
+	if err != nil {
+		s.status.LastError = err.Error()
+		return
+	}
+	s.status.LastError = ""
+	s.status.LastSuccess = &now
+	s.status.Files = files
+}
+
+// writeRuleFiles writes one rule file per PrometheusRule and removes the ones that don't exist anymore.
+// It returns the number of rule files and if at least one of them changed, even when it fails after having written some of them.
+func (s *service) writeRuleFiles() (int, bool, error) {
+	rules, err := s.dao.List(&prometheusrule.Query{})
+	if err != nil {
+		return 0, false, fmt.Errorf("unable to get the prometheusRules: %w", err)
+	}
+	changed := false
+	expectedFiles := make(map[string]bool, len(rules))
+	for _, rule := range rules {
+		data, err := yaml.Marshal(rule.Spec.RuleFile().WithProjectLabel(rule.Metadata.Project))
+		if err != nil {
+			return 0, false, fmt.Errorf("unable to marshal the prometheusRule '%s' of the project '%s': %w", rule.Metadata.Name, rule.Metadata.Project, err)
+		}
+		path := filepath.Join(s.folder, rule.Metadata.Project, rule.Metadata.Name+ruleFileExtension)
+		written, err := writeFile(path, data)
+		if err != nil {
+			return 0, changed, fmt.Errorf("unable to write the rule file '%s': %w", path, err)
+		}
+		changed = changed || written
+		expectedFiles[path] = true
+	}
+	removed, err := removeStaleFiles(s.folder, expectedFiles)
+	if err != nil {
+		return 0, changed || removed, fmt.Errorf("unable to remove the stale rule files: %w", err)
+	}
+	return len(expectedFiles), changed || removed, nil
+}
+
+func (s *service) reload() error {
+	resp, err := s.httpClient.Post(s.reloadURL, "", nil)
+	if err != nil {
+		return fmt.Errorf("unable to reload Prometheus: %w", err)
+	}
+	defer resp.Body.Close() // nolint: errcheck
+	if resp.StatusCode >= http.StatusMultipleChoices {
+		body, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("unable to reload Prometheus, status code %d: %s", resp.StatusCode, string(bytes.TrimSpace(body)))
+	}
+	return nil
+}
@@ -0,0 +1,181 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulefile_sync
+
+import (
+	"context"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/config"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/prometheus/pkg/rulefmt"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDAO struct {
+	prometheusrule.DAO
+	rules []*v1.PrometheusRule
+}
+
+func (d *fakeDAO) List(_ etcd.Query) ([]*v1.PrometheusRule, error) {
+	return d.rules, nil
+}
+
+func newPrometheusRule(project string, name string, expr string) *v1.PrometheusRule {
+	return &v1.PrometheusRule{
+		Kind: v1.KindPrometheusRule,
+		Metadata: v1.ProjectMetadata{
+			Metadata: v1.Metadata{Name: name},
+			Project:  project,
+		},
+		Spec: v1.PrometheusRuleSpec{
+			Groups: []v1.RuleGroup{
+				{
+					Name: name,
+					Rules: []v1.Rule{
+						{
+							Alert:  "InstanceDown",
+							Expr:   expr,
+							Labels: map[string]string{"severity": "critical"},
+						},
+					},
+				},
+			},
+		},
+	}
+}
+
+func listFiles(t *testing.T, folder string) []string {
+	files, err := filepath.Glob(filepath.Join(folder, "*", "*"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := range files {
+		files[i], _ = filepath.Rel(folder, files[i])
+	}
+	return files
+}
+
+func TestSync(t *testing.T) {
+	folder, err := ioutil.TempDir("", "rulefile_sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder) // nolint: errcheck
+	var reloads int32
+	reloadStatus := int32(http.StatusOK)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			t.Errorf("unexpected method %s", r.Method)
+		}
+		atomic.AddInt32(&reloads, 1)
+		w.WriteHeader(int(atomic.LoadInt32(&reloadStatus)))
+	}))
+	defer server.Close()
+
+	dao := &fakeDAO{
+		rules: []*v1.PrometheusRule{
+			newPrometheusRule("perses", "node", "up == 0"),
+			newPrometheusRule("demo", "etcd", "etcd_server_has_leader == 0"),
+		},
+	}
+	s := NewService(&config.RuleFileSyncConfig{Folder: folder, ReloadURL: server.URL + "/-/reload"}, dao).(*service)
+	// the config isn't verified, the default interval is used
+	assert.Equal(t, config.DefaultResyncIntervalSeconds*time.Second, s.resyncInterval)
+
+	// first synchronization writes every file
+	s.sync()
+	assert.Equal(t, []string{"demo/etcd.yaml", "perses/node.yaml"}, listFiles(t, folder))
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reloads))
+	for _, file := range listFiles(t, folder) {
+		groups, errs := rulefmt.ParseFile(filepath.Join(folder, file))
+		assert.Empty(t, errs)
+		// the alerts are routed to the AlertmanagerConfig of the project
+		assert.Equal(t, filepath.Dir(file), groups.Groups[0].Rules[0].Labels[v1.AlertmanagerProjectLabel])
+	}
+	status := s.GetStatus()
+	assert.True(t, status.Enabled)
+	assert.Equal(t, 2, status.Files)
+	assert.Empty(t, status.LastError)
+
+	// nothing changed, so Prometheus is not reloaded
+	s.sync()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reloads))
+
+	// a removed PrometheusRule is removed from the disk with its project folder
+	dao.rules = dao.rules[:1]
+	s.sync()
+	assert.Equal(t, []string{"perses/node.yaml"}, listFiles(t, folder))
+	assert.NoDirExists(t, filepath.Join(folder, "demo"))
+	assert.Equal(t, int32(2), atomic.LoadInt32(&reloads))
+
+	// a failed reload is exposed in the status
+	atomic.StoreInt32(&reloadStatus, http.StatusInternalServerError)
+	dao.rules[0] = newPrometheusRule("perses", "node", "up{job=\"node\"} == 0")
+	s.sync()
+	status = s.GetStatus()
+	assert.Contains(t, status.LastError, "status code 500")
+	assert.NotEqual(t, status.LastSync, status.LastSuccess)
+}
+
+func TestRetryReload(t *testing.T) {
+	folder, err := ioutil.TempDir("", "rulefile_sync")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(folder) // nolint: errcheck
+	var reloads int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// only the first reload fails
+		if atomic.AddInt32(&reloads, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer server.Close()
+
+	dao := &fakeDAO{rules: []*v1.PrometheusRule{newPrometheusRule("perses", "node", "up == 0")}}
+	s := NewService(&config.RuleFileSyncConfig{Folder: folder, ReloadURL: server.URL + "/-/reload"}, dao).(*service)
+	s.sync()
+	assert.Equal(t, int32(1), atomic.LoadInt32(&reloads))
+	status := s.GetStatus()
+	assert.Contains(t, status.LastError, "status code 503")
+	assert.Nil(t, status.LastSuccess)
+
+	// the files didn't change, but the reload is retried since Prometheus still runs the previous rules
+	s.sync()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&reloads))
+	status = s.GetStatus()
+	assert.Empty(t, status.LastError)
+	assert.Equal(t, status.LastSync, status.LastSuccess)
+
+	// once reloaded, it's not done again until the files change
+	s.sync()
+	assert.Equal(t, int32(2), atomic.LoadInt32(&reloads))
+}
+
+func TestDisabled(t *testing.T) {
+	s := NewService(nil, &fakeDAO{})
+	assert.NoError(t, s.Initialize())
+	assert.NoError(t, s.Execute(context.Background(), nil))
+	assert.Equal(t, &v1.RuleFileSyncStatus{}, s.GetStatus())
+}
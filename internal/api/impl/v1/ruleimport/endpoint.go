@@ -0,0 +1,57 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruleimport
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/perses/internal/api/interface/v1/ruleimport"
+	"github.com/perses/perses/internal/api/shared"
+)
+
+type Endpoint struct {
+	service ruleimport.Service
+}
+
+func NewEndpoint(service ruleimport.Service) *Endpoint {
+	return &Endpoint{
+		service: service,
+	}
+}
+
+func (e *Endpoint) RegisterRoutes(g *echo.Group) {
+	group := g.Group(fmt.Sprintf("/%s/:%s/%s", shared.PathProject, shared.ParamProject, shared.PathPrometheusRule))
+	group.POST(fmt.Sprintf("/%s", shared.PathImport), e.Import)
+}
+
+// Import expects a body containing one or several YAML documents.
+// Each of them is either a PrometheusRule of the prometheus-operator, a PrometheusRule of Perses or a plain Prometheus rule file.
+func (e *Endpoint) Import(ctx echo.Context) error {
+	data, err := ioutil.ReadAll(ctx.Request().Body)
+	if err != nil {
+		return shared.HandleError(fmt.Errorf("%w: unable to read the body: %s", shared.BadRequestError, err))
+	}
+	q := ruleimport.Query{
+		Conflict: ctx.QueryParam("conflict"),
+		Name:     ctx.QueryParam("name"),
+	}
+	response, err := e.service.Import(ctx.Param(shared.ParamProject), data, q)
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,131 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruleimport
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/ruleimport"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+type service struct {
+	ruleimport.Service
+	ruleService prometheusrule.Service
+}
+
+// NewService returns the import service. It relies on the PrometheusRule service, so the imported rules are checked
+// and linted like any other rules.
+func NewService(ruleService prometheusrule.Service) ruleimport.Service {
+	return &service{
+		ruleService: ruleService,
+	}
+}
+
+func (s *service) Import(project string, data []byte, q ruleimport.Query) ([]v1.RuleImportResult, error) {
+	mode, err := q.ConflictMode()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
+	rules, err := v1.ParsePrometheusRules(data, project, q.Name)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
+	if mode == v1.ImportConflictFail {
+		results, failed, checkErr := s.checkAll(rules)
+		if checkErr != nil {
+			return nil, checkErr
+		}
+		if failed {
+			return results, nil
+		}
+	}
+	results := make([]v1.RuleImportResult, 0, len(rules))
+	for _, rule := range rules {
+		results = append(results, s.importRule(rule, mode))
+	}
+	return results, nil
+}
+
+// checkAll verifies that every rule can be imported, before any of them is written. When one cannot,
+// because it already exists or because it's not valid, nothing is imported: it is reported as failed and the others as skipped.
+// An error is returned only when the verification itself fails.
+func (s *service) checkAll(rules []*v1.PrometheusRule) ([]v1.RuleImportResult, bool, error) {
+	results := make([]v1.RuleImportResult, 0, len(rules))
+	failed := false
+	for _, rule := range rules {
+		result := v1.RuleImportResult{Name: rule.Metadata.Name}
+		err := s.ruleService.Validate(rule)
+		if err == nil {
+			if _, err = s.ruleService.Get(parameters(rule)); err == nil {
+				err = shared.ConflictError
+			} else if errors.Is(err, shared.NotFoundError) {
+				err = nil
+			}
+		}
+		if errors.Is(err, shared.InternalError) {
+			return nil, false, err
+		}
+		if err != nil {
+			failed = true
+			result.Status = v1.RuleImportStatusFailed
+			result.Error = err.Error()
+		}
+		results = append(results, result)
+	}
+	if !failed {
+		return results, false, nil
+	}
+	for i := range results {
+		if results[i].Status != v1.RuleImportStatusFailed {
+			results[i].Status = v1.RuleImportStatusSkipped
+			results[i].Error = "import aborted because another PrometheusRule cannot be imported"
+		}
+	}
+	return results, true, nil
+}
+
+func (s *service) importRule(rule *v1.PrometheusRule, mode v1.ImportConflictMode) v1.RuleImportResult {
+	result := v1.RuleImportResult{Name: rule.Metadata.Name, Status: v1.RuleImportStatusCreated}
+	entity, err := s.ruleService.Create(rule)
+	if errors.Is(err, shared.ConflictError) {
+		switch mode {
+		case v1.ImportConflictSkip:
+			result.Status = v1.RuleImportStatusSkipped
+			return result
+		case v1.ImportConflictOverwrite:
+			result.Status = v1.RuleImportStatusUpdated
+			entity, err = s.ruleService.Update(rule, parameters(rule))
+		}
+	}
+	if err != nil {
+		result.Status = v1.RuleImportStatusFailed
+		result.Error = err.Error()
+		return result
+	}
+	if ruleObject, ok := entity.(*v1.PrometheusRule); ok && ruleObject.Status != nil {
+		result.Warnings = ruleObject.Status.Warnings
+	}
+	return result
+}
+
+func parameters(rule *v1.PrometheusRule) shared.Parameters {
+	return shared.Parameters{
+		Project: rule.Metadata.Project,
+		Name:    rule.Metadata.Name,
+	}
+}
@@ -0,0 +1,170 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruleimport
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/ruleimport"
+	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/pkg/model/api"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakeRuleService stores the PrometheusRules in a map and counts the calls to Create and Update.
+// The PrometheusRules whose name is in invalid are rejected.
+type fakeRuleService struct {
+	prometheusrule.Service
+	rules   map[string]*v1.PrometheusRule
+	invalid map[string]bool
+	created int
+	updated int
+}
+
+func (s *fakeRuleService) Validate(entity *v1.PrometheusRule) error {
+	if s.invalid[entity.Metadata.Name] {
+		return fmt.Errorf("%w: rules don't respect the policies of the project", shared.BadRequestError)
+	}
+	return nil
+}
+
+func (s *fakeRuleService) Create(entity api.Entity) (interface{}, error) {
+	rule := entity.(*v1.PrometheusRule)
+	if err := s.Validate(rule); err != nil {
+		return nil, err
+	}
+	if _, ok := s.rules[rule.Metadata.Name]; ok {
+		return nil, shared.ConflictError
+	}
+	s.created++
+	s.rules[rule.Metadata.Name] = rule
+	return rule, nil
+}
+
+func (s *fakeRuleService) Update(entity api.Entity, _ shared.Parameters) (interface{}, error) {
+	rule := entity.(*v1.PrometheusRule)
+	s.updated++
+	s.rules[rule.Metadata.Name] = rule
+	return rule, nil
+}
+
+func (s *fakeRuleService) Get(parameters shared.Parameters) (interface{}, error) {
+	if rule, ok := s.rules[parameters.Name]; ok {
+		return rule, nil
+	}
+	return nil, shared.NotFoundError
+}
+
+const manifests = `
+apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  name: node
+spec:
+  groups:
+    - name: node
+      rules:
+        - alert: InstanceDown
+          expr: up == 0
+---
+apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  name: etcd
+spec:
+  groups:
+    - name: etcd
+      rules:
+        - alert: EtcdNoLeader
+          expr: etcd_server_has_leader == 0
+`
+
+func TestImport(t *testing.T) {
+	testSuite := []struct {
+		title    string
+		conflict string
+		results  []v1.RuleImportResult
+		created  int
+		updated  int
+	}{
+		{
+			title:    "skip",
+			conflict: "skip",
+			results: []v1.RuleImportResult{
+				{Name: "node", Status: v1.RuleImportStatusSkipped},
+				{Name: "etcd", Status: v1.RuleImportStatusCreated},
+			},
+			created: 1,
+		},
+		{
+			title:    "overwrite",
+			conflict: "overwrite",
+			results: []v1.RuleImportResult{
+				{Name: "node", Status: v1.RuleImportStatusUpdated},
+				{Name: "etcd", Status: v1.RuleImportStatusCreated},
+			},
+			created: 1,
+			updated: 1,
+		},
+		{
+			title: "fail by default",
+			results: []v1.RuleImportResult{
+				{Name: "node", Status: v1.RuleImportStatusFailed, Error: shared.ConflictError.Error()},
+				{Name: "etcd", Status: v1.RuleImportStatusSkipped, Error: "import aborted because another PrometheusRule cannot be imported"},
+			},
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			ruleService := &fakeRuleService{rules: map[string]*v1.PrometheusRule{"node": {}}}
+			s := NewService(ruleService)
+			results, err := s.Import("perses", []byte(manifests), ruleimport.Query{Conflict: test.conflict})
+			assert.NoError(t, err)
+			assert.Equal(t, test.results, results)
+			assert.Equal(t, test.created, ruleService.created)
+			assert.Equal(t, test.updated, ruleService.updated)
+		})
+	}
+}
+
+func TestImportUnknownConflictMode(t *testing.T) {
+	s := NewService(&fakeRuleService{})
+	_, err := s.Import("perses", []byte(manifests), ruleimport.Query{Conflict: "merge"})
+	assert.Error(t, err)
+}
+
+func TestImportFailWithInvalidRule(t *testing.T) {
+	// the first rule is valid, the second one isn't: nothing must be written
+	ruleService := &fakeRuleService{rules: map[string]*v1.PrometheusRule{}, invalid: map[string]bool{"etcd": true}}
+	s := NewService(ruleService)
+	results, err := s.Import("perses", []byte(manifests), ruleimport.Query{})
+	assert.NoError(t, err)
+	if assert.Len(t, results, 2) {
+		assert.Equal(t, v1.RuleImportStatusSkipped, results[0].Status)
+		assert.Equal(t, v1.RuleImportStatusFailed, results[1].Status)
+	}
+	assert.Equal(t, 0, ruleService.created)
+	assert.Empty(t, ruleService.rules)
+}
+
+func TestImportDuplicateName(t *testing.T) {
+	ruleService := &fakeRuleService{rules: map[string]*v1.PrometheusRule{}}
+	s := NewService(ruleService)
+	_, err := s.Import("perses", []byte(manifests+"---\n"+manifests), ruleimport.Query{})
+	assert.Error(t, err)
+	assert.Empty(t, ruleService.rules)
+}
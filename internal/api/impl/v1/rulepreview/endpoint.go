@@ -0,0 +1,73 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulepreview
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/perses/internal/api/interface/v1/rulepreview"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+type Endpoint struct {
+	service rulepreview.Service
+}
+
+func NewEndpoint(service rulepreview.Service) *Endpoint {
+	return &Endpoint{
+		service: service,
+	}
+}
+
+func (e *Endpoint) RegisterRoutes(g *echo.Group) {
+	group := g.Group(fmt.Sprintf("/%s", shared.PathPrometheusRule))
+	group.POST(fmt.Sprintf("/%s", shared.PathPreview), e.PreviewPrometheusRule)
+
+	subGroup := g.Group(fmt.Sprintf("/%s/:%s/%s", shared.PathProject, shared.ParamProject, shared.PathPrometheusRule))
+	subGroup.POST(fmt.Sprintf("/:%s/%s", shared.ParamName, shared.PathPreview), e.Preview)
+}
+
+func (e *Endpoint) Preview(ctx echo.Context) error {
+	response, err := e.service.Preview(ctx.Param(shared.ParamProject), ctx.Param(shared.ParamName), getQuery(ctx))
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, response)
+}
+
+func (e *Endpoint) PreviewPrometheusRule(ctx echo.Context) error {
+	body := &v1.PrometheusRule{}
+	if err := ctx.Bind(body); err != nil {
+		return shared.HandleError(fmt.Errorf("%w: %s", shared.BadRequestError, err))
+	}
+	response, err := e.service.PreviewPrometheusRule(body, getQuery(ctx))
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, response)
+}
+
+// getQuery reads the query parameters. They are not bound by echo for a POST request.
+func getQuery(ctx echo.Context) *rulepreview.Query {
+	return &rulepreview.Query{
+		Datasource: ctx.QueryParam("datasource"),
+		Time:       ctx.QueryParam("time"),
+		Start:      ctx.QueryParam("start"),
+		End:        ctx.QueryParam("end"),
+		Step:       ctx.QueryParam("step"),
+	}
+}
@@ -0,0 +1,295 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulepreview
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/perses/perses/internal/api/impl/v1/dashboard_feed/plugin"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/template"
+)
+
+const (
+	// defaultStep is the interval between two evaluations when neither the query nor the rule group defines it.
+	defaultStep = time.Minute
+	// maxPoints is the maximum number of evaluations of a rule, the same as the maximum number of points per series
+	// Prometheus returns for a range query.
+	maxPoints = 11000
+)
+
+func metricToLabels(metric model.Metric) labels.Labels {
+	m := make(map[string]string, len(metric))
+	for k, v := range metric {
+		m[string(k)] = string(v)
+	}
+	return labels.FromMap(m)
+}
+
+func parseDuration(s string) (time.Duration, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	d, err := model.ParseDuration(s)
+	return time.Duration(d), err
+}
+
+// evaluator evaluates the rules like Prometheus would have done it, using the data of the datasource.
+// The rules are evaluated every step between start and end. For an instant evaluation, start and end are equal.
+type evaluator struct {
+	ctx              context.Context
+	datasourcePlugin plugin.Plugin
+	start            time.Time
+	end              time.Time
+	// step is the one asked in the query. When it's 0, the interval of the group is used.
+	step time.Duration
+}
+
+// evaluationRange returns the interval between two evaluations of the rule and the duration of its field 'for'.
+func evaluationRange(group v1.RuleGroup, rule v1.Rule, step time.Duration) (time.Duration, time.Duration, error) {
+	if step == 0 {
+		groupInterval, err := parseDuration(group.Interval)
+		if err != nil {
+			return 0, 0, fmt.Errorf("invalid interval of the group: %s", err)
+		}
+		step = groupInterval
+	}
+	if step == 0 {
+		step = defaultStep
+	}
+	hold, err := parseDuration(rule.For)
+	if err != nil {
+		return 0, 0, fmt.Errorf("invalid field 'for': %s", err)
+	}
+	return step, hold, nil
+}
+
+// checkPoints returns an error if a rule would be evaluated more than maxPoints times between start and end.
+// The rules whose interval or field 'for' is invalid are skipped, the error is reported in their preview.
+func checkPoints(spec v1.PrometheusRuleSpec, start time.Time, end time.Time, step time.Duration) error {
+	for _, group := range spec.Groups {
+		for _, rule := range group.Rules {
+			ruleStep, hold, err := evaluationRange(group, rule, step)
+			if err != nil {
+				continue
+			}
+			// the evaluation starts earlier when the rule has a field 'for', see evaluateRule
+			points := int64(end.Sub(start.Add(-hold))/ruleStep) + 1
+			if points > maxPoints {
+				name := rule.Record
+				if len(name) == 0 {
+					name = rule.Alert
+				}
+				return fmt.Errorf("the rule '%s' of the group '%s' would be evaluated %d times, more than the maximum of %d: reduce the time range or increase the step",
+					name, group.Name, points, maxPoints)
+			}
+		}
+	}
+	return nil
+}
+
+func (e *evaluator) evaluate(group v1.RuleGroup, rule v1.Rule) v1.RulePreview {
+	preview := v1.RulePreview{
+		Group:  group.Name,
+		Record: rule.Record,
+		Alert:  rule.Alert,
+		Expr:   rule.Expr,
+	}
+	if err := e.evaluateRule(group, rule, &preview); err != nil {
+		preview.Error = err.Error()
+	}
+	return preview
+}
+
+func (e *evaluator) evaluateRule(group v1.RuleGroup, rule v1.Rule, preview *v1.RulePreview) error {
+	step, hold, err := evaluationRange(group, rule, e.step)
+	if err != nil {
+		return err
+	}
+	// The evaluation starts earlier to know which alerts are already firing at the beginning of the time range.
+	evalStart := e.start.Add(-hold)
+	value, err := e.datasourcePlugin.QueryRange(e.ctx, rule.Expr, plugin.Range{
+		Start: evalStart,
+		End:   e.end,
+		Step:  step,
+	})
+	if err != nil {
+		return err
+	}
+	matrix, ok := value.(model.Matrix)
+	if !ok {
+		return fmt.Errorf("unexpected result of type '%s' returned by the datasource", value.Type())
+	}
+	if len(rule.Record) > 0 {
+		preview.Series = recordedSeries(rule, matrix)
+		return nil
+	}
+	preview.Series = keepAfter(matrix, e.start)
+	// the time of the last evaluation, that can be before the end when the time range is not a multiple of the step.
+	lastEval := evalStart.Add(e.end.Sub(evalStart) / step * step)
+	for _, stream := range matrix {
+		if alert, ok := e.alert(rule, stream, step, hold, lastEval); ok {
+			preview.Alerts = append(preview.Alerts, alert)
+		}
+	}
+	sort.Slice(preview.Alerts, func(i, j int) bool {
+		return labels.Compare(labels.FromMap(preview.Alerts[i].Labels), labels.FromMap(preview.Alerts[j].Labels)) < 0
+	})
+	return nil
+}
+
+// alert replays the evaluations of the alerting rule for one series. It returns false if the series never triggered an
+// alert during the time range.
+func (e *evaluator) alert(rule v1.Rule, stream *model.SampleStream, step time.Duration, hold time.Duration, lastEval time.Time) (v1.RulePreviewAlert, bool) {
+	var activeAt, lastActive, firingStart time.Time
+	var periods []v1.RulePreviewPeriod
+	for _, pair := range stream.Values {
+		ts := pair.Timestamp.Time().UTC()
+		// A missing evaluation resolves the alert, then it starts again from the beginning.
+		if activeAt.IsZero() || ts.Sub(lastActive) > step {
+			if !firingStart.IsZero() {
+				periods = append(periods, v1.RulePreviewPeriod{Start: firingStart, End: lastActive})
+				firingStart = time.Time{}
+			}
+			activeAt = ts
+		}
+		if firingStart.IsZero() && ts.Sub(activeAt) >= hold {
+			firingStart = ts
+		}
+		lastActive = ts
+	}
+	if len(stream.Values) == 0 || lastActive.Before(e.start) {
+		return v1.RulePreviewAlert{}, false
+	}
+	if !firingStart.IsZero() {
+		periods = append(periods, v1.RulePreviewPeriod{Start: firingStart, End: lastActive})
+	}
+	state := v1.AlertStateInactive
+	if lastActive.Equal(lastEval) {
+		state = v1.AlertStatePending
+		if lastActive.Sub(activeAt) >= hold {
+			state = v1.AlertStateFiring
+		}
+	}
+	value := float64(stream.Values[len(stream.Values)-1].Value)
+	lbls, annotations := e.renderTemplates(rule, stream.Metric, value, lastActive)
+	return v1.RulePreviewAlert{
+		Labels:        lbls,
+		Annotations:   annotations,
+		State:         state,
+		ActiveAt:      activeAt,
+		Value:         value,
+		FiringPeriods: periods,
+	}, true
+}
+
+// renderTemplates returns the labels and the annotations of the alert like Prometheus creates them.
+func (e *evaluator) renderTemplates(rule v1.Rule, metric model.Metric, value float64, ts time.Time) (map[string]string, map[string]string) {
+	seriesLabels := make(map[string]string, len(metric))
+	for k, v := range metric {
+		seriesLabels[string(k)] = string(v)
+	}
+	data := template.AlertTemplateData(seriesLabels, map[string]string{}, value)
+	// These variables are the ones Prometheus injects to make the templates easier to write.
+	defs := []string{
+		"{{$labels := .Labels}}",
+		"{{$externalLabels := .ExternalLabels}}",
+		"{{$value := .Value}}",
+	}
+	expand := func(text string) string {
+		expander := template.NewTemplateExpander(
+			e.ctx,
+			strings.Join(append(defs, text), ""),
+			"__alert_"+rule.Alert,
+			data,
+			model.TimeFromUnixNano(ts.UnixNano()),
+			e.templateQuery,
+			&url.URL{},
+		)
+		result, err := expander.Expand()
+		if err != nil {
+			return fmt.Sprintf("<error expanding template: %s>", err)
+		}
+		return result
+	}
+	lb := labels.NewBuilder(metricToLabels(metric)).Del(labels.MetricName)
+	for k, v := range rule.Labels {
+		lb.Set(k, expand(v))
+	}
+	lb.Set(labels.AlertName, rule.Alert)
+	var annotations map[string]string
+	if len(rule.Annotations) > 0 {
+		annotations = make(map[string]string, len(rule.Annotations))
+		for k, v := range rule.Annotations {
+			annotations[k] = expand(v)
+		}
+	}
+	return lb.Labels().Map(), annotations
+}
+
+// templateQuery is used by the function query available in the templates.
+func (e *evaluator) templateQuery(ctx context.Context, q string, ts time.Time) (promql.Vector, error) {
+	value, err := e.datasourcePlugin.Query(ctx, q, ts)
+	if err != nil {
+		return nil, err
+	}
+	vector, ok := value.(model.Vector)
+	if !ok {
+		return nil, fmt.Errorf("the query in the template must return a vector")
+	}
+	result := make(promql.Vector, 0, len(vector))
+	for _, sample := range vector {
+		result = append(result, promql.Sample{
+			Point:  promql.Point{T: int64(sample.Timestamp), V: float64(sample.Value)},
+			Metric: metricToLabels(sample.Metric),
+		})
+	}
+	return result, nil
+}
+
+// recordedSeries returns the series that the recording rule would have recorded.
+func recordedSeries(rule v1.Rule, matrix model.Matrix) model.Matrix {
+	result := make(model.Matrix, 0, len(matrix))
+	for _, stream := range matrix {
+		metric := stream.Metric.Clone()
+		metric[model.MetricNameLabel] = model.LabelValue(rule.Record)
+		for k, v := range rule.Labels {
+			metric[model.LabelName(k)] = model.LabelValue(v)
+		}
+		result = append(result, &model.SampleStream{Metric: metric, Values: stream.Values})
+	}
+	return result
+}
+
+// keepAfter removes the values before the given time, and the series that don't have any value left.
+func keepAfter(matrix model.Matrix, start time.Time) model.Matrix {
+	result := make(model.Matrix, 0, len(matrix))
+	for _, stream := range matrix {
+		i := sort.Search(len(stream.Values), func(i int) bool {
+			return !stream.Values[i].Timestamp.Time().Before(start)
+		})
+		if i < len(stream.Values) {
+			result = append(result, &model.SampleStream{Metric: stream.Metric, Values: stream.Values[i:]})
+		}
+	}
+	return result
+}
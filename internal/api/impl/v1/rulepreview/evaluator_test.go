@@ -0,0 +1,171 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulepreview
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/perses/perses/internal/api/impl/v1/dashboard_feed/plugin"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+)
+
+// fakePlugin returns a series for every instance, active at each given offset (in minutes) from the start of the range.
+type fakePlugin struct {
+	plugin.Plugin
+	instances map[string][]int
+	queries   []plugin.Range
+}
+
+func (p *fakePlugin) QueryRange(_ context.Context, _ string, r plugin.Range) (model.Value, error) {
+	p.queries = append(p.queries, r)
+	var result model.Matrix
+	for instance, offsets := range p.instances {
+		stream := &model.SampleStream{Metric: model.Metric{"__name__": "up", "instance": model.LabelValue(instance)}}
+		for _, offset := range offsets {
+			ts := r.Start.Add(time.Duration(offset) * time.Minute)
+			stream.Values = append(stream.Values, model.SamplePair{Timestamp: model.TimeFromUnixNano(ts.UnixNano()), Value: 0})
+		}
+		result = append(result, stream)
+	}
+	return result, nil
+}
+
+func minutes(from int, to int) []int {
+	var result []int
+	for i := from; i <= to; i++ {
+		result = append(result, i)
+	}
+	return result
+}
+
+func TestEvaluateAlert(t *testing.T) {
+	start := time.Date(2021, 4, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(20 * time.Minute)
+	datasourcePlugin := &fakePlugin{
+		instances: map[string][]int{
+			// the evaluation starts 5 minutes before the start, so this one is already firing at the start.
+			"always-down": minutes(0, 25),
+			// never active long enough
+			"flapping": {7, 8, 10, 11},
+			// active from start+1m to start+10m, so firing from start+6m
+			"down-once": minutes(6, 15),
+			// active only before the start
+			"down-before": minutes(0, 3),
+			// becomes active at the end
+			"down-now": {24, 25},
+		},
+	}
+	e := &evaluator{
+		ctx:              context.Background(),
+		datasourcePlugin: datasourcePlugin,
+		start:            start,
+		end:              end,
+	}
+	group := v1.RuleGroup{Name: "node", Interval: "1m"}
+	rule := v1.Rule{
+		Alert:       "InstanceDown",
+		Expr:        "up == 0",
+		For:         "5m",
+		Labels:      map[string]string{"severity": "page"},
+		Annotations: map[string]string{"summary": "{{ $labels.instance }} is down"},
+	}
+	result := e.evaluate(group, rule)
+	assert.Empty(t, result.Error)
+	assert.Equal(t, []plugin.Range{{Start: start.Add(-5 * time.Minute), End: end, Step: time.Minute}}, datasourcePlugin.queries)
+	assert.Equal(t, 4, len(result.Alerts))
+	assert.Equal(t, 4, len(result.Series))
+	expected := []v1.RulePreviewAlert{
+		{
+			Labels:        map[string]string{"alertname": "InstanceDown", "instance": "always-down", "severity": "page"},
+			Annotations:   map[string]string{"summary": "always-down is down"},
+			State:         v1.AlertStateFiring,
+			ActiveAt:      start.Add(-5 * time.Minute),
+			FiringPeriods: []v1.RulePreviewPeriod{{Start: start, End: end}},
+		},
+		{
+			Labels:      map[string]string{"alertname": "InstanceDown", "instance": "down-now", "severity": "page"},
+			Annotations: map[string]string{"summary": "down-now is down"},
+			State:       v1.AlertStatePending,
+			ActiveAt:    end.Add(-time.Minute),
+		},
+		{
+			Labels:        map[string]string{"alertname": "InstanceDown", "instance": "down-once", "severity": "page"},
+			Annotations:   map[string]string{"summary": "down-once is down"},
+			State:         v1.AlertStateInactive,
+			ActiveAt:      start.Add(time.Minute),
+			FiringPeriods: []v1.RulePreviewPeriod{{Start: start.Add(6 * time.Minute), End: start.Add(10 * time.Minute)}},
+		},
+		{
+			Labels:      map[string]string{"alertname": "InstanceDown", "instance": "flapping", "severity": "page"},
+			Annotations: map[string]string{"summary": "flapping is down"},
+			State:       v1.AlertStateInactive,
+			ActiveAt:    start.Add(5 * time.Minute),
+		},
+	}
+	assert.Equal(t, expected, result.Alerts)
+}
+
+func TestEvaluateRecordingRule(t *testing.T) {
+	now := time.Date(2021, 4, 1, 10, 0, 0, 0, time.UTC)
+	datasourcePlugin := &fakePlugin{instances: map[string][]int{"node-1": {0}}}
+	e := &evaluator{
+		ctx:              context.Background(),
+		datasourcePlugin: datasourcePlugin,
+		start:            now,
+		end:              now,
+		step:             30 * time.Second,
+	}
+	rule := v1.Rule{Record: "instance:up", Expr: "up", Labels: map[string]string{"team": "perses"}}
+	result := e.evaluate(v1.RuleGroup{Name: "node", Interval: "1m"}, rule)
+	assert.Empty(t, result.Error)
+	assert.Empty(t, result.Alerts)
+	assert.Equal(t, []plugin.Range{{Start: now, End: now, Step: 30 * time.Second}}, datasourcePlugin.queries)
+	assert.Equal(t, model.Matrix{
+		{
+			Metric: model.Metric{"__name__": "instance:up", "instance": "node-1", "team": "perses"},
+			Values: []model.SamplePair{{Timestamp: model.TimeFromUnixNano(now.UnixNano()), Value: 0}},
+		},
+	}, result.Series)
+}
+
+func TestEvaluateError(t *testing.T) {
+	e := &evaluator{ctx: context.Background(), datasourcePlugin: &fakePlugin{}}
+	result := e.evaluate(v1.RuleGroup{Name: "node"}, v1.Rule{Alert: "InstanceDown", Expr: "up == 0", For: "five minutes"})
+	assert.Contains(t, result.Error, "invalid field 'for'")
+}
+
+func TestCheckPoints(t *testing.T) {
+	start := time.Date(2021, 4, 1, 10, 0, 0, 0, time.UTC)
+	end := start.Add(7 * 24 * time.Hour)
+	spec := v1.PrometheusRuleSpec{Groups: []v1.RuleGroup{{
+		Name:  "node",
+		Rules: []v1.Rule{{Record: "job:up:sum", Expr: "sum by (job) (up)"}},
+	}}}
+	// a week every minute is 10081 evaluations
+	assert.NoError(t, checkPoints(spec, start, end, time.Minute))
+	// the evaluation of an alert starts earlier with its field 'for'
+	spec.Groups[0].Rules = append(spec.Groups[0].Rules, v1.Rule{Alert: "InstanceDown", Expr: "up == 0", For: "1d"})
+	err := checkPoints(spec, start, end, time.Minute)
+	if assert.Error(t, err) {
+		assert.Contains(t, err.Error(), "the rule 'InstanceDown' of the group 'node' would be evaluated 11521 times")
+	}
+	assert.NoError(t, checkPoints(spec, start, end, 5*time.Minute))
+	// without step in the query, the interval of the group is used
+	spec.Groups[0].Interval = "5m"
+	assert.NoError(t, checkPoints(spec, start, end, 0))
+}
@@ -0,0 +1,113 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulepreview
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/impl/v1/dashboard_feed/plugin"
+	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/rulepreview"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// previewTimeout is the maximum amount of time to evaluate all the rules of a PrometheusRule.
+const previewTimeout = time.Minute
+
+type service struct {
+	rulepreview.Service
+	dao prometheusrule.DAO
+	// datasourceDAO is used instead of the datasource service since the secrets of the datasource are required to contact it.
+	datasourceDAO datasource.DAO
+}
+
+func NewService(dao prometheusrule.DAO, datasourceDAO datasource.DAO) rulepreview.Service {
+	return &service{
+		dao:           dao,
+		datasourceDAO: datasourceDAO,
+	}
+}
+
+func (s *service) Preview(project string, name string, query *rulepreview.Query) (*v1.RulePreviewResult, error) {
+	entity, err := s.dao.Get(project, name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the prometheusRule '%s' in the project '%s'", name, project)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to find the prometheusRule '%s' in the project '%s', something wrong with etcd", name, project)
+		return nil, shared.InternalError
+	}
+	return s.PreviewPrometheusRule(entity, query)
+}
+
+func (s *service) PreviewPrometheusRule(entity *v1.PrometheusRule, query *rulepreview.Query) (*v1.RulePreviewResult, error) {
+	if len(query.Datasource) == 0 {
+		return nil, fmt.Errorf("%w: the datasource used to evaluate the rules must be set", shared.BadRequestError)
+	}
+	start, end, err := query.TimeRange(time.Now().UTC())
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
+	step, err := query.StepDuration()
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
+	// the limit is checked before contacting the datasource, to not overload it
+	if err := checkPoints(entity.Spec, start, end, step); err != nil {
+		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
+	dts, err := datasourceImpl.Find(s.datasourceDAO, entity.Metadata.Project, query.Datasource)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the Datasource '%s'", query.Datasource)
+			return nil, fmt.Errorf("%w: the datasource '%s' doesn't exist", shared.BadRequestError, query.Datasource)
+		}
+		logrus.WithError(err).Errorf("unable to find the Datasource '%s', something wrong with etcd", query.Datasource)
+		return nil, shared.InternalError
+	}
+	datasourcePlugin, err := plugin.New(dts.Spec)
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to create the plugin for the datasource '%s'", query.Datasource)
+		return nil, shared.InternalError
+	}
+	defer datasourcePlugin.Close()
+	ctx, cancel := context.WithTimeout(context.Background(), previewTimeout)
+	defer cancel()
+	e := &evaluator{
+		ctx:              ctx,
+		datasourcePlugin: datasourcePlugin,
+		start:            start,
+		end:              end,
+		step:             step,
+	}
+	result := &v1.RulePreviewResult{
+		Start: start,
+		End:   end,
+		Rules: []v1.RulePreview{},
+	}
+	for _, group := range entity.Spec.Groups {
+		for _, rule := range group.Rules {
+			result.Rules = append(result.Rules, e.evaluate(group, rule))
+		}
+	}
+	return result, nil
+}
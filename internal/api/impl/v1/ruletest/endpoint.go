@@ -0,0 +1,62 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruletest
+
+import (
+	"fmt"
+	"net/http"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/perses/internal/api/interface/v1/ruletest"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+type Endpoint struct {
+	service ruletest.Service
+}
+
+func NewEndpoint(service ruletest.Service) *Endpoint {
+	return &Endpoint{
+		service: service,
+	}
+}
+
+func (e *Endpoint) RegisterRoutes(g *echo.Group) {
+	group := g.Group(fmt.Sprintf("/%s", shared.PathPrometheusRule))
+	group.POST(fmt.Sprintf("/%s", shared.PathTest), e.TestPrometheusRule)
+
+	subGroup := g.Group(fmt.Sprintf("/%s/:%s/%s", shared.PathProject, shared.ParamProject, shared.PathPrometheusRule))
+	subGroup.POST(fmt.Sprintf("/:%s/%s", shared.ParamName, shared.PathTest), e.Test)
+}
+
+func (e *Endpoint) Test(ctx echo.Context) error {
+	response, err := e.service.Test(ctx.Param(shared.ParamProject), ctx.Param(shared.ParamName))
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, response)
+}
+
+func (e *Endpoint) TestPrometheusRule(ctx echo.Context) error {
+	body := &v1.PrometheusRule{}
+	if err := ctx.Bind(body); err != nil {
+		return shared.HandleError(fmt.Errorf("%w: %s", shared.BadRequestError, err))
+	}
+	response, err := e.service.TestPrometheusRule(body)
+	if err != nil {
+		return shared.HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, response)
+}
@@ -0,0 +1,491 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruletest
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/go-kit/kit/log"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+	"github.com/prometheus/prometheus/pkg/labels"
+	"github.com/prometheus/prometheus/pkg/rulefmt"
+	"github.com/prometheus/prometheus/promql"
+	"github.com/prometheus/prometheus/promql/parser"
+	"github.com/prometheus/prometheus/rules"
+	"github.com/prometheus/prometheus/storage"
+	"gopkg.in/yaml.v2"
+)
+
+// The rules are evaluated like promtool does it. This file is mostly a port of cmd/promtool/unittest.go that is not
+// importable, using the PrometheusRule stored in memory instead of files.
+
+const (
+	defaultEvaluationInterval = time.Minute
+	ruleFileName              = "rules.yaml"
+	// maxEvaluationSteps is the maximum number of times the rules are evaluated in a test, that is the highest eval_time
+	// divided by the evaluation interval.
+	maxEvaluationSteps = 10000
+	// maxInputSeries is the maximum number of input series in a test.
+	maxInputSeries = 1000
+	// maxInputSamples is the maximum number of samples generated by all the input series of a test.
+	maxInputSamples = 1000000
+)
+
+// memoryLoader is a rules.GroupLoader returning the rule groups it holds instead of reading them from a file.
+type memoryLoader struct {
+	data []byte
+}
+
+func (l *memoryLoader) Load(_ string) (*rulefmt.RuleGroups, []error) {
+	return rulefmt.Parse(l.data)
+}
+
+func (l *memoryLoader) Parse(query string) (parser.Expr, error) {
+	return parser.ParseExpr(query)
+}
+
+// storageFailure is raised (with a panic) when the in-memory storage fails, since it expects to be stopped like a test.
+type storageFailure struct {
+	err error
+}
+
+// storageFailureHandler implements the interface testutil.T required by the in-memory storage.
+type storageFailureHandler struct{}
+
+func (storageFailureHandler) Fatal(args ...interface{}) {
+	panic(storageFailure{err: fmt.Errorf("%s", fmt.Sprint(args...))})
+}
+
+func (storageFailureHandler) Fatalf(format string, args ...interface{}) {
+	panic(storageFailure{err: fmt.Errorf(format, args...)})
+}
+
+// runTests runs every test of the spec. It returns an error only if the tests cannot be run at all,
+// that includes the tests exceeding the limits or not completed before the context is done.
+func runTests(ctx context.Context, spec v1.PrometheusRuleSpec) (*v1.RuleTestResult, error) {
+	if spec.Tests == nil || len(spec.Tests.Tests) == 0 {
+		return nil, fmt.Errorf("no test defined")
+	}
+	data, err := yaml.Marshal(spec.RuleFile())
+	if err != nil {
+		return nil, fmt.Errorf("unable to marshal the rules: %w", err)
+	}
+	evalInterval := time.Duration(spec.Tests.EvaluationInterval)
+	if evalInterval == 0 {
+		evalInterval = defaultEvaluationInterval
+	}
+	// Lower number group should be evaluated before higher number group.
+	groupOrderMap := make(map[string]int, len(spec.Tests.GroupEvalOrder))
+	for i, groupName := range spec.Tests.GroupEvalOrder {
+		groupOrderMap[groupName] = i
+	}
+	result := &v1.RuleTestResult{Success: true}
+	for i, testGroup := range spec.Tests.Tests {
+		if err := checkLimits(testGroup, evalInterval); err != nil {
+			return nil, fmt.Errorf("test %d: %w", i, err)
+		}
+	}
+	for i, testGroup := range spec.Tests.Tests {
+		name := testGroup.Name
+		if len(name) == 0 {
+			name = strconv.Itoa(i)
+		}
+		testResult := v1.RuleTestGroupResult{Name: name, Success: true}
+		testErrs := runTestGroup(ctx, testGroup, data, evalInterval, groupOrderMap)
+		if ctx.Err() != nil {
+			return nil, fmt.Errorf("the tests were not completed in time: %w", ctx.Err())
+		}
+		for _, testErr := range testErrs {
+			testResult.Success = false
+			testResult.Errors = append(testResult.Errors, testErr.Error())
+		}
+		result.Success = result.Success && testResult.Success
+		result.Tests = append(result.Tests, testResult)
+	}
+	return result, nil
+}
+
+// checkLimits verifies the test doesn't require too many resources to be run.
+func checkLimits(tg v1.RuleTestGroup, evalInterval time.Duration) error {
+	if steps := maxEvalTime(tg) / evalInterval; steps > maxEvaluationSteps {
+		return fmt.Errorf("the rules would be evaluated %d times, the maximum is %d, reduce the eval_time or increase the evaluation_interval", steps, maxEvaluationSteps)
+	}
+	if len(tg.InputSeries) > maxInputSeries {
+		return fmt.Errorf("%d input series are defined, the maximum is %d", len(tg.InputSeries), maxInputSeries)
+	}
+	var samples uint64
+	for _, is := range tg.InputSeries {
+		samples += countSamples(is.Values)
+		if samples > maxInputSamples {
+			return fmt.Errorf("the input series are generating more than %d samples", maxInputSamples)
+		}
+	}
+	return nil
+}
+
+// countSamples returns the number of samples generated by the values of an input series, without expanding them.
+// A value like 'a+bxn' or '_xn' generates n+1 samples, any other value only one.
+// A value that isn't valid is counted as one sample, the error is reported when the series are loaded.
+func countSamples(values string) uint64 {
+	var result uint64
+	for _, value := range strings.Fields(values) {
+		i := strings.LastIndex(value, "x")
+		if i < 0 {
+			result++
+			continue
+		}
+		n, err := strconv.ParseUint(value[i+1:], 10, 64)
+		if err != nil || n == math.MaxUint64 {
+			result++
+			continue
+		}
+		result += n + 1
+	}
+	return result
+}
+
+func runTestGroup(ctx context.Context, tg v1.RuleTestGroup, data []byte, evalInterval time.Duration, groupOrderMap map[string]int) (errs []error) {
+	defer func() {
+		if r := recover(); r != nil {
+			failure, ok := r.(storageFailure)
+			if !ok {
+				panic(r)
+			}
+			errs = append(errs, failure.err)
+		}
+	}()
+	interval := tg.Interval
+	if interval == 0 {
+		interval = model.Duration(evalInterval)
+	}
+	suite, err := promql.NewLazyLoader(storageFailureHandler{}, seriesLoadingString(interval, tg.InputSeries))
+	if err != nil {
+		return []error{err}
+	}
+	defer suite.Close()
+	suite.SubqueryInterval = evalInterval
+
+	// Load the rules.
+	opts := &rules.ManagerOptions{
+		QueryFunc:   rules.EngineQueryFunc(suite.QueryEngine(), suite.Storage()),
+		Appendable:  suite.Storage(),
+		Context:     ctx,
+		NotifyFunc:  func(ctx context.Context, expr string, alerts ...*rules.Alert) {},
+		Logger:      log.NewNopLogger(),
+		GroupLoader: &memoryLoader{data: data},
+	}
+	m := rules.NewManager(opts)
+	groupsMap, errs := m.LoadGroups(evalInterval, labels.FromMap(tg.ExternalLabels), ruleFileName)
+	if errs != nil {
+		return errs
+	}
+	groups := orderedGroups(groupsMap, groupOrderMap)
+
+	// Bounds for evaluating the rules.
+	mint := time.Unix(0, 0).UTC()
+	maxt := mint.Add(maxEvalTime(tg))
+
+	// All the `eval_time` for which we have unit tests for alerts.
+	alertEvalTimesMap := map[model.Duration]struct{}{}
+	// Map of all the eval_time+alertname combination present in the unit tests.
+	alertsInTest := make(map[model.Duration]map[string]struct{})
+	// Map of all the unit tests for given eval_time.
+	alertTests := make(map[model.Duration][]v1.AlertRuleTestCase)
+	for _, alert := range tg.AlertRuleTests {
+		alertEvalTimesMap[alert.EvalTime] = struct{}{}
+		if _, ok := alertsInTest[alert.EvalTime]; !ok {
+			alertsInTest[alert.EvalTime] = make(map[string]struct{})
+		}
+		alertsInTest[alert.EvalTime][alert.Alertname] = struct{}{}
+		alertTests[alert.EvalTime] = append(alertTests[alert.EvalTime], alert)
+	}
+	alertEvalTimes := make([]model.Duration, 0, len(alertEvalTimesMap))
+	for k := range alertEvalTimesMap {
+		alertEvalTimes = append(alertEvalTimes, k)
+	}
+	sort.Slice(alertEvalTimes, func(i, j int) bool {
+		return alertEvalTimes[i] < alertEvalTimes[j]
+	})
+
+	// Current index in alertEvalTimes what we are looking at.
+	curr := 0
+
+	for _, g := range groups {
+		for _, r := range g.Rules() {
+			if alertRule, ok := r.(*rules.AlertingRule); ok {
+				// Mark alerting rules as restored, to ensure the ALERTS timeseries is created when they run.
+				alertRule.SetRestored(true)
+			}
+		}
+	}
+
+	for ts := mint; ts.Before(maxt) || ts.Equal(maxt); ts = ts.Add(evalInterval) {
+		if err := ctx.Err(); err != nil {
+			return append(errs, err)
+		}
+		var evalErrs []error
+		suite.WithSamplesTill(ts, func(err error) {
+			if err != nil {
+				errs = append(errs, err)
+				return
+			}
+			for _, g := range groups {
+				g.Eval(ctx, ts)
+				for _, r := range g.Rules() {
+					if r.LastError() != nil {
+						evalErrs = append(evalErrs, fmt.Errorf("rule: %s, time: %s, err: %v", r.Name(), ts.Sub(mint), r.LastError()))
+					}
+				}
+			}
+		})
+		errs = append(errs, evalErrs...)
+		// Only end testing at this point if errors occurred evaluating above,
+		// rather than any test failures already collected in errs.
+		if len(evalErrs) > 0 {
+			return errs
+		}
+
+		// If 'ts <= `eval_time=alertEvalTimes[curr]` < ts+evalInterval' then we compare alerts with the Eval at `ts`.
+		for curr < len(alertEvalTimes) && ts.Sub(mint) <= time.Duration(alertEvalTimes[curr]) &&
+			time.Duration(alertEvalTimes[curr]) < ts.Add(evalInterval).Sub(mint) {
+			t := alertEvalTimes[curr]
+			errs = append(errs, checkAlerts(groups, alertsInTest[t], alertTests[t])...)
+			curr++
+		}
+	}
+
+	for _, testCase := range tg.PromQLExprTests {
+		if err := checkExpr(ctx, suite, mint, testCase); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
+
+// checkAlerts compares the alerts firing with the ones expected.
+func checkAlerts(groups []*rules.Group, presentAlerts map[string]struct{}, testCases []v1.AlertRuleTestCase) []error {
+	got := make(map[string]labelsAndAnnotations)
+	// Same Alert name can be present in multiple groups.
+	// Hence we collect them all to check against expected alerts.
+	for _, g := range groups {
+		for _, r := range g.Rules() {
+			ar, ok := r.(*rules.AlertingRule)
+			if !ok {
+				continue
+			}
+			if _, ok := presentAlerts[ar.Name()]; !ok {
+				continue
+			}
+			var alerts labelsAndAnnotations
+			for _, a := range ar.ActiveAlerts() {
+				if a.State == rules.StateFiring {
+					alerts = append(alerts, labelAndAnnotation{
+						Labels:      append(labels.Labels{}, a.Labels...),
+						Annotations: append(labels.Labels{}, a.Annotations...),
+					})
+				}
+			}
+			got[ar.Name()] = append(got[ar.Name()], alerts...)
+		}
+	}
+
+	var errs []error
+	for _, testCase := range testCases {
+		gotAlerts := got[testCase.Alertname]
+		var expAlerts labelsAndAnnotations
+		for _, a := range testCase.ExpAlerts {
+			// User gives only the labels from alerting rule, which doesn't include this label (added by Prometheus during Eval).
+			expLabels := make(map[string]string, len(a.ExpLabels)+1)
+			for k, v := range a.ExpLabels {
+				expLabels[k] = v
+			}
+			expLabels[labels.AlertName] = testCase.Alertname
+			expAlerts = append(expAlerts, labelAndAnnotation{
+				Labels:      labels.FromMap(expLabels),
+				Annotations: labels.FromMap(a.ExpAnnotations),
+			})
+		}
+		sort.Sort(gotAlerts)
+		sort.Sort(expAlerts)
+		if gotAlerts.Len() != expAlerts.Len() || !reflect.DeepEqual(expAlerts, gotAlerts) {
+			errs = append(errs, fmt.Errorf("alertname: %s, time: %s, exp: %s, got: %s", testCase.Alertname, testCase.EvalTime, expAlerts, gotAlerts))
+		}
+	}
+	return errs
+}
+
+// checkExpr compares the result of the expression with the samples expected.
+func checkExpr(ctx context.Context, suite *promql.LazyLoader, mint time.Time, testCase v1.PromQLExprTestCase) error {
+	got, err := query(ctx, testCase.Expr, mint.Add(time.Duration(testCase.EvalTime)), suite.QueryEngine(), suite.Queryable())
+	if err != nil {
+		return fmt.Errorf("expr: %q, time: %s, err: %s", testCase.Expr, testCase.EvalTime, err)
+	}
+	var gotSamples []parsedSample
+	for _, s := range got {
+		gotSamples = append(gotSamples, parsedSample{
+			Labels: s.Metric.Copy(),
+			Value:  s.V,
+		})
+	}
+	var expSamples []parsedSample
+	for _, s := range testCase.ExpSamples {
+		lb, err := parser.ParseMetric(s.Labels)
+		if err != nil {
+			return fmt.Errorf("expr: %q, time: %s, err: labels %q: %s", testCase.Expr, testCase.EvalTime, s.Labels, err)
+		}
+		expSamples = append(expSamples, parsedSample{
+			Labels: lb,
+			Value:  s.Value,
+		})
+	}
+	sort.Slice(expSamples, func(i, j int) bool {
+		return labels.Compare(expSamples[i].Labels, expSamples[j].Labels) <= 0
+	})
+	sort.Slice(gotSamples, func(i, j int) bool {
+		return labels.Compare(gotSamples[i].Labels, gotSamples[j].Labels) <= 0
+	})
+	if !reflect.DeepEqual(expSamples, gotSamples) {
+		return fmt.Errorf("expr: %q, time: %s, exp: %s, got: %s", testCase.Expr, testCase.EvalTime, parsedSamplesString(expSamples), parsedSamplesString(gotSamples))
+	}
+	return nil
+}
+
+// seriesLoadingString returns the input series in PromQL notation.
+func seriesLoadingString(interval model.Duration, inputSeries []v1.RuleTestSeries) string {
+	var result strings.Builder
+	result.WriteString(fmt.Sprintf("load %v\n", shortDuration(interval)))
+	for _, is := range inputSeries {
+		result.WriteString(fmt.Sprintf("  %v %v\n", is.Series, is.Values))
+	}
+	return result.String()
+}
+
+func shortDuration(d model.Duration) string {
+	s := d.String()
+	if strings.HasSuffix(s, "m0s") {
+		s = s[:len(s)-2]
+	}
+	if strings.HasSuffix(s, "h0m") {
+		s = s[:len(s)-2]
+	}
+	return s
+}
+
+// orderedGroups returns the groups following the order mentioned by groupOrderMap. NOTE: This is partial ordering.
+func orderedGroups(groupsMap map[string]*rules.Group, groupOrderMap map[string]int) []*rules.Group {
+	groups := make([]*rules.Group, 0, len(groupsMap))
+	for _, g := range groupsMap {
+		groups = append(groups, g)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return groupOrderMap[groups[i].Name()] < groupOrderMap[groups[j].Name()]
+	})
+	return groups
+}
+
+// maxEvalTime returns the max eval time among all alert and promql unit tests.
+func maxEvalTime(tg v1.RuleTestGroup) time.Duration {
+	var maxd model.Duration
+	for _, alert := range tg.AlertRuleTests {
+		if alert.EvalTime > maxd {
+			maxd = alert.EvalTime
+		}
+	}
+	for _, pet := range tg.PromQLExprTests {
+		if pet.EvalTime > maxd {
+			maxd = pet.EvalTime
+		}
+	}
+	return time.Duration(maxd)
+}
+
+func query(ctx context.Context, qs string, t time.Time, engine *promql.Engine, qu storage.Queryable) (promql.Vector, error) {
+	q, err := engine.NewInstantQuery(qu, qs, t)
+	if err != nil {
+		return nil, err
+	}
+	res := q.Exec(ctx)
+	if res.Err != nil {
+		return nil, res.Err
+	}
+	switch v := res.Value.(type) {
+	case promql.Vector:
+		return v, nil
+	case promql.Scalar:
+		return promql.Vector{promql.Sample{
+			Point:  promql.Point(v),
+			Metric: labels.Labels{},
+		}}, nil
+	default:
+		return nil, fmt.Errorf("rule result is not a vector or scalar")
+	}
+}
+
+type labelsAndAnnotations []labelAndAnnotation
+
+func (la labelsAndAnnotations) Len() int      { return len(la) }
+func (la labelsAndAnnotations) Swap(i, j int) { la[i], la[j] = la[j], la[i] }
+func (la labelsAndAnnotations) Less(i, j int) bool {
+	diff := labels.Compare(la[i].Labels, la[j].Labels)
+	if diff != 0 {
+		return diff < 0
+	}
+	return labels.Compare(la[i].Annotations, la[j].Annotations) < 0
+}
+
+func (la labelsAndAnnotations) String() string {
+	result := make([]string, 0, len(la))
+	for _, l := range la {
+		result = append(result, l.String())
+	}
+	return "[" + strings.Join(result, ", ") + "]"
+}
+
+type labelAndAnnotation struct {
+	Labels      labels.Labels
+	Annotations labels.Labels
+}
+
+func (la *labelAndAnnotation) String() string {
+	return "Labels:" + la.Labels.String() + " Annotations:" + la.Annotations.String()
+}
+
+// parsedSample is a sample with parsed Labels.
+type parsedSample struct {
+	Labels labels.Labels
+	Value  float64
+}
+
+func (ps *parsedSample) String() string {
+	return ps.Labels.String() + " " + strconv.FormatFloat(ps.Value, 'E', -1, 64)
+}
+
+func parsedSamplesString(pss []parsedSample) string {
+	if len(pss) == 0 {
+		return "nil"
+	}
+	result := make([]string, 0, len(pss))
+	for _, ps := range pss {
+		result = append(result, ps.String())
+	}
+	return strings.Join(result, ", ")
+}
@@ -0,0 +1,195 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruletest
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const ruleSpec = `
+groups:
+  - name: node
+    rules:
+      - record: job:up:sum
+        expr: sum by (job) (up)
+      - alert: InstanceDown
+        expr: up == 0
+        for: 5m
+        labels:
+          severity: page
+        annotations:
+          summary: 'Instance {{ $labels.instance }} down'
+tests:
+  tests:
+    - name: instance down
+      interval: 1m
+      input_series:
+        - series: 'up{job="node", instance="node-1"}'
+          values: '1 1 1 0 0 0 0 0 0 0 0'
+        - series: 'up{job="node", instance="node-2"}'
+          values: '1x10'
+      alert_rule_test:
+        - eval_time: 2m
+          alertname: InstanceDown
+          exp_alerts: []
+        - eval_time: 10m
+          alertname: InstanceDown
+          exp_alerts:
+            - exp_labels:
+                severity: page
+                instance: node-1
+                job: node
+              exp_annotations:
+                summary: 'Instance node-1 down'
+      promql_expr_test:
+        - expr: job:up:sum
+          eval_time: 10m
+          exp_samples:
+            - labels: 'job:up:sum{job="node"}'
+              value: 1
+    - input_series:
+        - series: 'up{job="node", instance="node-1"}'
+          values: '0x10'
+      alert_rule_test:
+        - eval_time: 10m
+          alertname: InstanceDown
+          exp_alerts: []
+      promql_expr_test:
+        - expr: job:up:sum
+          eval_time: 10m
+          exp_samples:
+            - labels: 'job:up:sum{job="node"}'
+              value: 1
+`
+
+func TestRunTests(t *testing.T) {
+	spec := v1.PrometheusRuleSpec{}
+	if err := yaml.Unmarshal([]byte(ruleSpec), &spec); err != nil {
+		t.Fatal(err)
+	}
+	result, err := runTests(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.Equal(t, 2, len(result.Tests))
+
+	assert.Equal(t, v1.RuleTestGroupResult{Name: "instance down", Success: true}, result.Tests[0])
+
+	assert.Equal(t, "1", result.Tests[1].Name)
+	assert.False(t, result.Tests[1].Success)
+	if assert.Equal(t, 2, len(result.Tests[1].Errors)) {
+		assert.Contains(t, result.Tests[1].Errors[0], "alertname: InstanceDown, time: 10m")
+		assert.Contains(t, result.Tests[1].Errors[1], `expr: "job:up:sum", time: 10m`)
+	}
+}
+
+func TestRunTestsError(t *testing.T) {
+	testSuite := []struct {
+		title string
+		spec  v1.PrometheusRuleSpec
+	}{
+		{
+			title: "no test",
+			spec: v1.PrometheusRuleSpec{
+				Groups: []v1.RuleGroup{{Name: "node", Rules: []v1.Rule{{Record: "job:up:sum", Expr: "sum by (job) (up)"}}}},
+			},
+		},
+		{
+			title: "empty tests",
+			spec: v1.PrometheusRuleSpec{
+				Groups: []v1.RuleGroup{{Name: "node", Rules: []v1.Rule{{Record: "job:up:sum", Expr: "sum by (job) (up)"}}}},
+				Tests:  &v1.RuleTest{},
+			},
+		},
+		{
+			title: "too many evaluations",
+			spec: newSpecWithTest(v1.RuleTestGroup{
+				InputSeries:     []v1.RuleTestSeries{{Series: "up", Values: "1x10"}},
+				PromQLExprTests: []v1.PromQLExprTestCase{{Expr: "up", EvalTime: model.Duration(365 * 24 * time.Hour)}},
+			}),
+		},
+		{
+			title: "too many input series",
+			spec: newSpecWithTest(v1.RuleTestGroup{
+				InputSeries: func() []v1.RuleTestSeries {
+					series := make([]v1.RuleTestSeries, maxInputSeries+1)
+					for i := range series {
+						series[i] = v1.RuleTestSeries{Series: fmt.Sprintf(`up{instance="%d"}`, i), Values: "1"}
+					}
+					return series
+				}(),
+			}),
+		},
+		{
+			title: "too many input samples",
+			spec: newSpecWithTest(v1.RuleTestGroup{
+				InputSeries: []v1.RuleTestSeries{{Series: "up", Values: "1 0+1x100000000"}},
+			}),
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := runTests(context.Background(), test.spec)
+			assert.Error(t, err)
+		})
+	}
+}
+
+func newSpecWithTest(test v1.RuleTestGroup) v1.PrometheusRuleSpec {
+	return v1.PrometheusRuleSpec{
+		Groups: []v1.RuleGroup{{Name: "node", Rules: []v1.Rule{{Record: "job:up:sum", Expr: "sum by (job) (up)"}}}},
+		Tests:  &v1.RuleTest{Tests: []v1.RuleTestGroup{test}},
+	}
+}
+
+func TestRunTestsTimeout(t *testing.T) {
+	spec := newSpecWithTest(v1.RuleTestGroup{
+		InputSeries:     []v1.RuleTestSeries{{Series: "up", Values: "1x100"}},
+		PromQLExprTests: []v1.PromQLExprTestCase{{Expr: "job:up:sum", EvalTime: model.Duration(time.Hour)}},
+	})
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := runTests(ctx, spec)
+	assert.Error(t, err)
+}
+
+func TestCountSamples(t *testing.T) {
+	assert.Equal(t, uint64(0), countSamples(""))
+	assert.Equal(t, uint64(3), countSamples("1 _ stale"))
+	assert.Equal(t, uint64(13), countSamples("0+1x10 _x1"))
+}
+
+func TestRunTestsInvalidRule(t *testing.T) {
+	spec := v1.PrometheusRuleSpec{
+		Groups: []v1.RuleGroup{{Name: "node", Rules: []v1.Rule{{Record: "job:up:sum", Expr: "sum by (job) (up"}}}},
+		Tests: &v1.RuleTest{
+			Tests: []v1.RuleTestGroup{
+				{
+					InputSeries: []v1.RuleTestSeries{{Series: "up", Values: "1x10"}},
+				},
+			},
+		},
+	}
+	result, err := runTests(context.Background(), spec)
+	assert.NoError(t, err)
+	assert.False(t, result.Success)
+	assert.NotEmpty(t, result.Tests[0].Errors)
+}
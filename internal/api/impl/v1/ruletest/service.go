@@ -0,0 +1,64 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruletest
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/ruletest"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+// testTimeout is the maximum amount of time to run all the tests of a PrometheusRule.
+const testTimeout = 30 * time.Second
+
+type service struct {
+	ruletest.Service
+	dao prometheusrule.DAO
+}
+
+func NewService(dao prometheusrule.DAO) ruletest.Service {
+	return &service{
+		dao: dao,
+	}
+}
+
+func (s *service) Test(project string, name string) (*v1.RuleTestResult, error) {
+	entity, err := s.dao.Get(project, name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the prometheusRule '%s' in the project '%s'", name, project)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to find the prometheusRule '%s' in the project '%s', something wrong with etcd", name, project)
+		return nil, shared.InternalError
+	}
+	return s.TestPrometheusRule(entity)
+}
+
+func (s *service) TestPrometheusRule(entity *v1.PrometheusRule) (*v1.RuleTestResult, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), testTimeout)
+	defer cancel()
+	result, err := runTests(ctx, entity.Spec)
+	if err != nil {
+		return nil, fmt.Errorf("%w: %s", shared.BadRequestError, err)
+	}
+	return result, nil
+}
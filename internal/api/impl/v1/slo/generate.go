@@ -0,0 +1,224 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slo
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+)
+
+// burnRateAlert is one of the alerts of the multi-window, multi-burn-rate alerting described in the SRE workbook.
+// The alert fires when the budget consumed over the long window reaches budgetPercent of the error budget of the SLO,
+// and the short window confirms that the budget is still being consumed.
+type burnRateAlert struct {
+	budgetPercent float64
+	longWindow    model.Duration
+	shortWindow   model.Duration
+	forDuration   model.Duration
+	page          bool
+}
+
+var burnRateAlerts = []burnRateAlert{
+	{budgetPercent: 2, longWindow: duration(time.Hour), shortWindow: duration(5 * time.Minute), forDuration: duration(2 * time.Minute), page: true},
+	{budgetPercent: 5, longWindow: duration(6 * time.Hour), shortWindow: duration(30 * time.Minute), forDuration: duration(15 * time.Minute), page: true},
+	{budgetPercent: 10, longWindow: duration(24 * time.Hour), shortWindow: duration(2 * time.Hour), forDuration: duration(time.Hour)},
+	{budgetPercent: 10, longWindow: duration(3 * 24 * time.Hour), shortWindow: duration(6 * time.Hour), forDuration: duration(3 * time.Hour)},
+}
+
+const (
+	// sloLabel is the label set with the name of the SLO on every rule generated.
+	sloLabel = "slo"
+	// longWindowLabel is the label set with the long window on the alerts, so each of them has a distinct set of labels.
+	longWindowLabel = "long_window"
+	// errorRatioRecordPrefix is the prefix of the recording rules of the error ratio, the window is appended to it.
+	errorRatioRecordPrefix = "slo:sli_error:ratio_rate"
+	objectiveRecord        = "slo:objective:ratio"
+	alertName              = "SLOErrorBudgetBurn"
+)
+
+func duration(d time.Duration) model.Duration {
+	return model.Duration(d)
+}
+
+// managedName is the name of the PrometheusRule and of the Dashboard generated from the SLO.
+func managedName(slo *v1.SLO) string {
+	return fmt.Sprintf("slo-%s", slo.Metadata.Name)
+}
+
+// managedBy is set on the resources generated from the SLO, so they can only be modified through it.
+func managedBy(slo *v1.SLO) string {
+	return fmt.Sprintf("%s/%s", v1.KindSLO, slo.Metadata.Name)
+}
+
+func formatFloat(f float64) string {
+	return strconv.FormatFloat(f, 'f', -1, 64)
+}
+
+// windows returns every window for which the error ratio is recorded, sorted.
+func windows(slo *v1.SLO) []model.Duration {
+	set := map[model.Duration]bool{slo.Spec.Window: true}
+	for _, alert := range burnRateAlerts {
+		set[alert.longWindow] = true
+		set[alert.shortWindow] = true
+	}
+	result := make([]model.Duration, 0, len(set))
+	for window := range set {
+		result = append(result, window)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i] < result[j] })
+	return result
+}
+
+func ruleLabels(slo *v1.SLO, extra map[string]string) map[string]string {
+	result := make(map[string]string, len(slo.Spec.Labels)+len(extra)+2)
+	for name, value := range slo.Spec.Labels {
+		result[name] = value
+	}
+	for name, value := range extra {
+		result[name] = value
+	}
+	result[sloLabel] = slo.Metadata.Name
+	result[v1.AlertmanagerProjectLabel] = slo.Metadata.Project
+	return result
+}
+
+// selector selects the series recorded for the SLO.
+func selector(slo *v1.SLO) string {
+	return fmt.Sprintf("{%s=%q, %s=%q}", sloLabel, slo.Metadata.Name, v1.AlertmanagerProjectLabel, slo.Metadata.Project)
+}
+
+func errorRatioRecord(window model.Duration) string {
+	return errorRatioRecordPrefix + window.String()
+}
+
+// errorBudget returns the error budget of the SLO as a PromQL expression.
+func errorBudget(slo *v1.SLO) string {
+	return fmt.Sprintf("(1 - %s)", formatFloat(slo.Spec.Objective))
+}
+
+func generatePrometheusRule(slo *v1.SLO) *v1.PrometheusRule {
+	recordingGroup := v1.RuleGroup{Name: fmt.Sprintf("slo-%s-recordings", slo.Metadata.Name)}
+	for _, window := range windows(slo) {
+		good := strings.ReplaceAll(slo.Spec.Good, v1.SLOWindowPlaceholder, window.String())
+		total := strings.ReplaceAll(slo.Spec.Total, v1.SLOWindowPlaceholder, window.String())
+		recordingGroup.Rules = append(recordingGroup.Rules, v1.Rule{
+			Record: errorRatioRecord(window),
+			Expr:   fmt.Sprintf("1 - ((%s) / (%s))", good, total),
+			Labels: ruleLabels(slo, nil),
+		})
+	}
+	recordingGroup.Rules = append(recordingGroup.Rules, v1.Rule{
+		Record: objectiveRecord,
+		Expr:   fmt.Sprintf("vector(%s)", formatFloat(slo.Spec.Objective)),
+		Labels: ruleLabels(slo, nil),
+	})
+
+	alertGroup := v1.RuleGroup{Name: fmt.Sprintf("slo-%s-alerts", slo.Metadata.Name)}
+	for _, alert := range burnRateAlerts {
+		// the burn rate consuming budgetPercent of the error budget of the whole window during the long window.
+		burnRate := alert.budgetPercent * float64(slo.Spec.Window) / (100 * float64(alert.longWindow))
+		threshold := fmt.Sprintf("(%s * %s)", formatFloat(burnRate), errorBudget(slo))
+		labels := slo.Spec.Alerting.TicketLabels
+		if alert.page {
+			labels = slo.Spec.Alerting.PageLabels
+		}
+		if len(labels) == 0 {
+			severity := "ticket"
+			if alert.page {
+				severity = "page"
+			}
+			labels = map[string]string{"severity": severity}
+		}
+		annotations := map[string]string{
+			"summary":     fmt.Sprintf("The SLO %s of the project %s is burning its error budget too fast.", slo.Metadata.Name, slo.Metadata.Project),
+			"description": fmt.Sprintf("%s%% of the error budget of %s has been consumed in %s.", formatFloat(alert.budgetPercent), slo.Spec.Window, alert.longWindow),
+		}
+		for name, value := range slo.Spec.Alerting.Annotations {
+			annotations[name] = value
+		}
+		alertLabels := ruleLabels(slo, labels)
+		alertLabels[longWindowLabel] = alert.longWindow.String()
+		alertGroup.Rules = append(alertGroup.Rules, v1.Rule{
+			Alert: alertName,
+			Expr: fmt.Sprintf("%s%s > %s\nand\n%s%s > %s",
+				errorRatioRecord(alert.longWindow), selector(slo), threshold,
+				errorRatioRecord(alert.shortWindow), selector(slo), threshold),
+			For:         alert.forDuration.String(),
+			Labels:      alertLabels,
+			Annotations: annotations,
+		})
+	}
+	return &v1.PrometheusRule{
+		Kind: v1.KindPrometheusRule,
+		Metadata: v1.ProjectMetadata{
+			Metadata:  v1.Metadata{Name: managedName(slo)},
+			Project:   slo.Metadata.Project,
+			ManagedBy: managedBy(slo),
+		},
+		Spec: v1.PrometheusRuleSpec{Groups: []v1.RuleGroup{recordingGroup, alertGroup}},
+	}
+}
+
+func newPanel(name string, order uint64, lines ...v1.Line) v1.Panel {
+	return v1.Panel{
+		Name:  name,
+		Order: order,
+		Chart: &v1.LineChart{Kind: v1.KindLineChart, ShowLegend: true, Lines: lines},
+	}
+}
+
+func generateDashboard(slo *v1.SLO) *v1.Dashboard {
+	sel := selector(slo)
+	var burnRates []v1.Line
+	for _, alert := range burnRateAlerts {
+		burnRates = append(burnRates, v1.Line{
+			Expr:   fmt.Sprintf("%s%s / %s", errorRatioRecord(alert.longWindow), sel, errorBudget(slo)),
+			Legend: fmt.Sprintf("burn rate %s", alert.longWindow),
+		})
+	}
+	return &v1.Dashboard{
+		Kind: v1.KindDashboard,
+		Metadata: v1.ProjectMetadata{
+			Metadata:  v1.Metadata{Name: managedName(slo)},
+			Project:   slo.Metadata.Project,
+			ManagedBy: managedBy(slo),
+		},
+		Spec: v1.DashboardSpec{
+			Datasource: slo.Spec.Dashboard.Datasource,
+			Duration:   duration(24 * time.Hour),
+			Sections: []v1.DashboardSection{
+				{
+					Name: fmt.Sprintf("SLO %s", slo.Metadata.Name),
+					Open: true,
+					Panels: []v1.Panel{
+						newPanel("SLI", 0,
+							v1.Line{Expr: fmt.Sprintf("1 - %s%s", errorRatioRecord(burnRateAlerts[0].shortWindow), sel), Legend: "SLI"},
+							v1.Line{Expr: fmt.Sprintf("%s%s", objectiveRecord, sel), Legend: "objective"},
+						),
+						newPanel("Remaining error budget", 1,
+							v1.Line{Expr: fmt.Sprintf("1 - %s%s / %s", errorRatioRecord(slo.Spec.Window), sel, errorBudget(slo)), Legend: "remaining error budget"},
+						),
+						newPanel("Burn rates", 2, burnRates...),
+					},
+				},
+			},
+		},
+	}
+}
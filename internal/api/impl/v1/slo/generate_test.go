@@ -0,0 +1,106 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slo
+
+import (
+	"testing"
+
+	"github.com/perses/perses/internal/api/impl/v1/dashboard/expression"
+	prometheusruleImpl "github.com/perses/perses/internal/api/impl/v1/prometheusrule"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+	"gopkg.in/yaml.v2"
+)
+
+const availabilitySLO = `
+kind: SLO
+metadata:
+  name: api-availability
+  project: perses
+spec:
+  objective: 0.999
+  good: sum(rate(http_requests_total{code!~"5.."}[$window]))
+  total: sum(rate(http_requests_total[$window]))
+  labels:
+    team: perses
+  alerting:
+    annotations:
+      runbook_url: https://runbook/api-availability
+  dashboard:
+    datasource: PrometheusDemo
+`
+
+func unmarshalSLO(t *testing.T, data string) *v1.SLO {
+	entity := &v1.SLO{}
+	if err := yaml.Unmarshal([]byte(data), entity); err != nil {
+		t.Fatal(err)
+	}
+	return entity
+}
+
+func TestGeneratePrometheusRule(t *testing.T) {
+	entity := unmarshalSLO(t, availabilitySLO)
+	rule := generatePrometheusRule(entity)
+	assert.NoError(t, prometheusruleImpl.CheckRule(rule.Spec))
+	assert.Equal(t, "slo-api-availability", rule.Metadata.Name)
+	assert.Equal(t, "perses", rule.Metadata.Project)
+	assert.Equal(t, "SLO/api-availability", rule.Metadata.ManagedBy)
+
+	if assert.Len(t, rule.Spec.Groups, 2) {
+		var records []string
+		for _, r := range rule.Spec.Groups[0].Rules {
+			records = append(records, r.Record)
+		}
+		assert.Equal(t, []string{
+			"slo:sli_error:ratio_rate5m",
+			"slo:sli_error:ratio_rate30m",
+			"slo:sli_error:ratio_rate1h",
+			"slo:sli_error:ratio_rate2h",
+			"slo:sli_error:ratio_rate6h",
+			"slo:sli_error:ratio_rate1d",
+			"slo:sli_error:ratio_rate3d",
+			"slo:sli_error:ratio_rate30d",
+			"slo:objective:ratio",
+		}, records)
+		assert.Equal(t, `1 - ((sum(rate(http_requests_total{code!~"5.."}[5m]))) / (sum(rate(http_requests_total[5m]))))`, rule.Spec.Groups[0].Rules[0].Expr)
+		assert.Equal(t, map[string]string{"team": "perses", "slo": "api-availability", "project": "perses"}, rule.Spec.Groups[0].Rules[0].Labels)
+
+		alerts := rule.Spec.Groups[1].Rules
+		if assert.Len(t, alerts, 4) {
+			// the burn rates of the SRE workbook for a 30d window
+			assert.Equal(t, "slo:sli_error:ratio_rate1h{slo=\"api-availability\", project=\"perses\"} > (14.4 * (1 - 0.999))\nand\nslo:sli_error:ratio_rate5m{slo=\"api-availability\", project=\"perses\"} > (14.4 * (1 - 0.999))", alerts[0].Expr)
+			assert.Contains(t, alerts[1].Expr, "> (6 * (1 - 0.999))")
+			assert.Contains(t, alerts[2].Expr, "> (3 * (1 - 0.999))")
+			assert.Contains(t, alerts[3].Expr, "> (1 * (1 - 0.999))")
+			assert.Equal(t, "2m", alerts[0].For)
+			assert.Equal(t, "page", alerts[0].Labels["severity"])
+			assert.Equal(t, "ticket", alerts[3].Labels["severity"])
+			assert.Equal(t, "https://runbook/api-availability", alerts[3].Annotations["runbook_url"])
+			// the alerts having the same severity must still be distinct for Alertmanager
+			assert.Equal(t, "1h", alerts[0].Labels["long_window"])
+			assert.Equal(t, "6h", alerts[1].Labels["long_window"])
+			assert.Equal(t, alerts[0].Labels["severity"], alerts[1].Labels["severity"])
+			assert.NotEqual(t, alerts[0].Labels, alerts[1].Labels)
+			assert.NotEqual(t, alerts[2].Labels, alerts[3].Labels)
+		}
+	}
+}
+
+func TestGenerateDashboard(t *testing.T) {
+	entity := unmarshalSLO(t, availabilitySLO)
+	dashboard := generateDashboard(entity)
+	assert.Equal(t, "slo-api-availability", dashboard.Metadata.Name)
+	assert.Equal(t, "PrometheusDemo", dashboard.Spec.Datasource)
+	assert.NoError(t, expression.Check(dashboard.Spec, nil))
+}
@@ -0,0 +1,61 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slo
+
+import (
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/slo"
+	"github.com/perses/perses/internal/api/shared/database"
+	"github.com/perses/perses/internal/api/shared/metrics"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+type dao struct {
+	slo.DAO
+	client database.DAO
+}
+
+func NewDAO(persistence database.DAO) slo.DAO {
+	client := metrics.NewInstrumentedDAO(persistence, string(v1.KindSLO))
+	return &dao{
+		client: client,
+	}
+}
+
+func (d *dao) Create(entity *v1.SLO) error {
+	key := entity.GenerateID()
+	return d.client.Create(key, entity)
+}
+
+func (d *dao) Update(entity *v1.SLO) error {
+	key := entity.GenerateID()
+	return d.client.Upsert(key, entity, entity.Metadata.Version)
+}
+
+func (d *dao) Delete(project string, name string, version uint64) error {
+	key := v1.GenerateSLOID(project, name)
+	return d.client.Delete(key, version)
+}
+
+func (d *dao) Get(project string, name string) (*v1.SLO, error) {
+	key := v1.GenerateSLOID(project, name)
+	entity := &v1.SLO{}
+	return entity, d.client.Get(key, entity)
+}
+
+func (d *dao) List(q etcd.Query) ([]*v1.SLO, error) {
+	var result []*v1.SLO
+	err := d.client.Query(q, &result)
+	return result, err
+}
@@ -0,0 +1,370 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slo
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/perses/common/etcd"
+	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
+	projectImpl "github.com/perses/perses/internal/api/impl/v1/project"
+	"github.com/perses/perses/internal/api/interface/v1/dashboard"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/project"
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/slo"
+	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/pkg/model/api"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+type service struct {
+	slo.Service
+	dao           slo.DAO
+	ruleDAO       prometheusrule.DAO
+	ruleService   prometheusrule.Service
+	dashboardDAO  dashboard.DAO
+	projectDAO    project.DAO
+	datasourceDAO datasource.DAO
+	history       shared.History
+}
+
+// NewService returns the SLO service. The generated PrometheusRule is validated by ruleService, so it respects the
+// policies of the project like any other PrometheusRule, and the changes of the generated resources are added to the history.
+func NewService(dao slo.DAO, ruleDAO prometheusrule.DAO, ruleService prometheusrule.Service, dashboardDAO dashboard.DAO,
+	projectDAO project.DAO, datasourceDAO datasource.DAO, history shared.History) slo.Service {
+	return &service{
+		dao:           dao,
+		ruleDAO:       ruleDAO,
+		ruleService:   ruleService,
+		dashboardDAO:  dashboardDAO,
+		projectDAO:    projectDAO,
+		datasourceDAO: datasourceDAO,
+		history:       history,
+	}
+}
+
+func (s *service) Create(entity api.Entity) (interface{}, error) {
+	if sloObject, ok := entity.(*v1.SLO); ok {
+		return s.create(sloObject)
+	}
+	return nil, fmt.Errorf("%w: wrong entity format, attempting SLO format, received '%T'", shared.BadRequestError, entity)
+}
+
+func (s *service) create(entity *v1.SLO) (*v1.SLO, error) {
+	if err := s.checkReferences(entity); err != nil {
+		return nil, err
+	}
+	rule := generatePrometheusRule(entity)
+	if err := s.ruleService.Validate(rule); err != nil {
+		return nil, err
+	}
+	// the generated resources must not replace resources that are not managed by the SLO
+	if err := s.checkManageable(entity); err != nil {
+		return nil, err
+	}
+	entity.Status = status(entity)
+	// Update the time contains in the entity
+	entity.Metadata.CreateNow()
+	if err := s.dao.Create(entity); err != nil {
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to create the SLO '%s'. It already exits", entity.Metadata.Name)
+			return nil, shared.ConflictError
+		}
+		logrus.WithError(err).Errorf("unable to perform the creation of the SLO '%s', something wrong with etcd", entity.Metadata.Name)
+		return nil, shared.InternalError
+	}
+	if err := s.syncManagedResources(entity, rule); err != nil {
+		s.rollback(entity, nil)
+		return nil, err
+	}
+	return entity, nil
+}
+
+// checkReferences verifies the project of the SLO exists, and the datasource used by its dashboard too.
+func (s *service) checkReferences(entity *v1.SLO) error {
+	if err := projectImpl.CheckExists(s.projectDAO, entity.Metadata.Project); err != nil {
+		return err
+	}
+	if entity.Spec.Dashboard == nil {
+		return nil
+	}
+	return datasourceImpl.CheckExists(s.datasourceDAO, entity.Metadata.Project, entity.Spec.Dashboard.Datasource)
+}
+
+func (s *service) Update(entity api.Entity, parameters shared.Parameters) (interface{}, error) {
+	if sloObject, ok := entity.(*v1.SLO); ok {
+		return s.update(sloObject, parameters)
+	}
+	return nil, fmt.Errorf("%w: wrong entity format, attempting SLO format, received '%T'", shared.BadRequestError, entity)
+}
+
+func (s *service) update(entity *v1.SLO, parameters shared.Parameters) (*v1.SLO, error) {
+	if entity.Metadata.Name != parameters.Name {
+		logrus.Debugf("name in SLO '%s' and coming from the http request: '%s' doesn't match", entity.Metadata.Name, parameters.Name)
+		return nil, fmt.Errorf("%w: metadata.name and the name in the http path request doesn't match", shared.BadRequestError)
+	}
+	if len(entity.Metadata.Project) == 0 {
+		entity.Metadata.Project = parameters.Project
+	} else if entity.Metadata.Project != parameters.Project {
+		logrus.Debugf("project in SLO '%s' and coming from the http request: '%s' doesn't match", entity.Metadata.Project, parameters.Project)
+		return nil, fmt.Errorf("%w: metadata.project and the project name in the http path request doesn't match", shared.BadRequestError)
+	}
+	if err := s.checkReferences(entity); err != nil {
+		return nil, err
+	}
+	rule := generatePrometheusRule(entity)
+	if err := s.ruleService.Validate(rule); err != nil {
+		return nil, err
+	}
+	// find the previous version of the SLO
+	oldEntity, err := s.Get(parameters)
+	if err != nil {
+		return nil, err
+	}
+	oldObject := oldEntity.(*v1.SLO)
+	if err := s.checkManageable(entity); err != nil {
+		return nil, err
+	}
+	entity.Status = status(entity)
+	// update the immutable field of the newEntity with the old one
+	entity.Metadata.CreatedAt = oldObject.Metadata.CreatedAt
+	// update the field UpdatedAt with the new time
+	entity.Metadata.UpdatedAt = time.Now().UTC()
+	if err := s.dao.Update(entity); err != nil {
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to update the SLO '%s', it has been modified since the version given", entity.Metadata.Name)
+			return nil, shared.VersionConflictError
+		}
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to update the SLO '%s', it has been deleted in the meantime", entity.Metadata.Name)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to perform the update of the SLO '%s', something wrong with etcd", entity.Metadata.Name)
+		return nil, shared.InternalError
+	}
+	if err := s.syncManagedResources(entity, rule); err != nil {
+		s.rollback(entity, oldObject)
+		return nil, err
+	}
+	return entity, nil
+}
+
+func (s *service) Delete(parameters shared.Parameters) error {
+	if err := s.dao.Delete(parameters.Project, parameters.Name, parameters.Version); err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the SLO '%s'", parameters.Name)
+			return shared.NotFoundError
+		}
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to delete the SLO '%s', it has been modified since the version given", parameters.Name)
+			return shared.VersionConflictError
+		}
+		logrus.WithError(err).Errorf("unable to delete the SLO '%s', something wrong with etcd", parameters.Name)
+		return shared.InternalError
+	}
+	// the resources that cannot be removed are adopted again by an SLO created with the same name
+	return s.deleteManagedResources(&v1.SLO{Metadata: v1.ProjectMetadata{Metadata: v1.Metadata{Name: parameters.Name}, Project: parameters.Project}})
+}
+
+func (s *service) Get(parameters shared.Parameters) (interface{}, error) {
+	entity, err := s.dao.Get(parameters.Project, parameters.Name)
+	if err != nil {
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to find the SLO '%s'", parameters.Name)
+			return nil, shared.NotFoundError
+		}
+		logrus.WithError(err).Errorf("unable to find the previous version of the SLO '%s', something wrong with etcd", parameters.Name)
+		return nil, shared.InternalError
+	}
+	return entity, nil
+}
+
+func (s *service) List(q etcd.Query, _ shared.Parameters) (interface{}, error) {
+	return s.dao.List(q)
+}
+
+// checkManageable returns a conflict if a resource with the name of a generated one already exists and is not managed by the SLO.
+func (s *service) checkManageable(entity *v1.SLO) error {
+	name, project := managedName(entity), entity.Metadata.Project
+	var existing []v1.ProjectMetadata
+	if rule, err := s.ruleDAO.Get(project, name); err == nil {
+		existing = append(existing, rule.Metadata)
+	} else if !etcd.IsKeyNotFound(err) {
+		logrus.WithError(err).Errorf("unable to check if the prometheusRule '%s' exists, something wrong with etcd", name)
+		return shared.InternalError
+	}
+	if entity.Spec.Dashboard != nil {
+		if dashboardObject, err := s.dashboardDAO.Get(project, name); err == nil {
+			existing = append(existing, dashboardObject.Metadata)
+		} else if !etcd.IsKeyNotFound(err) {
+			logrus.WithError(err).Errorf("unable to check if the dashboard '%s' exists, something wrong with etcd", name)
+			return shared.InternalError
+		}
+	}
+	for _, metadata := range existing {
+		if metadata.ManagedBy != managedBy(entity) {
+			return managedConflict(name)
+		}
+	}
+	return nil
+}
+
+// syncManagedResources stores the PrometheusRule and the Dashboard generated from the SLO.
+// The dashboard is removed when the SLO doesn't ask for it anymore.
+func (s *service) syncManagedResources(entity *v1.SLO, rule *v1.PrometheusRule) error {
+	if err := s.writeRule(rule); err != nil {
+		return err
+	}
+	if entity.Spec.Dashboard == nil {
+		return s.deleteDashboard(entity)
+	}
+	return s.writeDashboard(generateDashboard(entity))
+}
+
+// deleteManagedResources removes the resources generated from the SLO. The resources it doesn't manage are left untouched.
+func (s *service) deleteManagedResources(entity *v1.SLO) error {
+	if err := s.deleteRule(entity); err != nil {
+		return err
+	}
+	return s.deleteDashboard(entity)
+}
+
+// rollback restores the SLO as it was before a write whose generated resources couldn't be stored, so the SLO and
+// the resources generated from it don't diverge. previous is nil when the SLO has just been created.
+// The SLO has already been written, so a failure is only logged.
+func (s *service) rollback(entity *v1.SLO, previous *v1.SLO) {
+	if previous == nil {
+		if err := s.dao.Delete(entity.Metadata.Project, entity.Metadata.Name, entity.Metadata.Version); err != nil {
+			logrus.WithError(err).Errorf("unable to roll back the creation of the SLO '%s'", entity.Metadata.Name)
+			return
+		}
+		if err := s.deleteManagedResources(entity); err != nil {
+			logrus.WithError(err).Errorf("unable to remove the resources generated by the SLO '%s' after its creation failed", entity.Metadata.Name)
+		}
+		return
+	}
+	previous.Metadata.Version = entity.Metadata.Version
+	if err := s.dao.Update(previous); err != nil {
+		logrus.WithError(err).Errorf("unable to roll back the update of the SLO '%s'", entity.Metadata.Name)
+		return
+	}
+	if err := s.syncManagedResources(previous, generatePrometheusRule(previous)); err != nil {
+		logrus.WithError(err).Errorf("unable to restore the resources generated by the SLO '%s' after its update failed", entity.Metadata.Name)
+	}
+}
+
+// writeRule stores the PrometheusRule generated from the SLO. An existing PrometheusRule is only replaced if it's
+// managed by the SLO and if it hasn't been modified since it has been read.
+func (s *service) writeRule(rule *v1.PrometheusRule) error {
+	operation := v1.RevisionCreated
+	rule.Metadata.CreateNow()
+	oldRule, err := s.ruleDAO.Get(rule.Metadata.Project, rule.Metadata.Name)
+	if err == nil {
+		if oldRule.Metadata.ManagedBy != rule.Metadata.ManagedBy {
+			return managedConflict(rule.Metadata.Name)
+		}
+		operation = v1.RevisionUpdated
+		rule.Metadata.CreatedAt = oldRule.Metadata.CreatedAt
+		rule.Metadata.Version = oldRule.Metadata.Version
+		err = s.ruleDAO.Update(rule)
+	} else if etcd.IsKeyNotFound(err) {
+		err = s.ruleDAO.Create(rule)
+	}
+	if err != nil {
+		return managedWriteError(err, rule.Metadata.Name)
+	}
+	s.history.Record(rule, operation, "", 0)
+	return nil
+}
+
+// writeDashboard stores the Dashboard generated from the SLO, with the same checks as writeRule.
+func (s *service) writeDashboard(dashboardObject *v1.Dashboard) error {
+	operation := v1.RevisionCreated
+	dashboardObject.Metadata.CreateNow()
+	oldDashboard, err := s.dashboardDAO.Get(dashboardObject.Metadata.Project, dashboardObject.Metadata.Name)
+	if err == nil {
+		if oldDashboard.Metadata.ManagedBy != dashboardObject.Metadata.ManagedBy {
+			return managedConflict(dashboardObject.Metadata.Name)
+		}
+		operation = v1.RevisionUpdated
+		dashboardObject.Metadata.CreatedAt = oldDashboard.Metadata.CreatedAt
+		dashboardObject.Metadata.Version = oldDashboard.Metadata.Version
+		err = s.dashboardDAO.Update(dashboardObject)
+	} else if etcd.IsKeyNotFound(err) {
+		err = s.dashboardDAO.Create(dashboardObject)
+	}
+	if err != nil {
+		return managedWriteError(err, dashboardObject.Metadata.Name)
+	}
+	s.history.Record(dashboardObject, operation, "", 0)
+	return nil
+}
+
+// deleteRule removes the PrometheusRule generated from the SLO, if it's still managed by the SLO.
+func (s *service) deleteRule(entity *v1.SLO) error {
+	name := managedName(entity)
+	oldRule, err := s.ruleDAO.Get(entity.Metadata.Project, name)
+	if err == nil && oldRule.Metadata.ManagedBy == managedBy(entity) {
+		err = s.ruleDAO.Delete(entity.Metadata.Project, name, oldRule.Metadata.Version)
+		if err == nil {
+			s.history.Record(oldRule, v1.RevisionDeleted, "", 0)
+		}
+	}
+	if err != nil && !etcd.IsKeyNotFound(err) {
+		return managedWriteError(err, name)
+	}
+	return nil
+}
+
+// deleteDashboard removes the Dashboard generated from the SLO, if it's still managed by the SLO.
+func (s *service) deleteDashboard(entity *v1.SLO) error {
+	name := managedName(entity)
+	oldDashboard, err := s.dashboardDAO.Get(entity.Metadata.Project, name)
+	if err == nil && oldDashboard.Metadata.ManagedBy == managedBy(entity) {
+		err = s.dashboardDAO.Delete(entity.Metadata.Project, name, oldDashboard.Metadata.Version)
+		if err == nil {
+			s.history.Record(oldDashboard, v1.RevisionDeleted, "", 0)
+		}
+	}
+	if err != nil && !etcd.IsKeyNotFound(err) {
+		return managedWriteError(err, name)
+	}
+	return nil
+}
+
+func managedConflict(name string) error {
+	logrus.Debugf("unable to store the resource '%s' generated by the SLO, it is not managed by the SLO", name)
+	return fmt.Errorf("%w: the resource '%s' generated by the SLO already exists", shared.ConflictError, name)
+}
+
+// managedWriteError converts the error returned by the DAO when a generated resource is written.
+func managedWriteError(err error, name string) error {
+	if etcd.IsKeyConflict(err) || etcd.IsKeyNotFound(err) {
+		logrus.Debugf("unable to store the resource '%s' generated by the SLO, it has been modified in the meantime", name)
+		return fmt.Errorf("%w: the resource '%s' generated by the SLO has been modified in the meantime", shared.ConflictError, name)
+	}
+	logrus.WithError(err).Errorf("unable to store the resource '%s' generated by the SLO, something wrong with etcd", name)
+	return shared.InternalError
+}
+
+func status(entity *v1.SLO) *v1.SLOStatus {
+	result := &v1.SLOStatus{PrometheusRule: managedName(entity)}
+	if entity.Spec.Dashboard != nil {
+		result.Dashboard = managedName(entity)
+	}
+	return result
+}
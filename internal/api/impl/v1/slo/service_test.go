@@ -0,0 +1,302 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package slo
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/perses/common/etcd"
+	prometheusruleImpl "github.com/perses/perses/internal/api/impl/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/dashboard"
+	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/project"
+	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/slo"
+	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/pkg/model/api"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+func keyNotFound(key string) error {
+	return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+}
+
+type fakeSLODAO struct {
+	slo.DAO
+	entities map[string]*v1.SLO
+}
+
+func (d *fakeSLODAO) Create(entity *v1.SLO) error {
+	d.entities[entity.Metadata.Name] = entity
+	return nil
+}
+
+func (d *fakeSLODAO) Update(entity *v1.SLO) error {
+	d.entities[entity.Metadata.Name] = entity
+	return nil
+}
+
+func (d *fakeSLODAO) Get(project string, name string) (*v1.SLO, error) {
+	if entity, ok := d.entities[name]; ok {
+		return entity, nil
+	}
+	return nil, keyNotFound(v1.GenerateSLOID(project, name))
+}
+
+func (d *fakeSLODAO) Delete(project string, name string, _ uint64) error {
+	if _, ok := d.entities[name]; !ok {
+		return keyNotFound(v1.GenerateSLOID(project, name))
+	}
+	delete(d.entities, name)
+	return nil
+}
+
+type fakeRuleDAO struct {
+	prometheusrule.DAO
+	entities map[string]*v1.PrometheusRule
+}
+
+func (d *fakeRuleDAO) Create(entity *v1.PrometheusRule) error {
+	d.entities[entity.Metadata.Name] = entity
+	return nil
+}
+
+func (d *fakeRuleDAO) Update(entity *v1.PrometheusRule) error {
+	d.entities[entity.Metadata.Name] = entity
+	return nil
+}
+
+func (d *fakeRuleDAO) Get(project string, name string) (*v1.PrometheusRule, error) {
+	if entity, ok := d.entities[name]; ok {
+		return entity, nil
+	}
+	return nil, keyNotFound(v1.GeneratePrometheusRuleID(project, name))
+}
+
+func (d *fakeRuleDAO) Delete(project string, name string, _ uint64) error {
+	if _, ok := d.entities[name]; !ok {
+		return keyNotFound(v1.GeneratePrometheusRuleID(project, name))
+	}
+	delete(d.entities, name)
+	return nil
+}
+
+type fakeDashboardDAO struct {
+	dashboard.DAO
+	entities map[string]*v1.Dashboard
+	// err is returned by the writes when it's set
+	err error
+}
+
+func (d *fakeDashboardDAO) Create(entity *v1.Dashboard) error {
+	return d.Update(entity)
+}
+
+func (d *fakeDashboardDAO) Update(entity *v1.Dashboard) error {
+	if d.err != nil {
+		return d.err
+	}
+	d.entities[entity.Metadata.Name] = entity
+	return nil
+}
+
+func (d *fakeDashboardDAO) Get(project string, name string) (*v1.Dashboard, error) {
+	if entity, ok := d.entities[name]; ok {
+		return entity, nil
+	}
+	return nil, keyNotFound(v1.GenerateDashboardID(project, name))
+}
+
+func (d *fakeDashboardDAO) Delete(project string, name string, _ uint64) error {
+	if _, ok := d.entities[name]; !ok {
+		return keyNotFound(v1.GenerateDashboardID(project, name))
+	}
+	delete(d.entities, name)
+	return nil
+}
+
+type fakeProjectDAO struct {
+	project.DAO
+}
+
+func (d *fakeProjectDAO) Get(name string) (*v1.Project, error) {
+	if name != "perses" {
+		return nil, keyNotFound(v1.GenerateProjectID(name))
+	}
+	return &v1.Project{Kind: v1.KindProject, Metadata: v1.Metadata{Name: name}}, nil
+}
+
+type fakeDatasourceDAO struct {
+	datasource.DAO
+}
+
+func (d *fakeDatasourceDAO) Get(project string, name string) (*v1.Datasource, error) {
+	if len(project) > 0 || name != "PrometheusDemo" {
+		return nil, keyNotFound(v1.GenerateDatasourceID(project, name))
+	}
+	return &v1.Datasource{Kind: v1.KindDatasource, Metadata: v1.OptionalProjectMetadata{Metadata: v1.Metadata{Name: name}}}, nil
+}
+
+type fakeRuleService struct {
+	prometheusrule.Service
+}
+
+// Validate rejects the rules of the SLOs whose objective is below 0.99, like a policy of the project would do.
+func (s *fakeRuleService) Validate(entity *v1.PrometheusRule) error {
+	if err := prometheusruleImpl.CheckRule(entity.Spec); err != nil {
+		return err
+	}
+	if strings.Contains(entity.Spec.Groups[1].Rules[0].Expr, "(1 - 0.9)") {
+		return fmt.Errorf("%w: rules don't respect the policies of the project", shared.BadRequestError)
+	}
+	return nil
+}
+
+type fakeHistory struct {
+	shared.History
+	revisions []string
+}
+
+func (h *fakeHistory) Record(entity api.Entity, operation v1.RevisionOperation, _ string, _ uint64) {
+	h.revisions = append(h.revisions, fmt.Sprintf("%s %s", entity.GenerateID(), operation))
+}
+
+func newService(sloDAO slo.DAO, ruleDAO prometheusrule.DAO, dashboardDAO dashboard.DAO) slo.Service {
+	return NewService(sloDAO, ruleDAO, &fakeRuleService{}, dashboardDAO, &fakeProjectDAO{}, &fakeDatasourceDAO{}, &fakeHistory{})
+}
+
+func TestManagedResources(t *testing.T) {
+	sloDAO := &fakeSLODAO{entities: map[string]*v1.SLO{}}
+	ruleDAO := &fakeRuleDAO{entities: map[string]*v1.PrometheusRule{}}
+	dashboardDAO := &fakeDashboardDAO{entities: map[string]*v1.Dashboard{}}
+	s := newService(sloDAO, ruleDAO, dashboardDAO)
+
+	// the creation generates the prometheusRule and the dashboard
+	_, err := s.Create(unmarshalSLO(t, availabilitySLO))
+	assert.NoError(t, err)
+	assert.Contains(t, ruleDAO.entities, "slo-api-availability")
+	assert.Contains(t, dashboardDAO.entities, "slo-api-availability")
+	assert.Equal(t, &v1.SLOStatus{PrometheusRule: "slo-api-availability", Dashboard: "slo-api-availability"}, sloDAO.entities["api-availability"].Status)
+	assert.Equal(t, "SLO/api-availability", ruleDAO.entities["slo-api-availability"].Metadata.ManagedBy)
+	assert.Equal(t, "SLO/api-availability", dashboardDAO.entities["slo-api-availability"].Metadata.ManagedBy)
+
+	// the update regenerates the prometheusRule and removes the dashboard that is not wanted anymore
+	entity := unmarshalSLO(t, availabilitySLO)
+	entity.Spec.Objective = 0.99
+	entity.Spec.Dashboard = nil
+	_, err = s.Update(entity, shared.Parameters{Project: "perses", Name: "api-availability"})
+	assert.NoError(t, err)
+	assert.Contains(t, ruleDAO.entities["slo-api-availability"].Spec.Groups[1].Rules[0].Expr, "(1 - 0.99)")
+	assert.NotContains(t, dashboardDAO.entities, "slo-api-availability")
+	assert.Equal(t, &v1.SLOStatus{PrometheusRule: "slo-api-availability"}, sloDAO.entities["api-availability"].Status)
+
+	// the deletion removes the generated resources
+	assert.NoError(t, s.Delete(shared.Parameters{Project: "perses", Name: "api-availability"}))
+	assert.Empty(t, ruleDAO.entities)
+	assert.Empty(t, sloDAO.entities)
+
+	// every change of the generated resources is added to their history
+	assert.Equal(t, []string{
+		"/prometheusrules/perses/slo-api-availability created",
+		"/dashboards/perses/slo-api-availability created",
+		"/prometheusrules/perses/slo-api-availability updated",
+		"/dashboards/perses/slo-api-availability deleted",
+		"/prometheusrules/perses/slo-api-availability deleted",
+	}, s.(*service).history.(*fakeHistory).revisions)
+}
+
+func TestCreateRejectedByPolicies(t *testing.T) {
+	sloDAO := &fakeSLODAO{entities: map[string]*v1.SLO{}}
+	ruleDAO := &fakeRuleDAO{entities: map[string]*v1.PrometheusRule{}}
+	s := newService(sloDAO, ruleDAO, &fakeDashboardDAO{entities: map[string]*v1.Dashboard{}})
+	entity := unmarshalSLO(t, availabilitySLO)
+	entity.Spec.Objective = 0.9
+	_, err := s.Create(entity)
+	assert.True(t, errors.Is(err, shared.BadRequestError))
+	assert.Empty(t, sloDAO.entities)
+	assert.Empty(t, ruleDAO.entities)
+}
+
+func TestRollback(t *testing.T) {
+	sloDAO := &fakeSLODAO{entities: map[string]*v1.SLO{}}
+	ruleDAO := &fakeRuleDAO{entities: map[string]*v1.PrometheusRule{}}
+	dashboardDAO := &fakeDashboardDAO{entities: map[string]*v1.Dashboard{}, err: errors.New("etcd is down")}
+	s := newService(sloDAO, ruleDAO, dashboardDAO)
+
+	// the creation is rolled back when the dashboard cannot be stored
+	_, err := s.Create(unmarshalSLO(t, availabilitySLO))
+	assert.True(t, errors.Is(err, shared.InternalError))
+	assert.Empty(t, sloDAO.entities)
+	assert.Empty(t, ruleDAO.entities)
+
+	dashboardDAO.err = nil
+	_, err = s.Create(unmarshalSLO(t, availabilitySLO))
+	assert.NoError(t, err)
+
+	// the update is rolled back too, with the resources already regenerated
+	dashboardDAO.err = errors.New("etcd is down")
+	entity := unmarshalSLO(t, availabilitySLO)
+	entity.Spec.Objective = 0.99
+	_, err = s.Update(entity, shared.Parameters{Project: "perses", Name: "api-availability"})
+	assert.True(t, errors.Is(err, shared.InternalError))
+	assert.Equal(t, 0.999, sloDAO.entities["api-availability"].Spec.Objective)
+	assert.Contains(t, ruleDAO.entities["slo-api-availability"].Spec.Groups[1].Rules[0].Expr, "(1 - 0.999)")
+}
+
+func TestCreateDoesNotReplaceUnmanagedResources(t *testing.T) {
+	ruleDAO := &fakeRuleDAO{entities: map[string]*v1.PrometheusRule{"slo-api-availability": {}}}
+	s := newService(&fakeSLODAO{entities: map[string]*v1.SLO{}}, ruleDAO, &fakeDashboardDAO{entities: map[string]*v1.Dashboard{}})
+	_, err := s.Create(unmarshalSLO(t, availabilitySLO))
+	assert.True(t, errors.Is(err, shared.ConflictError))
+
+	// the resources left behind by an SLO with the same name are adopted
+	ruleDAO.entities["slo-api-availability"].Metadata.ManagedBy = "SLO/api-availability"
+	_, err = s.Create(unmarshalSLO(t, availabilitySLO))
+	assert.NoError(t, err)
+}
+
+func TestCreateWithUnknownReferences(t *testing.T) {
+	testSuite := []struct {
+		title   string
+		modify  func(entity *v1.SLO)
+		message string
+	}{
+		{
+			title:   "unknown project",
+			modify:  func(entity *v1.SLO) { entity.Metadata.Project = "unknown" },
+			message: "project 'unknown' doesn't exist",
+		},
+		{
+			title:   "unknown datasource",
+			modify:  func(entity *v1.SLO) { entity.Spec.Dashboard.Datasource = "unknown" },
+			message: "datasource 'unknown' doesn't exist",
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			sloDAO := &fakeSLODAO{entities: map[string]*v1.SLO{}}
+			s := newService(sloDAO, &fakeRuleDAO{entities: map[string]*v1.PrometheusRule{}}, &fakeDashboardDAO{entities: map[string]*v1.Dashboard{}})
+			entity := unmarshalSLO(t, availabilitySLO)
+			test.modify(entity)
+			_, err := s.Create(entity)
+			assert.True(t, errors.Is(err, shared.BadRequestError))
+			assert.Contains(t, err.Error(), test.message)
+			assert.Empty(t, sloDAO.entities)
+		})
+	}
+}
@@ -14,21 +14,20 @@
 package user
 
 import (
-	"time"
-
 	"github.com/perses/common/etcd"
 	"github.com/perses/perses/internal/api/interface/v1/user"
+	"github.com/perses/perses/internal/api/shared/database"
+	"github.com/perses/perses/internal/api/shared/metrics"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
-	clientv3 "go.etcd.io/etcd/client/v3"
 )
 
 type dao struct {
 	user.DAO
-	client etcd.DAO
+	client database.DAO
 }
 
-func NewDAO(etcdClient *clientv3.Client, timeout time.Duration) user.DAO {
-	client := etcd.NewDAO(etcdClient, timeout)
+func NewDAO(persistence database.DAO) user.DAO {
+	client := metrics.NewInstrumentedDAO(persistence, string(v1.KindUser))
 	return &dao{
 		client: client,
 	}
@@ -41,12 +40,12 @@ func (d *dao) Create(entity *v1.User) error {
 
 func (d *dao) Update(entity *v1.User) error {
 	key := entity.GenerateID()
-	return d.client.Upsert(key, entity)
+	return d.client.Upsert(key, entity, entity.Metadata.Version)
 }
 
-func (d *dao) Delete(name string) error {
+func (d *dao) Delete(name string, version uint64) error {
 	key := v1.GenerateUserID(name)
-	return d.client.Delete(key)
+	return d.client.Delete(key, version)
 }
 
 func (d *dao) Get(name string) (*v1.User, error) {
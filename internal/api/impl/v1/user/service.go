@@ -117,6 +117,14 @@ func (s *service) update(entity *v1.User, parameters shared.Parameters) (*v1.Use
 		entity.Spec.LastName = oldObject.Spec.LastName
 	}
 	if err := s.dao.Update(entity); err != nil {
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to update the user '%s', it has been modified since the version given", entity.Metadata.Name)
+			return nil, shared.VersionConflictError
+		}
+		if etcd.IsKeyNotFound(err) {
+			logrus.Debugf("unable to update the user '%s', it has been deleted in the meantime", entity.Metadata.Name)
+			return nil, shared.NotFoundError
+		}
 		logrus.WithError(err).Errorf("unable to perform the update of the project '%s', something wrong with etcd", entity.Metadata.Name)
 		return nil, shared.InternalError
 	}
@@ -126,11 +134,15 @@ func (s *service) update(entity *v1.User, parameters shared.Parameters) (*v1.Use
 }
 
 func (s *service) Delete(parameters shared.Parameters) error {
-	if err := s.dao.Delete(parameters.Name); err != nil {
+	if err := s.dao.Delete(parameters.Name, parameters.Version); err != nil {
 		if etcd.IsKeyNotFound(err) {
 			logrus.Debugf("unable to find the user '%s'", parameters.Name)
 			return shared.NotFoundError
 		}
+		if etcd.IsKeyConflict(err) {
+			logrus.Debugf("unable to delete the user '%s', it has been modified since the version given", parameters.Name)
+			return shared.VersionConflictError
+		}
 		logrus.WithError(err).Errorf("unable to delete the user '%s', something wrong with etcd", parameters.Name)
 		return shared.InternalError
 	}
@@ -152,6 +164,15 @@ func (s *service) Get(parameters shared.Parameters) (interface{}, error) {
 	return entity, nil
 }
 
+// FilterWatched removes the password of the watched users, like List does.
+func (s *service) FilterWatched(entity api.Entity, _ etcd.Query, _ shared.Parameters) bool {
+	userObject, ok := entity.(*v1.User)
+	if ok {
+		userObject.Spec.Password = nil
+	}
+	return ok
+}
+
 func (s *service) List(q etcd.Query, _ shared.Parameters) (interface{}, error) {
 	// on each user found, let's remove the password so it won't be leaked
 	results, err := s.dao.List(q)
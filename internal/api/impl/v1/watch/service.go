@@ -0,0 +1,90 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/watch"
+	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/internal/api/shared/database"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+var eventTypes = map[database.EventType]v1.WatchEventType{
+	database.EventCreate: v1.WatchEventAdded,
+	database.EventUpdate: v1.WatchEventModified,
+	database.EventDelete: v1.WatchEventDeleted,
+}
+
+type service struct {
+	watch.Service
+	dao database.DAO
+}
+
+func NewService(dao database.DAO) watch.Service {
+	return &service{
+		dao: dao,
+	}
+}
+
+func (s *service) Watch(ctx context.Context, q etcd.Query, fromVersion uint64) (<-chan *v1.WatchEvent, error) {
+	watchChan, err := s.dao.Watch(ctx, q, fromVersion)
+	if err != nil {
+		if errors.Is(err, database.ErrVersionCompacted) {
+			logrus.Debugf("unable to resume the watch from the version %d", fromVersion)
+			return nil, fmt.Errorf("%w: %s, the resources must be listed again", shared.GoneError, err)
+		}
+		logrus.WithError(err).Error("unable to watch the resources")
+		return nil, shared.InternalError
+	}
+	result := make(chan *v1.WatchEvent)
+	go func() {
+		defer close(result)
+		for response := range watchChan {
+			for _, event := range convertResponse(response) {
+				select {
+				case result <- event:
+				case <-ctx.Done():
+					return
+				}
+			}
+		}
+	}()
+	return result, nil
+}
+
+func convertResponse(response database.WatchResponse) []*v1.WatchEvent {
+	events := make([]*v1.WatchEvent, 0, len(response.Events)+1)
+	for _, event := range response.Events {
+		events = append(events, &v1.WatchEvent{
+			Type:     eventTypes[event.Type],
+			Version:  event.Version,
+			Resource: event.Value,
+		})
+	}
+	if response.Err != nil {
+		logrus.WithError(response.Err).Error("error received when watching the resources")
+		message := shared.InternalError.Error()
+		if errors.Is(response.Err, database.ErrVersionCompacted) {
+			message = response.Err.Error()
+		}
+		events = append(events, &v1.WatchEvent{Type: v1.WatchEventError, Error: message})
+	}
+	return events
+}
@@ -0,0 +1,75 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package watch
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/interface/v1/project"
+	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/internal/api/shared/database"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeDAO struct {
+	database.DAO
+	responses []database.WatchResponse
+	err       error
+}
+
+func (d *fakeDAO) Watch(_ context.Context, _ etcd.Query, _ uint64) (database.WatchChan, error) {
+	if d.err != nil {
+		return nil, d.err
+	}
+	result := make(chan database.WatchResponse, len(d.responses))
+	for _, response := range d.responses {
+		result <- response
+	}
+	close(result)
+	return result, nil
+}
+
+func TestWatch(t *testing.T) {
+	dao := &fakeDAO{responses: []database.WatchResponse{
+		{Events: []database.Event{
+			{Type: database.EventCreate, Key: "/projects/perses", Value: []byte(`{"kind":"Project"}`), Version: 1},
+			{Type: database.EventUpdate, Key: "/projects/perses", Value: []byte(`{"kind":"Project"}`), Version: 2},
+		}},
+		{Events: []database.Event{
+			{Type: database.EventDelete, Key: "/projects/perses", Value: []byte(`{"kind":"Project"}`), Version: 3},
+		}},
+		{Err: database.ErrVersionCompacted},
+	}}
+	events, err := NewService(dao).Watch(context.Background(), &project.Query{}, 0)
+	assert.NoError(t, err)
+	var types []v1.WatchEventType
+	var versions []uint64
+	for event := range events {
+		types = append(types, event.Type)
+		versions = append(versions, event.Version)
+	}
+	assert.Equal(t, []v1.WatchEventType{v1.WatchEventAdded, v1.WatchEventModified, v1.WatchEventDeleted, v1.WatchEventError}, types)
+	assert.Equal(t, []uint64{1, 2, 3, 0}, versions)
+}
+
+func TestWatchFromCompactedVersion(t *testing.T) {
+	_, err := NewService(&fakeDAO{err: database.ErrVersionCompacted}).Watch(context.Background(), &project.Query{}, 1)
+	assert.True(t, errors.Is(err, shared.GoneError))
+	_, err = NewService(&fakeDAO{err: errors.New("connection lost")}).Watch(context.Background(), &project.Query{}, 1)
+	assert.True(t, errors.Is(err, shared.InternalError))
+}
@@ -14,8 +14,11 @@
 package dashboard
 
 import (
+	"context"
+
 	"github.com/perses/common/etcd"
 	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/internal/api/shared/database"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
 )
 
@@ -36,9 +39,11 @@ func (q *Query) Build() (string, error) {
 type DAO interface {
 	Create(entity *v1.Dashboard) error
 	Update(entity *v1.Dashboard) error
-	Delete(project string, name string) error
+	Delete(project string, name string, version uint64) error
 	Get(project string, name string) (*v1.Dashboard, error)
 	List(q etcd.Query) ([]*v1.Dashboard, error)
+	// Watch returns the changes of every Dashboard, in all projects, until the context is canceled.
+	Watch(ctx context.Context) (database.WatchChan, error)
 }
 
 type Service interface {
@@ -24,17 +24,21 @@ type Query struct {
 	// Name is a prefix of the Datasource.metadata.name that is used to filter the list of the Datasource.
 	// Name can be empty in case you want to return the full list of Datasource available.
 	Name string `query:"name"`
+	// Project is the exact name of the project.
+	// The value can come or from the path of the URL or from the query parameter
+	// When it is empty, only the global Datasource are considered.
+	Project string `path:"project" query:"project"`
 }
 
 func (q *Query) Build() (string, error) {
-	return v1.GenerateDatasourceID(q.Name), nil
+	return v1.GenerateDatasourceID(q.Project, q.Name), nil
 }
 
 type DAO interface {
 	Create(entity *v1.Datasource) error
 	Update(entity *v1.Datasource) error
-	Delete(name string) error
-	Get(name string) (*v1.Datasource, error)
+	Delete(project string, name string, version uint64) error
+	Get(project string, name string) (*v1.Datasource, error)
 	List(q etcd.Query) ([]*v1.Datasource, error)
 }
 
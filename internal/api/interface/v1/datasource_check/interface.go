@@ -0,0 +1,24 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_check
+
+import v1 "github.com/perses/perses/pkg/model/api/v1"
+
+type Service interface {
+	// Check verifies the connectivity with the datasource stored with the given name.
+	// The datasource of the project is used in priority, then the global one. The project can be empty.
+	Check(project string, name string) (*v1.DatasourceCheckResponse, error)
+	// CheckDatasource verifies the connectivity with the given datasource that doesn't need to be stored (aka dry-run).
+	CheckDatasource(entity *v1.Datasource) (*v1.DatasourceCheckResponse, error)
+}
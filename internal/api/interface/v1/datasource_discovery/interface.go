@@ -0,0 +1,60 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_discovery
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+// Query contains the optional parameters used to limit the results of the discovery.
+type Query struct {
+	// Matchers are series selectors. When set, only the series matching at least one of them are considered.
+	Matchers []string `query:"match[]"`
+	// Start and End are the time range to consider. They are either a RFC3339 date or an unix timestamp in seconds.
+	Start string `query:"start"`
+	End   string `query:"end"`
+}
+
+// TimeRange returns the time range of the query. A zero time is returned when the bound is not set.
+func (q *Query) TimeRange() (time.Time, time.Time, error) {
+	start, err := shared.ParseTime(q.Start)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid start: %s", err)
+	}
+	end, err := shared.ParseTime(q.End)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid end: %s", err)
+	}
+	if !start.IsZero() && !end.IsZero() && end.Before(start) {
+		return time.Time{}, time.Time{}, fmt.Errorf("end cannot be before start")
+	}
+	return start, end, nil
+}
+
+// Service is providing the information required to write a query (like the auto-completion in an editor).
+// The datasource used is the one of the project in priority, then the global one. The project can be empty.
+type Service interface {
+	// MetricNames returns the name of the metrics.
+	MetricNames(project string, name string, query *Query) ([]string, error)
+	// MetricMetadata returns the metadata (type, help, unit) of the metrics grouped by metric name.
+	MetricMetadata(project string, name string, query *Query) (map[string][]v1.MetricMetadata, error)
+	// LabelNames returns the name of the labels.
+	LabelNames(project string, name string, query *Query) ([]string, error)
+	// LabelValues returns the values of the given label.
+	LabelValues(project string, name string, label string, query *Query) ([]string, error)
+}
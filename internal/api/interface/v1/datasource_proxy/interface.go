@@ -0,0 +1,23 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package datasource_proxy
+
+import "net/http"
+
+type Service interface {
+	// Proxy forwards the request to the datasource with the given name.
+	// The datasource of the project is used in priority, then the global one. The project can be empty.
+	// path is the path requested on the datasource. It must be one of the read-only path of the API of the datasource.
+	Proxy(project string, name string, path string, w http.ResponseWriter, r *http.Request) error
+}
@@ -0,0 +1,32 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metric_dependency
+
+import (
+	"github.com/perses/common/async"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+type Service interface {
+	// Task keeps the index of the metrics up to date with the changes of the dashboards and the PrometheusRules,
+	// until the application stops. When it's not running, the index is built for each request.
+	async.Task
+	// GetMetricUsage returns the recording rules producing the metric and the dashboards and the rules using it.
+	GetMetricUsage(metric string) (*v1.MetricUsage, error)
+	// GetDashboardDependencies returns the metrics used by the dashboard. For each metric, UsedBy only contains the expressions of the dashboard.
+	GetDashboardDependencies(project string, name string) ([]v1.MetricUsage, error)
+	// GetPrometheusRuleDeletionWarnings returns a warning for each metric recorded by the PrometheusRule that is still used,
+	// and that no other rule is recording.
+	GetPrometheusRuleDeletionWarnings(project string, name string) ([]string, error)
+}
@@ -33,9 +33,16 @@ func (q *Query) Build() (string, error) {
 type DAO interface {
 	Create(entity *v1.Project) error
 	Update(entity *v1.Project) error
-	Delete(name string) error
+	Delete(name string, version uint64) error
 	Get(name string) (*v1.Project, error)
 	List(q etcd.Query) ([]*v1.Project, error)
+	// ResourceKeys returns the keys of the resources belonging to the project.
+	ResourceKeys(name string) ([]string, error)
+	// DeleteIfEmpty deletes the project only if no resource belongs to it, in a single transaction.
+	// It fails with database.ErrPrefixNotEmpty otherwise.
+	DeleteIfEmpty(name string, version uint64) error
+	// DeleteWithResources deletes the project with the resources belonging to it and their revisions, in a single transaction.
+	DeleteWithResources(name string, version uint64) error
 }
 
 type Service interface {
@@ -14,8 +14,11 @@
 package prometheusrule
 
 import (
+	"context"
+
 	"github.com/perses/common/etcd"
 	"github.com/perses/perses/internal/api/shared"
+	"github.com/perses/perses/internal/api/shared/database"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
 )
 
@@ -36,11 +39,15 @@ func (q *Query) Build() (string, error) {
 type DAO interface {
 	Create(entity *v1.PrometheusRule) error
 	Update(entity *v1.PrometheusRule) error
-	Delete(project string, name string) error
+	Delete(project string, name string, version uint64) error
 	Get(project string, name string) (*v1.PrometheusRule, error)
 	List(q etcd.Query) ([]*v1.PrometheusRule, error)
+	// Watch returns the changes of every PrometheusRule, in all projects, until the context is canceled.
+	Watch(ctx context.Context) (database.WatchChan, error)
 }
 
 type Service interface {
 	shared.ToolboxService
+	// Validate runs on the PrometheusRule every check done before writing it, without writing it.
+	Validate(entity *v1.PrometheusRule) error
 }
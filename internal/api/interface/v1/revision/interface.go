@@ -0,0 +1,42 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package revision
+
+import (
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+type Query struct {
+	etcd.Query
+	// Key is the key of the resource for which the revisions are returned.
+	Key string
+}
+
+func (q *Query) Build() (string, error) {
+	return v1.GenerateRevisionPrefix(q.Key), nil
+}
+
+type DAO interface {
+	Create(key string, entity *v1.Revision) error
+	Delete(key string, number uint64) error
+	Get(key string, number uint64) (*v1.Revision, error)
+	// List returns the revisions of the resource sorted by number.
+	List(key string) ([]*v1.Revision, error)
+}
+
+type Service interface {
+	shared.History
+}
@@ -0,0 +1,26 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulefile_sync
+
+import (
+	"github.com/perses/common/async"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+type Service interface {
+	// Task writes the PrometheusRules on disk every time they change, until the application stops.
+	async.Task
+	// GetStatus returns the state of the last synchronization.
+	GetStatus() *v1.RuleFileSyncStatus
+}
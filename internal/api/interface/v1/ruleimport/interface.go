@@ -0,0 +1,47 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruleimport
+
+import (
+	"fmt"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+// Query contains the parameters of the import.
+type Query struct {
+	// Conflict decides what to do when a PrometheusRule already exists: skip, overwrite or fail. Default is fail.
+	Conflict string `query:"conflict"`
+	// Name is the name of the PrometheusRule created from a plain rule file, as a rule file doesn't have one.
+	Name string `query:"name"`
+}
+
+// ConflictMode returns the conflict mode of the query, fail when it's not set.
+func (q *Query) ConflictMode() (v1.ImportConflictMode, error) {
+	mode := v1.ImportConflictMode(q.Conflict)
+	switch mode {
+	case "":
+		return v1.ImportConflictFail, nil
+	case v1.ImportConflictSkip, v1.ImportConflictOverwrite, v1.ImportConflictFail:
+		return mode, nil
+	default:
+		return "", fmt.Errorf("unknown conflict mode '%s', it should be one of: %s, %s, %s", q.Conflict, v1.ImportConflictSkip, v1.ImportConflictOverwrite, v1.ImportConflictFail)
+	}
+}
+
+type Service interface {
+	// Import turns the given YAML documents into PrometheusRules and stores them in the project.
+	// It returns the result of the import of each PrometheusRule.
+	Import(project string, data []byte, q Query) ([]v1.RuleImportResult, error)
+}
@@ -0,0 +1,94 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package rulepreview
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/perses/perses/internal/api/shared"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/prometheus/common/model"
+)
+
+// Query contains the parameters of the evaluation.
+type Query struct {
+	// Datasource is the name of the datasource used to evaluate the rules.
+	// The datasource of the project is used in priority, then the global one.
+	Datasource string `query:"datasource"`
+	// Time is the time of an instant evaluation. Default is now. It cannot be used with Start and End.
+	Time string `query:"time"`
+	// Start and End are the time range of a range evaluation. They must be set together.
+	// Like Time, they are either a RFC3339 date or an unix timestamp in seconds.
+	Start string `query:"start"`
+	End   string `query:"end"`
+	// Step is the interval between two evaluations of a range evaluation, like 1m.
+	// Default is the interval of the rule group, or 1m when the group doesn't have one.
+	Step string `query:"step"`
+}
+
+// TimeRange returns the time range of the evaluation. For an instant evaluation, start and end are equal.
+func (q *Query) TimeRange(now time.Time) (time.Time, time.Time, error) {
+	if len(q.Time) > 0 && (len(q.Start) > 0 || len(q.End) > 0) {
+		return time.Time{}, time.Time{}, fmt.Errorf("time cannot be used with start and end")
+	}
+	if len(q.Start) > 0 || len(q.End) > 0 {
+		start, err := shared.ParseTime(q.Start)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid start: %s", err)
+		}
+		end, err := shared.ParseTime(q.End)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("invalid end: %s", err)
+		}
+		if start.IsZero() || end.IsZero() {
+			return time.Time{}, time.Time{}, fmt.Errorf("start and end must be set together")
+		}
+		if end.Before(start) {
+			return time.Time{}, time.Time{}, fmt.Errorf("end cannot be before start")
+		}
+		return start, end, nil
+	}
+	t, err := shared.ParseTime(q.Time)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("invalid time: %s", err)
+	}
+	if t.IsZero() {
+		t = now
+	}
+	return t, t, nil
+}
+
+// StepDuration returns the step of the query. It is 0 when it's not set.
+func (q *Query) StepDuration() (time.Duration, error) {
+	if len(q.Step) == 0 {
+		return 0, nil
+	}
+	step, err := model.ParseDuration(q.Step)
+	if err != nil {
+		return 0, fmt.Errorf("invalid step: %s", err)
+	}
+	if step <= 0 {
+		return 0, fmt.Errorf("step must be greater than 0")
+	}
+	return time.Duration(step), nil
+}
+
+// Service evaluates the rules of a PrometheusRule against a live datasource, to know how they would behave.
+type Service interface {
+	// Preview evaluates the rules of the PrometheusRule stored with the given project and name.
+	Preview(project string, name string, query *Query) (*v1.RulePreviewResult, error)
+	// PreviewPrometheusRule evaluates the rules of the given PrometheusRule that doesn't need to be stored (aka dry-run).
+	PreviewPrometheusRule(entity *v1.PrometheusRule, query *Query) (*v1.RulePreviewResult, error)
+}
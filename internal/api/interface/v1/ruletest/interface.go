@@ -0,0 +1,23 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package ruletest
+
+import v1 "github.com/perses/perses/pkg/model/api/v1"
+
+type Service interface {
+	// Test runs the tests of the PrometheusRule stored with the given project and name.
+	Test(project string, name string) (*v1.RuleTestResult, error)
+	// TestPrometheusRule runs the tests of the given PrometheusRule that doesn't need to be stored (aka dry-run).
+	TestPrometheusRule(entity *v1.PrometheusRule) (*v1.RuleTestResult, error)
+}
@@ -33,7 +33,7 @@ func (q *Query) Build() (string, error) {
 type DAO interface {
 	Create(entity *v1.User) error
 	Update(entity *v1.User) error
-	Delete(name string) error
+	Delete(name string, version uint64) error
 	Get(name string) (*v1.User, error)
 	List(q etcd.Query) ([]*v1.User, error)
 }
@@ -0,0 +1,199 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package database contains the backends where the resources are stored.
+// The backend is chosen in the configuration: etcd, files on disk for a single instance of Perses,
+// or the memory for the tests and the demos.
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"reflect"
+	"strconv"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/config"
+	"github.com/perses/perses/pkg/model/api"
+)
+
+type EventType string
+
+const (
+	EventCreate EventType = "create"
+	EventUpdate EventType = "update"
+	EventDelete EventType = "delete"
+)
+
+// ErrVersionCompacted is returned when a watch is resumed from a version whose following changes are not available anymore.
+// The resources must then be reloaded before watching them again.
+var ErrVersionCompacted = errors.New("the changes following the version requested are not available anymore")
+
+// ErrPrefixNotEmpty is returned by DeleteIfPrefixesEmpty when a key still starts with one of the prefixes.
+var ErrPrefixNotEmpty = errors.New("some keys still start with the prefixes")
+
+// Event is a change of a key.
+type Event struct {
+	Type EventType
+	Key  string
+	// Value is the entity encoded in JSON. When the key has been deleted, it's the entity before the deletion.
+	Value []byte
+	// Version is the version of the entity after the change.
+	Version uint64
+}
+
+// WatchResponse contains the changes that occurred since the previous response, or the error received.
+type WatchResponse struct {
+	Events []Event
+	Err    error
+}
+
+// WatchChan is closed when the context of the watch is canceled, or when the backend is not able to follow the changes anymore.
+// In the later case, the watch should be restarted after reloading the resources.
+type WatchChan <-chan WatchResponse
+
+// DAO defines the operations available on every backend.
+// A missing key and an already existing key are always reported with an *etcd.Error,
+// so etcd.IsKeyNotFound and etcd.IsKeyConflict can be used whatever the backend is.
+//
+// The version of the entities (see api.Versioned) is set by the DAO every time they are read or written.
+// When a version is given to Upsert or Delete, the operation is done only if the entity has still this version,
+// otherwise it fails with a conflict, or with a key not found if the entity doesn't exist anymore.
+// The version 0 means the operation is done whatever the version of the entity is.
+type DAO interface {
+	io.Closer
+	Create(key string, entity interface{}) error
+	Upsert(key string, entity interface{}, version uint64) error
+	Get(key string, entity interface{}) error
+	// Query returns the entities whose key starts with the prefix built by the query.
+	// slice must be a pointer to a slice.
+	Query(query etcd.Query, slice interface{}) error
+	Delete(key string, version uint64) error
+	// DeleteWithPrefixes deletes the key, checking its version like Delete does, and all the keys starting with the prefixes,
+	// in a single transaction.
+	DeleteWithPrefixes(key string, version uint64, prefixes []string) error
+	// DeleteIfPrefixesEmpty deletes the key, checking its version like Delete does, only if no key starts with the prefixes,
+	// in a single transaction. It fails with ErrPrefixNotEmpty otherwise.
+	DeleteIfPrefixesEmpty(key string, version uint64, prefixes []string) error
+	// Keys returns the keys starting with the prefix, without decoding the entities.
+	Keys(ctx context.Context, prefix string) ([]string, error)
+	// Watch returns the changes of the keys starting with the prefix built by the query, until the context is canceled.
+	// When fromVersion isn't 0, the changes done after this version are sent first.
+	Watch(ctx context.Context, query etcd.Query, fromVersion uint64) (WatchChan, error)
+	HealthCheck() bool
+}
+
+// New returns the DAO of the backend set in the configuration.
+func New(conf config.Database) (DAO, error) {
+	if conf.InMemory {
+		return NewMemoryDAO(), nil
+	}
+	if conf.File != nil {
+		return NewFileDAO(*conf.File)
+	}
+	return NewETCDDAO(*conf.Etcd)
+}
+
+// storedValue is an entity encoded in JSON, as stored in the database.
+type storedValue struct {
+	key  string
+	data []byte
+	// version replaces the version stored in the data when it's not 0.
+	version uint64
+}
+
+// decodeSlice decodes the values in the slice. slice must be a pointer to a slice.
+func decodeSlice(values []storedValue, slice interface{}) error {
+	sliceValue := reflect.ValueOf(slice)
+	if sliceValue.Kind() != reflect.Ptr || sliceValue.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("slice in parameter is not a pointer to a slice but a '%T'", slice)
+	}
+	sliceType := sliceValue.Elem().Type()
+	elemType := sliceType.Elem()
+	isPointer := elemType.Kind() == reflect.Ptr
+	if isPointer {
+		elemType = elemType.Elem()
+	}
+	// the slice is always initialized, even when it's empty.
+	result := reflect.MakeSlice(sliceType, 0, len(values))
+	for _, value := range values {
+		elem := reflect.New(elemType)
+		if err := json.Unmarshal(value.data, elem.Interface()); err != nil {
+			return fmt.Errorf("error decoding the value associated with the key '%s': %w", value.key, err)
+		}
+		if value.version != 0 {
+			setVersion(elem.Interface(), value.version)
+		}
+		if isPointer {
+			result = reflect.Append(result, elem)
+		} else {
+			result = reflect.Append(result, elem.Elem())
+		}
+	}
+	sliceValue.Elem().Set(result)
+	return nil
+}
+
+// setVersion sets the version of the entity, if it's versioned.
+func setVersion(entity interface{}, version uint64) {
+	if versioned := api.GetVersioned(entity); versioned != nil {
+		versioned.SetVersion(version)
+	}
+}
+
+// storedVersion returns the version stored in an entity encoded in JSON, for the backends storing the version in the entity.
+func storedVersion(data []byte) uint64 {
+	stored := struct {
+		Metadata struct {
+			Version uint64 `json:"version"`
+		} `json:"metadata"`
+	}{}
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return 0
+	}
+	return stored.Metadata.Version
+}
+
+// withVersion returns the entity encoded in JSON with its version set, for the backends not storing the version in the entity.
+// The data is returned as it is when it's not an entity with metadata.
+func withVersion(data []byte, version uint64) []byte {
+	var document map[string]json.RawMessage
+	var metadata map[string]json.RawMessage
+	if json.Unmarshal(data, &document) != nil || json.Unmarshal(document["metadata"], &metadata) != nil || metadata == nil {
+		return data
+	}
+	metadata["version"] = json.RawMessage(strconv.FormatUint(version, 10))
+	document["metadata"], _ = json.Marshal(metadata)
+	result, err := json.Marshal(document)
+	if err != nil {
+		return data
+	}
+	return result
+}
+
+// checkVersion returns an error if the key doesn't have the expected version. Nothing is checked when the expected version is 0.
+func checkVersion(key string, exists bool, version uint64, expectedVersion uint64) error {
+	if expectedVersion == 0 {
+		return nil
+	}
+	if !exists {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+	}
+	if version != expectedVersion {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyConflict}
+	}
+	return nil
+}
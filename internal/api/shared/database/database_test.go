@@ -0,0 +1,274 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/perses/common/etcd"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/stretchr/testify/assert"
+)
+
+type entity struct {
+	Metadata v1.Metadata       `json:"metadata"`
+	Value    int               `json:"value"`
+	Tags     map[string]string `json:"tags,omitempty"`
+}
+
+func newEntity(name string, value int) *entity {
+	return &entity{Metadata: v1.Metadata{Name: name}, Value: value}
+}
+
+func (e *entity) GenerateID() string {
+	return e.Metadata.Name
+}
+
+func (e *entity) GetMetadata() interface{} {
+	return &e.Metadata
+}
+
+type prefixQuery struct {
+	etcd.Query
+	prefix string
+}
+
+func (q *prefixQuery) Build() (string, error) {
+	return q.prefix, nil
+}
+
+// names returns the names of the entities and checks they all have a version.
+func names(t *testing.T, list []*entity) []string {
+	result := make([]string, 0, len(list))
+	for _, e := range list {
+		assert.NotZero(t, e.Metadata.Version)
+		result = append(result, e.Metadata.Name)
+	}
+	return result
+}
+
+// testDAO checks the behavior every backend must have.
+func testDAO(t *testing.T, dao DAO) {
+	node := newEntity("node", 1)
+	node.Tags = map[string]string{"team": "perses"}
+	assert.NoError(t, dao.Create("/dashboards/perses/node", node))
+	assert.NotZero(t, node.Metadata.Version)
+	assert.True(t, etcd.IsKeyConflict(dao.Create("/dashboards/perses/node", newEntity("node", 1))))
+
+	result := &entity{}
+	assert.NoError(t, dao.Get("/dashboards/perses/node", result))
+	assert.Equal(t, node, result)
+	assert.True(t, etcd.IsKeyNotFound(dao.Get("/dashboards/perses/unknown", result)))
+
+	assert.NoError(t, dao.Upsert("/dashboards/perses/node", newEntity("node", 2), 0))
+	assert.NoError(t, dao.Upsert("/dashboards/perses/etcd", newEntity("etcd", 3), 0))
+	assert.NoError(t, dao.Upsert("/dashboards/demo/node", newEntity("node", 4), 0))
+
+	var list []*entity
+	assert.NoError(t, dao.Query(&prefixQuery{prefix: "/dashboards/perses/"}, &list))
+	assert.Equal(t, []string{"etcd", "node"}, names(t, list))
+	assert.NoError(t, dao.Query(&prefixQuery{prefix: "/dashboards/perses/no"}, &list))
+	assert.Equal(t, []string{"node"}, names(t, list))
+	assert.Equal(t, 2, list[0].Value)
+	assert.NoError(t, dao.Query(&prefixQuery{prefix: "/datasources/"}, &list))
+	assert.Equal(t, []*entity{}, list)
+
+	keys, err := dao.Keys(context.Background(), "/dashboards/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/dashboards/demo/node", "/dashboards/perses/etcd", "/dashboards/perses/node"}, keys)
+
+	assert.NoError(t, dao.Delete("/dashboards/demo/node", 0))
+	assert.True(t, etcd.IsKeyNotFound(dao.Delete("/dashboards/demo/node", 0)))
+	assert.True(t, dao.HealthCheck())
+}
+
+// testVersion checks the entities are modified only if they have still the expected version.
+func testVersion(t *testing.T, dao DAO) {
+	read := newEntity("node", 1)
+	assert.NoError(t, dao.Create("/dashboards/perses/node", read))
+	version := read.Metadata.Version
+
+	// a first update with the version read succeeds, and changes the version
+	update := newEntity("node", 2)
+	assert.NoError(t, dao.Upsert("/dashboards/perses/node", update, version))
+	assert.Greater(t, update.Metadata.Version, version)
+	result := &entity{}
+	assert.NoError(t, dao.Get("/dashboards/perses/node", result))
+	assert.Equal(t, update.Metadata.Version, result.Metadata.Version)
+
+	// a second one with the same version is rejected, as well as the deletion
+	assert.True(t, etcd.IsKeyConflict(dao.Upsert("/dashboards/perses/node", newEntity("node", 3), version)))
+	assert.True(t, etcd.IsKeyConflict(dao.Delete("/dashboards/perses/node", version)))
+	assert.NoError(t, dao.Get("/dashboards/perses/node", result))
+	assert.Equal(t, 2, result.Value)
+
+	// the current version can be used to delete it, then it doesn't exist anymore
+	assert.NoError(t, dao.Delete("/dashboards/perses/node", update.Metadata.Version))
+	assert.True(t, etcd.IsKeyNotFound(dao.Upsert("/dashboards/perses/node", newEntity("node", 4), update.Metadata.Version)))
+	assert.True(t, etcd.IsKeyNotFound(dao.Delete("/dashboards/perses/node", update.Metadata.Version)))
+}
+
+// testDeleteWithPrefixes checks the key and the keys with the prefixes are deleted together, only if the key has the version given.
+func testDeleteWithPrefixes(t *testing.T, dao DAO) {
+	project := newEntity("perses", 0)
+	assert.NoError(t, dao.Create("/projects/perses", project))
+	for _, key := range []string{"/dashboards/perses/node", "/dashboards/perses/cpu", "/dashboards/perses-dev/node", "/revisions/dashboards/perses/node/1"} {
+		assert.NoError(t, dao.Create(key, newEntity("node", 0)))
+	}
+	prefixes := []string{"/dashboards/perses/", "/datasources/perses/", "/revisions/dashboards/perses/"}
+
+	err := dao.DeleteWithPrefixes("/projects/perses", project.Metadata.Version+100, prefixes)
+	assert.True(t, etcd.IsKeyConflict(err))
+	keys, err := dao.Keys(context.Background(), "/")
+	assert.NoError(t, err)
+	assert.Equal(t, 5, len(keys))
+
+	assert.NoError(t, dao.DeleteWithPrefixes("/projects/perses", project.Metadata.Version, prefixes))
+	keys, err = dao.Keys(context.Background(), "/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/dashboards/perses-dev/node"}, keys)
+
+	err = dao.DeleteWithPrefixes("/projects/perses", 0, prefixes)
+	assert.True(t, etcd.IsKeyNotFound(err))
+}
+
+// testDeleteIfPrefixesEmpty checks the key is deleted only when no key starts with the prefixes and it has the version given.
+func testDeleteIfPrefixesEmpty(t *testing.T, dao DAO) {
+	project := newEntity("perses", 0)
+	assert.NoError(t, dao.Create("/projects/perses", project))
+	assert.NoError(t, dao.Create("/dashboards/perses/node", newEntity("node", 0)))
+	assert.NoError(t, dao.Create("/dashboards/perses-dev/node", newEntity("node", 0)))
+	prefixes := []string{"/dashboards/perses/", "/datasources/perses/"}
+
+	err := dao.DeleteIfPrefixesEmpty("/projects/perses", project.Metadata.Version, prefixes)
+	assert.True(t, errors.Is(err, ErrPrefixNotEmpty))
+	assert.NoError(t, dao.Delete("/dashboards/perses/node", 0))
+
+	err = dao.DeleteIfPrefixesEmpty("/projects/perses", project.Metadata.Version+100, prefixes)
+	assert.True(t, etcd.IsKeyConflict(err))
+	assert.NoError(t, dao.DeleteIfPrefixesEmpty("/projects/perses", project.Metadata.Version, prefixes))
+	keys, err := dao.Keys(context.Background(), "/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/dashboards/perses-dev/node"}, keys)
+
+	err = dao.DeleteIfPrefixesEmpty("/projects/perses", 0, prefixes)
+	assert.True(t, etcd.IsKeyNotFound(err))
+}
+
+// testWatch checks the changes are sent to the watchers, until their context is canceled.
+func testWatch(t *testing.T, dao DAO) {
+	ctx, cancel := context.WithCancel(context.Background())
+	watchChan, err := dao.Watch(ctx, &prefixQuery{prefix: "/prometheusrules/"}, 0)
+	assert.NoError(t, err)
+
+	assert.NoError(t, dao.Upsert("/dashboards/perses/node", newEntity("node", 0), 0))
+	assert.NoError(t, dao.Upsert("/prometheusrules/perses/node", newEntity("node", 0), 0))
+	assert.NoError(t, dao.Upsert("/prometheusrules/perses/node", newEntity("node", 1), 0))
+	assert.NoError(t, dao.Delete("/prometheusrules/perses/node", 0))
+	response := <-watchChan
+	assert.Equal(t, 1, len(response.Events))
+	assert.Equal(t, EventCreate, response.Events[0].Type)
+	assert.Equal(t, "/prometheusrules/perses/node", response.Events[0].Key)
+	assert.Equal(t, response.Events[0].Version, storedVersion(response.Events[0].Value))
+	response = <-watchChan
+	assert.Equal(t, 1, len(response.Events))
+	assert.Equal(t, EventUpdate, response.Events[0].Type)
+	updateVersion := response.Events[0].Version
+	response = <-watchChan
+	assert.Equal(t, 1, len(response.Events))
+	assert.Equal(t, EventDelete, response.Events[0].Type)
+	// the value is the entity before its deletion
+	assert.Equal(t, updateVersion, storedVersion(response.Events[0].Value))
+
+	cancel()
+	_, ok := <-watchChan
+	assert.False(t, ok)
+}
+
+func TestSlowWatcher(t *testing.T) {
+	dao := NewMemoryDAO()
+	watchChan, err := dao.Watch(context.Background(), &prefixQuery{prefix: "/"}, 0)
+	assert.NoError(t, err)
+	for i := 0; i <= watchBufferSize; i++ {
+		assert.NoError(t, dao.Upsert("/projects/perses", newEntity("perses", i), 0))
+	}
+	// the watcher is closed instead of blocking the writes
+	count := 0
+	for range watchChan {
+		count++
+	}
+	assert.Equal(t, watchBufferSize, count)
+}
+
+// testResumeWatch checks the changes done after a version are sent when the watch is resumed from it.
+func testResumeWatch(t *testing.T, dao DAO) {
+	entity := newEntity("node", 0)
+	assert.NoError(t, dao.Create("/prometheusrules/perses/node", entity))
+	version := entity.Metadata.Version
+	assert.NoError(t, dao.Upsert("/dashboards/perses/node", newEntity("node", 0), 0))
+	assert.NoError(t, dao.Delete("/prometheusrules/perses/node", 0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	watchChan, err := dao.Watch(ctx, &prefixQuery{prefix: "/prometheusrules/"}, version)
+	assert.NoError(t, err)
+	assert.NoError(t, dao.Create("/prometheusrules/perses/node", newEntity("node", 0)))
+	response := <-watchChan
+	assert.Equal(t, EventDelete, response.Events[0].Type)
+	response = <-watchChan
+	assert.Equal(t, EventCreate, response.Events[0].Type)
+}
+
+func TestResumeWatchFromCompactedVersion(t *testing.T) {
+	dao := NewMemoryDAO()
+	// the two first changes are not kept in the history
+	for i := 0; i < watchHistorySize+2; i++ {
+		assert.NoError(t, dao.Upsert("/projects/perses", newEntity("perses", i), 0))
+	}
+	_, err := dao.Watch(context.Background(), &prefixQuery{prefix: "/"}, 1)
+	assert.Equal(t, ErrVersionCompacted, err)
+	_, err = dao.Watch(context.Background(), &prefixQuery{prefix: "/"}, 2)
+	assert.NoError(t, err)
+}
+
+func TestWithVersion(t *testing.T) {
+	testSuite := []struct {
+		title    string
+		data     string
+		expected string
+	}{
+		{
+			title:    "version added",
+			data:     `{"kind":"Project","metadata":{"name":"perses"}}`,
+			expected: `{"kind":"Project","metadata":{"name":"perses","version":42}}`,
+		},
+		{
+			title:    "version replaced",
+			data:     `{"metadata":{"name":"perses","version":3}}`,
+			expected: `{"metadata":{"name":"perses","version":42}}`,
+		},
+		{
+			title:    "no metadata",
+			data:     `{"name":"perses"}`,
+			expected: `{"name":"perses"}`,
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			assert.JSONEq(t, test.expected, string(withVersion([]byte(test.data), 42)))
+		})
+	}
+}
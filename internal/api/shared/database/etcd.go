@@ -0,0 +1,296 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/perses/common/config"
+	"github.com/perses/common/etcd"
+	clientv3 "go.etcd.io/etcd/client/v3"
+)
+
+type etcdDAO struct {
+	etcd.DAO
+	client  *clientv3.Client
+	timeout time.Duration
+}
+
+// NewETCDDAO returns a DAO storing the resources in etcd.
+func NewETCDDAO(conf config.EtcdConfig) (DAO, error) {
+	client, err := etcd.NewETCDClient(conf)
+	if err != nil {
+		return nil, err
+	}
+	timeout := time.Duration(conf.RequestTimeoutSeconds) * time.Second
+	return &etcdDAO{
+		DAO:     etcd.NewDAO(client, timeout),
+		client:  client,
+		timeout: timeout,
+	}, nil
+}
+
+func (d *etcdDAO) Create(key string, entity interface{}) error {
+	value, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	response, err := d.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.CreateRevision(key), "=", 0)).
+		Then(clientv3.OpPut(key, string(value))).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !response.Succeeded {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyConflict}
+	}
+	setVersion(entity, uint64(response.Header.Revision))
+	return nil
+}
+
+// Upsert relies on the mod revision of the key, that is the version of the entity.
+func (d *etcdDAO) Upsert(key string, entity interface{}, version uint64) error {
+	value, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	if version == 0 {
+		response, err := d.client.Put(ctx, key, string(value))
+		if err != nil {
+			return err
+		}
+		setVersion(entity, uint64(response.Header.Revision))
+		return nil
+	}
+	response, err := d.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", int64(version))).
+		Then(clientv3.OpPut(key, string(value))).
+		Else(clientv3.OpGet(key, clientv3.WithCountOnly())).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !response.Succeeded {
+		return versionError(key, response)
+	}
+	setVersion(entity, uint64(response.Header.Revision))
+	return nil
+}
+
+func (d *etcdDAO) Get(key string, entity interface{}) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	response, err := d.client.Get(ctx, key)
+	if err != nil {
+		return err
+	}
+	if response.Count == 0 {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+	}
+	if err := json.Unmarshal(response.Kvs[0].Value, entity); err != nil {
+		return err
+	}
+	setVersion(entity, uint64(response.Kvs[0].ModRevision))
+	return nil
+}
+
+func (d *etcdDAO) Query(query etcd.Query, slice interface{}) error {
+	prefix, err := query.Build()
+	if err != nil {
+		return fmt.Errorf("unable to build the query: %s", err)
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	response, err := d.client.Get(ctx, prefix, clientv3.WithPrefix())
+	if err != nil {
+		return err
+	}
+	values := make([]storedValue, 0, len(response.Kvs))
+	for _, kv := range response.Kvs {
+		values = append(values, storedValue{key: string(kv.Key), data: kv.Value, version: uint64(kv.ModRevision)})
+	}
+	return decodeSlice(values, slice)
+}
+
+func (d *etcdDAO) Delete(key string, version uint64) error {
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	if version == 0 {
+		response, err := d.client.Delete(ctx, key)
+		if err != nil {
+			return err
+		}
+		if response.Deleted == 0 {
+			return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+		}
+		return nil
+	}
+	response, err := d.client.Txn(ctx).
+		If(clientv3.Compare(clientv3.ModRevision(key), "=", int64(version))).
+		Then(clientv3.OpDelete(key)).
+		Else(clientv3.OpGet(key, clientv3.WithCountOnly())).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !response.Succeeded {
+		return versionError(key, response)
+	}
+	return nil
+}
+
+func (d *etcdDAO) DeleteWithPrefixes(key string, version uint64, prefixes []string) error {
+	// without version, the key must simply exist
+	condition := clientv3.Compare(clientv3.CreateRevision(key), ">", 0)
+	if version != 0 {
+		condition = clientv3.Compare(clientv3.ModRevision(key), "=", int64(version))
+	}
+	operations := []clientv3.Op{clientv3.OpDelete(key)}
+	for _, prefix := range prefixes {
+		operations = append(operations, clientv3.OpDelete(prefix, clientv3.WithPrefix()))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	response, err := d.client.Txn(ctx).
+		If(condition).
+		Then(operations...).
+		Else(clientv3.OpGet(key, clientv3.WithCountOnly())).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !response.Succeeded {
+		return versionError(key, response)
+	}
+	return nil
+}
+
+func (d *etcdDAO) DeleteIfPrefixesEmpty(key string, version uint64, prefixes []string) error {
+	// without version, the key must simply exist
+	condition := clientv3.Compare(clientv3.CreateRevision(key), ">", 0)
+	if version != 0 {
+		condition = clientv3.Compare(clientv3.ModRevision(key), "=", int64(version))
+	}
+	conditions := []clientv3.Cmp{condition}
+	failures := []clientv3.Op{clientv3.OpGet(key, clientv3.WithCountOnly())}
+	for _, prefix := range prefixes {
+		// a comparison on a range holds when it holds for every key of the range, so when the range is empty here
+		conditions = append(conditions, clientv3.Compare(clientv3.CreateRevision(prefix), "=", 0).WithPrefix())
+		failures = append(failures, clientv3.OpGet(prefix, clientv3.WithPrefix(), clientv3.WithCountOnly()))
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), d.timeout)
+	defer cancel()
+	response, err := d.client.Txn(ctx).
+		If(conditions...).
+		Then(clientv3.OpDelete(key)).
+		Else(failures...).
+		Commit()
+	if err != nil {
+		return err
+	}
+	if !response.Succeeded {
+		if response.Responses[0].GetResponseRange().Count > 0 {
+			for _, prefixResponse := range response.Responses[1:] {
+				if prefixResponse.GetResponseRange().Count > 0 {
+					return ErrPrefixNotEmpty
+				}
+			}
+		}
+		return versionError(key, response)
+	}
+	return nil
+}
+
+func (d *etcdDAO) Keys(ctx context.Context, prefix string) ([]string, error) {
+	response, err := d.client.Get(ctx, prefix, clientv3.WithPrefix(), clientv3.WithKeysOnly())
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the keys with the prefix %q: %w", prefix, err)
+	}
+	keys := make([]string, 0, len(response.Kvs))
+	for _, kv := range response.Kvs {
+		keys = append(keys, string(kv.Key))
+	}
+	return keys, nil
+}
+
+func (d *etcdDAO) Watch(ctx context.Context, query etcd.Query, fromVersion uint64) (WatchChan, error) {
+	prefix, err := query.Build()
+	if err != nil {
+		return nil, fmt.Errorf("unable to build the query: %s", err)
+	}
+	options := []clientv3.OpOption{clientv3.WithPrefix(), clientv3.WithPrevKV()}
+	if fromVersion != 0 {
+		options = append(options, clientv3.WithRev(int64(fromVersion)+1))
+	}
+	watchChan := d.client.Watch(ctx, prefix, options...)
+	result := make(chan WatchResponse)
+	go func() {
+		defer close(result)
+		// the etcd channel is closed when the context is canceled, or after a compaction error.
+		for response := range watchChan {
+			r := WatchResponse{Err: response.Err()}
+			if response.CompactRevision != 0 {
+				r.Err = ErrVersionCompacted
+			}
+			for _, event := range response.Events {
+				r.Events = append(r.Events, convertEvent(event))
+			}
+			select {
+			case result <- r:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return result, nil
+}
+
+// versionError returns the error of a transaction that failed because the key didn't have the expected mod revision.
+// The transaction must count the keys when it fails, to know if the key still exists.
+func versionError(key string, response *clientv3.TxnResponse) error {
+	if response.Responses[0].GetResponseRange().Count == 0 {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+	}
+	return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyConflict}
+}
+
+// convertEvent converts an etcd event, setting the version of the entity as it's not stored in the value.
+func convertEvent(event *clientv3.Event) Event {
+	e := Event{
+		Key:     string(event.Kv.Key),
+		Version: uint64(event.Kv.ModRevision),
+	}
+	switch {
+	case event.Type == clientv3.EventTypeDelete:
+		e.Type = EventDelete
+		if event.PrevKv != nil {
+			e.Value = withVersion(event.PrevKv.Value, uint64(event.PrevKv.ModRevision))
+		}
+	case event.IsCreate():
+		e.Type = EventCreate
+		e.Value = withVersion(event.Kv.Value, e.Version)
+	default:
+		e.Type = EventUpdate
+		e.Value = withVersion(event.Kv.Value, e.Version)
+	}
+	return e
+}
@@ -0,0 +1,484 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/config"
+	"gopkg.in/yaml.v2"
+)
+
+// revisionFile stores the last revision used, so the revision never goes backwards after a restart, even when
+// the entities having the latest versions have been deleted. It's hidden so it's never taken for a resource.
+const revisionFile = ".revision"
+
+type fileDAO struct {
+	DAO
+	folder    string
+	extension string
+	isYAML    bool
+	// mutex prevents a resource from being read while it's written.
+	mutex sync.RWMutex
+	// revision is incremented at every change, it's used as the version of the entities.
+	// It's persisted in revisionFile before every change and restored from it at the start.
+	revision    uint64
+	broadcaster *broadcaster
+}
+
+// NewFileDAO returns a DAO storing one file per resource in a directory tree.
+// The key of a resource is its path relative to the folder, without the extension.
+// The files must not be modified by anything else while Perses is running, as the changes on disk are not watched.
+func NewFileDAO(conf config.FileConfig) (DAO, error) {
+	if err := os.MkdirAll(conf.Folder, 0755); err != nil {
+		return nil, fmt.Errorf("unable to create the folder %q: %w", conf.Folder, err)
+	}
+	d := &fileDAO{
+		folder:    conf.Folder,
+		extension: "." + string(conf.Extension),
+		isYAML:    conf.Extension == config.YAMLExtension,
+	}
+	revision, err := d.readRevision()
+	if err != nil {
+		return nil, err
+	}
+	d.revision = revision
+	// the versions of the entities are taken into account too, for the folders written before revisionFile existed
+	keys, err := d.keys("")
+	if err != nil {
+		return nil, err
+	}
+	for _, key := range keys {
+		data, err := d.read(key, d.keyPath(key))
+		if err != nil {
+			return nil, err
+		}
+		if version := storedVersion(data); version > d.revision {
+			d.revision = version
+		}
+	}
+	d.broadcaster = newBroadcaster(d.revision)
+	return d, nil
+}
+
+func (d *fileDAO) Close() error {
+	d.broadcaster.close()
+	return nil
+}
+
+func (d *fileDAO) Create(key string, entity interface{}) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, err := os.Stat(path); err == nil {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyConflict}
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	return d.write(key, path, entity)
+}
+
+func (d *fileDAO) Upsert(key string, entity interface{}, version uint64) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if err := d.checkVersion(key, path, version); err != nil {
+		return err
+	}
+	return d.write(key, path, entity)
+}
+
+func (d *fileDAO) Get(key string, entity interface{}) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	data, err := d.read(key, path)
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, entity)
+}
+
+func (d *fileDAO) Query(query etcd.Query, slice interface{}) error {
+	prefix, err := query.Build()
+	if err != nil {
+		return fmt.Errorf("unable to build the query: %s", err)
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	keys, err := d.keys(prefix)
+	if err != nil {
+		return err
+	}
+	values := make([]storedValue, 0, len(keys))
+	for _, key := range keys {
+		data, err := d.read(key, d.keyPath(key))
+		if err != nil {
+			return err
+		}
+		values = append(values, storedValue{key: key, data: data})
+	}
+	return decodeSlice(values, slice)
+}
+
+func (d *fileDAO) Delete(key string, version uint64) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	// the entity is read anyway to be sent to the watchers
+	previous, err := d.read(key, path)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(key, true, storedVersion(previous), version); err != nil {
+		return err
+	}
+	revision, err := d.nextRevision()
+	if err != nil {
+		return err
+	}
+	if err := d.remove(key, path); err != nil {
+		return err
+	}
+	d.revision = revision
+	d.broadcaster.notify(Event{Type: EventDelete, Key: key, Value: previous, Version: revision})
+	return nil
+}
+
+// DeleteWithPrefixes deletes the files while holding the lock, so nothing else can read or write in the meantime.
+// However, unlike with etcd, a failure can leave only a part of them deleted.
+func (d *fileDAO) DeleteWithPrefixes(key string, version uint64, prefixes []string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	previous, err := d.read(key, path)
+	if err != nil {
+		return err
+	}
+	if err := checkVersion(key, true, storedVersion(previous), version); err != nil {
+		return err
+	}
+	values := map[string][]byte{key: previous}
+	keys := []string{key}
+	for _, prefix := range prefixes {
+		prefixKeys, err := d.keys(prefix)
+		if err != nil {
+			return err
+		}
+		for _, k := range prefixKeys {
+			if _, ok := values[k]; ok {
+				// the key matches several prefixes
+				continue
+			}
+			if values[k], err = d.read(k, d.keyPath(k)); err != nil {
+				return err
+			}
+			keys = append(keys, k)
+		}
+	}
+	// like in etcd, all the deletions have the same version
+	revision, err := d.nextRevision()
+	if err != nil {
+		return err
+	}
+	d.revision = revision
+	for _, k := range keys {
+		if err := d.remove(k, d.keyPath(k)); err != nil {
+			return err
+		}
+		d.broadcaster.notify(Event{Type: EventDelete, Key: k, Value: values[k], Version: revision})
+	}
+	return nil
+}
+
+func (d *fileDAO) DeleteIfPrefixesEmpty(key string, version uint64, prefixes []string) error {
+	path, err := d.path(key)
+	if err != nil {
+		return err
+	}
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	previous, err := d.read(key, path)
+	if err != nil {
+		return err
+	}
+	for _, prefix := range prefixes {
+		keys, err := d.keys(prefix)
+		if err != nil {
+			return err
+		}
+		if len(keys) > 0 {
+			return ErrPrefixNotEmpty
+		}
+	}
+	if err := checkVersion(key, true, storedVersion(previous), version); err != nil {
+		return err
+	}
+	revision, err := d.nextRevision()
+	if err != nil {
+		return err
+	}
+	if err := d.remove(key, path); err != nil {
+		return err
+	}
+	d.revision = revision
+	d.broadcaster.notify(Event{Type: EventDelete, Key: key, Value: previous, Version: revision})
+	return nil
+}
+
+func (d *fileDAO) Keys(_ context.Context, prefix string) ([]string, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.keys(prefix)
+}
+
+func (d *fileDAO) Watch(ctx context.Context, query etcd.Query, fromVersion uint64) (WatchChan, error) {
+	prefix, err := query.Build()
+	if err != nil {
+		return nil, fmt.Errorf("unable to build the query: %s", err)
+	}
+	return d.broadcaster.watch(ctx, prefix, fromVersion)
+}
+
+func (d *fileDAO) HealthCheck() bool {
+	info, err := os.Stat(d.folder)
+	return err == nil && info.IsDir()
+}
+
+// path returns the file associated to the key. It fails if the key would lead outside of the folder.
+func (d *fileDAO) path(key string) (string, error) {
+	for _, part := range strings.Split(strings.TrimPrefix(key, "/"), "/") {
+		if len(part) == 0 || part == "." || part == ".." {
+			return "", fmt.Errorf("invalid key %q", key)
+		}
+	}
+	return d.keyPath(key), nil
+}
+
+func (d *fileDAO) keyPath(key string) string {
+	return filepath.Join(d.folder, filepath.FromSlash(key)) + d.extension
+}
+
+// keys returns the sorted keys starting with the prefix, like etcd does.
+func (d *fileDAO) keys(prefix string) ([]string, error) {
+	// only the deepest folder containing all the keys matching the prefix is walked through.
+	root := filepath.Join(d.folder, filepath.FromSlash(prefix[:strings.LastIndex(prefix, "/")+1]))
+	keys := []string{}
+	err := filepath.Walk(root, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		// the hidden files are the temporary files being written and revisionFile.
+		if info.IsDir() || strings.HasPrefix(info.Name(), ".") || filepath.Ext(path) != d.extension {
+			return nil
+		}
+		rel, err := filepath.Rel(d.folder, path)
+		if err != nil {
+			return err
+		}
+		key := "/" + filepath.ToSlash(strings.TrimSuffix(rel, d.extension))
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("unable to get the keys with the prefix %q: %w", prefix, err)
+	}
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// read returns the content of the file encoded in JSON.
+func (d *fileDAO) read(key string, path string) ([]byte, error) {
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+		}
+		return nil, err
+	}
+	if d.isYAML {
+		return yamlToJSON(data)
+	}
+	return data, nil
+}
+
+// remove deletes the file and the folders left empty. It must be called with the mutex locked.
+func (d *fileDAO) remove(key string, path string) error {
+	if err := os.Remove(path); err != nil {
+		if os.IsNotExist(err) {
+			return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+		}
+		return err
+	}
+	// the empty folders are removed, removing a folder that is not empty simply fails.
+	for dir := filepath.Dir(path); dir != filepath.Clean(d.folder); dir = filepath.Dir(dir) {
+		if os.Remove(dir) != nil {
+			break
+		}
+	}
+	return nil
+}
+
+func (d *fileDAO) checkVersion(key string, path string, expectedVersion uint64) error {
+	if expectedVersion == 0 {
+		return nil
+	}
+	data, err := d.read(key, path)
+	if err != nil {
+		return err
+	}
+	return checkVersion(key, true, storedVersion(data), expectedVersion)
+}
+
+// write encodes the entity in the file. It must be called with the mutex locked.
+func (d *fileDAO) write(key string, path string, entity interface{}) error {
+	revision, err := d.nextRevision()
+	if err != nil {
+		return err
+	}
+	setVersion(entity, revision)
+	value, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	data := value
+	if d.isYAML {
+		if data, err = jsonToYAML(value); err != nil {
+			return err
+		}
+	}
+	eventType := EventUpdate
+	if _, err := os.Stat(path); os.IsNotExist(err) {
+		eventType = EventCreate
+	}
+	if err := writeFile(path, data); err != nil {
+		return err
+	}
+	d.revision = revision
+	d.broadcaster.notify(Event{Type: eventType, Key: key, Value: value, Version: revision})
+	return nil
+}
+
+func (d *fileDAO) readRevision() (uint64, error) {
+	data, err := ioutil.ReadFile(filepath.Join(d.folder, revisionFile))
+	if err != nil {
+		if os.IsNotExist(err) {
+			return 0, nil
+		}
+		return 0, err
+	}
+	revision, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("unable to read the revision stored in %q: %w", revisionFile, err)
+	}
+	return revision, nil
+}
+
+// nextRevision persists the revision following the current one and returns it. It's persisted before the change is
+// done, so the revision stored is never behind the versions of the entities, even if Perses stops in the middle.
+// It must be called with the mutex locked.
+func (d *fileDAO) nextRevision() (uint64, error) {
+	revision := d.revision + 1
+	if err := writeFile(filepath.Join(d.folder, revisionFile), []byte(strconv.FormatUint(revision, 10))); err != nil {
+		return 0, fmt.Errorf("unable to store the revision: %w", err)
+	}
+	return revision, nil
+}
+
+// writeFile writes the data in a temporary file that is then renamed, so a partially written file is never read.
+func writeFile(path string, data []byte) error {
+	dir := filepath.Dir(path)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+	tmpFile, err := ioutil.TempFile(dir, fmt.Sprintf(".%s.*.tmp", filepath.Base(path)))
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpFile.Name()) // nolint: errcheck
+	if _, err := tmpFile.Write(data); err != nil {
+		tmpFile.Close() // nolint: errcheck
+		return err
+	}
+	if err := tmpFile.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile.Name(), path)
+}
+
+func jsonToYAML(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return yaml.Marshal(value)
+}
+
+func yamlToJSON(data []byte) ([]byte, error) {
+	var value interface{}
+	if err := yaml.Unmarshal(data, &value); err != nil {
+		return nil, err
+	}
+	return json.Marshal(withStringKeys(value))
+}
+
+// withStringKeys converts the maps decoded by the yaml package, so they can be encoded in JSON.
+func withStringKeys(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			result[fmt.Sprintf("%v", key)] = withStringKeys(item)
+		}
+		return result
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			result[i] = withStringKeys(item)
+		}
+		return result
+	default:
+		return v
+	}
+}
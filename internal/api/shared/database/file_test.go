@@ -0,0 +1,121 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/perses/perses/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func newFileDAO(t *testing.T, extension config.FileExtension) (DAO, string) {
+	folder := t.TempDir()
+	dao, err := NewFileDAO(config.FileConfig{Folder: folder, Extension: extension})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dao, folder
+}
+
+func TestFileDAO(t *testing.T) {
+	for _, extension := range []config.FileExtension{config.JSONExtension, config.YAMLExtension} {
+		t.Run(string(extension), func(t *testing.T) {
+			dao, folder := newFileDAO(t, extension)
+			testDAO(t, dao)
+			assert.FileExists(t, filepath.Join(folder, "dashboards", "perses", "node."+string(extension)))
+			// the folder of the project is removed with its last resource
+			_, err := os.Stat(filepath.Join(folder, "dashboards", "demo"))
+			assert.True(t, os.IsNotExist(err))
+		})
+	}
+}
+
+func TestFileDAOInvalidKey(t *testing.T) {
+	dao, folder := newFileDAO(t, config.JSONExtension)
+	assert.Error(t, dao.Upsert("/dashboards/perses/..", &entity{}, 0))
+	assert.Error(t, dao.Get("/dashboards//node", &entity{}))
+	files, err := ioutil.ReadDir(folder)
+	assert.NoError(t, err)
+	assert.Empty(t, files)
+}
+
+func TestFileDAOVersion(t *testing.T) {
+	dao, folder := newFileDAO(t, config.YAMLExtension)
+	testVersion(t, dao)
+	// the version continues from the one of the stored entities after a restart
+	node := newEntity("node", 1)
+	assert.NoError(t, dao.Create("/dashboards/perses/node", node))
+	restarted, err := NewFileDAO(config.FileConfig{Folder: folder, Extension: config.YAMLExtension})
+	assert.NoError(t, err)
+	update := newEntity("node", 2)
+	assert.NoError(t, restarted.Upsert("/dashboards/perses/node", update, node.Metadata.Version))
+	assert.Greater(t, update.Metadata.Version, node.Metadata.Version)
+}
+
+func TestFileDAOVersionAfterDelete(t *testing.T) {
+	dao, folder := newFileDAO(t, config.JSONExtension)
+	node := newEntity("node", 1)
+	assert.NoError(t, dao.Create("/dashboards/perses/node", node))
+	etcdEntity := newEntity("etcd", 1)
+	assert.NoError(t, dao.Create("/dashboards/perses/etcd", etcdEntity))
+	assert.NoError(t, dao.Delete("/dashboards/perses/etcd", 0))
+	// the entity having the latest version has been deleted, the version must still not go backwards after a restart
+	restarted, err := NewFileDAO(config.FileConfig{Folder: folder, Extension: config.JSONExtension})
+	assert.NoError(t, err)
+	recreated := newEntity("etcd", 2)
+	assert.NoError(t, restarted.Create("/dashboards/perses/etcd", recreated))
+	assert.Greater(t, recreated.Metadata.Version, etcdEntity.Metadata.Version+1)
+	// the file storing the revision is not taken for a resource
+	keys, err := restarted.Keys(context.Background(), "/")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"/dashboards/perses/etcd", "/dashboards/perses/node"}, keys)
+}
+
+func TestFileDAODeleteWithPrefixes(t *testing.T) {
+	dao, folder := newFileDAO(t, config.JSONExtension)
+	testDeleteWithPrefixes(t, dao)
+	// the folders left empty are removed
+	_, err := os.Stat(filepath.Join(folder, "revisions"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestFileDAODeleteIfPrefixesEmpty(t *testing.T) {
+	dao, _ := newFileDAO(t, config.JSONExtension)
+	testDeleteIfPrefixesEmpty(t, dao)
+}
+
+func TestFileDAOWatch(t *testing.T) {
+	dao, _ := newFileDAO(t, config.JSONExtension)
+	testWatch(t, dao)
+}
+
+func TestFileDAOResumeWatch(t *testing.T) {
+	dao, folder := newFileDAO(t, config.YAMLExtension)
+	testResumeWatch(t, dao)
+
+	// the changes done before a restart are unknown
+	node := newEntity("node", 0)
+	assert.NoError(t, dao.Upsert("/dashboards/perses/node", node, 0))
+	restarted, err := NewFileDAO(config.FileConfig{Folder: folder, Extension: config.YAMLExtension})
+	assert.NoError(t, err)
+	_, err = restarted.Watch(context.Background(), &prefixQuery{prefix: "/"}, node.Metadata.Version-1)
+	assert.Equal(t, ErrVersionCompacted, err)
+	_, err = restarted.Watch(context.Background(), &prefixQuery{prefix: "/"}, node.Metadata.Version)
+	assert.NoError(t, err)
+}
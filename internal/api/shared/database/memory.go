@@ -0,0 +1,210 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/perses/common/etcd"
+)
+
+type memoryDAO struct {
+	DAO
+	mutex sync.RWMutex
+	// values contains the entities encoded in JSON, so they can't be modified once stored.
+	values map[string][]byte
+	// revision is incremented at every change, it's used as the version of the entities.
+	revision    uint64
+	broadcaster *broadcaster
+}
+
+// NewMemoryDAO returns a DAO keeping the resources in memory. They are lost when Perses stops.
+// It's meant for the tests and the demos, as it doesn't need any external service.
+func NewMemoryDAO() DAO {
+	return &memoryDAO{
+		values:      make(map[string][]byte),
+		broadcaster: newBroadcaster(0),
+	}
+}
+
+func (d *memoryDAO) Close() error {
+	d.broadcaster.close()
+	return nil
+}
+
+func (d *memoryDAO) Create(key string, entity interface{}) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := d.values[key]; ok {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyConflict}
+	}
+	return d.write(key, entity)
+}
+
+func (d *memoryDAO) Upsert(key string, entity interface{}, version uint64) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if err := d.checkVersion(key, version); err != nil {
+		return err
+	}
+	return d.write(key, entity)
+}
+
+func (d *memoryDAO) Get(key string, entity interface{}) error {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	value, ok := d.values[key]
+	if !ok {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+	}
+	return json.Unmarshal(value, entity)
+}
+
+func (d *memoryDAO) Query(query etcd.Query, slice interface{}) error {
+	prefix, err := query.Build()
+	if err != nil {
+		return fmt.Errorf("unable to build the query: %s", err)
+	}
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	keys := d.keys(prefix)
+	values := make([]storedValue, 0, len(keys))
+	for _, key := range keys {
+		values = append(values, storedValue{key: key, data: d.values[key]})
+	}
+	return decodeSlice(values, slice)
+}
+
+func (d *memoryDAO) Delete(key string, version uint64) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := d.values[key]; !ok {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+	}
+	if err := d.checkVersion(key, version); err != nil {
+		return err
+	}
+	previous := d.values[key]
+	delete(d.values, key)
+	d.revision++
+	d.broadcaster.notify(Event{Type: EventDelete, Key: key, Value: previous, Version: d.revision})
+	return nil
+}
+
+func (d *memoryDAO) DeleteWithPrefixes(key string, version uint64, prefixes []string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	if _, ok := d.values[key]; !ok {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+	}
+	if err := d.checkVersion(key, version); err != nil {
+		return err
+	}
+	keys := []string{key}
+	for _, prefix := range prefixes {
+		keys = append(keys, d.keys(prefix)...)
+	}
+	// like in etcd, all the deletions done in the transaction have the same version
+	d.revision++
+	for _, k := range keys {
+		previous, ok := d.values[k]
+		if !ok {
+			// the key matches several prefixes
+			continue
+		}
+		delete(d.values, k)
+		d.broadcaster.notify(Event{Type: EventDelete, Key: k, Value: previous, Version: d.revision})
+	}
+	return nil
+}
+
+func (d *memoryDAO) DeleteIfPrefixesEmpty(key string, version uint64, prefixes []string) error {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+	previous, ok := d.values[key]
+	if !ok {
+		return &etcd.Error{Key: key, Code: etcd.ErrorCodeKeyNotFound}
+	}
+	for _, prefix := range prefixes {
+		if len(d.keys(prefix)) > 0 {
+			return ErrPrefixNotEmpty
+		}
+	}
+	if err := d.checkVersion(key, version); err != nil {
+		return err
+	}
+	delete(d.values, key)
+	d.revision++
+	d.broadcaster.notify(Event{Type: EventDelete, Key: key, Value: previous, Version: d.revision})
+	return nil
+}
+
+func (d *memoryDAO) Keys(_ context.Context, prefix string) ([]string, error) {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.keys(prefix), nil
+}
+
+func (d *memoryDAO) Watch(ctx context.Context, query etcd.Query, fromVersion uint64) (WatchChan, error) {
+	prefix, err := query.Build()
+	if err != nil {
+		return nil, fmt.Errorf("unable to build the query: %s", err)
+	}
+	return d.broadcaster.watch(ctx, prefix, fromVersion)
+}
+
+func (d *memoryDAO) HealthCheck() bool {
+	return true
+}
+
+// keys returns the sorted keys starting with the prefix, like etcd does.
+func (d *memoryDAO) keys(prefix string) []string {
+	keys := []string{}
+	for key := range d.values {
+		if strings.HasPrefix(key, prefix) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func (d *memoryDAO) checkVersion(key string, expectedVersion uint64) error {
+	value, ok := d.values[key]
+	return checkVersion(key, ok, storedVersion(value), expectedVersion)
+}
+
+// write must be called with the mutex locked.
+func (d *memoryDAO) write(key string, entity interface{}) error {
+	eventType := EventUpdate
+	if _, ok := d.values[key]; !ok {
+		eventType = EventCreate
+	}
+	// the revision is changed only once the entity is stored, so a failed write doesn't leave a gap
+	revision := d.revision + 1
+	setVersion(entity, revision)
+	value, err := json.Marshal(entity)
+	if err != nil {
+		return err
+	}
+	d.revision = revision
+	d.values[key] = value
+	d.broadcaster.notify(Event{Type: eventType, Key: key, Value: value, Version: revision})
+	return nil
+}
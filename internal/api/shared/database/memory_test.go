@@ -0,0 +1,55 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMemoryDAO(t *testing.T) {
+	testDAO(t, NewMemoryDAO())
+}
+
+func TestMemoryDAOVersion(t *testing.T) {
+	testVersion(t, NewMemoryDAO())
+}
+
+func TestMemoryDAOVersionAfterFailedWrite(t *testing.T) {
+	dao := NewMemoryDAO()
+	first := newEntity("node", 0)
+	assert.NoError(t, dao.Create("/dashboards/perses/node", first))
+	// a channel cannot be encoded in JSON
+	assert.Error(t, dao.Create("/dashboards/perses/cpu", map[string]interface{}{"value": make(chan int)}))
+	second := newEntity("etcd", 0)
+	assert.NoError(t, dao.Create("/dashboards/perses/etcd", second))
+	assert.Equal(t, first.Metadata.Version+1, second.Metadata.Version)
+}
+
+func TestMemoryDAODeleteWithPrefixes(t *testing.T) {
+	testDeleteWithPrefixes(t, NewMemoryDAO())
+}
+
+func TestMemoryDAODeleteIfPrefixesEmpty(t *testing.T) {
+	testDeleteIfPrefixesEmpty(t, NewMemoryDAO())
+}
+
+func TestMemoryDAOWatch(t *testing.T) {
+	testWatch(t, NewMemoryDAO())
+}
+
+func TestMemoryDAOResumeWatch(t *testing.T) {
+	testResumeWatch(t, NewMemoryDAO())
+}
@@ -0,0 +1,123 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package database
+
+import (
+	"context"
+	"strings"
+	"sync"
+)
+
+const (
+	// watchBufferSize is the number of responses a watcher can be late of before its channel is closed.
+	watchBufferSize = 100
+	// watchHistorySize is the number of changes kept to resume the watches from a previous version.
+	watchHistorySize = 1000
+)
+
+type watcher struct {
+	prefix    string
+	responses chan WatchResponse
+}
+
+// broadcaster sends the changes to the watchers, for the backends that are not able to watch the changes by themselves.
+// It works only because all the changes are done by the same instance of Perses.
+type broadcaster struct {
+	mutex    sync.Mutex
+	watchers map[*watcher]bool
+	// history contains the latest changes, so a watch can be resumed from a previous version.
+	history []Event
+	// compacted is the version of the latest change that is not in the history.
+	compacted uint64
+}
+
+// newBroadcaster returns a broadcaster for a backend at the given version. The previous changes are unknown.
+func newBroadcaster(version uint64) *broadcaster {
+	return &broadcaster{
+		watchers:  make(map[*watcher]bool),
+		compacted: version,
+	}
+}
+
+// watch returns the changes of the keys starting with the prefix, until the context is canceled.
+// When fromVersion isn't 0, the changes kept in the history after this version are sent first.
+func (b *broadcaster) watch(ctx context.Context, prefix string, fromVersion uint64) (WatchChan, error) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	var replayed []Event
+	if fromVersion != 0 {
+		if fromVersion < b.compacted {
+			return nil, ErrVersionCompacted
+		}
+		for _, event := range b.history {
+			if event.Version > fromVersion && strings.HasPrefix(event.Key, prefix) {
+				replayed = append(replayed, event)
+			}
+		}
+	}
+	w := &watcher{
+		prefix:    prefix,
+		responses: make(chan WatchResponse, watchBufferSize+len(replayed)),
+	}
+	for _, event := range replayed {
+		w.responses <- WatchResponse{Events: []Event{event}}
+	}
+	b.watchers[w] = true
+	go func() {
+		<-ctx.Done()
+		b.mutex.Lock()
+		defer b.mutex.Unlock()
+		b.remove(w)
+	}()
+	return w.responses, nil
+}
+
+// notify sends the event to the watchers interested in the key.
+// A watcher that doesn't consume its responses fast enough is closed, so it never blocks the writes.
+func (b *broadcaster) notify(event Event) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if len(b.history) == watchHistorySize {
+		b.compacted = b.history[0].Version
+		b.history = b.history[1:]
+	}
+	b.history = append(b.history, event)
+	for w := range b.watchers {
+		if !strings.HasPrefix(event.Key, w.prefix) {
+			continue
+		}
+		select {
+		case w.responses <- WatchResponse{Events: []Event{event}}:
+		default:
+			b.remove(w)
+		}
+	}
+}
+
+// close closes all the watchers.
+func (b *broadcaster) close() {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	for w := range b.watchers {
+		b.remove(w)
+	}
+}
+
+// remove must be called with the mutex locked.
+func (b *broadcaster) remove(w *watcher) {
+	if b.watchers[w] {
+		delete(b.watchers, w)
+		close(w.responses)
+	}
+}
@@ -14,63 +14,81 @@
 package dependency
 
 import (
-	"time"
-
-	"github.com/perses/common/config"
-	"github.com/perses/common/etcd"
+	alertmanagerconfigImpl "github.com/perses/perses/internal/api/impl/v1/alertmanagerconfig"
 	dashboardImpl "github.com/perses/perses/internal/api/impl/v1/dashboard"
 	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
 	projectImpl "github.com/perses/perses/internal/api/impl/v1/project"
 	prometheusruleImpl "github.com/perses/perses/internal/api/impl/v1/prometheusrule"
+	revisionImpl "github.com/perses/perses/internal/api/impl/v1/revision"
+	sloImpl "github.com/perses/perses/internal/api/impl/v1/slo"
 	userImpl "github.com/perses/perses/internal/api/impl/v1/user"
+	"github.com/perses/perses/internal/api/interface/v1/alertmanagerconfig"
 	"github.com/perses/perses/internal/api/interface/v1/dashboard"
 	"github.com/perses/perses/internal/api/interface/v1/datasource"
 	"github.com/perses/perses/internal/api/interface/v1/project"
 	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/revision"
+	"github.com/perses/perses/internal/api/interface/v1/slo"
 	"github.com/perses/perses/internal/api/interface/v1/user"
-	clientv3 "go.etcd.io/etcd/client/v3"
+	"github.com/perses/perses/internal/api/shared/database"
+	"github.com/perses/perses/internal/config"
 )
 
 type PersistenceManager interface {
+	GetAlertmanagerConfig() alertmanagerconfig.DAO
 	GetDashboard() dashboard.DAO
 	GetDatasource() datasource.DAO
 	GetProject() project.DAO
 	GetPrometheusRule() prometheusrule.DAO
+	GetRevision() revision.DAO
+	GetSLO() slo.DAO
 	GetUser() user.DAO
-	GetETCDClient() *clientv3.Client
+	GetDatabase() database.DAO
 }
 
 type persistence struct {
 	PersistenceManager
-	dashboard      dashboard.DAO
-	datasource     datasource.DAO
-	project        project.DAO
-	prometheusRule prometheusrule.DAO
-	user           user.DAO
-	etcdClient     *clientv3.Client
+	alertmanagerConfig alertmanagerconfig.DAO
+	dashboard          dashboard.DAO
+	datasource         datasource.DAO
+	project            project.DAO
+	prometheusRule     prometheusrule.DAO
+	revision           revision.DAO
+	slo                slo.DAO
+	user               user.DAO
+	database           database.DAO
 }
 
-func NewPersistenceManager(conf config.EtcdConfig) (PersistenceManager, error) {
-	timeout := time.Duration(conf.RequestTimeoutSeconds) * time.Second
-	etcdClient, err := etcd.NewETCDClient(conf)
+func NewPersistenceManager(conf config.Database) (PersistenceManager, error) {
+	persesDAO, err := database.New(conf)
 	if err != nil {
 		return nil, err
 	}
-	dashboardDAO := dashboardImpl.NewDAO(etcdClient, timeout)
-	datasourceDAO := datasourceImpl.NewDAO(etcdClient, timeout)
-	projectDAO := projectImpl.NewDAO(etcdClient, timeout)
-	prometheusRuleDAO := prometheusruleImpl.NewDAO(etcdClient, timeout)
-	userDAO := userImpl.NewDAO(etcdClient, timeout)
+	alertmanagerConfigDAO := alertmanagerconfigImpl.NewDAO(persesDAO)
+	dashboardDAO := dashboardImpl.NewDAO(persesDAO)
+	datasourceDAO := datasourceImpl.NewDAO(persesDAO)
+	projectDAO := projectImpl.NewDAO(persesDAO)
+	prometheusRuleDAO := prometheusruleImpl.NewDAO(persesDAO)
+	revisionDAO := revisionImpl.NewDAO(persesDAO)
+	sloDAO := sloImpl.NewDAO(persesDAO)
+	userDAO := userImpl.NewDAO(persesDAO)
 	return &persistence{
-		dashboard:      dashboardDAO,
-		datasource:     datasourceDAO,
-		project:        projectDAO,
-		prometheusRule: prometheusRuleDAO,
-		user:           userDAO,
-		etcdClient:     etcdClient,
+		alertmanagerConfig: alertmanagerConfigDAO,
+		dashboard:          dashboardDAO,
+		datasource:         datasourceDAO,
+		project:            projectDAO,
+		prometheusRule:     prometheusRuleDAO,
+		revision:           revisionDAO,
+		slo:                sloDAO,
+		user:               userDAO,
+		database:           persesDAO,
 	}, nil
 }
 
+func (p *persistence) GetAlertmanagerConfig() alertmanagerconfig.DAO {
+	return p.alertmanagerConfig
+}
+
 func (p *persistence) GetDashboard() dashboard.DAO {
 	return p.dashboard
 }
@@ -87,10 +105,18 @@ func (p *persistence) GetPrometheusRule() prometheusrule.DAO {
 	return p.prometheusRule
 }
 
+func (p *persistence) GetRevision() revision.DAO {
+	return p.revision
+}
+
+func (p *persistence) GetSLO() slo.DAO {
+	return p.slo
+}
+
 func (p *persistence) GetUser() user.DAO {
 	return p.user
 }
 
-func (p *persistence) GetETCDClient() *clientv3.Client {
-	return p.etcdClient
+func (p *persistence) GetDatabase() database.DAO {
+	return p.database
 }
@@ -16,56 +16,149 @@
 package dependency
 
 import (
+	alertmanagerImpl "github.com/perses/perses/internal/api/impl/v1/alertmanager"
+	alertmanagerconfigImpl "github.com/perses/perses/internal/api/impl/v1/alertmanagerconfig"
 	dashboardImpl "github.com/perses/perses/internal/api/impl/v1/dashboard"
 	dashboardFeedimpl "github.com/perses/perses/internal/api/impl/v1/dashboard_feed"
 	datasourceImpl "github.com/perses/perses/internal/api/impl/v1/datasource"
+	datasourceCheckImpl "github.com/perses/perses/internal/api/impl/v1/datasource_check"
+	datasourceDiscoveryImpl "github.com/perses/perses/internal/api/impl/v1/datasource_discovery"
+	datasourceProxyImpl "github.com/perses/perses/internal/api/impl/v1/datasource_proxy"
+	metricDependencyImpl "github.com/perses/perses/internal/api/impl/v1/metric_dependency"
 	projectImpl "github.com/perses/perses/internal/api/impl/v1/project"
 	prometheusruleImpl "github.com/perses/perses/internal/api/impl/v1/prometheusrule"
+	revisionImpl "github.com/perses/perses/internal/api/impl/v1/revision"
+	rulefileImpl "github.com/perses/perses/internal/api/impl/v1/rulefile"
+	rulefileSyncImpl "github.com/perses/perses/internal/api/impl/v1/rulefile_sync"
+	ruleimportImpl "github.com/perses/perses/internal/api/impl/v1/ruleimport"
+	rulepreviewImpl "github.com/perses/perses/internal/api/impl/v1/rulepreview"
+	ruletestImpl "github.com/perses/perses/internal/api/impl/v1/ruletest"
+	sloImpl "github.com/perses/perses/internal/api/impl/v1/slo"
 	userImpl "github.com/perses/perses/internal/api/impl/v1/user"
+	watchImpl "github.com/perses/perses/internal/api/impl/v1/watch"
+	"github.com/perses/perses/internal/api/interface/v1/alertmanager"
+	"github.com/perses/perses/internal/api/interface/v1/alertmanagerconfig"
 	"github.com/perses/perses/internal/api/interface/v1/dashboard"
 	"github.com/perses/perses/internal/api/interface/v1/dashboard_feed"
 	"github.com/perses/perses/internal/api/interface/v1/datasource"
+	"github.com/perses/perses/internal/api/interface/v1/datasource_check"
+	"github.com/perses/perses/internal/api/interface/v1/datasource_discovery"
+	"github.com/perses/perses/internal/api/interface/v1/datasource_proxy"
+	"github.com/perses/perses/internal/api/interface/v1/metric_dependency"
 	"github.com/perses/perses/internal/api/interface/v1/project"
 	"github.com/perses/perses/internal/api/interface/v1/prometheusrule"
+	"github.com/perses/perses/internal/api/interface/v1/revision"
+	"github.com/perses/perses/internal/api/interface/v1/rulefile"
+	"github.com/perses/perses/internal/api/interface/v1/rulefile_sync"
+	"github.com/perses/perses/internal/api/interface/v1/ruleimport"
+	"github.com/perses/perses/internal/api/interface/v1/rulepreview"
+	"github.com/perses/perses/internal/api/interface/v1/ruletest"
+	"github.com/perses/perses/internal/api/interface/v1/slo"
 	"github.com/perses/perses/internal/api/interface/v1/user"
+	"github.com/perses/perses/internal/api/interface/v1/watch"
+	"github.com/perses/perses/internal/config"
 )
 
 type ServiceManager interface {
+	GetAlertmanager() alertmanager.Service
+	GetAlertmanagerConfig() alertmanagerconfig.Service
 	GetDashboard() dashboard.Service
 	GetDashboardFeed() dashboard_feed.Service
 	GetDatasource() datasource.Service
+	GetDatasourceCheck() datasource_check.Service
+	GetDatasourceDiscovery() datasource_discovery.Service
+	GetDatasourceProxy() datasource_proxy.Service
+	GetMetricDependency() metric_dependency.Service
 	GetProject() project.Service
 	GetPrometheusRule() prometheusrule.Service
+	GetRevision() revision.Service
+	GetRuleFile() rulefile.Service
+	GetRuleFileSync() rulefile_sync.Service
+	GetRuleImport() ruleimport.Service
+	GetRulePreview() rulepreview.Service
+	GetRuleTest() ruletest.Service
+	GetSLO() slo.Service
 	GetUser() user.Service
+	GetWatch() watch.Service
 }
 
 type service struct {
 	ServiceManager
-	dashboard      dashboard.Service
-	dashboardFeed  dashboard_feed.Service
-	datasource     datasource.Service
-	project        project.Service
-	prometheusRule prometheusrule.Service
-	user           user.Service
-}
-
-func NewServiceManager(dao PersistenceManager) ServiceManager {
-	dashboardService := dashboardImpl.NewService(dao.GetDashboard())
-	datasourceService := datasourceImpl.NewService(dao.GetDatasource())
-	dashboardFeedService := dashboardFeedimpl.NewService(datasourceService)
+	alertmanager        alertmanager.Service
+	alertmanagerConfig  alertmanagerconfig.Service
+	dashboard           dashboard.Service
+	dashboardFeed       dashboard_feed.Service
+	datasource          datasource.Service
+	datasourceCheck     datasource_check.Service
+	datasourceDiscovery datasource_discovery.Service
+	datasourceProxy     datasource_proxy.Service
+	metricDependency    metric_dependency.Service
+	project             project.Service
+	prometheusRule      prometheusrule.Service
+	revision            revision.Service
+	ruleFile            rulefile.Service
+	ruleFileSync        rulefile_sync.Service
+	ruleImport          ruleimport.Service
+	rulePreview         rulepreview.Service
+	ruleTest            ruletest.Service
+	slo                 slo.Service
+	user                user.Service
+	watch               watch.Service
+}
+
+func NewServiceManager(dao PersistenceManager, conf config.Config) ServiceManager {
+	alertmanagerService := alertmanagerImpl.NewService(dao.GetAlertmanagerConfig())
+	alertmanagerConfigService := alertmanagerconfigImpl.NewService(dao.GetAlertmanagerConfig(), dao.GetProject())
+	dashboardService := dashboardImpl.NewService(dao.GetDashboard(), dao.GetProject(), dao.GetDatasource())
+	datasourceService := datasourceImpl.NewService(dao.GetDatasource(), dao.GetProject())
+	dashboardFeedService := dashboardFeedimpl.NewService(dao.GetDatasource())
+	datasourceCheckService := datasourceCheckImpl.NewService(dao.GetDatasource())
+	datasourceDiscoveryService := datasourceDiscoveryImpl.NewService(dao.GetDatasource())
+	datasourceProxyService := datasourceProxyImpl.NewService(dao.GetDatasource())
+	metricDependencyService := metricDependencyImpl.NewService(dao.GetDashboard(), dao.GetPrometheusRule())
 	projectService := projectImpl.NewService(dao.GetProject())
-	prometheusRuleService := prometheusruleImpl.NewService(dao.GetPrometheusRule())
+	prometheusRuleService := prometheusruleImpl.NewService(dao.GetPrometheusRule(), dao.GetProject(), metricDependencyService)
+	revisionService := revisionImpl.NewService(dao.GetRevision(), conf.History)
+	ruleFileService := rulefileImpl.NewService(dao.GetPrometheusRule())
+	ruleFileSyncService := rulefileSyncImpl.NewService(conf.RuleFileSync, dao.GetPrometheusRule())
+	ruleImportService := ruleimportImpl.NewService(prometheusRuleService)
+	rulePreviewService := rulepreviewImpl.NewService(dao.GetPrometheusRule(), dao.GetDatasource())
+	ruleTestService := ruletestImpl.NewService(dao.GetPrometheusRule())
+	sloService := sloImpl.NewService(dao.GetSLO(), dao.GetPrometheusRule(), prometheusRuleService, dao.GetDashboard(), dao.GetProject(), dao.GetDatasource(), revisionService)
 	userService := userImpl.NewService(dao.GetUser())
+	watchService := watchImpl.NewService(dao.GetDatabase())
 	return &service{
-		dashboard:      dashboardService,
-		dashboardFeed:  dashboardFeedService,
-		datasource:     datasourceService,
-		project:        projectService,
-		prometheusRule: prometheusRuleService,
-		user:           userService,
+		alertmanager:        alertmanagerService,
+		alertmanagerConfig:  alertmanagerConfigService,
+		dashboard:           dashboardService,
+		dashboardFeed:       dashboardFeedService,
+		datasource:          datasourceService,
+		datasourceCheck:     datasourceCheckService,
+		datasourceDiscovery: datasourceDiscoveryService,
+		datasourceProxy:     datasourceProxyService,
+		metricDependency:    metricDependencyService,
+		project:             projectService,
+		prometheusRule:      prometheusRuleService,
+		revision:            revisionService,
+		ruleFile:            ruleFileService,
+		ruleFileSync:        ruleFileSyncService,
+		ruleImport:          ruleImportService,
+		rulePreview:         rulePreviewService,
+		ruleTest:            ruleTestService,
+		slo:                 sloService,
+		user:                userService,
+		watch:               watchService,
 	}
 }
 
+func (s *service) GetAlertmanager() alertmanager.Service {
+	return s.alertmanager
+}
+
+func (s *service) GetAlertmanagerConfig() alertmanagerconfig.Service {
+	return s.alertmanagerConfig
+}
+
 func (s *service) GetDashboard() dashboard.Service {
 	return s.dashboard
 }
@@ -78,6 +171,22 @@ func (s *service) GetDatasource() datasource.Service {
 	return s.datasource
 }
 
+func (s *service) GetDatasourceCheck() datasource_check.Service {
+	return s.datasourceCheck
+}
+
+func (s *service) GetDatasourceDiscovery() datasource_discovery.Service {
+	return s.datasourceDiscovery
+}
+
+func (s *service) GetDatasourceProxy() datasource_proxy.Service {
+	return s.datasourceProxy
+}
+
+func (s *service) GetMetricDependency() metric_dependency.Service {
+	return s.metricDependency
+}
+
 func (s *service) GetProject() project.Service {
 	return s.project
 }
@@ -86,6 +195,38 @@ func (s *service) GetPrometheusRule() prometheusrule.Service {
 	return s.prometheusRule
 }
 
+func (s *service) GetRevision() revision.Service {
+	return s.revision
+}
+
+func (s *service) GetRuleFile() rulefile.Service {
+	return s.ruleFile
+}
+
+func (s *service) GetRuleFileSync() rulefile_sync.Service {
+	return s.ruleFileSync
+}
+
+func (s *service) GetRuleImport() ruleimport.Service {
+	return s.ruleImport
+}
+
+func (s *service) GetRulePreview() rulepreview.Service {
+	return s.rulePreview
+}
+
+func (s *service) GetRuleTest() ruletest.Service {
+	return s.ruleTest
+}
+
+func (s *service) GetSLO() slo.Service {
+	return s.slo
+}
+
 func (s *service) GetUser() user.Service {
 	return s.user
 }
+
+func (s *service) GetWatch() watch.Service {
+	return s.watch
+}
@@ -30,10 +30,18 @@ func (e *PersesError) Error() string {
 }
 
 var (
-	InternalError   = &PersesError{message: "internal server error"}
-	NotFoundError   = &PersesError{message: "document not found"}
-	ConflictError   = &PersesError{message: "document already exists"}
-	BadRequestError = &PersesError{message: "bad request"}
+	InternalError = &PersesError{message: "internal server error"}
+	NotFoundError = &PersesError{message: "document not found"}
+	ConflictError = &PersesError{message: "document already exists"}
+	// VersionConflictError is returned when a document has been modified since the version given by the client.
+	VersionConflictError = &PersesError{message: "document has been modified since it was read, its version doesn't match"}
+	BadRequestError      = &PersesError{message: "bad request"}
+	ForbiddenError       = &PersesError{message: "forbidden"}
+	BadGatewayError      = &PersesError{message: "bad gateway"}
+	// InUseError is returned when a document cannot be deleted because other documents depend on it.
+	InUseError = &PersesError{message: "document is still in use"}
+	// GoneError is returned when something requested is not available anymore, like an old version of the resources.
+	GoneError = &PersesError{message: "gone"}
 )
 
 // HandleError is translating the given error to the echoHTTPError
@@ -51,9 +59,24 @@ func HandleError(err error) error {
 	if errors.Is(err, ConflictError) {
 		return echo.NewHTTPError(http.StatusConflict, ConflictError.message)
 	}
+	if errors.Is(err, VersionConflictError) {
+		return echo.NewHTTPError(http.StatusConflict, VersionConflictError.message)
+	}
 	if errors.Is(err, BadRequestError) {
 		return echo.NewHTTPError(http.StatusBadRequest, err.Error())
 	}
+	if errors.Is(err, ForbiddenError) {
+		return echo.NewHTTPError(http.StatusForbidden, err.Error())
+	}
+	if errors.Is(err, BadGatewayError) {
+		return echo.NewHTTPError(http.StatusBadGateway, err.Error())
+	}
+	if errors.Is(err, InUseError) {
+		return echo.NewHTTPError(http.StatusConflict, err.Error())
+	}
+	if errors.Is(err, GoneError) {
+		return echo.NewHTTPError(http.StatusGone, err.Error())
+	}
 	logrus.WithError(err).Error("unexpected error not handle")
 	return echo.NewHTTPError(http.StatusInternalServerError, InternalError.message)
 }
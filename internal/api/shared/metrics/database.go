@@ -0,0 +1,112 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/shared/database"
+)
+
+const (
+	operationCreate = "create"
+	operationUpsert = "upsert"
+	operationGet    = "get"
+	operationQuery  = "query"
+	operationDelete = "delete"
+	operationWatch  = "watch"
+)
+
+// instrumentedDAO is wrapping a database.DAO to record the latency and the errors of each operation.
+type instrumentedDAO struct {
+	database.DAO
+	kind string
+}
+
+// NewInstrumentedDAO returns a database.DAO that records the latency and the errors of the operations performed by the dao.
+// kind is the kind of the resources managed by the dao. It is used to label the metrics.
+func NewInstrumentedDAO(dao database.DAO, kind string) database.DAO {
+	return &instrumentedDAO{
+		DAO:  dao,
+		kind: kind,
+	}
+}
+
+func (d *instrumentedDAO) Create(key string, entity interface{}) error {
+	start := time.Now()
+	err := d.DAO.Create(key, entity)
+	d.observe(operationCreate, start, err)
+	return err
+}
+
+func (d *instrumentedDAO) Upsert(key string, entity interface{}, version uint64) error {
+	start := time.Now()
+	err := d.DAO.Upsert(key, entity, version)
+	d.observe(operationUpsert, start, err)
+	return err
+}
+
+func (d *instrumentedDAO) Get(key string, entity interface{}) error {
+	start := time.Now()
+	err := d.DAO.Get(key, entity)
+	d.observe(operationGet, start, err)
+	return err
+}
+
+func (d *instrumentedDAO) Query(query etcd.Query, slice interface{}) error {
+	start := time.Now()
+	err := d.DAO.Query(query, slice)
+	d.observe(operationQuery, start, err)
+	return err
+}
+
+func (d *instrumentedDAO) Delete(key string, version uint64) error {
+	start := time.Now()
+	err := d.DAO.Delete(key, version)
+	d.observe(operationDelete, start, err)
+	return err
+}
+
+func (d *instrumentedDAO) DeleteWithPrefixes(key string, version uint64, prefixes []string) error {
+	start := time.Now()
+	err := d.DAO.DeleteWithPrefixes(key, version, prefixes)
+	d.observe(operationDelete, start, err)
+	return err
+}
+
+func (d *instrumentedDAO) DeleteIfPrefixesEmpty(key string, version uint64, prefixes []string) error {
+	start := time.Now()
+	err := d.DAO.DeleteIfPrefixesEmpty(key, version, prefixes)
+	d.observe(operationDelete, start, err)
+	return err
+}
+
+func (d *instrumentedDAO) Watch(ctx context.Context, query etcd.Query, fromVersion uint64) (database.WatchChan, error) {
+	start := time.Now()
+	watchChan, err := d.DAO.Watch(ctx, query, fromVersion)
+	d.observe(operationWatch, start, err)
+	return watchChan, err
+}
+
+// observe records the latency of the operation. The error is counted only if it's not an expected one like a key not found, a conflict,
+// or keys still starting with the prefixes of DeleteIfPrefixesEmpty.
+func (d *instrumentedDAO) observe(operation string, start time.Time, err error) {
+	databaseOperationDuration.WithLabelValues(d.kind, operation).Observe(time.Since(start).Seconds())
+	if err != nil && !etcd.IsKeyNotFound(err) && !etcd.IsKeyConflict(err) && !errors.Is(err, database.ErrPrefixNotEmpty) {
+		databaseOperationErrors.WithLabelValues(d.kind, operation).Inc()
+	}
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"strconv"
+	"time"
+
+	"github.com/labstack/echo/v4"
+)
+
+// HTTPMiddleware is an echo middleware recording the latency of every HTTP request, per route.
+// The route is the one registered in echo (like /api/v1/projects/:project/dashboards/:name) and not the actual path,
+// so the number of metrics doesn't depend on the number of resources.
+func HTTPMiddleware(next echo.HandlerFunc) echo.HandlerFunc {
+	return func(ctx echo.Context) error {
+		start := time.Now()
+		if err := next(ctx); err != nil {
+			// the error must be handled now so the status code of the response is known.
+			ctx.Error(err)
+		}
+		status := strconv.Itoa(ctx.Response().Status)
+		httpRequestDuration.WithLabelValues(ctx.Path(), ctx.Request().Method, status).Observe(time.Since(start).Seconds())
+		return nil
+	}
+}
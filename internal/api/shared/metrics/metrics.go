@@ -0,0 +1,93 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package metrics contains the Prometheus metrics exposed by Perses to monitor itself.
+// All of them are registered in the default registry and so they are served on /metrics.
+package metrics
+
+import (
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	namespace       = "perses"
+	labelCode       = "code"
+	labelDatasource = "datasource"
+	labelHandler    = "handler"
+	labelKind       = "kind"
+	labelMethod     = "method"
+	labelOperation  = "operation"
+	labelProject    = "project"
+)
+
+var (
+	httpRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of the HTTP requests received by the API, per route",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{labelHandler, labelMethod, labelCode})
+	feedQueryDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "feed",
+		Name:      "query_duration_seconds",
+		Help:      "Latency of the queries sent to the datasources to feed the dashboards",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{labelDatasource})
+	feedQueryErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "feed",
+		Name:      "query_errors_total",
+		Help:      "Total of the queries sent to the datasources to feed the dashboards that failed",
+	}, []string{labelDatasource})
+	databaseOperationDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "database",
+		Name:      "operation_duration_seconds",
+		Help:      "Latency of the operations performed on the database, per kind of resource",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{labelKind, labelOperation})
+	databaseOperationErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "database",
+		Name:      "operation_errors_total",
+		Help:      "Total of the operations performed on the database that failed unexpectedly, per kind of resource",
+	}, []string{labelKind, labelOperation})
+	resources = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Name:      "resources",
+		Help:      "Number of resources stored, per kind and per project. The project is empty for the resources that don't belong to a project",
+	}, []string{labelKind, labelProject})
+)
+
+func init() {
+	prometheus.MustRegister(
+		httpRequestDuration,
+		feedQueryDuration,
+		feedQueryErrors,
+		databaseOperationDuration,
+		databaseOperationErrors,
+		resources,
+	)
+}
+
+// ObserveFeedQuery records the latency of a query sent to the datasource to feed a dashboard and if it failed.
+func ObserveFeedQuery(datasource string, start time.Time, err error) {
+	feedQueryDuration.WithLabelValues(datasource).Observe(time.Since(start).Seconds())
+	if err != nil {
+		feedQueryErrors.WithLabelValues(datasource).Inc()
+	}
+}
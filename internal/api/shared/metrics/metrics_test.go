@@ -0,0 +1,127 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/labstack/echo/v4"
+	"github.com/perses/common/etcd"
+	"github.com/perses/perses/internal/api/shared/database"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCountPerProject(t *testing.T) {
+	testSuite := []struct {
+		title  string
+		prefix string
+		keys   []string
+		result map[string]float64
+	}{
+		{
+			title:  "no key",
+			prefix: "/dashboards/",
+			result: map[string]float64{},
+		},
+		{
+			title:  "resources without project",
+			prefix: "/projects/",
+			keys:   []string{"/projects/perses", "/projects/demo"},
+			result: map[string]float64{"": 2},
+		},
+		{
+			title:  "resources in projects",
+			prefix: "/dashboards/",
+			keys:   []string{"/dashboards/perses/node", "/dashboards/perses/etcd", "/dashboards/demo/node"},
+			result: map[string]float64{"perses": 2, "demo": 1},
+		},
+		{
+			title:  "global and project resources",
+			prefix: "/datasources/",
+			keys:   []string{"/datasources/prometheus", "/datasources/perses/prometheus"},
+			result: map[string]float64{"": 1, "perses": 1},
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			assert.Equal(t, test.result, countPerProject(test.prefix, test.keys))
+		})
+	}
+}
+
+type fakeDAO struct {
+	database.DAO
+	err error
+}
+
+func (d *fakeDAO) Get(_ string, _ interface{}) error {
+	return d.err
+}
+
+func TestInstrumentedDAOErrors(t *testing.T) {
+	testSuite := []struct {
+		title  string
+		kind   string
+		err    error
+		errors float64
+	}{
+		{
+			title:  "no error",
+			kind:   "NoError",
+			errors: 0,
+		},
+		{
+			title:  "key not found is not an error",
+			kind:   "KeyNotFound",
+			err:    &etcd.Error{Key: "/dashboards/perses/node", Code: etcd.ErrorCodeKeyNotFound},
+			errors: 0,
+		},
+		{
+			title:  "unexpected error",
+			kind:   "Unexpected",
+			err:    errors.New("context deadline exceeded"),
+			errors: 1,
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			dao := NewInstrumentedDAO(&fakeDAO{err: test.err}, test.kind)
+			assert.Equal(t, test.err, dao.Get("/dashboards/perses/node", nil))
+			assert.Equal(t, test.errors, testutil.ToFloat64(databaseOperationErrors.WithLabelValues(test.kind, operationGet)))
+			assert.Equal(t, 1, testutil.CollectAndCount(databaseOperationDuration.WithLabelValues(test.kind, operationGet).(prometheus.Histogram)))
+		})
+	}
+}
+
+func TestHTTPMiddleware(t *testing.T) {
+	e := echo.New()
+	e.Use(HTTPMiddleware)
+	e.GET("/api/v1/projects/:name", func(ctx echo.Context) error {
+		if ctx.Param("name") == "unknown" {
+			return echo.NewHTTPError(http.StatusNotFound, "project not found")
+		}
+		return ctx.NoContent(http.StatusOK)
+	})
+	for _, name := range []string{"perses", "demo", "unknown"} {
+		rec := httptest.NewRecorder()
+		e.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/api/v1/projects/"+name, nil))
+	}
+	assert.Equal(t, 2, testutil.CollectAndCount(httpRequestDuration))
+	assert.Equal(t, 1, testutil.CollectAndCount(httpRequestDuration.WithLabelValues("/api/v1/projects/:name", http.MethodGet, "404").(prometheus.Histogram)))
+}
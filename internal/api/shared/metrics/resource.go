@@ -0,0 +1,96 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package metrics
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/perses/common/async"
+	"github.com/perses/perses/internal/api/shared/database"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
+)
+
+const resourceCounterTimeout = 30 * time.Second
+
+// resourcePrefixes is the key prefix used by every kind of resource.
+var resourcePrefixes = map[v1.Kind]string{
+	v1.KindAlertmanagerConfig: "/alertmanagerconfigs/",
+	v1.KindDashboard:          "/dashboards/",
+	v1.KindDatasource:         "/datasources/",
+	v1.KindProject:            "/projects/",
+	v1.KindPrometheusRule:     "/prometheusrules/",
+	v1.KindSLO:                "/slos/",
+	v1.KindUser:               "/users/",
+}
+
+type resourceCounter struct {
+	async.SimpleTask
+	dao database.DAO
+}
+
+// NewResourceCounter returns a task counting the resources stored in the database, per kind and per project.
+// It's meant to be executed periodically to keep the gauge perses_resources up to date.
+func NewResourceCounter(dao database.DAO) async.SimpleTask {
+	return &resourceCounter{
+		dao: dao,
+	}
+}
+
+func (r *resourceCounter) String() string {
+	return "resource counter"
+}
+
+func (r *resourceCounter) Execute(ctx context.Context, _ context.CancelFunc) error {
+	counts := make(map[v1.Kind]map[string]float64, len(resourcePrefixes))
+	for kind, prefix := range resourcePrefixes {
+		keys, err := r.getKeys(ctx, prefix)
+		if err != nil {
+			// the counter will simply retry at the next execution.
+			logrus.WithError(err).Errorf("unable to count the resources of kind %s", kind)
+			return nil
+		}
+		counts[kind] = countPerProject(prefix, keys)
+	}
+	// Resetting the gauge removes the projects that don't exist anymore.
+	resources.Reset()
+	for kind, perProject := range counts {
+		for project, count := range perProject {
+			resources.WithLabelValues(string(kind), project).Set(count)
+		}
+	}
+	return nil
+}
+
+func (r *resourceCounter) getKeys(ctx context.Context, prefix string) ([]string, error) {
+	timeoutCtx, cancel := context.WithTimeout(ctx, resourceCounterTimeout)
+	defer cancel()
+	return r.dao.Keys(timeoutCtx, prefix)
+}
+
+// countPerProject counts the keys per project.
+// A key is either <prefix><name> for a resource without project, or <prefix><project>/<name>.
+func countPerProject(prefix string, keys []string) map[string]float64 {
+	result := make(map[string]float64)
+	for _, key := range keys {
+		project := ""
+		if i := strings.Index(strings.TrimPrefix(key, prefix), "/"); i >= 0 {
+			project = strings.TrimPrefix(key, prefix)[:i]
+		}
+		result[project]++
+	}
+	return result
+}
@@ -14,17 +14,34 @@
 package shared
 
 import (
+	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/labstack/echo/v4"
 	"github.com/perses/common/etcd"
 	"github.com/perses/perses/pkg/model/api"
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+	"github.com/sirupsen/logrus"
 )
 
+// watchKeepAliveInterval is the interval between two keep-alive messages sent by a watch when nothing changes.
+const watchKeepAliveInterval = 30 * time.Second
+
 type Parameters struct {
 	Project string
 	Name    string
+	// Version is the version of the resource expected by the client, given in the header If-Match.
+	// It's 0 when the client doesn't expect any particular version.
+	Version uint64
+	// Cascade is given by the query parameter cascade on a deletion, to delete as well the resources contained in the one deleted.
+	Cascade bool
 }
 
 func extractParameters(ctx echo.Context) Parameters {
@@ -34,6 +51,22 @@ func extractParameters(ctx echo.Context) Parameters {
 	}
 }
 
+// extractParametersWithVersion returns the parameters with the version given in the header If-Match.
+// The version can be quoted like an ETag.
+func extractParametersWithVersion(ctx echo.Context) (Parameters, error) {
+	parameters := extractParameters(ctx)
+	header := ctx.Request().Header.Get(headerIfMatch)
+	if len(header) == 0 {
+		return parameters, nil
+	}
+	version, err := strconv.ParseUint(strings.Trim(strings.TrimPrefix(header, "W/"), `"`), 10, 64)
+	if err != nil || version == 0 {
+		return parameters, fmt.Errorf("%w: invalid header %s '%s', it must be the version of the resource", BadRequestError, headerIfMatch, header)
+	}
+	parameters.Version = version
+	return parameters, nil
+}
+
 type ToolboxService interface {
 	Create(entity api.Entity) (interface{}, error)
 	Update(entity api.Entity, parameters Parameters) (interface{}, error)
@@ -42,6 +75,38 @@ type ToolboxService interface {
 	List(q etcd.Query, parameters Parameters) (interface{}, error)
 }
 
+// DeletionWarner can be implemented by a ToolboxService to warn the client about the consequences of a deletion.
+// The warnings are computed before the deletion and sent back in the header Warning of the response.
+type DeletionWarner interface {
+	DeletionWarnings(parameters Parameters) ([]string, error)
+}
+
+// WatchFilter can be implemented by a ToolboxService to apply to the resources sent by a watch the same filters
+// and transformations as the List. It returns false when the resource must not be sent.
+type WatchFilter interface {
+	FilterWatched(entity api.Entity, q etcd.Query, parameters Parameters) bool
+}
+
+// Watcher streams the changes of the resources.
+type Watcher interface {
+	// Watch returns the changes of the resources matching the query, until the context is canceled.
+	// When fromVersion isn't 0, the changes done after this version are sent first.
+	Watch(ctx context.Context, q etcd.Query, fromVersion uint64) (<-chan *v1.WatchEvent, error)
+}
+
+// History keeps the revisions of the resources modified through the Toolbox.
+type History interface {
+	// Record adds a revision to the history of the entity once it has been modified.
+	// restoredFrom is the number of the revision restored by the modification, 0 otherwise.
+	// The resource being already modified, a failure is only logged.
+	Record(entity api.Entity, operation v1.RevisionOperation, author string, restoredFrom uint64)
+	// ListRevisions returns the revisions of the resource identified by key, without the resource itself.
+	ListRevisions(key string) ([]*v1.Revision, error)
+	GetRevision(key string, number uint64) (*v1.Revision, error)
+	// DiffRevisions compares two revisions of the resource. When to is 0, the latest revision is used.
+	DiffRevisions(key string, from uint64, to uint64) (*v1.RevisionDiff, error)
+}
+
 // Toolbox is an interface that defines the different methods that can be used in the different endpoint of the API.
 // This is a way to align the code of the different endpoint.
 type Toolbox interface {
@@ -50,17 +115,30 @@ type Toolbox interface {
 	Delete(ctx echo.Context) error
 	Get(ctx echo.Context) error
 	List(ctx echo.Context, q etcd.Query) error
+	// Watch streams the changes of the resources matching the query as server-sent events.
+	// The entity given is empty, it's only used to decode the resources.
+	Watch(ctx echo.Context, q etcd.Query, entity api.Entity) error
+	// The following methods are about the history of a resource. The entity given is empty,
+	// it's only used to know the kind of the resource.
+	ListRevisions(ctx echo.Context, entity api.Entity) error
+	GetRevision(ctx echo.Context, entity api.Entity) error
+	DiffRevisions(ctx echo.Context, entity api.Entity) error
+	RestoreRevision(ctx echo.Context, entity api.Entity) error
 }
 
-func NewToolBox(service ToolboxService) Toolbox {
+func NewToolBox(service ToolboxService, history History, watcher Watcher) Toolbox {
 	return &toolbox{
 		service: service,
+		history: history,
+		watcher: watcher,
 	}
 }
 
 type toolbox struct {
 	Toolbox
 	service ToolboxService
+	history History
+	watcher Watcher
 }
 
 func (t *toolbox) Create(ctx echo.Context, entity api.Entity) error {
@@ -71,6 +149,8 @@ func (t *toolbox) Create(ctx echo.Context, entity api.Entity) error {
 	if err != nil {
 		return HandleError(err)
 	}
+	t.record(ctx, newEntity, v1.RevisionCreated, 0)
+	setETag(ctx, newEntity)
 	return ctx.JSON(http.StatusOK, newEntity)
 }
 
@@ -78,19 +158,53 @@ func (t *toolbox) Update(ctx echo.Context, entity api.Entity) error {
 	if err := t.bind(ctx, entity); err != nil {
 		return err
 	}
-	parameters := extractParameters(ctx)
+	parameters, err := extractParametersWithVersion(ctx)
+	if err != nil {
+		return HandleError(err)
+	}
+	// the expected version is given to the service in the entity, like when it comes from the body.
+	if versioned := api.GetVersioned(entity); versioned != nil && parameters.Version != 0 {
+		if versioned.GetVersion() != 0 && versioned.GetVersion() != parameters.Version {
+			return HandleError(fmt.Errorf("%w: metadata.version and the header %s don't match", BadRequestError, headerIfMatch))
+		}
+		versioned.SetVersion(parameters.Version)
+	}
 	newEntity, err := t.service.Update(entity, parameters)
 	if err != nil {
 		return HandleError(err)
 	}
+	t.record(ctx, newEntity, v1.RevisionUpdated, 0)
+	setETag(ctx, newEntity)
 	return ctx.JSON(http.StatusOK, newEntity)
 }
 
 func (t *toolbox) Delete(ctx echo.Context) error {
-	parameters := extractParameters(ctx)
+	parameters, err := extractParametersWithVersion(ctx)
+	if err != nil {
+		return HandleError(err)
+	}
+	if cascade := ctx.QueryParam(ParamCascade); len(cascade) > 0 {
+		if parameters.Cascade, err = strconv.ParseBool(cascade); err != nil {
+			return HandleError(fmt.Errorf("%w: invalid query parameter %s '%s'", BadRequestError, ParamCascade, cascade))
+		}
+	}
+	var warnings []string
+	if warner, ok := t.service.(DeletionWarner); ok {
+		var err error
+		if warnings, err = warner.DeletionWarnings(parameters); err != nil {
+			return HandleError(err)
+		}
+	}
+	// the resource is kept to record its deletion, a failure is reported by the deletion itself
+	previous, _ := t.service.Get(parameters)
 	if err := t.service.Delete(parameters); err != nil {
 		return HandleError(err)
 	}
+	t.record(ctx, previous, v1.RevisionDeleted, 0)
+	for _, warning := range warnings {
+		// 299 is the code of a persistent warning, see https://tools.ietf.org/html/rfc7234#section-5.5
+		ctx.Response().Header().Add("Warning", fmt.Sprintf("299 - %q", warning))
+	}
 	return ctx.NoContent(http.StatusNoContent)
 }
 
@@ -100,6 +214,7 @@ func (t *toolbox) Get(ctx echo.Context) error {
 	if err != nil {
 		return HandleError(err)
 	}
+	setETag(ctx, entity)
 	return ctx.JSON(http.StatusOK, entity)
 }
 
@@ -115,12 +230,191 @@ func (t *toolbox) List(ctx echo.Context, q etcd.Query) error {
 	return ctx.JSON(http.StatusOK, result)
 }
 
+// Watch sends every change as a server-sent event whose id is the version of the resources once changed.
+// The watch is resumed from the version given in the query parameter version or in the header Last-Event-ID,
+// so to not miss any change, a client can start to watch from the highest version of the resources it listed.
+func (t *toolbox) Watch(ctx echo.Context, q etcd.Query, entity api.Entity) error {
+	if err := ctx.Bind(q); err != nil {
+		return HandleError(fmt.Errorf("%w: %s", BadRequestError, err))
+	}
+	parameters := extractParameters(ctx)
+	fromVersion, err := getWatchVersion(ctx)
+	if err != nil {
+		return HandleError(err)
+	}
+	events, err := t.watcher.Watch(ctx.Request().Context(), q, fromVersion)
+	if err != nil {
+		return HandleError(err)
+	}
+	filter, _ := t.service.(WatchFilter)
+	response := ctx.Response()
+	response.Header().Set(echo.HeaderContentType, "text/event-stream")
+	response.Header().Set("Cache-Control", "no-cache")
+	response.WriteHeader(http.StatusOK)
+	response.Flush()
+	keepAlive := time.NewTicker(watchKeepAliveInterval)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case <-keepAlive.C:
+			// a comment is sent regularly, so the proxies don't close the connection
+			if _, err := fmt.Fprint(response, ": keep-alive\n\n"); err != nil {
+				return nil
+			}
+		case event, ok := <-events:
+			if !ok {
+				return nil
+			}
+			if filter != nil && len(event.Resource) > 0 && !filterWatched(filter, event, entity, q, parameters) {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				logrus.WithError(err).Error("unable to encode the watch event")
+				continue
+			}
+			if err := writeEvent(response, event, data); err != nil {
+				// the client is gone
+				return nil
+			}
+		}
+		response.Flush()
+	}
+}
+
+// writeEvent writes a server-sent event. The id is omitted when there is no version, like for an error,
+// so the client doesn't lose the last version it received.
+func writeEvent(response *echo.Response, event *v1.WatchEvent, data []byte) error {
+	if event.Version != 0 {
+		if _, err := fmt.Fprintf(response, "id: %d\n", event.Version); err != nil {
+			return err
+		}
+	}
+	_, err := fmt.Fprintf(response, "event: %s\ndata: %s\n\n", event.Type, data)
+	return err
+}
+
+// filterWatched decodes the resource of the event to give it to the filter, and then replaces it by the one filtered.
+func filterWatched(filter WatchFilter, event *v1.WatchEvent, entity api.Entity, q etcd.Query, parameters Parameters) bool {
+	decoded := reflect.New(reflect.TypeOf(entity).Elem()).Interface().(api.Entity)
+	if err := json.Unmarshal(event.Resource, decoded); err != nil {
+		logrus.WithError(err).Errorf("unable to decode the resource watched, version %d", event.Version)
+		return false
+	}
+	if !filter.FilterWatched(decoded, q, parameters) {
+		return false
+	}
+	data, err := json.Marshal(decoded)
+	if err != nil {
+		logrus.WithError(err).Errorf("unable to encode the resource watched, version %d", event.Version)
+		return false
+	}
+	event.Resource = data
+	return true
+}
+
+func (t *toolbox) ListRevisions(ctx echo.Context, entity api.Entity) error {
+	revisions, err := t.history.ListRevisions(resourceKey(entity, extractParameters(ctx)))
+	if err != nil {
+		return HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, revisions)
+}
+
+func (t *toolbox) GetRevision(ctx echo.Context, entity api.Entity) error {
+	number, err := getRevisionParameter(ctx)
+	if err != nil {
+		return HandleError(err)
+	}
+	revision, err := t.history.GetRevision(resourceKey(entity, extractParameters(ctx)), number)
+	if err != nil {
+		return HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, revision)
+}
+
+func (t *toolbox) DiffRevisions(ctx echo.Context, entity api.Entity) error {
+	from, err := parseRevision(ctx.QueryParam("from"))
+	if err != nil {
+		return HandleError(err)
+	}
+	if from == 0 {
+		return HandleError(fmt.Errorf("%w: the query parameter 'from' is required", BadRequestError))
+	}
+	var to uint64
+	if len(ctx.QueryParam("to")) > 0 {
+		if to, err = parseRevision(ctx.QueryParam("to")); err != nil {
+			return HandleError(err)
+		}
+	}
+	diff, err := t.history.DiffRevisions(resourceKey(entity, extractParameters(ctx)), from, to)
+	if err != nil {
+		return HandleError(err)
+	}
+	return ctx.JSON(http.StatusOK, diff)
+}
+
+// RestoreRevision replaces the resource by the one of a revision. A deleted resource is created again.
+// Like for an update, the header If-Match can be used to make sure the resource hasn't been modified in the meantime.
+func (t *toolbox) RestoreRevision(ctx echo.Context, entity api.Entity) error {
+	parameters, err := extractParametersWithVersion(ctx)
+	if err != nil {
+		return HandleError(err)
+	}
+	number, err := getRevisionParameter(ctx)
+	if err != nil {
+		return HandleError(err)
+	}
+	revision, err := t.history.GetRevision(resourceKey(entity, parameters), number)
+	if err != nil {
+		return HandleError(err)
+	}
+	if len(revision.Resource) == 0 {
+		return HandleError(fmt.Errorf("%w: the revision %d is a deletion, there is nothing to restore", BadRequestError, number))
+	}
+	if err := json.Unmarshal(revision.Resource, entity); err != nil {
+		return HandleError(fmt.Errorf("%w: the revision %d cannot be restored: %s", BadRequestError, number, err))
+	}
+	if versioned := api.GetVersioned(entity); versioned != nil {
+		versioned.SetVersion(parameters.Version)
+	}
+	operation := v1.RevisionUpdated
+	newEntity, err := t.service.Update(entity, parameters)
+	if errors.Is(err, NotFoundError) && parameters.Version == 0 {
+		operation = v1.RevisionCreated
+		newEntity, err = t.service.Create(entity)
+	}
+	if err != nil {
+		return HandleError(err)
+	}
+	t.record(ctx, newEntity, operation, number)
+	setETag(ctx, newEntity)
+	return ctx.JSON(http.StatusOK, newEntity)
+}
+
+// record adds the entity returned by the service to the history.
+func (t *toolbox) record(ctx echo.Context, entity interface{}, operation v1.RevisionOperation, restoredFrom uint64) {
+	if e, ok := entity.(api.Entity); ok {
+		t.history.Record(e, operation, ctx.Request().Header.Get(headerForwardedUser), restoredFrom)
+	}
+}
+
 func (t *toolbox) bind(ctx echo.Context, entity api.Entity) error {
 	if err := ctx.Bind(entity); err != nil {
 		return HandleError(fmt.Errorf("%w: %s", BadRequestError, err))
 	}
+	if err := setProjectFromPath(entity.GetMetadata(), getProjectParameter(ctx)); err != nil {
+		return HandleError(fmt.Errorf("%w: %s", BadRequestError, err))
+	}
 	if err := validateMetadata(entity.GetMetadata()); err != nil {
 		return HandleError(fmt.Errorf("%w: %s", BadRequestError, err))
 	}
 	return nil
 }
+
+// setETag sets the header ETag with the version of the entity, so it can be given back in the header If-Match.
+func setETag(ctx echo.Context, entity interface{}) {
+	if versioned := api.GetVersioned(entity); versioned != nil && versioned.GetVersion() != 0 {
+		ctx.Response().Header().Set(headerETag, strconv.Quote(strconv.FormatUint(versioned.GetVersion(), 10)))
+	}
+}
@@ -15,20 +15,54 @@ package shared
 
 import (
 	"fmt"
+	"math"
+	"strconv"
+	"time"
 
 	"github.com/labstack/echo/v4"
+	"github.com/perses/perses/pkg/model/api"
 	v1 "github.com/perses/perses/pkg/model/api/v1"
 )
 
 const (
-	ParamName          = "name"
-	ParamProject       = "project"
-	APIV1Prefix        = "/api/v1"
-	PathDashboard      = "dashboards"
-	PathDatasource     = "datasources"
-	PathProject        = "projects"
-	PathPrometheusRule = "prometheusrules"
-	PathUser           = "users"
+	ParamName              = "name"
+	ParamProject           = "project"
+	ParamLabel             = "label"
+	ParamRevision          = "revision"
+	ParamWatch             = "watch"
+	ParamVersion           = "version"
+	ParamCascade           = "cascade"
+	APIV1Prefix            = "/api/v1"
+	PathAlertmanagerConfig = "alertmanagerconfigs"
+	PathDashboard          = "dashboards"
+	PathDatasource         = "datasources"
+	PathProject            = "projects"
+	PathPrometheusRule     = "prometheusrules"
+	PathSLO                = "slos"
+	PathUser               = "users"
+	PathProxy              = "proxy"
+	PathCheck              = "check"
+	PathMetric             = "metrics"
+	PathMetadata           = "metadata"
+	PathLabel              = "labels"
+	PathValue              = "values"
+	PathRuleFile           = "rulefile"
+	PathSync               = "sync"
+	PathTest               = "test"
+	PathPreview            = "preview"
+	PathImport             = "import"
+	PathUsage              = "usage"
+	PathDependency         = "dependencies"
+	PathAlertmanager       = "alertmanager"
+	PathConfig             = "config"
+	PathRevision           = "revisions"
+	PathDiff               = "diff"
+	PathRestore            = "restore"
+	headerETag             = "ETag"
+	headerIfMatch          = "If-Match"
+	headerLastEventID      = "Last-Event-ID"
+	// headerForwardedUser is set by the authenticating proxy in front of the API with the name of the user.
+	headerForwardedUser = "X-Forwarded-User"
 )
 
 func getNameParameter(ctx echo.Context) string {
@@ -39,6 +73,87 @@ func getProjectParameter(ctx echo.Context) string {
 	return ctx.Param(ParamProject)
 }
 
+// IsWatchRequested returns true when the client asks to watch the resources instead of listing them.
+func IsWatchRequested(ctx echo.Context) bool {
+	watch, _ := strconv.ParseBool(ctx.QueryParam(ParamWatch))
+	return watch
+}
+
+// getWatchVersion returns the version from which a watch is resumed, given by the query parameter or,
+// when the client reconnects by itself, by the header Last-Event-ID.
+func getWatchVersion(ctx echo.Context) (uint64, error) {
+	s := ctx.QueryParam(ParamVersion)
+	if len(s) == 0 {
+		s = ctx.Request().Header.Get(headerLastEventID)
+	}
+	if len(s) == 0 {
+		return 0, nil
+	}
+	version, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: '%s' is not a valid version", BadRequestError, s)
+	}
+	return version, nil
+}
+
+// getRevisionParameter returns the number of the revision given in the path.
+func getRevisionParameter(ctx echo.Context) (uint64, error) {
+	number, err := parseRevision(ctx.Param(ParamRevision))
+	if err == nil && number == 0 {
+		err = fmt.Errorf("%w: the revision number must be greater than 0", BadRequestError)
+	}
+	return number, err
+}
+
+func parseRevision(s string) (uint64, error) {
+	if len(s) == 0 {
+		return 0, nil
+	}
+	number, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: '%s' is not a valid revision number", BadRequestError, s)
+	}
+	return number, nil
+}
+
+// resourceKey fills the metadata of an empty entity with the parameters of the request and returns its key.
+func resourceKey(entity api.Entity, parameters Parameters) string {
+	switch met := entity.GetMetadata().(type) {
+	case *v1.ProjectMetadata:
+		met.Project = parameters.Project
+		met.Name = parameters.Name
+	case *v1.OptionalProjectMetadata:
+		met.Project = parameters.Project
+		met.Name = parameters.Name
+	case *v1.Metadata:
+		met.Name = parameters.Name
+	}
+	return entity.GenerateID()
+}
+
+// setProjectFromPath fills the project of the metadata with the one given in the path of the request.
+// A project given in both places must be the same, so a resource is never written in another project than the one requested.
+func setProjectFromPath(metadata interface{}, project string) error {
+	if len(project) == 0 {
+		return nil
+	}
+	var metadataProject *string
+	switch met := metadata.(type) {
+	case *v1.ProjectMetadata:
+		metadataProject = &met.Project
+	case *v1.OptionalProjectMetadata:
+		metadataProject = &met.Project
+	default:
+		return nil
+	}
+	if len(*metadataProject) == 0 {
+		*metadataProject = project
+	} else if *metadataProject != project {
+		return fmt.Errorf("metadata.project and the project name in the http path request doesn't match")
+	}
+	return nil
+}
+
 func validateMetadata(metadata interface{}) error {
 	switch met := metadata.(type) {
 	case *v1.ProjectMetadata:
@@ -48,6 +163,10 @@ func validateMetadata(metadata interface{}) error {
 		if len(met.Name) == 0 {
 			return fmt.Errorf("metadata.name cannot be empty")
 		}
+	case *v1.OptionalProjectMetadata:
+		if len(met.Name) == 0 {
+			return fmt.Errorf("metadata.name cannot be empty")
+		}
 	case *v1.Metadata:
 		if len(met.Name) == 0 {
 			return fmt.Errorf("metadata.name cannot be empty")
@@ -55,3 +174,28 @@ func validateMetadata(metadata interface{}) error {
 	}
 	return nil
 }
+
+// ParseTime parses a time coming from a query parameter. It is either a RFC3339 date or an unix timestamp in seconds.
+// A zero time is returned when the string is empty.
+func ParseTime(s string) (time.Time, error) {
+	if len(s) == 0 {
+		return time.Time{}, nil
+	}
+	if t, err := strconv.ParseFloat(s, 64); err == nil {
+		seconds, fraction := math.Modf(t)
+		return time.Unix(int64(seconds), int64(fraction*float64(time.Second))).UTC(), nil
+	}
+	if t, err := time.Parse(time.RFC3339Nano, s); err == nil {
+		return t, nil
+	}
+	return time.Time{}, fmt.Errorf("cannot parse '%s' to a valid timestamp", s)
+}
+
+// CheckNotManaged rejects the modification of a resource generated by the server from another resource.
+// Such a resource can only be modified through the resource that manages it.
+func CheckNotManaged(metadata v1.ProjectMetadata) error {
+	if len(metadata.ManagedBy) > 0 {
+		return fmt.Errorf("%w: '%s' is managed by %s and can only be modified through it", BadRequestError, metadata.Name, metadata.ManagedBy)
+	}
+	return nil
+}
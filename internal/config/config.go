@@ -13,10 +13,31 @@
 
 package config
 
-import "github.com/perses/common/config"
+import (
+	"fmt"
+
+	"github.com/perses/common/config"
+)
 
 type Config struct {
-	Etcd *config.EtcdConfig `yaml:"etcd"`
+	// Etcd is deprecated, use Database.Etcd instead. It's still accepted when Database is not set.
+	Etcd *config.EtcdConfig `yaml:"etcd,omitempty"`
+	// Database defines where the resources are stored: etcd, or files on disk.
+	Database Database `yaml:"database"`
+	// RuleFileSync is optional. When it's set, the PrometheusRules are written on disk.
+	RuleFileSync *RuleFileSyncConfig `yaml:"rulefile_sync,omitempty"`
+	// History defines how many revisions of the resources are kept.
+	History HistoryConfig `yaml:"history,omitempty"`
+}
+
+func (c *Config) Verify() error {
+	if c.Etcd != nil {
+		if c.Database != (Database{}) {
+			return fmt.Errorf("etcd and database cannot be both set, etcd is deprecated and must be moved in database")
+		}
+		c.Database.Etcd = c.Etcd
+	}
+	return nil
 }
 
 func Resolve(configFile string) (Config, error) {
@@ -0,0 +1,67 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func writeConfig(t *testing.T, content string) string {
+	folder, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.RemoveAll(folder) }) // nolint: errcheck
+	file := filepath.Join(folder, "config.yaml")
+	if err := ioutil.WriteFile(file, []byte(content), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return file
+}
+
+func TestResolveDeprecatedEtcd(t *testing.T) {
+	// the layout used before the database could be chosen
+	c, err := Resolve(writeConfig(t, `
+etcd:
+  protocol: "http"
+  request_timeout: 10
+  connections:
+    - host: localhost
+      port: 2379
+`))
+	assert.NoError(t, err)
+	if assert.NotNil(t, c.Database.Etcd) {
+		assert.Equal(t, "localhost", c.Database.Etcd.Connections[0].Host)
+	}
+	assert.Nil(t, c.Database.File)
+}
+
+func TestResolveDeprecatedEtcdWithDatabase(t *testing.T) {
+	_, err := Resolve(writeConfig(t, `
+etcd:
+  protocol: "http"
+  request_timeout: 10
+  connections:
+    - host: localhost
+      port: 2379
+database:
+  in_memory: true
+`))
+	assert.Error(t, err)
+}
@@ -0,0 +1,74 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+
+	"github.com/perses/common/config"
+)
+
+type FileExtension string
+
+const (
+	JSONExtension FileExtension = "json"
+	YAMLExtension FileExtension = "yaml"
+)
+
+// FileConfig defines how the resources are stored on disk, so Perses can run without any other dependency.
+// It's suitable only for a single instance of Perses, as the files are not shared between several instances.
+type FileConfig struct {
+	// Folder is the directory where the resources are stored.
+	// There is one file per resource: <folder>/<plural kind>/<project>/<name>.<extension>.
+	Folder string `yaml:"folder"`
+	// Extension is the format of the files, json (by default) or yaml.
+	Extension FileExtension `yaml:"extension,omitempty"`
+}
+
+func (f *FileConfig) Verify() error {
+	if len(f.Folder) == 0 {
+		return fmt.Errorf("the folder where the resources are stored must be specified")
+	}
+	if len(f.Extension) == 0 {
+		f.Extension = JSONExtension
+	}
+	if f.Extension != JSONExtension && f.Extension != YAMLExtension {
+		return fmt.Errorf("invalid extension %q, it must be %q or %q", f.Extension, JSONExtension, YAMLExtension)
+	}
+	return nil
+}
+
+// Database defines where the resources are stored. Exactly one backend must be set.
+type Database struct {
+	Etcd *config.EtcdConfig `yaml:"etcd,omitempty"`
+	File *FileConfig        `yaml:"file,omitempty"`
+	// InMemory keeps the resources in memory, they are lost when Perses stops. It's meant for the demos.
+	InMemory bool `yaml:"in_memory,omitempty"`
+}
+
+func (d *Database) Verify() error {
+	count := 0
+	for _, isSet := range []bool{d.Etcd != nil, d.File != nil, d.InMemory} {
+		if isSet {
+			count++
+		}
+	}
+	if count == 0 {
+		return fmt.Errorf("a database must be configured: etcd, file or in_memory")
+	}
+	if count > 1 {
+		return fmt.Errorf("only one database can be configured: etcd, file or in_memory")
+	}
+	return nil
+}
@@ -0,0 +1,51 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+
+	v1 "github.com/perses/perses/pkg/model/api/v1"
+)
+
+const DefaultRevisionRetention = 10
+
+// HistoryConfig defines how many revisions of the resources are kept.
+type HistoryConfig struct {
+	// DefaultRetention is the number of revisions kept per resource, for the kinds not set in Retention.
+	// When it's 0, DefaultRevisionRetention is used.
+	DefaultRetention uint64 `yaml:"default_retention,omitempty"`
+	// Retention is the number of revisions kept per resource, per kind. 0 disables the history of a kind.
+	Retention map[v1.Kind]uint64 `yaml:"retention,omitempty"`
+}
+
+func (h *HistoryConfig) Verify() error {
+	for kind := range h.Retention {
+		if _, ok := v1.KindMap[kind]; !ok {
+			return fmt.Errorf("unknown kind '%s' used in the retention of the history", kind)
+		}
+	}
+	return nil
+}
+
+// GetRetention returns the number of revisions kept per resource of the kind.
+func (h HistoryConfig) GetRetention(kind v1.Kind) uint64 {
+	if retention, ok := h.Retention[kind]; ok {
+		return retention
+	}
+	if h.DefaultRetention == 0 {
+		return DefaultRevisionRetention
+	}
+	return h.DefaultRetention
+}
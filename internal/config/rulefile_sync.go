@@ -0,0 +1,50 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// DefaultResyncIntervalSeconds is the interval used when resync_interval is not set.
+const DefaultResyncIntervalSeconds = 300
+
+// RuleFileSyncConfig defines how the PrometheusRules are written on disk so Prometheus can load them.
+type RuleFileSyncConfig struct {
+	// Folder is the directory where the rule files are written.
+	// There is one file per PrometheusRule: <folder>/<project>/<name>.yaml.
+	// Any other yaml file matching this pattern will be removed.
+	Folder string `yaml:"folder"`
+	// ReloadURL is the URL called with a POST request every time the rule files changed, like http://localhost:9090/-/reload.
+	// Nothing is called when it's empty.
+	ReloadURL string `yaml:"reload_url,omitempty"`
+	// ResyncIntervalSeconds is the interval between two full synchronizations that don't depend on the changes in the database.
+	ResyncIntervalSeconds uint64 `yaml:"resync_interval,omitempty"`
+}
+
+func (c *RuleFileSyncConfig) Verify() error {
+	if len(c.Folder) == 0 {
+		return fmt.Errorf("the folder where the rule files are written must be specified")
+	}
+	if len(c.ReloadURL) > 0 {
+		if _, err := url.ParseRequestURI(c.ReloadURL); err != nil {
+			return fmt.Errorf("invalid reload_url: %w", err)
+		}
+	}
+	if c.ResyncIntervalSeconds == 0 {
+		c.ResyncIntervalSeconds = DefaultResyncIntervalSeconds
+	}
+	return nil
+}
@@ -21,10 +21,17 @@ import (
 
 type ClientInterface interface {
 	RESTClient() *perseshttp.RESTClient
+	AlertmanagerConfig(project string) AlertmanagerConfigInterface
 	Dashboard(project string) DashboardInterface
-	Datasource() DatasourceInterface
+	// Datasource is returning the client to manage the datasources of the project.
+	// The project can be empty to manage the global datasources.
+	Datasource(project string) DatasourceInterface
 	Project() ProjectInterface
 	PrometheusRule(project string) PrometheusRuleInterface
+	// RuleFile is returning the client to get the rule file containing the PrometheusRules of the project.
+	// The project can be empty to get the rule file of all projects.
+	RuleFile(project string) RuleFileInterface
+	SLO(project string) SLOInterface
 	User() UserInterface
 }
 
@@ -43,12 +50,16 @@ func (c *client) RESTClient() *perseshttp.RESTClient {
 	return c.restClient
 }
 
+func (c *client) AlertmanagerConfig(project string) AlertmanagerConfigInterface {
+	return newAlertmanagerConfig(c.restClient, project)
+}
+
 func (c *client) Dashboard(project string) DashboardInterface {
 	return newDashboard(c.restClient, project)
 }
 
-func (c *client) Datasource() DatasourceInterface {
-	return newDatasource(c.restClient)
+func (c *client) Datasource(project string) DatasourceInterface {
+	return newDatasource(c.restClient, project)
 }
 
 func (c *client) Project() ProjectInterface {
@@ -59,6 +70,14 @@ func (c *client) PrometheusRule(project string) PrometheusRuleInterface {
 	return newPrometheusRule(c.restClient, project)
 }
 
+func (c *client) RuleFile(project string) RuleFileInterface {
+	return newRuleFile(c.restClient, project)
+}
+
+func (c *client) SLO(project string) SLOInterface {
+	return newSLO(c.restClient, project)
+}
+
 func (c *client) User() UserInterface {
 	return newUser(c.restClient)
 }
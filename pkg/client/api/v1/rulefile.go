@@ -0,0 +1,46 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"github.com/perses/perses/pkg/client/perseshttp"
+)
+
+const ruleFileResource = "rulefile"
+
+type RuleFileInterface interface {
+	// Get is returning the rule groups of the PrometheusRules as a YAML document that Prometheus can load.
+	Get() ([]byte, error)
+}
+
+type ruleFile struct {
+	RuleFileInterface
+	client  *perseshttp.RESTClient
+	project string
+}
+
+func newRuleFile(client *perseshttp.RESTClient, project string) RuleFileInterface {
+	return &ruleFile{
+		client:  client,
+		project: project,
+	}
+}
+
+func (c *ruleFile) Get() ([]byte, error) {
+	return c.client.Get().
+		Resource(ruleFileResource).
+		Project(c.project).
+		Do().
+		Raw()
+}
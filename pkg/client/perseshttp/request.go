@@ -357,3 +357,12 @@ func (r *Response) Object(respObj interface{}) error {
 	}
 	return nil
 }
+
+// Raw returns the body of the response as it has been received.
+// It is useful when the response is not a JSON document.
+func (r *Response) Raw() ([]byte, error) {
+	if err := r.Error(); err != nil {
+		return nil, err
+	}
+	return r.body, nil
+}
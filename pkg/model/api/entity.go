@@ -17,3 +17,19 @@ type Entity interface {
 	GenerateID() string
 	GetMetadata() interface{}
 }
+
+// Versioned is implemented by the metadata of the entities, so the database can set their version.
+type Versioned interface {
+	GetVersion() uint64
+	SetVersion(version uint64)
+}
+
+// GetVersioned returns the metadata of the entity if it's versioned, nil otherwise.
+func GetVersioned(entity interface{}) Versioned {
+	if e, ok := entity.(Entity); ok {
+		if v, ok := e.GetMetadata().(Versioned); ok {
+			return v
+		}
+	}
+	return nil
+}
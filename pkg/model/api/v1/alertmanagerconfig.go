@@ -0,0 +1,213 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/common/model"
+)
+
+// AlertmanagerProjectLabel is the label used to route the alerts to the AlertmanagerConfig of their project.
+// The alerts coming from the PrometheusRules of a project must have this label set with the name of the project.
+const AlertmanagerProjectLabel = "project"
+
+func GenerateAlertmanagerConfigID(project string, name string) string {
+	return generateProjectResourceID("alertmanagerconfigs", project, name)
+}
+
+// AlertmanagerRoute is a route of the Alertmanager configuration.
+type AlertmanagerRoute struct {
+	Receiver       string               `json:"receiver,omitempty" yaml:"receiver,omitempty"`
+	GroupBy        []string             `json:"group_by,omitempty" yaml:"group_by,omitempty"`
+	Match          map[string]string    `json:"match,omitempty" yaml:"match,omitempty"`
+	MatchRE        map[string]string    `json:"match_re,omitempty" yaml:"match_re,omitempty"`
+	Continue       bool                 `json:"continue,omitempty" yaml:"continue,omitempty"`
+	Routes         []*AlertmanagerRoute `json:"routes,omitempty" yaml:"routes,omitempty"`
+	GroupWait      *model.Duration      `json:"group_wait,omitempty" yaml:"group_wait,omitempty"`
+	GroupInterval  *model.Duration      `json:"group_interval,omitempty" yaml:"group_interval,omitempty"`
+	RepeatInterval *model.Duration      `json:"repeat_interval,omitempty" yaml:"repeat_interval,omitempty"`
+}
+
+// AlertmanagerInhibitRule is an inhibition rule of the Alertmanager configuration.
+type AlertmanagerInhibitRule struct {
+	SourceMatch   map[string]string `json:"source_match,omitempty" yaml:"source_match,omitempty"`
+	SourceMatchRE map[string]string `json:"source_match_re,omitempty" yaml:"source_match_re,omitempty"`
+	TargetMatch   map[string]string `json:"target_match,omitempty" yaml:"target_match,omitempty"`
+	TargetMatchRE map[string]string `json:"target_match_re,omitempty" yaml:"target_match_re,omitempty"`
+	Equal         []string          `json:"equal,omitempty" yaml:"equal,omitempty"`
+}
+
+// AlertmanagerReceiver is a receiver of the Alertmanager configuration: a name and the configurations of the integrations
+// (email_configs, slack_configs, webhook_configs, ...). The integrations are kept as they are, so they support every
+// integration of Alertmanager, and they are validated with the parser of the Alertmanager configuration.
+type AlertmanagerReceiver map[string]interface{}
+
+// Name returns the name of the receiver.
+func (r AlertmanagerReceiver) Name() string {
+	name, _ := r["name"].(string)
+	return name
+}
+
+func (r *AlertmanagerReceiver) UnmarshalJSON(data []byte) error {
+	var tmp map[string]interface{}
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	if err := AlertmanagerReceiver(tmp).validate(); err != nil {
+		return err
+	}
+	*r = tmp
+	return nil
+}
+
+func (r *AlertmanagerReceiver) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp map[string]interface{}
+	if err := unmarshal(&tmp); err != nil {
+		return err
+	}
+	// the nested maps decoded from YAML have keys of type interface{} that cannot be marshalled in JSON.
+	for key, value := range tmp {
+		tmp[key] = convertYAMLValue(value)
+	}
+	if err := AlertmanagerReceiver(tmp).validate(); err != nil {
+		return err
+	}
+	*r = tmp
+	return nil
+}
+
+func (r AlertmanagerReceiver) validate() error {
+	if len(r.Name()) == 0 {
+		return fmt.Errorf("field 'name' of a receiver must be set")
+	}
+	return nil
+}
+
+func convertYAMLValue(value interface{}) interface{} {
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, nested := range v {
+			result[fmt.Sprintf("%v", key)] = convertYAMLValue(nested)
+		}
+		return result
+	case []interface{}:
+		for i, nested := range v {
+			v[i] = convertYAMLValue(nested)
+		}
+		return v
+	default:
+		return v
+	}
+}
+
+type AlertmanagerConfigSpec struct {
+	// Route is the route of the project. When the configurations are merged, it only matches the alerts of the project.
+	Route        AlertmanagerRoute         `json:"route" yaml:"route"`
+	Receivers    []AlertmanagerReceiver    `json:"receivers" yaml:"receivers"`
+	InhibitRules []AlertmanagerInhibitRule `json:"inhibit_rules,omitempty" yaml:"inhibit_rules,omitempty"`
+}
+
+func (a *AlertmanagerConfigSpec) UnmarshalJSON(data []byte) error {
+	var tmp AlertmanagerConfigSpec
+	type plain AlertmanagerConfigSpec
+	if err := json.Unmarshal(data, (*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*a = tmp
+	return nil
+}
+
+func (a *AlertmanagerConfigSpec) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp AlertmanagerConfigSpec
+	type plain AlertmanagerConfigSpec
+	if err := unmarshal((*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*a = tmp
+	return nil
+}
+
+func (a *AlertmanagerConfigSpec) validate() error {
+	if len(a.Route.Receiver) == 0 {
+		return fmt.Errorf("field 'route.receiver' must be set")
+	}
+	if len(a.Receivers) == 0 {
+		return fmt.Errorf("at least one receiver should be defined")
+	}
+	return nil
+}
+
+type AlertmanagerConfig struct {
+	Kind     Kind                   `json:"kind" yaml:"kind"`
+	Metadata ProjectMetadata        `json:"metadata" yaml:"metadata"`
+	Spec     AlertmanagerConfigSpec `json:"spec" yaml:"spec"`
+}
+
+func (a *AlertmanagerConfig) GenerateID() string {
+	return GenerateAlertmanagerConfigID(a.Metadata.Project, a.Metadata.Name)
+}
+
+func (a *AlertmanagerConfig) GetMetadata() interface{} {
+	return &a.Metadata
+}
+
+func (a *AlertmanagerConfig) UnmarshalJSON(data []byte) error {
+	var tmp AlertmanagerConfig
+	type plain AlertmanagerConfig
+	if err := json.Unmarshal(data, (*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*a = tmp
+	return nil
+}
+
+func (a *AlertmanagerConfig) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp AlertmanagerConfig
+	type plain AlertmanagerConfig
+	if err := unmarshal((*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*a = tmp
+	return nil
+}
+
+func (a *AlertmanagerConfig) validate() error {
+	if a.Kind != KindAlertmanagerConfig {
+		return fmt.Errorf("invalid kind: '%s' for a AlertmanagerConfig type", a.Kind)
+	}
+	return nil
+}
+
+// AlertmanagerConfigFile is a configuration that Alertmanager can load.
+// It is the result of the merge of the AlertmanagerConfig of every project.
+type AlertmanagerConfigFile struct {
+	Route        *AlertmanagerRoute        `json:"route" yaml:"route"`
+	Receivers    []AlertmanagerReceiver    `json:"receivers" yaml:"receivers"`
+	InhibitRules []AlertmanagerInhibitRule `json:"inhibit_rules,omitempty" yaml:"inhibit_rules,omitempty"`
+}
@@ -16,6 +16,7 @@ package v1
 import (
 	"encoding/json"
 	"fmt"
+	"time"
 
 	"github.com/prometheus/common/model"
 )
@@ -25,10 +26,25 @@ type PromQueryResult struct {
 	Result model.Value `json:"result"`
 }
 
+// LogLine is a log line returned by a datasource.
+type LogLine struct {
+	Timestamp time.Time         `json:"timestamp"`
+	Labels    map[string]string `json:"labels"`
+	Line      string            `json:"line"`
+}
+
+type LogsQueryResult struct {
+	Err   error     `json:"err,omitempty"`
+	Lines []LogLine `json:"lines"`
+}
+
 type PanelFeedResponse struct {
-	Name    string            `json:"name"`
-	Order   uint64            `json:"order"`
+	Name  string `json:"name"`
+	Order uint64 `json:"order"`
+	// Results is filled when the panel is a LineChart. There is one result per line.
 	Results []PromQueryResult `json:"results"`
+	// Logs is filled when the panel is a LogsChart.
+	Logs *LogsQueryResult `json:"logs,omitempty"`
 }
 
 type SectionFeedResponse struct {
@@ -43,6 +59,10 @@ type SectionFeedResponse struct {
 
 // SectionFeedRequest is the struct that represents the request performed by a client in order to get a set of data to feed a Dashboard.
 type SectionFeedRequest struct {
+	// Project is the project of the dashboard. It is optional.
+	// When it is set, the datasources are searched first in the project and then in the global datasources.
+	Project string `json:"project,omitempty"`
+	// Datasource is the name of the datasource used by every query that doesn't define its own datasource.
 	Datasource string             `json:"datasource"`
 	Duration   model.Duration     `json:"duration"`
 	Variables  map[string]string  `json:"variables"`
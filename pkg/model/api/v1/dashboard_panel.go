@@ -28,11 +28,15 @@ type ChartKind string
 
 const (
 	KindLineChart ChartKind = "LineChart"
+	KindLogsChart ChartKind = "LogsChart"
 )
 
 type Line struct {
-	Expr   string `json:"expr" yaml:"expr"`
-	Legend string `json:"legend,omitempty" yaml:"legend,omitempty"`
+	// Datasource is the name of the datasource used to perform the query.
+	// It is optional. If not set, the datasource of the panel is used and then the one of the dashboard.
+	Datasource string `json:"datasource,omitempty" yaml:"datasource,omitempty"`
+	Expr       string `json:"expr" yaml:"expr"`
+	Legend     string `json:"legend,omitempty" yaml:"legend,omitempty"`
 }
 
 func (l *Line) UnmarshalJSON(data []byte) error {
@@ -112,18 +116,70 @@ func (l *LineChart) validate() error {
 	return nil
 }
 
+// LogsChart is displaying the log lines returned by a LogQL query.
+// The datasource used must be a Loki datasource.
+type LogsChart struct {
+	Chart `json:"-" yaml:"-"`
+	Kind  ChartKind `json:"kind" yaml:"kind"`
+	// Query is the LogQL query used to select the log lines.
+	Query string `json:"query" yaml:"query"`
+	// Limit is the maximum number of log lines returned. If not set, the default limit of the datasource is used.
+	Limit uint64 `json:"limit,omitempty" yaml:"limit,omitempty"`
+}
+
+func (l *LogsChart) GetKind() ChartKind {
+	return l.Kind
+}
+
+func (l *LogsChart) UnmarshalJSON(data []byte) error {
+	var tmp LogsChart
+	type plain LogsChart
+	if err := json.Unmarshal(data, (*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*l = tmp
+	return nil
+}
+
+func (l *LogsChart) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp LogsChart
+	type plain LogsChart
+	if err := unmarshal((*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*l = tmp
+	return nil
+}
+
+func (l *LogsChart) validate() error {
+	if len(l.Query) == 0 {
+		return fmt.Errorf("query cannot be empty for a LogsChart")
+	}
+	return nil
+}
+
 type tmpPanel struct {
 	Name string `json:"name" yaml:"name"`
 	// Order is used to know the display order
-	Order uint64                 `json:"order" yaml:"order"`
-	Chart map[string]interface{} `json:"chart" yaml:"chart"`
+	Order      uint64                 `json:"order" yaml:"order"`
+	Datasource string                 `json:"datasource,omitempty" yaml:"datasource,omitempty"`
+	Chart      map[string]interface{} `json:"chart" yaml:"chart"`
 }
 
 type Panel struct {
 	Name string `json:"name" yaml:"name"`
 	// Order is used to know the display order
 	Order uint64 `json:"order" yaml:"order"`
-	Chart Chart  `json:"chart" yaml:"chart"`
+	// Datasource is the name of the datasource used by default by every query of the panel.
+	// It is optional. If not set, the datasource of the dashboard is used.
+	Datasource string `json:"datasource,omitempty" yaml:"datasource,omitempty"`
+	Chart      Chart  `json:"chart" yaml:"chart"`
 }
 
 func (p *Panel) UnmarshalJSON(data []byte) error {
@@ -157,6 +213,7 @@ func (p *Panel) unmarshal(unmarshal func(interface{}) error, staticMarshal func(
 	}
 	p.Name = tmpPanel.Name
 	p.Order = tmpPanel.Order
+	p.Datasource = tmpPanel.Datasource
 	chartKind := tmpPanel.Chart["kind"].(string)
 	if len(chartKind) == 0 {
 		return fmt.Errorf("chart.kind cannot be empty")
@@ -174,6 +231,12 @@ func (p *Panel) unmarshal(unmarshal func(interface{}) error, staticMarshal func(
 			return err
 		}
 		p.Chart = chart
+	case string(KindLogsChart):
+		chart := &LogsChart{}
+		if err := staticUnmarshal(rawChart, chart); err != nil {
+			return err
+		}
+		p.Chart = chart
 	default:
 		return fmt.Errorf("chart kind not supported: '%s'", chartKind)
 	}
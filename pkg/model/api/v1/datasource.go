@@ -19,30 +19,108 @@ import (
 	"net/url"
 )
 
-func GenerateDatasourceID(name string) string {
-	return fmt.Sprintf("/datasources/%s", name)
+// GenerateDatasourceID returns the key of the datasource. The project is empty for a global datasource.
+func GenerateDatasourceID(project string, name string) string {
+	if len(project) == 0 {
+		return fmt.Sprintf("/datasources/%s", name)
+	}
+	return generateProjectResourceID("datasources", project, name)
+}
+
+type DatasourceKind string
+
+const (
+	KindPrometheusDatasource DatasourceKind = "Prometheus"
+	KindLokiDatasource       DatasourceKind = "Loki"
+)
+
+var datasourceKindMap = map[DatasourceKind]bool{
+	KindPrometheusDatasource: true,
+	KindLokiDatasource:       true,
+}
+
+func (k *DatasourceKind) validate() error {
+	if _, ok := datasourceKindMap[*k]; !ok {
+		return fmt.Errorf("unknown datasource.kind '%s' used", *k)
+	}
+	return nil
+}
+
+type BasicAuth struct {
+	Username string `json:"username" yaml:"username"`
+	// Password is a secret. It is never returned by the API.
+	Password string `json:"password,omitempty" yaml:"password,omitempty"`
+}
+
+func (b *BasicAuth) validate() error {
+	if len(b.Username) == 0 {
+		return fmt.Errorf("basic_auth.username cannot be empty")
+	}
+	return nil
+}
+
+type TLSConfig struct {
+	// CA is the PEM encoded certificate of the authority used to verify the certificate of the datasource.
+	CA string `json:"ca,omitempty" yaml:"ca,omitempty"`
+	// Cert is the PEM encoded certificate used to authenticate Perses against the datasource.
+	Cert string `json:"cert,omitempty" yaml:"cert,omitempty"`
+	// Key is the PEM encoded private key associated to the Cert. It is a secret and so it is never returned by the API.
+	Key string `json:"key,omitempty" yaml:"key,omitempty"`
+	// InsecureSkipVerify disables the verification of the certificate of the datasource.
+	InsecureSkipVerify bool `json:"insecure_skip_verify,omitempty" yaml:"insecure_skip_verify,omitempty"`
 }
 
 type DatasourceSpec struct {
-	URL *url.URL `json:"url" yaml:"url"`
+	// Kind is the type of the datasource. When it is not set, the datasource is considered as a Prometheus one.
+	Kind      DatasourceKind `json:"kind" yaml:"kind"`
+	URL       *url.URL       `json:"url" yaml:"url"`
+	BasicAuth *BasicAuth     `json:"basic_auth,omitempty" yaml:"basic_auth,omitempty"`
+	// BearerToken is a secret. It is never returned by the API.
+	BearerToken string `json:"bearer_token,omitempty" yaml:"bearer_token,omitempty"`
+	// Headers are added to every request sent to the datasource.
+	// Since they can carry credentials, their values are never returned by the API.
+	Headers   map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	TLSConfig *TLSConfig        `json:"tls_config,omitempty" yaml:"tls_config,omitempty"`
+	// bearerTokenCleared is true when the bearer_token has been explicitly set to an empty string,
+	// to remove the one previously stored instead of keeping it.
+	bearerTokenCleared bool
+}
+
+// IsBearerTokenCleared returns true when the bearer_token has been explicitly set to an empty string.
+func (d *DatasourceSpec) IsBearerTokenCleared() bool {
+	return d.bearerTokenCleared
 }
 
 type tmpDatasourceSpec struct {
-	URL string `json:"url" yaml:"url"`
+	Kind      DatasourceKind `json:"kind" yaml:"kind"`
+	URL       string         `json:"url" yaml:"url"`
+	BasicAuth *BasicAuth     `json:"basic_auth,omitempty" yaml:"basic_auth,omitempty"`
+	// BearerToken is a pointer to distinguish a token explicitly set to an empty string from a token not provided.
+	BearerToken *string           `json:"bearer_token,omitempty" yaml:"bearer_token,omitempty"`
+	Headers     map[string]string `json:"headers,omitempty" yaml:"headers,omitempty"`
+	TLSConfig   *TLSConfig        `json:"tls_config,omitempty" yaml:"tls_config,omitempty"`
 }
 
 func (d *DatasourceSpec) MarshalJSON() ([]byte, error) {
-	tmp := &tmpDatasourceSpec{
-		URL: d.URL.String(),
-	}
-	return json.Marshal(tmp)
+	return json.Marshal(d.toTmp())
 }
 
 func (d *DatasourceSpec) MarshalYAML() (interface{}, error) {
+	return d.toTmp(), nil
+}
+
+func (d *DatasourceSpec) toTmp() *tmpDatasourceSpec {
 	tmp := &tmpDatasourceSpec{
-		URL: d.URL.String(),
+		Kind:      d.Kind,
+		URL:       d.URL.String(),
+		BasicAuth: d.BasicAuth,
+		Headers:   d.Headers,
+		TLSConfig: d.TLSConfig,
 	}
-	return tmp, nil
+	if len(d.BearerToken) > 0 {
+		tmp.BearerToken = &d.BearerToken
+	}
+	return tmp
 }
 
 func (d *DatasourceSpec) UnmarshalJSON(data []byte) error {
@@ -68,22 +146,56 @@ func (d *DatasourceSpec) UnmarshalYAML(unmarshal func(interface{}) error) error
 }
 
 func (d *DatasourceSpec) validate(spec tmpDatasourceSpec) error {
-	if u, err := url.Parse(spec.URL); err != nil {
+	if len(spec.Kind) == 0 {
+		// the datasources created before the kind was introduced are all Prometheus datasources.
+		spec.Kind = KindPrometheusDatasource
+	}
+	if err := (&spec.Kind).validate(); err != nil {
+		return err
+	}
+	u, err := url.Parse(spec.URL)
+	if err != nil {
 		return err
-	} else {
-		d.URL = u
 	}
+	if spec.BasicAuth != nil {
+		if err := spec.BasicAuth.validate(); err != nil {
+			return err
+		}
+	}
+	if u.User != nil {
+		// the credentials contained in the URL are moved to the basic_auth so the URL never contains any secret.
+		if spec.BasicAuth != nil {
+			return fmt.Errorf("credentials cannot be set in the url and in the basic_auth at the same time")
+		}
+		password, _ := u.User.Password()
+		spec.BasicAuth = &BasicAuth{Username: u.User.Username(), Password: password}
+		u.User = nil
+	}
+	var bearerToken string
+	if spec.BearerToken != nil {
+		bearerToken = *spec.BearerToken
+	}
+	if spec.BasicAuth != nil && len(bearerToken) > 0 {
+		return fmt.Errorf("only one of 'basic_auth' or 'bearer_token' can be set")
+	}
+	d.Kind = spec.Kind
+	d.URL = u
+	d.BasicAuth = spec.BasicAuth
+	d.BearerToken = bearerToken
+	d.bearerTokenCleared = spec.BearerToken != nil && len(bearerToken) == 0
+	d.Headers = spec.Headers
+	d.TLSConfig = spec.TLSConfig
 	return nil
 }
 
 type Datasource struct {
-	Kind     Kind           `json:"kind" yaml:"kind"`
-	Metadata Metadata       `json:"metadata" yaml:"metadata"`
-	Spec     DatasourceSpec `json:"spec" yaml:"spec"`
+	Kind     Kind                    `json:"kind" yaml:"kind"`
+	Metadata OptionalProjectMetadata `json:"metadata" yaml:"metadata"`
+	Spec     DatasourceSpec          `json:"spec" yaml:"spec"`
 }
 
 func (d *Datasource) GenerateID() string {
-	return GenerateDatasourceID(d.Metadata.Name)
+	return GenerateDatasourceID(d.Metadata.Project, d.Metadata.Name)
 }
 
 func (d *Datasource) GetMetadata() interface{} {
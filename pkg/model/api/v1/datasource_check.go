@@ -0,0 +1,45 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import "github.com/prometheus/common/model"
+
+type DatasourceCheckErrorType string
+
+const (
+	// CheckErrorConnection is used when the datasource cannot be reached (DNS resolution, connection refused, ...).
+	CheckErrorConnection DatasourceCheckErrorType = "connection"
+	// CheckErrorTimeout is used when the datasource didn't answer in time.
+	CheckErrorTimeout DatasourceCheckErrorType = "timeout"
+	// CheckErrorTLS is used when the TLS handshake with the datasource failed (unknown authority, wrong hostname, ...).
+	CheckErrorTLS DatasourceCheckErrorType = "tls"
+	// CheckErrorAuth is used when the datasource rejected the credentials.
+	CheckErrorAuth DatasourceCheckErrorType = "auth"
+	// CheckErrorUnexpectedResponse is used when the datasource answered something that is not expected from a Prometheus server.
+	CheckErrorUnexpectedResponse DatasourceCheckErrorType = "unexpected_response"
+)
+
+// DatasourceCheckResponse is the result of the verification of the connectivity with a datasource.
+type DatasourceCheckResponse struct {
+	// Reachable is true when the datasource answered, whatever the answer is.
+	Reachable bool `json:"reachable"`
+	// Ready is true when the datasource is ready to serve queries.
+	Ready bool `json:"ready"`
+	// Latency is the time taken by the datasource to answer if it is ready.
+	Latency model.Duration `json:"latency"`
+	// Version is the version of Prometheus running behind the datasource.
+	Version   string                   `json:"version,omitempty"`
+	ErrorType DatasourceCheckErrorType `json:"error_type,omitempty"`
+	Error     string                   `json:"error,omitempty"`
+}
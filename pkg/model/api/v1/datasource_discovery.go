@@ -0,0 +1,22 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+// MetricMetadata describes a metric as exposed by the datasource.
+type MetricMetadata struct {
+	// Type is the type of the metric like counter, gauge, histogram, summary...
+	Type string `json:"type" yaml:"type"`
+	Help string `json:"help" yaml:"help"`
+	Unit string `json:"unit" yaml:"unit"`
+}
@@ -21,19 +21,23 @@ import (
 type Kind string
 
 const (
-	KindDashboard      Kind = "Dashboard"
-	KindDatasource     Kind = "Datasource"
-	KindProject        Kind = "Project"
-	KindPrometheusRule Kind = "PrometheusRule"
-	KindUser           Kind = "User"
+	KindAlertmanagerConfig Kind = "AlertmanagerConfig"
+	KindDashboard          Kind = "Dashboard"
+	KindDatasource         Kind = "Datasource"
+	KindProject            Kind = "Project"
+	KindPrometheusRule     Kind = "PrometheusRule"
+	KindSLO                Kind = "SLO"
+	KindUser               Kind = "User"
 )
 
 var KindMap = map[Kind]bool{
-	KindDashboard:      true,
-	KindDatasource:     true,
-	KindProject:        true,
-	KindPrometheusRule: true,
-	KindUser:           true,
+	KindAlertmanagerConfig: true,
+	KindDashboard:          true,
+	KindDatasource:         true,
+	KindProject:            true,
+	KindPrometheusRule:     true,
+	KindSLO:                true,
+	KindUser:               true,
 }
 
 func (k *Kind) UnmarshalJSON(data []byte) error {
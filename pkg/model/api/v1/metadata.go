@@ -19,6 +19,11 @@ import (
 )
 
 func generateProjectResourceID(pluralKind string, project string, name string) string {
+	if len(project) == 0 {
+		// Without project, it can only be the prefix used to search across all projects.
+		// The name cannot be used in this case since it is after the project in the key.
+		return fmt.Sprintf("/%s/", pluralKind)
+	}
 	return fmt.Sprintf("/%s/%s/%s", pluralKind, project, name)
 }
 
@@ -26,6 +31,9 @@ type Metadata struct {
 	Name      string    `json:"name" yaml:"name"`
 	CreatedAt time.Time `json:"created_at" yaml:"created_at"`
 	UpdatedAt time.Time `json:"updated_at" yaml:"updated_at"`
+	// Version is set by the database and changes every time the resource is modified (with etcd, it's the mod revision).
+	// When it's set in an update, the update is rejected if the resource has been modified in the meantime.
+	Version uint64 `json:"version,omitempty" yaml:"version,omitempty"`
 }
 
 func (m *Metadata) CreateNow() {
@@ -33,8 +41,26 @@ func (m *Metadata) CreateNow() {
 	m.UpdatedAt = m.CreatedAt
 }
 
+func (m *Metadata) GetVersion() uint64 {
+	return m.Version
+}
+
+func (m *Metadata) SetVersion(version uint64) {
+	m.Version = version
+}
+
 // ProjectMetadata is the metadata struct for resources that belongs to a project.
 type ProjectMetadata struct {
 	Metadata `json:",inline" yaml:";,inline"`
 	Project  string `json:"project" yaml:"project"`
+	// ManagedBy is set by the server on the resources it generates from another resource, like the PrometheusRule of an SLO.
+	// It's the kind and the name of this resource, and the generated resource can only be modified through it.
+	ManagedBy string `json:"managed_by,omitempty" yaml:"managed_by,omitempty"`
+}
+
+// OptionalProjectMetadata is the metadata struct for resources that can belong to a project or be global.
+// When the project is empty, the resource is global and so shared by every project.
+type OptionalProjectMetadata struct {
+	Metadata `json:",inline" yaml:";,inline"`
+	Project  string `json:"project,omitempty" yaml:"project,omitempty"`
 }
@@ -0,0 +1,34 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+// MetricReference is the location of a PromQL expression in a resource.
+type MetricReference struct {
+	// Kind is either Dashboard or PrometheusRule.
+	Kind    Kind   `json:"kind" yaml:"kind"`
+	Project string `json:"project" yaml:"project"`
+	Name    string `json:"name" yaml:"name"`
+	// Path is the path of the field containing the expression, like spec.groups[0].rules[1].expr.
+	// For a recording rule, it's the path of the field 'record'.
+	Path string `json:"path" yaml:"path"`
+}
+
+// MetricUsage describes where a metric comes from and where it's used.
+type MetricUsage struct {
+	Metric string `json:"metric" yaml:"metric"`
+	// RecordedBy are the recording rules producing the metric. It's empty when the metric is scraped.
+	RecordedBy []MetricReference `json:"recorded_by" yaml:"recorded_by"`
+	// UsedBy are the expressions of the dashboards and of the rules using the metric.
+	UsedBy []MetricReference `json:"used_by" yaml:"used_by"`
+}
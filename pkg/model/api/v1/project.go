@@ -22,9 +22,15 @@ func GenerateProjectID(name string) string {
 	return fmt.Sprintf("/projects/%s", name)
 }
 
+type ProjectSpec struct {
+	// RulePolicies are the lint policies applied on the PrometheusRules of the project.
+	RulePolicies []RulePolicy `json:"rule_policies,omitempty" yaml:"rule_policies,omitempty"`
+}
+
 type Project struct {
-	Kind     Kind     `json:"kind" yaml:"kind"`
-	Metadata Metadata `json:"metadata" yaml:"metadata"`
+	Kind     Kind         `json:"kind" yaml:"kind"`
+	Metadata Metadata     `json:"metadata" yaml:"metadata"`
+	Spec     *ProjectSpec `json:"spec,omitempty" yaml:"spec,omitempty"`
 }
 
 func (p *Project) GenerateID() string {
@@ -27,7 +27,7 @@ type Rule struct {
 	Alert       string            `json:"alert,omitempty" yaml:"alert,omitempty"`
 	Expr        string            `json:"expr" yaml:"expr"`
 	For         string            `json:"for,omitempty" yaml:"for,omitempty"`
-	Labels      map[string]string `json:"labels,omitempty" yaml:"labels;,omitempty"`
+	Labels      map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
 	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
 }
 
@@ -119,6 +119,39 @@ func (p *RuleGroup) validate() error {
 
 type PrometheusRuleSpec struct {
 	Groups []RuleGroup `json:"groups" yaml:"groups"`
+	// Tests is optional. It contains the unit tests of the rules.
+	// It's not part of the rule file format, so use RuleFile to get what Prometheus can load.
+	Tests *RuleTest `json:"tests,omitempty" yaml:"tests,omitempty"`
+}
+
+// RuleFile returns the spec without the tests. It's the content of a rule file that Prometheus can load.
+func (p PrometheusRuleSpec) RuleFile() PrometheusRuleSpec {
+	return PrometheusRuleSpec{Groups: p.Groups}
+}
+
+// WithProjectLabel returns a copy of the spec where every alerting rule has the label AlertmanagerProjectLabel set with
+// the project, so the alerts are routed to the AlertmanagerConfig of the project. The recording rules are left as they
+// are since the label would change the series they produce.
+func (p PrometheusRuleSpec) WithProjectLabel(project string) PrometheusRuleSpec {
+	result := p
+	result.Groups = make([]RuleGroup, 0, len(p.Groups))
+	for _, group := range p.Groups {
+		rules := make([]Rule, 0, len(group.Rules))
+		for _, rule := range group.Rules {
+			if len(rule.Alert) > 0 {
+				labels := make(map[string]string, len(rule.Labels)+1)
+				for name, value := range rule.Labels {
+					labels[name] = value
+				}
+				labels[AlertmanagerProjectLabel] = project
+				rule.Labels = labels
+			}
+			rules = append(rules, rule)
+		}
+		group.Rules = rules
+		result.Groups = append(result.Groups, group)
+	}
+	return result
 }
 
 func (p *PrometheusRuleSpec) UnmarshalJSON(data []byte) error {
@@ -154,10 +187,17 @@ func (p *PrometheusRuleSpec) validate() error {
 	return nil
 }
 
+// PrometheusRuleStatus is set by the server. It's ignored when creating or updating a PrometheusRule.
+type PrometheusRuleStatus struct {
+	// Warnings are the violations of the policies of the project that don't reject the rules.
+	Warnings []RuleViolation `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
 type PrometheusRule struct {
-	Kind     Kind               `json:"kind" yaml:"kind"`
-	Metadata ProjectMetadata    `json:"metadata" yaml:"metadata"`
-	Spec     PrometheusRuleSpec `json:"spec" yaml:"spec"`
+	Kind     Kind                  `json:"kind" yaml:"kind"`
+	Metadata ProjectMetadata       `json:"metadata" yaml:"metadata"`
+	Spec     PrometheusRuleSpec    `json:"spec" yaml:"spec"`
+	Status   *PrometheusRuleStatus `json:"status,omitempty" yaml:"status,omitempty"`
 }
 
 func (p *PrometheusRule) GenerateID() string {
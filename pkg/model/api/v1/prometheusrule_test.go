@@ -0,0 +1,43 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestWithProjectLabel(t *testing.T) {
+	spec := PrometheusRuleSpec{
+		Groups: []RuleGroup{
+			{
+				Name: "node",
+				Rules: []Rule{
+					{Record: "instance:up:sum", Expr: "sum by (instance) (up)"},
+					{Alert: "InstanceDown", Expr: "up == 0", Labels: map[string]string{"severity": "critical", "project": "spoofed"}},
+					{Alert: "NodeDown", Expr: "up{job=\"node\"} == 0"},
+				},
+			},
+		},
+	}
+	result := spec.WithProjectLabel("perses")
+	rules := result.Groups[0].Rules
+	assert.Empty(t, rules[0].Labels)
+	assert.Equal(t, map[string]string{"severity": "critical", "project": "perses"}, rules[1].Labels)
+	assert.Equal(t, map[string]string{"project": "perses"}, rules[2].Labels)
+	// the original spec is left unchanged
+	assert.Equal(t, "spoofed", spec.Groups[0].Rules[1].Labels["project"])
+	assert.Empty(t, spec.Groups[0].Rules[2].Labels)
+}
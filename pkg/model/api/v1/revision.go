@@ -0,0 +1,80 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// GenerateRevisionID returns the key of a revision of the resource identified by resourceID.
+// The number is padded with zeros, so the revisions are sorted by number when they are sorted by key.
+func GenerateRevisionID(resourceID string, number uint64) string {
+	return fmt.Sprintf("%s%020d", GenerateRevisionPrefix(resourceID), number)
+}
+
+const (
+	// globalRevisionPrefix and projectRevisionPrefix keep the revisions of the global resources (/<kind>/<name>) apart
+	// from the ones of the resources of a project (/<kind>/<project>/<name>). Otherwise the prefix of the revisions of
+	// the global datasource foo would also match the revisions of the datasources of the project foo.
+	globalRevisionPrefix  = "/revisions/global"
+	projectRevisionPrefix = "/revisions/project"
+)
+
+// GenerateRevisionPrefix returns the prefix of the keys of all revisions of the resource identified by resourceID.
+func GenerateRevisionPrefix(resourceID string) string {
+	if strings.Count(resourceID, "/") > 2 {
+		return fmt.Sprintf("%s%s/", projectRevisionPrefix, resourceID)
+	}
+	return fmt.Sprintf("%s%s/", globalRevisionPrefix, resourceID)
+}
+
+// GenerateProjectRevisionPrefix returns the prefix of the keys of the revisions of all the resources whose key starts
+// with resourcesPrefix, the prefix of the keys of a kind of resources in a project like /dashboards/<project>/.
+func GenerateProjectRevisionPrefix(resourcesPrefix string) string {
+	return projectRevisionPrefix + resourcesPrefix
+}
+
+type RevisionOperation string
+
+const (
+	RevisionCreated RevisionOperation = "created"
+	RevisionUpdated RevisionOperation = "updated"
+	RevisionDeleted RevisionOperation = "deleted"
+)
+
+// Revision is a past version of a resource. A revision is recorded every time a resource is modified through the API.
+type Revision struct {
+	// Number identifies the revision in the history of the resource. It's incremented at every change.
+	Number    uint64            `json:"number" yaml:"number"`
+	Operation RevisionOperation `json:"operation" yaml:"operation"`
+	// Author is the user that modified the resource, when it's known.
+	Author string    `json:"author,omitempty" yaml:"author,omitempty"`
+	Date   time.Time `json:"date" yaml:"date"`
+	// RestoredFrom is the number of the revision that has been restored by this change, if any.
+	RestoredFrom uint64 `json:"restored_from,omitempty" yaml:"restored_from,omitempty"`
+	// Resource is the resource once modified. It's empty when the resource has been deleted,
+	// and when the revisions are listed.
+	Resource json.RawMessage `json:"resource,omitempty" yaml:"resource,omitempty"`
+}
+
+// RevisionDiff is the difference between two revisions of a resource.
+type RevisionDiff struct {
+	From uint64 `json:"from" yaml:"from"`
+	To   uint64 `json:"to" yaml:"to"`
+	// Diff is the unified diff of the resource written in YAML. It's empty when the two revisions are identical.
+	Diff string `json:"diff" yaml:"diff"`
+}
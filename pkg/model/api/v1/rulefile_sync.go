@@ -0,0 +1,31 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import "time"
+
+// RuleFileSyncStatus describes the state of the synchronization of the PrometheusRules on disk.
+type RuleFileSyncStatus struct {
+	// Enabled is false when the synchronization is not configured.
+	Enabled bool   `json:"enabled"`
+	Folder  string `json:"folder,omitempty"`
+	// Files is the number of rule files written by the last successful synchronization.
+	Files int `json:"files"`
+	// LastSync is the time of the last synchronization, successful or not.
+	LastSync *time.Time `json:"last_sync,omitempty"`
+	// LastSuccess is the time of the last successful synchronization.
+	LastSuccess *time.Time `json:"last_success,omitempty"`
+	// LastError is the error of the last synchronization. It's empty when it succeeded.
+	LastError string `json:"last_error,omitempty"`
+}
@@ -0,0 +1,185 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+
+	"gopkg.in/yaml.v2"
+)
+
+// PrometheusOperatorAPIVersion is the apiVersion of the PrometheusRule defined by the prometheus-operator.
+const PrometheusOperatorAPIVersion = "monitoring.coreos.com/v1"
+
+type ImportConflictMode string
+
+const (
+	// ImportConflictSkip keeps the existing PrometheusRule and ignores the imported one.
+	ImportConflictSkip ImportConflictMode = "skip"
+	// ImportConflictOverwrite replaces the existing PrometheusRule with the imported one.
+	ImportConflictOverwrite ImportConflictMode = "overwrite"
+	// ImportConflictFail aborts the import, nothing is imported if at least one PrometheusRule already exists or is not valid.
+	ImportConflictFail ImportConflictMode = "fail"
+)
+
+type RuleImportStatus string
+
+const (
+	RuleImportStatusCreated RuleImportStatus = "created"
+	RuleImportStatusUpdated RuleImportStatus = "updated"
+	RuleImportStatusSkipped RuleImportStatus = "skipped"
+	RuleImportStatusFailed  RuleImportStatus = "failed"
+)
+
+// RuleImportResult is the result of the import of one PrometheusRule.
+type RuleImportResult struct {
+	Name   string           `json:"name" yaml:"name"`
+	Status RuleImportStatus `json:"status" yaml:"status"`
+	Error  string           `json:"error,omitempty" yaml:"error,omitempty"`
+	// Warnings are the violations of the policies of the project that didn't reject the rules.
+	Warnings []RuleViolation `json:"warnings,omitempty" yaml:"warnings,omitempty"`
+}
+
+// ruleManifest is the union of the formats that can be imported:
+//   - a PrometheusRule of the prometheus-operator (or a list of them),
+//   - a PrometheusRule of Perses,
+//   - a plain Prometheus rule file.
+type ruleManifest struct {
+	APIVersion string `yaml:"apiVersion"`
+	Kind       string `yaml:"kind"`
+	Metadata   struct {
+		Name string `yaml:"name"`
+	} `yaml:"metadata"`
+	Spec   *PrometheusRuleSpec `yaml:"spec"`
+	Groups []RuleGroup         `yaml:"groups"`
+	Items  []ruleManifest      `yaml:"items"`
+}
+
+func (m *ruleManifest) isEmpty() bool {
+	return len(m.Kind) == 0 && len(m.Groups) == 0 && len(m.Items) == 0
+}
+
+func (m *ruleManifest) isRuleFile() bool {
+	return len(m.Kind) == 0 && len(m.Groups) > 0
+}
+
+func newImportedRule(project string, name string, spec PrometheusRuleSpec) *PrometheusRule {
+	return &PrometheusRule{
+		Kind: KindPrometheusRule,
+		Metadata: ProjectMetadata{
+			Metadata: Metadata{Name: name},
+			Project:  project,
+		},
+		Spec: spec,
+	}
+}
+
+func (m *ruleManifest) toPrometheusRules(project string) ([]*PrometheusRule, error) {
+	switch m.Kind {
+	case string(KindPrometheusRule):
+		if len(m.APIVersion) > 0 && m.APIVersion != PrometheusOperatorAPIVersion {
+			return nil, fmt.Errorf("unsupported apiVersion '%s' for the kind '%s'", m.APIVersion, m.Kind)
+		}
+		if len(m.Metadata.Name) == 0 {
+			return nil, fmt.Errorf("metadata.name cannot be empty")
+		}
+		if m.Spec == nil {
+			return nil, fmt.Errorf("spec of the PrometheusRule '%s' cannot be empty", m.Metadata.Name)
+		}
+		return []*PrometheusRule{newImportedRule(project, m.Metadata.Name, *m.Spec)}, nil
+	case "List", "PrometheusRuleList":
+		var result []*PrometheusRule
+		for _, item := range m.Items {
+			if item.Kind != string(KindPrometheusRule) {
+				return nil, fmt.Errorf("unsupported kind '%s' in the list", item.Kind)
+			}
+			rules, err := item.toPrometheusRules(project)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, rules...)
+		}
+		return result, nil
+	default:
+		return nil, fmt.Errorf("unsupported kind '%s'", m.Kind)
+	}
+}
+
+// ParsePrometheusRules turns YAML documents into PrometheusRules of the given project.
+// Each document is either a PrometheusRule of the prometheus-operator, a list of them, a PrometheusRule of Perses or a plain Prometheus rule file.
+// As a rule file doesn't have a name, the given name is used for it. When there are several rule files, their index is appended to the name.
+// Two PrometheusRules with the same name are rejected.
+func ParsePrometheusRules(data []byte, project string, name string) ([]*PrometheusRule, error) {
+	var manifests []ruleManifest
+	// index of the manifests in the documents, empty documents are ignored
+	var indexes []int
+	ruleFileCount := 0
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	for i := 0; ; i++ {
+		manifest := ruleManifest{}
+		if err := decoder.Decode(&manifest); err != nil {
+			if err == io.EOF {
+				break
+			}
+			return nil, fmt.Errorf("unable to decode the document %d: %s", i, err)
+		}
+		if manifest.isEmpty() {
+			continue
+		}
+		if manifest.isRuleFile() {
+			ruleFileCount++
+		}
+		manifests = append(manifests, manifest)
+		indexes = append(indexes, i)
+	}
+	if len(manifests) == 0 {
+		return nil, fmt.Errorf("no PrometheusRule found")
+	}
+	if ruleFileCount > 0 && len(name) == 0 {
+		return nil, fmt.Errorf("a name is required to import a rule file")
+	}
+	var result []*PrometheusRule
+	ruleFileIndex := 0
+	for i, manifest := range manifests {
+		if manifest.isRuleFile() {
+			ruleFileIndex++
+			ruleName := name
+			if ruleFileCount > 1 {
+				ruleName = fmt.Sprintf("%s-%d", name, ruleFileIndex)
+			}
+			spec := PrometheusRuleSpec{Groups: manifest.Groups}
+			if err := spec.validate(); err != nil {
+				return nil, fmt.Errorf("invalid document %d: %s", indexes[i], err)
+			}
+			result = append(result, newImportedRule(project, ruleName, spec))
+			continue
+		}
+		rules, err := manifest.toPrometheusRules(project)
+		if err != nil {
+			return nil, fmt.Errorf("invalid document %d: %s", indexes[i], err)
+		}
+		result = append(result, rules...)
+	}
+	// the PrometheusRules are all imported in the same project, so their names must be unique
+	names := make(map[string]bool, len(result))
+	for _, rule := range result {
+		if names[rule.Metadata.Name] {
+			return nil, fmt.Errorf("the PrometheusRule '%s' is defined several times", rule.Metadata.Name)
+		}
+		names[rule.Metadata.Name] = true
+	}
+	return result, nil
+}
@@ -0,0 +1,157 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParsePrometheusRules(t *testing.T) {
+	nodeGroup := RuleGroup{
+		Name: "node",
+		Rules: []Rule{
+			{
+				Alert:  "InstanceDown",
+				Expr:   "up == 0",
+				For:    "5m",
+				Labels: map[string]string{"severity": "page"},
+			},
+		},
+	}
+	testSuite := []struct {
+		title  string
+		data   string
+		name   string
+		result []*PrometheusRule
+	}{
+		{
+			title: "prometheus-operator PrometheusRule",
+			data: `
+apiVersion: monitoring.coreos.com/v1
+kind: PrometheusRule
+metadata:
+  name: node
+  namespace: monitoring
+  labels:
+    prometheus: k8s
+spec:
+  groups:
+    - name: node
+      rules:
+        - alert: InstanceDown
+          expr: up == 0
+          for: 5m
+          labels:
+            severity: page
+`,
+			result: []*PrometheusRule{newImportedRule("perses", "node", PrometheusRuleSpec{Groups: []RuleGroup{nodeGroup}})},
+		},
+		{
+			title: "rule files and a list of PrometheusRules in several documents",
+			name:  "imported",
+			data: `
+groups:
+  - name: node
+    rules:
+      - alert: InstanceDown
+        expr: up == 0
+        for: 5m
+        labels:
+          severity: page
+---
+---
+apiVersion: v1
+kind: List
+items:
+  - apiVersion: monitoring.coreos.com/v1
+    kind: PrometheusRule
+    metadata:
+      name: etcd
+    spec:
+      groups:
+        - name: node
+          rules:
+            - alert: InstanceDown
+              expr: up == 0
+              for: 5m
+              labels:
+                severity: page
+---
+groups:
+  - name: node
+    rules:
+      - alert: InstanceDown
+        expr: up == 0
+        for: 5m
+        labels:
+          severity: page
+`,
+			result: []*PrometheusRule{
+				newImportedRule("perses", "imported-1", PrometheusRuleSpec{Groups: []RuleGroup{nodeGroup}}),
+				newImportedRule("perses", "etcd", PrometheusRuleSpec{Groups: []RuleGroup{nodeGroup}}),
+				newImportedRule("perses", "imported-2", PrometheusRuleSpec{Groups: []RuleGroup{nodeGroup}}),
+			},
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			result, err := ParsePrometheusRules([]byte(test.data), "perses", test.name)
+			assert.NoError(t, err)
+			assert.Equal(t, test.result, result)
+		})
+	}
+}
+
+func TestParsePrometheusRulesError(t *testing.T) {
+	testSuite := []struct {
+		title string
+		data  string
+		name  string
+	}{
+		{
+			title: "empty document",
+			data:  "---\n",
+		},
+		{
+			title: "rule file without name",
+			data:  "groups:\n  - name: node\n    rules:\n      - record: job:up:sum\n        expr: sum by (job) (up)\n",
+		},
+		{
+			title: "unsupported kind",
+			data:  "apiVersion: monitoring.coreos.com/v1\nkind: ServiceMonitor\nmetadata:\n  name: node\n",
+		},
+		{
+			title: "unsupported apiVersion",
+			data:  "apiVersion: monitoring.coreos.com/v2\nkind: PrometheusRule\nmetadata:\n  name: node\nspec:\n  groups:\n    - name: node\n      rules:\n        - record: job:up:sum\n          expr: sum by (job) (up)\n",
+		},
+		{
+			title: "invalid rule",
+			name:  "node",
+			data:  "groups:\n  - name: node\n    rules:\n      - expr: up == 0\n",
+		},
+		{
+			title: "duplicate name",
+			name:  "node",
+			data:  "kind: PrometheusRule\nmetadata:\n  name: node\nspec:\n  groups:\n    - name: node\n      rules:\n        - record: job:up:sum\n          expr: sum by (job) (up)\n---\ngroups:\n  - name: node\n    rules:\n      - record: job:up:sum\n        expr: sum by (job) (up)\n",
+		},
+	}
+	for _, test := range testSuite {
+		t.Run(test.title, func(t *testing.T) {
+			_, err := ParsePrometheusRules([]byte(test.data), "perses", test.name)
+			assert.Error(t, err)
+		})
+	}
+}
@@ -0,0 +1,172 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+
+	"github.com/prometheus/common/model"
+)
+
+// DefaultRecordNamePattern is the pattern used by the RecordName policy when no pattern is set.
+// It's the naming convention level:metric:operations recommended by Prometheus for the recording rules.
+const DefaultRecordNamePattern = `^[a-zA-Z_][a-zA-Z0-9_]*:[a-zA-Z_][a-zA-Z0-9_]*:[a-zA-Z0-9_]+$`
+
+type PolicySeverity string
+
+const (
+	// PolicySeverityWarning means a violation is reported but the rules are accepted.
+	PolicySeverityWarning PolicySeverity = "warning"
+	// PolicySeverityError means a violation causes the rules to be rejected.
+	PolicySeverityError PolicySeverity = "error"
+)
+
+func (s *PolicySeverity) UnmarshalJSON(data []byte) error {
+	var tmp string
+	if err := json.Unmarshal(data, &tmp); err != nil {
+		return err
+	}
+	if err := (*PolicySeverity)(&tmp).validate(); err != nil {
+		return err
+	}
+	*s = PolicySeverity(tmp)
+	return nil
+}
+
+func (s *PolicySeverity) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp string
+	if err := unmarshal(&tmp); err != nil {
+		return err
+	}
+	if err := (*PolicySeverity)(&tmp).validate(); err != nil {
+		return err
+	}
+	*s = PolicySeverity(tmp)
+	return nil
+}
+
+func (s *PolicySeverity) validate() error {
+	if len(*s) == 0 {
+		*s = PolicySeverityError
+	}
+	if *s != PolicySeverityWarning && *s != PolicySeverityError {
+		return fmt.Errorf("unknown policy severity '%s'", *s)
+	}
+	return nil
+}
+
+type RulePolicyKind string
+
+const (
+	// RulePolicyKindLabel requires the alerting rules to have the label Name. When Values is set, the label must have one of them.
+	RulePolicyKindLabel RulePolicyKind = "Label"
+	// RulePolicyKindAnnotation requires the alerting rules to have the annotation Name.
+	RulePolicyKindAnnotation RulePolicyKind = "Annotation"
+	// RulePolicyKindRecordName requires the name of the recording rules to match Pattern.
+	RulePolicyKindRecordName RulePolicyKind = "RecordName"
+	// RulePolicyKindMinimumFor requires the alerting rules to have a 'for' duration greater or equal to Duration.
+	RulePolicyKindMinimumFor RulePolicyKind = "MinimumFor"
+)
+
+// RulePolicy is a lint policy applied on the PrometheusRules of a project each time they are created or updated.
+type RulePolicy struct {
+	Kind RulePolicyKind `json:"kind" yaml:"kind"`
+	// Severity decides if a violation of the policy is only a warning or if it rejects the rules. Default is error.
+	Severity PolicySeverity `json:"severity,omitempty" yaml:"severity,omitempty"`
+	// Name is the name of the label or of the annotation. Used by the kinds Label and Annotation.
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Values is the list of the values allowed for the label. Used by the kind Label.
+	Values []string `json:"values,omitempty" yaml:"values,omitempty"`
+	// Pattern is the regexp the name of the recording rules must match. Used by the kind RecordName.
+	// Default is DefaultRecordNamePattern.
+	Pattern string `json:"pattern,omitempty" yaml:"pattern,omitempty"`
+	// Duration is the minimum 'for' duration. Used by the kind MinimumFor.
+	Duration model.Duration `json:"duration,omitempty" yaml:"duration,omitempty"`
+	// Selector restricts the policy to the alerting rules having all these labels, e.g. severity: page.
+	// Not used by the kind RecordName.
+	Selector map[string]string `json:"selector,omitempty" yaml:"selector,omitempty"`
+}
+
+func (r *RulePolicy) UnmarshalJSON(data []byte) error {
+	var tmp RulePolicy
+	type plain RulePolicy
+	if err := json.Unmarshal(data, (*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*r = tmp
+	return nil
+}
+
+func (r *RulePolicy) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp RulePolicy
+	type plain RulePolicy
+	if err := unmarshal((*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*r = tmp
+	return nil
+}
+
+func (r *RulePolicy) validate() error {
+	if err := r.Severity.validate(); err != nil {
+		return err
+	}
+	switch r.Kind {
+	case RulePolicyKindLabel, RulePolicyKindAnnotation:
+		if len(r.Name) == 0 {
+			return fmt.Errorf("field 'name' must be set for a policy of kind '%s'", r.Kind)
+		}
+		if r.Kind == RulePolicyKindAnnotation && len(r.Values) > 0 {
+			return fmt.Errorf("field 'values' is not supported for a policy of kind '%s'", r.Kind)
+		}
+	case RulePolicyKindRecordName:
+		if len(r.Pattern) == 0 {
+			r.Pattern = DefaultRecordNamePattern
+		}
+		if _, err := regexp.Compile(r.Pattern); err != nil {
+			return fmt.Errorf("invalid field 'pattern': %s", err)
+		}
+		if len(r.Selector) > 0 {
+			return fmt.Errorf("field 'selector' is not supported for a policy of kind '%s'", r.Kind)
+		}
+	case RulePolicyKindMinimumFor:
+		if r.Duration <= 0 {
+			return fmt.Errorf("field 'duration' must be set for a policy of kind '%s'", r.Kind)
+		}
+	default:
+		return fmt.Errorf("unknown policy kind '%s'", r.Kind)
+	}
+	return nil
+}
+
+// RuleViolation describes a rule that doesn't respect one of the policies of its project.
+type RuleViolation struct {
+	Group    string         `json:"group" yaml:"group"`
+	Rule     string         `json:"rule" yaml:"rule"`
+	Policy   RulePolicyKind `json:"policy" yaml:"policy"`
+	Severity PolicySeverity `json:"severity" yaml:"severity"`
+	Message  string         `json:"message" yaml:"message"`
+}
+
+func (v RuleViolation) String() string {
+	return fmt.Sprintf("%s/%s: %s", v.Group, v.Rule, v.Message)
+}
@@ -0,0 +1,71 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+type AlertState string
+
+const (
+	AlertStateInactive AlertState = "inactive"
+	AlertStatePending  AlertState = "pending"
+	AlertStateFiring   AlertState = "firing"
+)
+
+// RulePreviewPeriod is a period of time during which an alert was firing.
+type RulePreviewPeriod struct {
+	Start time.Time `json:"start"`
+	End   time.Time `json:"end"`
+}
+
+// RulePreviewAlert is an alert that would have been created by an alerting rule.
+type RulePreviewAlert struct {
+	// Labels and Annotations are the ones of the alert, with the templates rendered.
+	Labels      map[string]string `json:"labels"`
+	Annotations map[string]string `json:"annotations,omitempty"`
+	// State is the state of the alert at the end of the evaluation.
+	State AlertState `json:"state"`
+	// ActiveAt is the time the alert became active for the last time.
+	ActiveAt time.Time `json:"active_at"`
+	// Value is the last value of the series that triggered the alert.
+	Value float64 `json:"value"`
+	// FiringPeriods are the periods during which the alert was firing.
+	FiringPeriods []RulePreviewPeriod `json:"firing_periods,omitempty"`
+}
+
+// RulePreview is the result of the evaluation of one rule.
+type RulePreview struct {
+	Group  string `json:"group"`
+	Record string `json:"record,omitempty"`
+	Alert  string `json:"alert,omitempty"`
+	Expr   string `json:"expr"`
+	// Series are the series returned by the expression.
+	// For a recording rule, they have the name and the labels of the series that would have been recorded.
+	Series model.Matrix `json:"series,omitempty"`
+	// Alerts are the alerts that would have been created, only for an alerting rule.
+	Alerts []RulePreviewAlert `json:"alerts,omitempty"`
+	// Error is set when the rule cannot be evaluated. The other rules are evaluated anyway.
+	Error string `json:"error,omitempty"`
+}
+
+// RulePreviewResult is the result of the evaluation of the rules of a PrometheusRule against a datasource.
+type RulePreviewResult struct {
+	Start time.Time     `json:"start"`
+	End   time.Time     `json:"end"`
+	Rules []RulePreview `json:"rules"`
+}
@@ -0,0 +1,142 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/prometheus/common/model"
+)
+
+// RuleTest contains the unit tests of the rules of a PrometheusRule. It follows the format of the test files used by promtool.
+type RuleTest struct {
+	// EvaluationInterval is the interval used to evaluate the rules. Default is 1m.
+	EvaluationInterval model.Duration `json:"evaluation_interval,omitempty" yaml:"evaluation_interval,omitempty"`
+	// GroupEvalOrder is the order in which the groups of rules are evaluated.
+	// Groups that are not in the list are evaluated before the others, in any order.
+	GroupEvalOrder []string        `json:"group_eval_order,omitempty" yaml:"group_eval_order,omitempty"`
+	Tests          []RuleTestGroup `json:"tests" yaml:"tests"`
+}
+
+func (r *RuleTest) UnmarshalJSON(data []byte) error {
+	var tmp RuleTest
+	type plain RuleTest
+	if err := json.Unmarshal(data, (*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*r = tmp
+	return nil
+}
+
+func (r *RuleTest) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp RuleTest
+	type plain RuleTest
+	if err := unmarshal((*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*r = tmp
+	return nil
+}
+
+func (r *RuleTest) validate() error {
+	if len(r.Tests) == 0 {
+		return fmt.Errorf("at least one test should be defined")
+	}
+	groups := make(map[string]bool, len(r.GroupEvalOrder))
+	for _, group := range r.GroupEvalOrder {
+		if groups[group] {
+			return fmt.Errorf("group name repeated in evaluation order: %s", group)
+		}
+		groups[group] = true
+	}
+	for i, test := range r.Tests {
+		for _, alertTest := range test.AlertRuleTests {
+			if len(alertTest.Alertname) == 0 {
+				return fmt.Errorf("field 'alertname' must be set in the alert_rule_test of the test %d", i)
+			}
+		}
+		for _, exprTest := range test.PromQLExprTests {
+			if len(exprTest.Expr) == 0 {
+				return fmt.Errorf("field 'expr' must be set in the promql_expr_test of the test %d", i)
+			}
+		}
+	}
+	return nil
+}
+
+// RuleTestGroup is a set of input series and the alerts and the samples that are expected from them.
+type RuleTestGroup struct {
+	Name string `json:"name,omitempty" yaml:"name,omitempty"`
+	// Interval is the interval between two values of the input series. Default is the evaluation interval.
+	Interval        model.Duration       `json:"interval,omitempty" yaml:"interval,omitempty"`
+	InputSeries     []RuleTestSeries     `json:"input_series" yaml:"input_series"`
+	AlertRuleTests  []AlertRuleTestCase  `json:"alert_rule_test,omitempty" yaml:"alert_rule_test,omitempty"`
+	PromQLExprTests []PromQLExprTestCase `json:"promql_expr_test,omitempty" yaml:"promql_expr_test,omitempty"`
+	ExternalLabels  map[string]string    `json:"external_labels,omitempty" yaml:"external_labels,omitempty"`
+}
+
+// RuleTestSeries is an input series written with the expanding notation of promtool, like:
+// series: 'up{job="prometheus"}'
+// values: '1+0x10 0 0'
+type RuleTestSeries struct {
+	Series string `json:"series" yaml:"series"`
+	Values string `json:"values" yaml:"values"`
+}
+
+// AlertRuleTestCase contains the alerts expected to be firing at a given time.
+type AlertRuleTestCase struct {
+	EvalTime  model.Duration  `json:"eval_time" yaml:"eval_time"`
+	Alertname string          `json:"alertname" yaml:"alertname"`
+	ExpAlerts []ExpectedAlert `json:"exp_alerts" yaml:"exp_alerts"`
+}
+
+type ExpectedAlert struct {
+	ExpLabels      map[string]string `json:"exp_labels,omitempty" yaml:"exp_labels,omitempty"`
+	ExpAnnotations map[string]string `json:"exp_annotations,omitempty" yaml:"exp_annotations,omitempty"`
+}
+
+// PromQLExprTestCase contains the samples expected to be returned by the expression at a given time.
+type PromQLExprTestCase struct {
+	Expr       string           `json:"expr" yaml:"expr"`
+	EvalTime   model.Duration   `json:"eval_time" yaml:"eval_time"`
+	ExpSamples []ExpectedSample `json:"exp_samples" yaml:"exp_samples"`
+}
+
+type ExpectedSample struct {
+	// Labels is the series written in the PromQL notation, like: 'up{job="prometheus"}'
+	Labels string  `json:"labels" yaml:"labels"`
+	Value  float64 `json:"value" yaml:"value"`
+}
+
+// RuleTestGroupResult is the result of one RuleTestGroup.
+type RuleTestGroupResult struct {
+	// Name is the name of the RuleTestGroup, or its position when it's not named.
+	Name    string   `json:"name"`
+	Success bool     `json:"success"`
+	Errors  []string `json:"errors,omitempty"`
+}
+
+// RuleTestResult is the result of the tests of a PrometheusRule.
+type RuleTestResult struct {
+	// Success is true when every test succeeded.
+	Success bool                  `json:"success"`
+	Tests   []RuleTestGroupResult `json:"tests"`
+}
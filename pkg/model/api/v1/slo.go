@@ -0,0 +1,198 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/prometheus/common/model"
+)
+
+// SLOWindowPlaceholder is the placeholder used in the expressions of a SLO for the range of the range vectors.
+// It is replaced by each window used to compute the burn rates, like 5m or 1h.
+const SLOWindowPlaceholder = "$window"
+
+// DefaultSLOWindow is the window of a SLO when it's not set.
+const DefaultSLOWindow = model.Duration(30 * 24 * time.Hour)
+
+func GenerateSLOID(project string, name string) string {
+	return generateProjectResourceID("slos", project, name)
+}
+
+// SLOAlerting configures the alerts generated from the SLO.
+// Every alert also has the label long_window set with the long window of its burn rate, so the alerts stay distinct.
+type SLOAlerting struct {
+	// PageLabels are the labels of the alerts of the fast burn rates. Default is severity: page.
+	PageLabels map[string]string `json:"page_labels,omitempty" yaml:"page_labels,omitempty"`
+	// TicketLabels are the labels of the alerts of the slow burn rates. Default is severity: ticket.
+	TicketLabels map[string]string `json:"ticket_labels,omitempty" yaml:"ticket_labels,omitempty"`
+	// Annotations are added to every alert, like a runbook_url.
+	Annotations map[string]string `json:"annotations,omitempty" yaml:"annotations,omitempty"`
+}
+
+type SLODashboard struct {
+	// Datasource is the name of the datasource used by the dashboard.
+	Datasource string `json:"datasource" yaml:"datasource"`
+}
+
+func (s *SLODashboard) UnmarshalJSON(data []byte) error {
+	var tmp SLODashboard
+	type plain SLODashboard
+	if err := json.Unmarshal(data, (*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*s = tmp
+	return nil
+}
+
+func (s *SLODashboard) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp SLODashboard
+	type plain SLODashboard
+	if err := unmarshal((*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*s = tmp
+	return nil
+}
+
+func (s *SLODashboard) validate() error {
+	if len(s.Datasource) == 0 {
+		return fmt.Errorf("field 'datasource' of the dashboard must be set")
+	}
+	return nil
+}
+
+type SLOSpec struct {
+	Description string `json:"description,omitempty" yaml:"description,omitempty"`
+	// Objective is the ratio of good events to reach over the window, like 0.999.
+	Objective float64 `json:"objective" yaml:"objective"`
+	// Window is the period over which the objective is computed. Default is 30d.
+	Window model.Duration `json:"window,omitempty" yaml:"window,omitempty"`
+	// Good is the PromQL expression returning the rate of the good events, like sum(rate(http_requests_total{code!~"5.."}[$window])).
+	// Total is the one returning the rate of all events. Both must use SLOWindowPlaceholder as the range of their range vectors.
+	Good  string `json:"good" yaml:"good"`
+	Total string `json:"total" yaml:"total"`
+	// Labels are added to every rule generated.
+	Labels   map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Alerting SLOAlerting       `json:"alerting,omitempty" yaml:"alerting,omitempty"`
+	// Dashboard is optional. When it's set, an overview dashboard of the SLO is generated.
+	Dashboard *SLODashboard `json:"dashboard,omitempty" yaml:"dashboard,omitempty"`
+}
+
+func (s *SLOSpec) UnmarshalJSON(data []byte) error {
+	var tmp SLOSpec
+	type plain SLOSpec
+	if err := json.Unmarshal(data, (*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*s = tmp
+	return nil
+}
+
+func (s *SLOSpec) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp SLOSpec
+	type plain SLOSpec
+	if err := unmarshal((*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*s = tmp
+	return nil
+}
+
+func (s *SLOSpec) validate() error {
+	if s.Objective <= 0 || s.Objective >= 1 {
+		return fmt.Errorf("field 'objective' must be between 0 and 1 excluded")
+	}
+	if s.Window == 0 {
+		s.Window = DefaultSLOWindow
+	} else if s.Window < 0 {
+		return fmt.Errorf("field 'window' cannot be negative")
+	}
+	if !strings.Contains(s.Good, SLOWindowPlaceholder) {
+		return fmt.Errorf("field 'good' must use %s as the range of its range vectors", SLOWindowPlaceholder)
+	}
+	if !strings.Contains(s.Total, SLOWindowPlaceholder) {
+		return fmt.Errorf("field 'total' must use %s as the range of its range vectors", SLOWindowPlaceholder)
+	}
+	return nil
+}
+
+// SLOStatus is set by the server. It contains the name of the resources generated from the SLO.
+type SLOStatus struct {
+	PrometheusRule string `json:"prometheus_rule" yaml:"prometheus_rule"`
+	Dashboard      string `json:"dashboard,omitempty" yaml:"dashboard,omitempty"`
+}
+
+type SLO struct {
+	Kind     Kind            `json:"kind" yaml:"kind"`
+	Metadata ProjectMetadata `json:"metadata" yaml:"metadata"`
+	Spec     SLOSpec         `json:"spec" yaml:"spec"`
+	Status   *SLOStatus      `json:"status,omitempty" yaml:"status,omitempty"`
+}
+
+func (s *SLO) GenerateID() string {
+	return GenerateSLOID(s.Metadata.Project, s.Metadata.Name)
+}
+
+func (s *SLO) GetMetadata() interface{} {
+	return &s.Metadata
+}
+
+func (s *SLO) UnmarshalJSON(data []byte) error {
+	var tmp SLO
+	type plain SLO
+	if err := json.Unmarshal(data, (*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*s = tmp
+	return nil
+}
+
+func (s *SLO) UnmarshalYAML(unmarshal func(interface{}) error) error {
+	var tmp SLO
+	type plain SLO
+	if err := unmarshal((*plain)(&tmp)); err != nil {
+		return err
+	}
+	if err := (&tmp).validate(); err != nil {
+		return err
+	}
+	*s = tmp
+	return nil
+}
+
+func (s *SLO) validate() error {
+	if s.Kind != KindSLO {
+		return fmt.Errorf("invalid kind: '%s' for a SLO type", s.Kind)
+	}
+	return nil
+}
@@ -0,0 +1,37 @@
+// Copyright 2021 Amadeus s.a.s
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package v1
+
+import "encoding/json"
+
+type WatchEventType string
+
+const (
+	WatchEventAdded    WatchEventType = "ADDED"
+	WatchEventModified WatchEventType = "MODIFIED"
+	WatchEventDeleted  WatchEventType = "DELETED"
+	// WatchEventError is sent when the watch cannot continue. The resources should then be listed again.
+	WatchEventError WatchEventType = "ERROR"
+)
+
+// WatchEvent is a change of a resource sent by the watch of a list of resources.
+type WatchEvent struct {
+	Type WatchEventType `json:"type" yaml:"type"`
+	// Version is the version of the resources once changed. The watch can be resumed from it to receive the following changes.
+	Version uint64 `json:"version,omitempty" yaml:"version,omitempty"`
+	// Resource is the resource created or modified. When it's deleted, it's the resource before its deletion.
+	Resource json.RawMessage `json:"resource,omitempty" yaml:"resource,omitempty"`
+	// Error is set when the type is WatchEventError.
+	Error string `json:"error,omitempty" yaml:"error,omitempty"`
+}
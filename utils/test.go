@@ -11,8 +11,6 @@
 // See the License for the specific language governing permissions and
 // limitations under the License.
 
-// +build integration
-
 package utils
 
 import (
@@ -24,18 +22,23 @@ import (
 	"github.com/labstack/echo/v4"
 	"github.com/perses/common/config"
 	"github.com/perses/perses/internal/api/core"
+	"github.com/perses/perses/internal/api/shared/database"
 	"github.com/perses/perses/internal/api/shared/dependency"
-	clientv3 "go.etcd.io/etcd/client/v3"
+	persesConfig "github.com/perses/perses/internal/config"
 )
 
-func ClearAllKeys(t *testing.T, client *clientv3.Client) {
-	kv := clientv3.NewKV(client)
+func ClearAllKeys(t *testing.T, dao database.DAO) {
 	ctx, cancel := context.WithTimeout(context.Background(), 30*time.Second)
 	defer cancel()
-	_, err := kv.Delete(ctx, "", clientv3.WithPrefix())
+	keys, err := dao.Keys(ctx, "")
 	if err != nil {
 		t.Fatal(err)
 	}
+	for _, key := range keys {
+		if err := dao.Delete(key, 0); err != nil {
+			t.Fatal(err)
+		}
+	}
 }
 
 func DefaultETCDConfig() config.EtcdConfig {
@@ -51,13 +54,20 @@ func DefaultETCDConfig() config.EtcdConfig {
 	}
 }
 
+// CreateServer returns a server exposing the whole API, with the resources kept in memory.
+// It doesn't need any external service, so it can be used in any test.
 func CreateServer(t *testing.T) (*httptest.Server, dependency.PersistenceManager) {
+	return CreateServerWithDatabase(t, persesConfig.Database{InMemory: true})
+}
+
+// CreateServerWithDatabase returns a server exposing the whole API, with the resources stored in the given database.
+func CreateServerWithDatabase(t *testing.T, conf persesConfig.Database) (*httptest.Server, dependency.PersistenceManager) {
 	handler := echo.New()
-	persistenceManager, err := dependency.NewPersistenceManager(DefaultETCDConfig())
+	persistenceManager, err := dependency.NewPersistenceManager(conf)
 	if err != nil {
 		t.Fatal(err)
 	}
-	serviceManager := dependency.NewServiceManager(persistenceManager)
+	serviceManager := dependency.NewServiceManager(persistenceManager, persesConfig.Config{})
 	persesAPI := core.NewPersesAPI(serviceManager)
 	persesAPI.RegisterRoute(handler)
 	return httptest.NewServer(handler), persistenceManager